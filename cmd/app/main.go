@@ -5,11 +5,14 @@ import (
 	"Effective_Mobile_Test_Project/internal/config"
 	"Effective_Mobile_Test_Project/internal/handler"
 	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/repository/sqlite"
 	"Effective_Mobile_Test_Project/internal/service"
+	"Effective_Mobile_Test_Project/internal/worker"
 	"Effective_Mobile_Test_Project/migrations"
 	"context"
 	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"log"
 	"net/http"
@@ -29,20 +32,20 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	if os.Getenv("MIGRATE_ON_START") == "true" {
-		if err := migrations.RunMigrations(); err != nil {
+	cfg, err := config.LoadConfig("config.yaml")
+	if err != nil {
+		log.Fatalf("ошибка загрузки конфига: %v", err)
+	}
+
+	if cfg.DatabaseConfig.Driver == config.DriverPostgres && os.Getenv("MIGRATE_ON_START") == "true" {
+		if err := migrations.RunMigrations(cfg.DatabaseConfig.DSN); err != nil {
 			log.Fatalf("ошибка миграции: %v", err)
 		}
 
 		log.Println("миграция успешно выполнилась")
 	}
 
-	cfg, err := config.LoadConfig("config.yaml")
-	if err != nil {
-		log.Fatalf("ошибка загрузки конфига: %v", err)
-	}
-
-	database, err := config.SetupDatabase(cfg.DatabaseConfig.DSN)
+	database, err := config.SetupDatabase(cfg.DatabaseConfig)
 	if err != nil {
 		log.Fatalf("не удалось подключиться к БД: %v", err)
 	}
@@ -52,26 +55,211 @@ func main() {
 		}
 	}()
 
-	subscriptionRepository := repository.NewSubscriptionRepository(database)
-	subscriptionService := service.NewSubscriptionService(subscriptionRepository)
-	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService)
-
 	restServer, router := config.SetupRestServer(cfg.ServerAddr)
 
+	router.Use(middleware.RequestID, handler.RequestMeta)
 	router.Get("/swagger/*", httpSwagger.WrapHandler)
 
+	var (
+		subscriptionService *service.SubscriptionService
+		exchangeRateService *service.ExchangeRateService
+		calendarTokens      repository.CalendarTokenStore
+	)
+	switch cfg.DatabaseConfig.Driver {
+	case config.DriverSQLite:
+		subscriptionService, exchangeRateService, calendarTokens = setupSQLiteStore(cfg, database)
+	default:
+		subscriptionService, exchangeRateService, calendarTokens = setupPostgresStore(ctx, cfg, database, router)
+	}
+
+	subscriptionHandler := handler.NewSubscriptionHandler(subscriptionService)
+	exchangeRateHandler := handler.NewExchangeRateHandler(exchangeRateService)
+
+	if path := cfg.CurrencyConfig.ExchangeRatesFile; path != "" {
+		if err := exchangeRateService.LoadExchangeRatesFile(ctx, path); err != nil {
+			log.Fatalf("ошибка загрузки курсов валют: %v", err)
+		}
+	}
+
+	purgeWorker := worker.NewPurgeWorker(
+		subscriptionService,
+		cfg.SoftDeleteConfig.Retention,
+		cfg.SoftDeleteConfig.PurgeInterval,
+	)
+	go purgeWorker.Run(ctx)
+
 	router.Route("/subscriptions", func(r chi.Router) {
+		r.Get("/", subscriptionHandler.List)
+		r.Get("/deleted", subscriptionHandler.ListDeleted)
+		r.Get("/upcoming", subscriptionHandler.GetUpcoming)
+		r.Get("/export", subscriptionHandler.Export)
 		r.Post("/create", subscriptionHandler.Create)
+		r.Post("/import", subscriptionHandler.Import)
 		r.Get("/user/{uuid}", subscriptionHandler.GetByUserUUID)
 		r.Get("/get/{id}", subscriptionHandler.GetByID)
 		r.Put("/update/{id}", subscriptionHandler.UpdateByID)
+		r.Patch("/{id}", subscriptionHandler.PatchByID)
 		r.Delete("/delete/{id}", subscriptionHandler.DeleteByID)
+		r.Post("/{id}/restore", subscriptionHandler.RestoreByID)
+		r.Get("/{id}/history", subscriptionHandler.GetHistory)
+		r.Get("/{id}/prices", subscriptionHandler.GetPrices)
+		r.Post("/{id}/prices", subscriptionHandler.SchedulePrice)
+		r.Post("/{id}/pause", subscriptionHandler.Pause)
+		r.Post("/{id}/resume", subscriptionHandler.Resume)
+		r.Post("/{id}/cancel", subscriptionHandler.Cancel)
+		r.Get("/{id}/pauses", subscriptionHandler.GetPauses)
 		r.Get("/total-cost", subscriptionHandler.GetTotalCost)
+		r.Get("/cost-breakdown", subscriptionHandler.GetCostBreakdown)
+	})
+
+	router.Get("/currencies", exchangeRateHandler.ListCurrencies)
+	router.Route("/admin/exchange-rates", func(r chi.Router) {
+		r.Get("/", exchangeRateHandler.ListRates)
+		r.Put("/", exchangeRateHandler.SaveRates)
+	})
+
+	calendarHandler := handler.NewCalendarHandler(
+		service.NewCalendarService(subscriptionService.SubscriptionStore, exchangeRateService.ExchangeRateStore, calendarTokens),
+		cfg.CalendarConfig.PublicURL,
+	)
+	router.Route("/users/{uuid}", func(r chi.Router) {
+		r.Post("/calendar", calendarHandler.IssueLink)
+		r.Delete("/calendar", calendarHandler.RevokeLink)
+		r.Get("/renewals.ics", calendarHandler.GetRenewals)
 	})
 
 	runServer(ctx, restServer)
 }
 
+// setupSQLiteStore применяет миграции SQLite и собирает сервисы поверх хранилища
+// sqlite.Store. Каталог сервисов ведется внутри хранилища и пополняется при создании
+// подписок; бюджеты, журнал аудита, outbox, вебхуки и напоминания работают только
+// с Postgres: их маршруты не регистрируются, а история изменений всегда пуста (404)
+func setupSQLiteStore(cfg *config.AppConfig, database *config.Database) (*service.SubscriptionService, *service.ExchangeRateService, repository.CalendarTokenStore) {
+	if err := migrations.RunSQLiteMigrations(database.DB.DB); err != nil {
+		log.Fatalf("ошибка миграции SQLite: %v", err)
+	}
+	log.Println("миграция SQLite успешно выполнилась")
+
+	store := sqlite.NewStore(database)
+	subscriptionService := service.NewSubscriptionService(store, store, nil, nil, nil, store, cfg.IdempotencyConfig.TTL)
+	log.Println("хранилище SQLite: бюджеты, история изменений, outbox, вебхуки, напоминания и эндпоинты /services отключены")
+	return subscriptionService, service.NewExchangeRateService(store), store
+}
+
+// setupPostgresStore собирает сервисы поверх репозиториев Postgres, запускает фоновые
+// обработчики вебхуков, outbox и напоминаний и регистрирует маршруты, доступные только с Postgres
+func setupPostgresStore(ctx context.Context, cfg *config.AppConfig, database *config.Database, router chi.Router) (*service.SubscriptionService, *service.ExchangeRateService, repository.CalendarTokenStore) {
+	subscriptionRepository := repository.NewSubscriptionRepository(database)
+	catalogRepository := repository.NewCatalogRepository(database)
+	auditRepository := repository.NewAuditRepository(database)
+	idempotencyRepository := repository.NewIdempotencyRepository(database)
+	budgetRepository := repository.NewBudgetRepository(database)
+	webhookRepository := repository.NewWebhookRepository(database)
+	outboxRepository := repository.NewOutboxRepository(database)
+	subscriptionService := service.NewSubscriptionService(
+		subscriptionRepository,
+		catalogRepository,
+		budgetRepository,
+		auditRepository,
+		outboxRepository,
+		idempotencyRepository,
+		cfg.IdempotencyConfig.TTL,
+	)
+
+	catalogHandler := handler.NewCatalogHandler(service.NewCatalogService(catalogRepository, subscriptionService))
+
+	budgetHandler := handler.NewBudgetHandler(service.NewBudgetService(budgetRepository, subscriptionRepository))
+
+	webhookService := service.NewWebhookService(webhookRepository, service.WebhookDeliveryOptions{
+		MaxAttempts:          cfg.WebhookConfig.MaxAttempts,
+		InitialBackoff:       cfg.WebhookConfig.InitialBackoff,
+		MaxBackoff:           cfg.WebhookConfig.MaxBackoff,
+		Timeout:              cfg.WebhookConfig.Timeout,
+		BatchSize:            cfg.WebhookConfig.BatchSize,
+		AllowPrivateNetworks: cfg.WebhookConfig.AllowPrivateNetworks,
+	})
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	go worker.NewWebhookWorker(webhookService, cfg.WebhookConfig.Interval).Run(ctx)
+
+	publishers, err := setupEventPublishers(cfg.OutboxConfig, webhookRepository)
+	if err != nil {
+		log.Fatalf("ошибка настройки публикации событий: %v", err)
+	}
+	outboxService := service.NewOutboxService(outboxRepository, publishers, service.OutboxRelayOptions{
+		BatchSize:   cfg.OutboxConfig.BatchSize,
+		Timeout:     cfg.OutboxConfig.Timeout,
+		MaxAttempts: cfg.OutboxConfig.MaxAttempts,
+		Retention:   cfg.OutboxConfig.Retention,
+	})
+	go worker.NewOutboxWorker(outboxService, cfg.OutboxConfig.Interval).Run(ctx)
+
+	if cfg.ReminderConfig.DaysBefore > 0 {
+		reminderService := service.NewReminderService(
+			repository.NewReminderRepository(database),
+			outboxRepository,
+			service.LogReminderNotifier{},
+			cfg.ReminderConfig.DaysBefore,
+			cfg.ReminderConfig.BatchSize,
+			cfg.ReminderConfig.Timeout,
+		)
+		reminderWorker := worker.NewReminderWorker(reminderService, cfg.ReminderConfig.Interval)
+		go reminderWorker.Run(ctx)
+	}
+
+	router.Route("/services", func(r chi.Router) {
+		r.Get("/", catalogHandler.ListServices)
+		r.Post("/", catalogHandler.CreateService)
+		r.Get("/{id}", catalogHandler.GetService)
+		r.Put("/{id}", catalogHandler.UpdateService)
+		r.Delete("/{id}", catalogHandler.DeleteService)
+	})
+
+	router.Route("/budgets", func(r chi.Router) {
+		r.Get("/", budgetHandler.ListBudgets)
+		r.Post("/", budgetHandler.CreateBudget)
+		r.Get("/report", budgetHandler.GetBudgetReport)
+		r.Get("/{id}", budgetHandler.GetBudget)
+		r.Put("/{id}", budgetHandler.UpdateBudget)
+		r.Delete("/{id}", budgetHandler.DeleteBudget)
+	})
+
+	router.Route("/webhooks", func(r chi.Router) {
+		r.Get("/", webhookHandler.ListWebhooks)
+		r.Post("/", webhookHandler.CreateWebhook)
+		r.Get("/{id}", webhookHandler.GetWebhook)
+		r.Put("/{id}", webhookHandler.UpdateWebhook)
+		r.Delete("/{id}", webhookHandler.DeleteWebhook)
+		r.Get("/{id}/deliveries", webhookHandler.GetDeliveries)
+		r.Get("/{id}/deliveries/{delivery_id}/attempts", webhookHandler.GetDeliveryAttempts)
+		r.Post("/{id}/deliveries/{delivery_id}/redeliver", webhookHandler.Redeliver)
+	})
+
+	return subscriptionService, service.NewExchangeRateService(repository.NewExchangeRateRepository(database)), repository.NewCalendarTokenRepository(database)
+}
+
+// setupEventPublishers собирает издателей событий outbox, перечисленных в конфиге
+func setupEventPublishers(cfg config.OutboxConfig, webhooks *repository.WebhookRepository) ([]service.EventPublisher, error) {
+	publishers := make([]service.EventPublisher, 0, len(cfg.Publishers))
+	for _, name := range cfg.Publishers {
+		switch name {
+		case "webhook":
+			publishers = append(publishers, service.NewWebhookEventPublisher(webhooks))
+		case "log":
+			publishers = append(publishers, service.LogEventPublisher{})
+		case "nats":
+			publisher, err := service.NewNATSEventPublisher(cfg.NATS.URL, cfg.NATS.SubjectPrefix, cfg.NATS.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			publishers = append(publishers, publisher)
+		default:
+			return nil, fmt.Errorf("неизвестный издатель событий %q, ожидается webhook, log или nats", name)
+		}
+	}
+	return publishers, nil
+}
+
 func runServer(ctx context.Context, server *http.Server) {
 	serverErrors := make(chan error, 1)
 	go func() {
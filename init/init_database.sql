@@ -1,8 +1,385 @@
+CREATE TABLE IF NOT EXISTS currencies (
+    code CHAR(3) PRIMARY KEY,
+    minor_units SMALLINT NOT NULL CHECK (minor_units BETWEEN 0 AND 4),
+    name TEXT NOT NULL
+);
+
+INSERT INTO currencies (code, minor_units, name) VALUES
+    ('RUB', 2, 'Российский рубль'),
+    ('USD', 2, 'Доллар США'),
+    ('EUR', 2, 'Евро')
+ON CONFLICT (code) DO NOTHING;
+
+-- ключ сопоставления названий сервиса: нижний регистр, ё как е, любые последовательности
+-- знаков кроме букв и цифр схлопываются в один пробел. Название только из знаков
+-- сравнивается целиком в нижнем регистре
+CREATE OR REPLACE FUNCTION normalize_service_name(name TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE STRICT AS $$
+    SELECT COALESCE(
+        NULLIF(btrim(regexp_replace(translate(lower(name), 'ё', 'е'), '[^[:alnum:]]+', ' ', 'g')), ''),
+        lower(btrim(name))
+    )
+$$;
+
+CREATE TABLE IF NOT EXISTS services (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    category TEXT,
+    default_price INTEGER CHECK (default_price >= 0),
+    currency CHAR(3) NOT NULL DEFAULT 'RUB' CONSTRAINT services_currency_fkey REFERENCES currencies (code),
+    logo_url TEXT
+);
+
+-- написания, по которым находится сервис; каноническое название тоже хранится здесь,
+-- поэтому два сервиса не могут называться одинаково
+CREATE TABLE IF NOT EXISTS service_aliases (
+    id SERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services (id) ON DELETE CASCADE,
+    alias TEXT NOT NULL,
+    normalized_alias TEXT GENERATED ALWAYS AS (normalize_service_name(alias)) STORED
+        CONSTRAINT service_aliases_normalized_alias_key UNIQUE
+        CHECK (normalized_alias <> '')
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_aliases_service_id ON service_aliases (service_id);
+CREATE INDEX IF NOT EXISTS idx_services_category ON services (category) WHERE category IS NOT NULL;
+
+-- сервисы, которые пишут разными алфавитами и нормализация не сводит к одному ключу
+INSERT INTO services (name, category)
+SELECT seed.name, seed.category
+FROM (VALUES
+    ('Yandex Plus', 'streaming'),
+    ('Kinopoisk', 'streaming'),
+    ('VK Music', 'music')
+) AS seed (name, category)
+WHERE NOT EXISTS (
+    SELECT 1 FROM service_aliases a WHERE a.normalized_alias = normalize_service_name(seed.name)
+);
+
+INSERT INTO service_aliases (service_id, alias)
+SELECT s.id, seed.alias
+FROM (VALUES
+    ('Yandex Plus', 'Yandex Plus'),
+    ('Yandex Plus', 'Яндекс Плюс'),
+    ('Yandex Plus', 'Яндекс Plus'),
+    ('Kinopoisk', 'Kinopoisk'),
+    ('Kinopoisk', 'Кинопоиск'),
+    ('VK Music', 'VK Music'),
+    ('VK Music', 'VK Музыка')
+) AS seed (name, alias)
+JOIN services s ON s.name = seed.name
+ON CONFLICT (normalized_alias) DO NOTHING;
+
 CREATE TABLE IF NOT EXISTS subscriptions (
      id SERIAL PRIMARY KEY,
      service_name TEXT NOT NULL,
      price INTEGER NOT NULL,
      user_id UUID NOT NULL,
      start_date DATE NOT NULL,
-     end_date DATE
-);
\ No newline at end of file
+     end_date DATE,
+     version INTEGER NOT NULL DEFAULT 1,
+     deleted_at TIMESTAMPTZ,
+     currency CHAR(3) NOT NULL DEFAULT 'RUB' CONSTRAINT subscriptions_currency_fkey REFERENCES currencies (code),
+     billing_period TEXT NOT NULL DEFAULT 'month' CHECK (billing_period IN ('day', 'week', 'month', 'year')),
+     billing_interval INTEGER NOT NULL DEFAULT 1 CHECK (billing_interval > 0),
+     trial_end DATE,
+     cancelled_at TIMESTAMPTZ,
+     service_id INTEGER NOT NULL CONSTRAINT subscriptions_service_id_fkey REFERENCES services (id),
+     category TEXT
+);
+
+-- даты оплаты отсчитываются от anchor_date: anchor_date + k периодов. Для месяцев и лет
+-- каждая дата считается от якоря, а не от предыдущей оплаты, поэтому подписка от 31-го
+-- оплачивается 28/29 февраля, а в марте снова 31-го
+CREATE OR REPLACE FUNCTION subscription_charge_dates(
+    anchor_date DATE,
+    until_date DATE,
+    from_date DATE,
+    to_date DATE,
+    billing_period TEXT,
+    billing_interval INTEGER
+) RETURNS SETOF DATE
+LANGUAGE plpgsql IMMUTABLE AS $$
+DECLARE
+    first_date DATE := GREATEST(anchor_date, from_date);
+    last_date DATE := LEAST(COALESCE(until_date, to_date), to_date);
+    step_days INTEGER;
+    step_months INTEGER;
+    first_k INTEGER;
+    last_k INTEGER;
+BEGIN
+    IF first_date > last_date THEN
+        RETURN;
+    END IF;
+
+    CASE billing_period
+        WHEN 'day' THEN step_days := billing_interval;
+        WHEN 'week' THEN step_days := 7 * billing_interval;
+        WHEN 'month' THEN step_months := billing_interval;
+        WHEN 'year' THEN step_months := 12 * billing_interval;
+        ELSE RAISE EXCEPTION 'неизвестный период оплаты %', billing_period;
+    END CASE;
+
+    IF step_days IS NOT NULL THEN
+        first_k := ceil((first_date - anchor_date)::numeric / step_days);
+        last_k := floor((last_date - anchor_date)::numeric / step_days);
+        RETURN QUERY
+            SELECT anchor_date + k * step_days
+            FROM generate_series(first_k, last_k) AS k;
+        RETURN;
+    END IF;
+
+    -- шаг назад от first_k покрывает оплату в последние дни короткого месяца
+    first_k := GREATEST(0, ((date_part('year', first_date) - date_part('year', anchor_date)) * 12
+        + date_part('month', first_date) - date_part('month', anchor_date))::int / step_months - 1);
+    last_k := ((date_part('year', last_date) - date_part('year', anchor_date)) * 12
+        + date_part('month', last_date) - date_part('month', anchor_date))::int / step_months;
+
+    RETURN QUERY
+        SELECT charged_on
+        FROM (
+            SELECT (anchor_date + k * step_months * INTERVAL '1 month')::date AS charged_on
+            FROM generate_series(first_k, last_k) AS k
+        ) AS charges
+        WHERE charged_on BETWEEN first_date AND last_date;
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id_id ON subscriptions (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name_id ON subscriptions (service_name, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id_id ON subscriptions (service_id, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_category ON subscriptions (category) WHERE category IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_subscriptions_price_id ON subscriptions (price, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_date_id ON subscriptions (start_date, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date_id
+    ON subscriptions ((COALESCE(NULLIF(end_date, '0001-01-01'), '9999-12-31'::date)), id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted_at ON subscriptions (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key TEXT PRIMARY KEY,
+    request_hash TEXT NOT NULL,
+    status_code INTEGER,
+    response JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+
+CREATE TABLE IF NOT EXISTS subscription_audit (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL,
+    action TEXT NOT NULL CHECK (action IN ('create', 'update', 'delete', 'restore', 'price_change', 'pause', 'resume', 'cancel')),
+    before JSONB,
+    after JSONB,
+    actor TEXT NOT NULL,
+    request_id TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_audit_subscription_id ON subscription_audit (subscription_id, id);
+
+CREATE OR REPLACE FUNCTION subscription_audit_append_only() RETURNS trigger
+LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'журнал subscription_audit доступен только для добавления';
+END
+$$;
+
+DROP TRIGGER IF EXISTS subscription_audit_append_only ON subscription_audit;
+CREATE TRIGGER subscription_audit_append_only
+    BEFORE UPDATE OR DELETE ON subscription_audit
+    FOR EACH ROW EXECUTE FUNCTION subscription_audit_append_only();
+
+CREATE TABLE IF NOT EXISTS subscription_prices (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    effective_from DATE NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (subscription_id, effective_from)
+);
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    base_currency CHAR(3) NOT NULL REFERENCES currencies (code),
+    quote_currency CHAR(3) NOT NULL REFERENCES currencies (code),
+    rate_date DATE NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (base_currency, quote_currency, rate_date),
+    CHECK (base_currency <> quote_currency)
+);
+
+CREATE OR REPLACE FUNCTION convert_amount(
+    amount BIGINT,
+    from_currency CHAR(3),
+    to_currency CHAR(3),
+    on_date DATE
+) RETURNS BIGINT
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    found_rate NUMERIC;
+    scale_diff INTEGER;
+BEGIN
+    IF from_currency = to_currency THEN
+        RETURN amount;
+    END IF;
+
+    SELECT r.rate INTO found_rate
+    FROM (
+        SELECT er.rate, er.rate_date
+        FROM exchange_rates er
+        WHERE er.base_currency = from_currency AND er.quote_currency = to_currency AND er.rate_date <= on_date
+        UNION ALL
+        SELECT 1 / er.rate, er.rate_date
+        FROM exchange_rates er
+        WHERE er.base_currency = to_currency AND er.quote_currency = from_currency AND er.rate_date <= on_date
+    ) AS r
+    ORDER BY r.rate_date DESC
+    LIMIT 1;
+
+    IF found_rate IS NULL THEN
+        RAISE EXCEPTION 'нет курса % к % на %', from_currency, to_currency, to_char(on_date, 'DD-MM-YYYY')
+            USING ERRCODE = '22023', CONSTRAINT = 'exchange_rate_required';
+    END IF;
+
+    SELECT t.minor_units - f.minor_units INTO scale_diff
+    FROM currencies f, currencies t
+    WHERE f.code = from_currency AND t.code = to_currency;
+
+    IF scale_diff IS NULL THEN
+        RAISE EXCEPTION 'неизвестная валюта %', to_currency
+            USING ERRCODE = '22023', CONSTRAINT = 'currency_required';
+    END IF;
+
+    RETURN ROUND(amount * found_rate * power(10::numeric, scale_diff));
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    paused_from DATE NOT NULL,
+    resumed_on DATE,
+    CHECK (resumed_on IS NULL OR resumed_on >= paused_from)
+);
+
+-- у подписки не больше одной незавершенной паузы
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_pauses_open
+    ON subscription_pauses (subscription_id) WHERE resumed_on IS NULL;
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription_id
+    ON subscription_pauses (subscription_id, paused_from);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE CHECK (name <> '')
+);
+
+CREATE TABLE IF NOT EXISTS subscription_tags (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag_id ON subscription_tags (tag_id, subscription_id);
+
+CREATE TABLE IF NOT EXISTS budgets (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    scope TEXT NOT NULL CHECK (scope IN ('total', 'category', 'service')),
+    category TEXT,
+    service_id INTEGER CONSTRAINT budgets_service_id_fkey REFERENCES services (id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    currency CHAR(3) NOT NULL DEFAULT 'RUB' CONSTRAINT budgets_currency_fkey REFERENCES currencies (code),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT budgets_scope_target_check CHECK (
+        (scope = 'total' AND category IS NULL AND service_id IS NULL) OR
+        (scope = 'category' AND category IS NOT NULL AND service_id IS NULL) OR
+        (scope = 'service' AND service_id IS NOT NULL AND category IS NULL)
+    )
+);
+
+-- у пользователя не больше одного бюджета на область: общий, категорию или сервис
+CREATE UNIQUE INDEX IF NOT EXISTS budgets_scope_key
+    ON budgets (user_id, scope, COALESCE(category, ''), COALESCE(service_id, 0));
+
+-- напоминания о предстоящих оплатах. На дату оплаты подписки создается не больше одного
+-- напоминания, поэтому повторный проход планировщика не дублирует их; pending-напоминание
+-- доставляется повторно, пока доставка не будет подтверждена
+CREATE TABLE IF NOT EXISTS subscription_reminders (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    billing_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ,
+    CONSTRAINT subscription_reminders_billing_date_key UNIQUE (subscription_id, billing_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_reminders_pending
+    ON subscription_reminders (next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    event_types TEXT[] NOT NULL CHECK (cardinality(event_types) > 0),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- доставка одного события одному вебхуку. event_id - ID записи журнала аудита,
+-- породившей событие; получатель отбрасывает повторы по нему. Доставка повторяется,
+-- пока не будет принята или не исчерпает попытки и не перейдет в dead
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    delivered_at TIMESTAMPTZ,
+    CONSTRAINT webhook_deliveries_event_key UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending
+    ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id
+    ON webhook_deliveries (webhook_id, id);
+
+-- журнал попыток доставки
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    delivery_id BIGINT NOT NULL REFERENCES webhook_deliveries (id) ON DELETE CASCADE,
+    attempted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    status_code INTEGER,
+    error TEXT,
+    duration_ms INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id
+    ON webhook_delivery_attempts (delivery_id, id);
+
+-- события, записанные в транзакции породивших их изменений. Релей публикует
+-- неотправленные события и отмечает их sent_at; неудачная публикация повторяется
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (next_attempt_at, id) WHERE sent_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_sent_at ON outbox (sent_at) WHERE sent_at IS NOT NULL;
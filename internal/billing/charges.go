@@ -0,0 +1,100 @@
+// Package billing повторяет на Go правила списаний, которые в Postgres задают функции
+// subscription_charge_dates и convert_amount и запрос billedChargesQuery. Им пользуются
+// хранилища подписок без Postgres, чтобы стоимость совпадала до копейки
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"fmt"
+	"time"
+)
+
+// Day отбрасывает время и часовой пояс, оставляя календарную дату в UTC. Так даты
+// ведут себя как колонки DATE
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Month возвращает первый день месяца даты, как date_trunc('month', ...)
+func Month(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// AddMonths прибавляет к дате months месяцев так же, как date + interval в Postgres:
+// если в месяце результата нет такого дня, берется последний день месяца
+func AddMonths(date time.Time, months int) time.Time {
+	year, month, day := date.Date()
+	first := time.Date(year, month+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(day, lastDay), 0, 0, 0, 0, time.UTC)
+}
+
+// ChargeDates возвращает даты оплаты подписки внутри периода [from, to] по правилам
+// subscription_charge_dates: оплаты идут от anchor с шагом interval периодов period и
+// заканчиваются на until; нулевой until - бессрочная подписка. Для месяцев и лет каждая
+// дата отсчитывается от якоря, поэтому подписка от 31-го оплачивается 28/29 февраля,
+// а в марте снова 31-го
+func ChargeDates(anchor, until, from, to time.Time, period string, interval int) ([]time.Time, error) {
+	anchor, from, to = Day(anchor), Day(from), Day(to)
+	first := anchor
+	if from.After(first) {
+		first = from
+	}
+	last := to
+	if !until.IsZero() && Day(until).Before(last) {
+		last = Day(until)
+	}
+	if first.After(last) {
+		return nil, nil
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("интервал оплаты должен быть положительным: %d", interval)
+	}
+
+	var stepDays, stepMonths int
+	switch period {
+	case model.BillingPeriodDay:
+		stepDays = interval
+	case model.BillingPeriodWeek:
+		stepDays = 7 * interval
+	case model.BillingPeriodMonth:
+		stepMonths = interval
+	case model.BillingPeriodYear:
+		stepMonths = 12 * interval
+	default:
+		return nil, fmt.Errorf("неизвестный период оплаты %s", period)
+	}
+
+	var dates []time.Time
+	if stepDays > 0 {
+		firstK := (daysBetween(anchor, first) + stepDays - 1) / stepDays
+		lastK := daysBetween(anchor, last) / stepDays
+		for k := firstK; k <= lastK; k++ {
+			dates = append(dates, anchor.AddDate(0, 0, k*stepDays))
+		}
+		return dates, nil
+	}
+
+	// шаг назад от firstK покрывает оплату в последние дни короткого месяца
+	firstK := max(0, monthsBetween(anchor, first)/stepMonths-1)
+	lastK := monthsBetween(anchor, last) / stepMonths
+	for k := firstK; k <= lastK; k++ {
+		date := AddMonths(anchor, k*stepMonths)
+		if !date.Before(first) && !date.After(last) {
+			dates = append(dates, date)
+		}
+	}
+	return dates, nil
+}
+
+// daysBetween считает дни между датами через Unix-время, которое, в отличие от
+// time.Duration, не переполняется на промежутках в сотни лет
+func daysBetween(from, to time.Time) int {
+	return int((to.Unix() - from.Unix()) / (24 * 60 * 60))
+}
+
+// monthsBetween считает разницу календарных месяцев без учета дней, как
+// date_part('year') * 12 + date_part('month')
+func monthsBetween(from, to time.Time) int {
+	return (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+}
@@ -0,0 +1,159 @@
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAddMonths(t *testing.T) {
+	tests := []struct {
+		date   time.Time
+		months int
+		want   time.Time
+	}{
+		{date(2025, time.January, 15), 1, date(2025, time.February, 15)},
+		{date(2025, time.January, 31), 1, date(2025, time.February, 28)},
+		{date(2024, time.January, 31), 1, date(2024, time.February, 29)},
+		{date(2025, time.January, 31), 2, date(2025, time.March, 31)},
+		{date(2025, time.January, 31), 3, date(2025, time.April, 30)},
+		{date(2025, time.March, 31), -1, date(2025, time.February, 28)},
+		{date(2024, time.February, 29), 12, date(2025, time.February, 28)},
+		{date(2024, time.February, 29), 48, date(2028, time.February, 29)},
+		{date(2025, time.December, 31), 2, date(2026, time.February, 28)},
+	}
+
+	for _, test := range tests {
+		if got := AddMonths(test.date, test.months); !got.Equal(test.want) {
+			t.Errorf("AddMonths(%s, %d) = %s, ожидалось %s",
+				test.date.Format(time.DateOnly), test.months, got.Format(time.DateOnly), test.want.Format(time.DateOnly))
+		}
+	}
+}
+
+func TestChargeDates(t *testing.T) {
+	tests := []struct {
+		name     string
+		anchor   time.Time
+		until    time.Time
+		from, to time.Time
+		period   string
+		interval int
+		want     []time.Time
+	}{
+		{
+			name:   "месяц, период внутри подписки",
+			anchor: date(2025, time.January, 10), from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+			period: model.BillingPeriodMonth, interval: 1,
+			want: []time.Time{date(2025, time.February, 10), date(2025, time.March, 10), date(2025, time.April, 10)},
+		},
+		{
+			name:   "месяц, первая оплата в день начала",
+			anchor: date(2025, time.January, 10), from: date(2025, time.January, 1), to: date(2025, time.January, 31),
+			period: model.BillingPeriodMonth, interval: 1,
+			want: []time.Time{date(2025, time.January, 10)},
+		},
+		{
+			name:   "31 число в коротких месяцах",
+			anchor: date(2025, time.January, 31), from: date(2025, time.February, 1), to: date(2025, time.May, 31),
+			period: model.BillingPeriodMonth, interval: 1,
+			want: []time.Time{date(2025, time.February, 28), date(2025, time.March, 31), date(2025, time.April, 30), date(2025, time.May, 31)},
+		},
+		{
+			name:   "конец короткого месяца на границе периода",
+			anchor: date(2025, time.January, 31), from: date(2025, time.February, 28), to: date(2025, time.February, 28),
+			period: model.BillingPeriodMonth, interval: 1,
+			want: []time.Time{date(2025, time.February, 28)},
+		},
+		{
+			name:   "раз в три месяца",
+			anchor: date(2025, time.January, 15), from: date(2025, time.January, 1), to: date(2025, time.December, 31),
+			period: model.BillingPeriodMonth, interval: 3,
+			want: []time.Time{date(2025, time.January, 15), date(2025, time.April, 15), date(2025, time.July, 15), date(2025, time.October, 15)},
+		},
+		{
+			name:   "день",
+			anchor: date(2025, time.January, 30), from: date(2025, time.February, 1), to: date(2025, time.February, 3),
+			period: model.BillingPeriodDay, interval: 1,
+			want: []time.Time{date(2025, time.February, 1), date(2025, time.February, 2), date(2025, time.February, 3)},
+		},
+		{
+			name:   "раз в две недели",
+			anchor: date(2025, time.January, 27), from: date(2025, time.February, 1), to: date(2025, time.March, 31),
+			period: model.BillingPeriodWeek, interval: 2,
+			want: []time.Time{date(2025, time.February, 10), date(2025, time.February, 24), date(2025, time.March, 10), date(2025, time.March, 24)},
+		},
+		{
+			name:   "год от 29 февраля",
+			anchor: date(2024, time.February, 29), from: date(2024, time.January, 1), to: date(2028, time.December, 31),
+			period: model.BillingPeriodYear, interval: 1,
+			want: []time.Time{
+				date(2024, time.February, 29), date(2025, time.February, 28), date(2026, time.February, 28),
+				date(2027, time.February, 28), date(2028, time.February, 29),
+			},
+		},
+		{
+			name:   "оплаты до даты окончания включительно",
+			anchor: date(2024, time.December, 15), until: date(2025, time.March, 15),
+			from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+			period: model.BillingPeriodMonth, interval: 1,
+			want: []time.Time{date(2025, time.February, 15), date(2025, time.March, 15)},
+		},
+		{
+			name:   "подписка закончилась до периода",
+			anchor: date(2024, time.June, 1), until: date(2025, time.January, 15),
+			from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+			period: model.BillingPeriodMonth, interval: 1,
+		},
+		{
+			name:   "подписка начинается после периода",
+			anchor: date(2025, time.May, 1), from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+			period: model.BillingPeriodMonth, interval: 1,
+		},
+		{
+			name:   "время и часовой пояс не влияют на даты",
+			anchor: time.Date(2025, time.January, 10, 23, 30, 0, 0, time.FixedZone("MSK", 3*60*60)),
+			from:   time.Date(2025, time.February, 10, 12, 0, 0, 0, time.UTC), to: date(2025, time.February, 10),
+			period: model.BillingPeriodMonth, interval: 1,
+			want: []time.Time{date(2025, time.February, 10)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ChargeDates(test.anchor, test.until, test.from, test.to, test.period, test.interval)
+			if err != nil {
+				t.Fatalf("ChargeDates: %v", err)
+			}
+			if !slices.EqualFunc(got, test.want, time.Time.Equal) {
+				t.Errorf("даты оплаты %v, ожидались %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestChargeDatesErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		period   string
+		interval int
+	}{
+		{name: "нулевой интервал", period: model.BillingPeriodMonth, interval: 0},
+		{name: "отрицательный интервал", period: model.BillingPeriodWeek, interval: -1},
+		{name: "неизвестный период", period: "quarter", interval: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ChargeDates(date(2025, time.January, 1), time.Time{}, date(2025, time.January, 1), date(2025, time.December, 31), test.period, test.interval)
+			if err == nil {
+				t.Error("ожидалась ошибка")
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// Причины, по которым сумму нельзя перевести в другую валюту. Соответствуют
+// ограничениям exchange_rate_required и currency_required функции convert_amount
+var (
+	ErrNoExchangeRate  = errors.New("нет курса валют")
+	ErrUnknownCurrency = errors.New("неизвестная валюта")
+)
+
+// ConversionError
+// Ошибка перевода суммы: Reason - одна из причин выше, Message - текст для клиента
+type ConversionError struct {
+	Reason  error
+	Message string
+}
+
+func (err *ConversionError) Error() string {
+	return err.Message
+}
+
+func (err *ConversionError) Unwrap() error {
+	return err.Reason
+}
+
+// Converter переводит суммы между валютами по правилам convert_amount: берется последний
+// курс пары на дату, прямой или обратный, а сумма пересчитывается с учетом разницы
+// в числе знаков минимальных единиц валют
+type Converter struct {
+	currencies map[string]model.Currency
+	rates      []model.ExchangeRate
+}
+
+func NewConverter(currencies []model.Currency, rates []model.ExchangeRate) *Converter {
+	converter := &Converter{
+		currencies: make(map[string]model.Currency, len(currencies)),
+		rates:      rates,
+	}
+	for _, currency := range currencies {
+		converter.currencies[currency.Code] = currency
+	}
+	return converter
+}
+
+// Convert переводит amount минимальных единиц валюты from в валюту to по курсу на дату on.
+// Результат округляется до целой минимальной единицы, половина - от нуля
+func (converter *Converter) Convert(amount int, from, to string, on time.Time) (int, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	rate := converter.rateOn(from, to, Day(on))
+	if rate == nil {
+		return 0, &ConversionError{
+			Reason:  ErrNoExchangeRate,
+			Message: fmt.Sprintf("нет курса %s к %s на %s", from, to, on.Format("02-01-2006")),
+		}
+	}
+
+	fromCurrency, fromOK := converter.currencies[from]
+	toCurrency, toOK := converter.currencies[to]
+	if !fromOK || !toOK {
+		missing := to
+		if !fromOK {
+			missing = from
+		}
+		return 0, &ConversionError{
+			Reason:  ErrUnknownCurrency,
+			Message: fmt.Sprintf("неизвестная валюта %s", missing),
+		}
+	}
+
+	converted := new(big.Rat).Mul(big.NewRat(int64(amount), 1), rate)
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(toCurrency.MinorUnits-fromCurrency.MinorUnits))), nil)
+	if toCurrency.MinorUnits >= fromCurrency.MinorUnits {
+		converted.Mul(converted, new(big.Rat).SetInt(scale))
+	} else {
+		converted.Quo(converted, new(big.Rat).SetInt(scale))
+	}
+	return roundHalfAwayFromZero(converted), nil
+}
+
+// rateOn ищет последний курс пары на дату среди прямых и обратных курсов
+func (converter *Converter) rateOn(from, to string, on time.Time) *big.Rat {
+	var found *big.Rat
+	var foundDate time.Time
+	for _, rate := range converter.rates {
+		rateDate := Day(rate.RateDate.ToTime())
+		if rateDate.After(on) || (found != nil && !rateDate.After(foundDate)) {
+			continue
+		}
+
+		switch {
+		case rate.BaseCurrency == from && rate.QuoteCurrency == to:
+			found, foundDate = storedRate(rate.Rate), rateDate
+		case rate.BaseCurrency == to && rate.QuoteCurrency == from:
+			found, foundDate = new(big.Rat).Inv(storedRate(rate.Rate)), rateDate
+		}
+	}
+	return found
+}
+
+// storedRate возвращает курс с той точностью, с которой его хранит NUMERIC(20, 10)
+func storedRate(rate float64) *big.Rat {
+	value, _ := new(big.Rat).SetString(strconv.FormatFloat(rate, 'f', 10, 64))
+	return value
+}
+
+func roundHalfAwayFromZero(value *big.Rat) int {
+	half := big.NewRat(1, 2)
+	if value.Sign() < 0 {
+		half.Neg(half)
+	}
+	shifted := new(big.Rat).Add(value, half)
+	return int(new(big.Int).Quo(shifted.Num(), shifted.Denom()).Int64())
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,66 @@
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	converter := NewConverter(
+		[]model.Currency{
+			{Code: "RUB", MinorUnits: 2},
+			{Code: "USD", MinorUnits: 2},
+			{Code: "JPY", MinorUnits: 0},
+		},
+		[]model.ExchangeRate{
+			{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(date(2025, time.March, 1)), Rate: 100},
+			{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(date(2025, time.January, 1)), Rate: 90},
+			{BaseCurrency: "USD", QuoteCurrency: "JPY", RateDate: model.DayMonthYear(date(2025, time.January, 1)), Rate: 150},
+			{BaseCurrency: "EUR", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(date(2025, time.January, 1)), Rate: 95},
+		},
+	)
+
+	tests := []struct {
+		name     string
+		amount   int
+		from, to string
+		on       time.Time
+		want     int
+		err      error
+		message  string
+	}{
+		{name: "та же валюта", amount: 12345, from: "RUB", to: "RUB", on: date(2020, time.January, 1), want: 12345},
+		{name: "прямой курс", amount: 1000, from: "USD", to: "RUB", on: date(2025, time.February, 15), want: 90000},
+		{name: "последний курс на дату", amount: 1000, from: "USD", to: "RUB", on: date(2025, time.March, 1), want: 100000},
+		{name: "обратный курс", amount: 9000, from: "RUB", to: "USD", on: date(2025, time.February, 15), want: 100},
+		{name: "половина округляется вверх", amount: 45, from: "RUB", to: "USD", on: date(2025, time.February, 15), want: 1},
+		{name: "половина округляется от нуля", amount: -45, from: "RUB", to: "USD", on: date(2025, time.February, 15), want: -1},
+		{name: "в валюту без дробной части", amount: 1000, from: "USD", to: "JPY", on: date(2025, time.February, 15), want: 1500},
+		{name: "из валюты без дробной части", amount: 150, from: "JPY", to: "USD", on: date(2025, time.February, 15), want: 100},
+		{name: "нет курса на дату", amount: 1000, from: "USD", to: "RUB", on: date(2024, time.December, 31), err: ErrNoExchangeRate},
+		{name: "нет курса пары", amount: 1000, from: "JPY", to: "RUB", on: date(2025, time.February, 15), err: ErrNoExchangeRate},
+		{name: "исходной валюты нет в справочнике", amount: 1000, from: "EUR", to: "RUB", on: date(2025, time.February, 15), err: ErrUnknownCurrency, message: "неизвестная валюта EUR"},
+		{name: "целевой валюты нет в справочнике", amount: 1000, from: "RUB", to: "EUR", on: date(2025, time.February, 15), err: ErrUnknownCurrency, message: "неизвестная валюта EUR"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := converter.Convert(test.amount, test.from, test.to, test.on)
+			if test.err != nil {
+				var conversionErr *ConversionError
+				if !errors.Is(err, test.err) || !errors.As(err, &conversionErr) {
+					t.Fatalf("ошибка %v, ожидалась %v", err, test.err)
+				}
+				if test.message != "" && err.Error() != test.message {
+					t.Errorf("сообщение %q, ожидалось %q", err.Error(), test.message)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("Convert(%d %s -> %s) = %d, ошибка %v, ожидалось %d", test.amount, test.from, test.to, got, err, test.want)
+			}
+		})
+	}
+}
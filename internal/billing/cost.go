@@ -0,0 +1,192 @@
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// BilledCharge
+// Оплата подписки, переведенная в валюту расчета, вместе с ключами группировки.
+// Category - собственная категория подписки или категория ее сервиса
+type BilledCharge struct {
+	SubscriptionID int
+	ServiceName    string
+	Category       *string
+	Tags           []string
+	Date           time.Time
+	Amount         int
+}
+
+// Total считает итог расчета стоимости. BilledMonths - число пар (подписка, календарный
+// месяц) хотя бы с одной оплатой
+func Total(charges []BilledCharge) model.SubscriptionCost {
+	type billedMonth struct {
+		subscriptionID int
+		month          time.Time
+	}
+
+	var cost model.SubscriptionCost
+	months := map[billedMonth]bool{}
+	for _, charge := range charges {
+		cost.Total += charge.Amount
+		cost.Charges++
+		months[billedMonth{charge.SubscriptionID, Month(charge.Date)}] = true
+	}
+	cost.BilledMonths = len(months)
+	return cost
+}
+
+// groupKey - значение разреза группировки; nil - группа без категории или тега
+type groupKey struct {
+	value *string
+}
+
+func (key groupKey) less(other groupKey) bool {
+	// группы без значения идут последними, как NULLS LAST
+	if key.value == nil || other.value == nil {
+		return key.value != nil && other.value == nil
+	}
+	return *key.value < *other.value
+}
+
+func (key groupKey) id() string {
+	if key.value == nil {
+		return "\x00"
+	}
+	return "=" + *key.value
+}
+
+// groupKeys возвращает группы, в которые попадает оплата. Оплата подписки с несколькими
+// тегами попадает в группу каждого тега, без тегов - в группу nil
+func groupKeys(charge *BilledCharge, groupBy string) ([]groupKey, error) {
+	switch groupBy {
+	case model.CostGroupByService:
+		name := charge.ServiceName
+		return []groupKey{{&name}}, nil
+	case model.CostGroupByCategory:
+		return []groupKey{{charge.Category}}, nil
+	case model.CostGroupByTag:
+		if len(charge.Tags) == 0 {
+			return []groupKey{{nil}}, nil
+		}
+		keys := make([]groupKey, 0, len(charge.Tags))
+		for _, tag := range charge.Tags {
+			keys = append(keys, groupKey{&tag})
+		}
+		return keys, nil
+	}
+	return nil, fmt.Errorf("неизвестный разрез группировки %q", groupBy)
+}
+
+// setGroup заполняет поле разреза группировки
+func setGroup(groupBy string, key groupKey, serviceName *string, category, tag **string) {
+	switch groupBy {
+	case model.CostGroupByService:
+		*serviceName = *key.value
+	case model.CostGroupByCategory:
+		*category = key.value
+	case model.CostGroupByTag:
+		*tag = key.value
+	}
+}
+
+// Groups делит стоимость на группы по разрезу groupBy в порядке значения группы
+func Groups(charges []BilledCharge, groupBy string) ([]model.CostGroup, error) {
+	keys := []groupKey{}
+	groups := map[string]*model.CostGroup{}
+	for i := range charges {
+		chargeKeys, err := groupKeys(&charges[i], groupBy)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range chargeKeys {
+			group, ok := groups[key.id()]
+			if !ok {
+				group = &model.CostGroup{}
+				setGroup(groupBy, key, &group.ServiceName, &group.Category, &group.Tag)
+				groups[key.id()] = group
+				keys = append(keys, key)
+			}
+			group.Total += charges[i].Amount
+			group.Charges++
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	result := make([]model.CostGroup, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *groups[key.id()])
+	}
+	return result, nil
+}
+
+// Breakdown суммирует списания по календарным месяцам и разрезу groupBy в порядке
+// месяца, а внутри месяца - значения группы
+func Breakdown(charges []BilledCharge, groupBy string) ([]model.MonthlyCost, error) {
+	type monthGroup struct {
+		month time.Time
+		key   groupKey
+	}
+
+	keys := []monthGroup{}
+	costs := map[string]*model.MonthlyCost{}
+	for i := range charges {
+		chargeKeys, err := groupKeys(&charges[i], groupBy)
+		if err != nil {
+			return nil, err
+		}
+		month := Month(charges[i].Date)
+		for _, key := range chargeKeys {
+			id := month.Format(time.DateOnly) + key.id()
+			cost, ok := costs[id]
+			if !ok {
+				cost = &model.MonthlyCost{Month: model.MonthYear(month)}
+				setGroup(groupBy, key, &cost.ServiceName, &cost.Category, &cost.Tag)
+				costs[id] = cost
+				keys = append(keys, monthGroup{month, key})
+			}
+			cost.Amount += charges[i].Amount
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].month.Equal(keys[j].month) {
+			return keys[i].month.Before(keys[j].month)
+		}
+		return keys[i].key.less(keys[j].key)
+	})
+	result := make([]model.MonthlyCost, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *costs[key.month.Format(time.DateOnly)+key.key.id()])
+	}
+	return result, nil
+}
+
+// MonthlySpend считает траты по календарным месяцам и долю подписки subscriptionID
+// в каждом из них. Месяцы без оплат в результат не попадают
+func MonthlySpend(charges []BilledCharge, subscriptionID int) []model.MonthlySpend {
+	months := []time.Time{}
+	spends := map[time.Time]*model.MonthlySpend{}
+	for _, charge := range charges {
+		month := Month(charge.Date)
+		spend, ok := spends[month]
+		if !ok {
+			spend = &model.MonthlySpend{Month: model.MonthYear(month)}
+			spends[month] = spend
+			months = append(months, month)
+		}
+		spend.Spent += charge.Amount
+		if charge.SubscriptionID == subscriptionID {
+			spend.SubscriptionSpent += charge.Amount
+		}
+	}
+
+	sort.Slice(months, func(i, j int) bool { return months[i].Before(months[j]) })
+	result := make([]model.MonthlySpend, 0, len(months))
+	for _, month := range months {
+		result = append(result, *spends[month])
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// costCharges - оплаты двух месяцев: видео за 1000 с категорией streaming и тегами a и b
+// (01.01 и 31.01 в одном месяце, затем 28.02), музыка за 300 с категорией education
+// и тегом b и книги за 200 без категории и тегов
+func costCharges() []BilledCharge {
+	education := "education"
+	streaming := "streaming"
+	return []BilledCharge{
+		{SubscriptionID: 1, ServiceName: "Video", Category: &streaming, Tags: []string{"a", "b"}, Date: date(2025, time.January, 1), Amount: 1000},
+		{SubscriptionID: 1, ServiceName: "Video", Category: &streaming, Tags: []string{"a", "b"}, Date: date(2025, time.January, 31), Amount: 1000},
+		{SubscriptionID: 2, ServiceName: "Music", Category: &education, Tags: []string{"b"}, Date: date(2025, time.January, 5), Amount: 300},
+		{SubscriptionID: 1, ServiceName: "Video", Category: &streaming, Tags: []string{"a", "b"}, Date: date(2025, time.February, 28), Amount: 1000},
+		{SubscriptionID: 3, ServiceName: "Books", Date: date(2025, time.February, 10), Amount: 200},
+	}
+}
+
+func TestTotal(t *testing.T) {
+	tests := []struct {
+		name    string
+		charges []BilledCharge
+		want    model.SubscriptionCost
+	}{
+		{name: "без оплат", want: model.SubscriptionCost{}},
+		{
+			name:    "оплаты одной подписки в одном месяце - один оплаченный месяц",
+			charges: costCharges()[:2],
+			want:    model.SubscriptionCost{Total: 2000, Charges: 2, BilledMonths: 1},
+		},
+		{
+			name:    "месяцы считаются по подпискам",
+			charges: costCharges(),
+			want:    model.SubscriptionCost{Total: 3500, Charges: 5, BilledMonths: 4},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Total(test.charges); got != test.want {
+				t.Errorf("Total = %+v, ожидалось %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	text := func(value string) *string { return &value }
+
+	tests := []struct {
+		groupBy string
+		want    []model.CostGroup
+	}{
+		{
+			groupBy: model.CostGroupByService,
+			want: []model.CostGroup{
+				{ServiceName: "Books", Total: 200, Charges: 1},
+				{ServiceName: "Music", Total: 300, Charges: 1},
+				{ServiceName: "Video", Total: 3000, Charges: 3},
+			},
+		},
+		{
+			groupBy: model.CostGroupByCategory,
+			want: []model.CostGroup{
+				{Category: text("education"), Total: 300, Charges: 1},
+				{Category: text("streaming"), Total: 3000, Charges: 3},
+				{Total: 200, Charges: 1},
+			},
+		},
+		{
+			// оплата с несколькими тегами попадает в группу каждого тега
+			groupBy: model.CostGroupByTag,
+			want: []model.CostGroup{
+				{Tag: text("a"), Total: 3000, Charges: 3},
+				{Tag: text("b"), Total: 3300, Charges: 4},
+				{Total: 200, Charges: 1},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.groupBy, func(t *testing.T) {
+			got, err := Groups(costCharges(), test.groupBy)
+			if err != nil {
+				t.Fatalf("Groups: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Groups = %+v, ожидалось %+v", got, test.want)
+			}
+		})
+	}
+
+	if _, err := Groups(costCharges(), "currency"); err == nil {
+		t.Error("неизвестный разрез должен вернуть ошибку")
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	january := model.MonthYear(date(2025, time.January, 1))
+	february := model.MonthYear(date(2025, time.February, 1))
+
+	got, err := Breakdown(costCharges(), model.CostGroupByService)
+	if err != nil {
+		t.Fatalf("Breakdown: %v", err)
+	}
+	want := []model.MonthlyCost{
+		{Month: january, ServiceName: "Music", Amount: 300},
+		{Month: january, ServiceName: "Video", Amount: 2000},
+		{Month: february, ServiceName: "Books", Amount: 200},
+		{Month: february, ServiceName: "Video", Amount: 1000},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Breakdown = %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestMonthlySpend(t *testing.T) {
+	january := model.MonthYear(date(2025, time.January, 1))
+	february := model.MonthYear(date(2025, time.February, 1))
+
+	tests := []struct {
+		name           string
+		subscriptionID int
+		want           []model.MonthlySpend
+	}{
+		{
+			name:           "подписка с оплатами в обоих месяцах",
+			subscriptionID: 1,
+			want: []model.MonthlySpend{
+				{Month: january, Spent: 2300, SubscriptionSpent: 2000},
+				{Month: february, Spent: 1200, SubscriptionSpent: 1000},
+			},
+		},
+		{
+			name:           "подписка без оплат в месяце",
+			subscriptionID: 3,
+			want: []model.MonthlySpend{
+				{Month: january, Spent: 2300},
+				{Month: february, Spent: 1200, SubscriptionSpent: 200},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := MonthlySpend(costCharges(), test.subscriptionID); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("MonthlySpend = %+v, ожидалось %+v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"time"
+)
+
+// Plan
+// Все, от чего зависят оплаты подписки: даты, период, пробный период, история цен и паузы.
+// Нулевые End и TrialEnd означают бессрочную подписку и подписку без пробного периода
+type Plan struct {
+	Start        time.Time
+	End          time.Time
+	Period       string
+	Interval     int
+	TrialEnd     time.Time
+	Price        int
+	PriceChanges []model.SubscriptionPrice
+	Pauses       []model.SubscriptionPause
+}
+
+// Charge
+// Оплата подписки: дата и цена, действующая на эту дату, в валюте подписки
+type Charge struct {
+	Date  time.Time
+	Price int
+}
+
+// NewPlan собирает план оплат подписки из ее истории цен и пауз
+func NewPlan(subscription *model.SubscriptionDetails, prices []model.SubscriptionPrice, pauses []model.SubscriptionPause) Plan {
+	plan := Plan{
+		Start:        subscription.StartDate.ToTime(),
+		End:          subscription.EndDate.ToTime(),
+		Period:       subscription.BillingPeriod,
+		Interval:     subscription.BillingInterval,
+		Price:        subscription.Price,
+		PriceChanges: prices,
+		Pauses:       pauses,
+	}
+	if subscription.TrialEnd != nil {
+		plan.TrialEnd = subscription.TrialEnd.ToTime()
+	}
+	return plan
+}
+
+// Charges возвращает оплаты внутри периода [from, to] по правилам billedChargesQuery:
+// оплаты до конца пробного периода (TrialEnd включительно) и внутри пауз не списываются
+func (plan Plan) Charges(from, to time.Time) ([]Charge, error) {
+	dates, err := ChargeDates(plan.Start, plan.End, from, to, plan.Period, plan.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	charges := make([]Charge, 0, len(dates))
+	for _, date := range dates {
+		if !plan.TrialEnd.IsZero() && !date.After(Day(plan.TrialEnd)) {
+			continue
+		}
+		if plan.paused(date) {
+			continue
+		}
+		charges = append(charges, Charge{Date: date, Price: plan.PriceOn(date)})
+	}
+	return charges, nil
+}
+
+// PriceOn возвращает цену, действующую на дату: последнее изменение, вступившее в силу
+// к этой дате, а до первого изменения - цену самой подписки
+func (plan Plan) PriceOn(date time.Time) int {
+	price := plan.Price
+	var effective *time.Time
+	for _, change := range plan.PriceChanges {
+		from := Day(change.EffectiveFrom.ToTime())
+		if from.After(date) || (effective != nil && from.Before(*effective)) {
+			continue
+		}
+		price, effective = change.Price, &from
+	}
+	return price
+}
+
+// paused проверяет, попадает ли дата в паузу: с PausedFrom и до ResumedOn, не включая
+func (plan Plan) paused(date time.Time) bool {
+	for _, pause := range plan.Pauses {
+		if date.Before(Day(pause.PausedFrom.ToTime())) {
+			continue
+		}
+		if pause.ResumedOn == nil || date.Before(Day(pause.ResumedOn.ToTime())) {
+			return true
+		}
+	}
+	return false
+}
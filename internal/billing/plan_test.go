@@ -0,0 +1,104 @@
+package billing
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPlanCharges(t *testing.T) {
+	resumed := model.DayMonthYear(date(2025, time.April, 20))
+	tests := []struct {
+		name string
+		plan Plan
+		want []Charge
+	}{
+		{
+			name: "без изменений",
+			plan: Plan{Start: date(2025, time.January, 10), Period: model.BillingPeriodMonth, Interval: 1, Price: 1000},
+			want: []Charge{
+				{date(2025, time.January, 10), 1000}, {date(2025, time.February, 10), 1000},
+				{date(2025, time.March, 10), 1000}, {date(2025, time.April, 10), 1000},
+			},
+		},
+		{
+			name: "пробный период до последнего дня включительно",
+			plan: Plan{
+				Start: date(2025, time.January, 10), Period: model.BillingPeriodMonth, Interval: 1, Price: 1000,
+				TrialEnd: date(2025, time.February, 10),
+			},
+			want: []Charge{{date(2025, time.March, 10), 1000}, {date(2025, time.April, 10), 1000}},
+		},
+		{
+			name: "цена на дату оплаты",
+			plan: Plan{
+				Start: date(2025, time.January, 10), Period: model.BillingPeriodMonth, Interval: 1, Price: 1000,
+				PriceChanges: []model.SubscriptionPrice{
+					{Price: 2000, EffectiveFrom: model.DayMonthYear(date(2025, time.April, 10))},
+					{Price: 1500, EffectiveFrom: model.DayMonthYear(date(2025, time.March, 1))},
+				},
+			},
+			want: []Charge{
+				{date(2025, time.January, 10), 1000}, {date(2025, time.February, 10), 1000},
+				{date(2025, time.March, 10), 1500}, {date(2025, time.April, 10), 2000},
+			},
+		},
+		{
+			name: "завершенная пауза",
+			plan: Plan{
+				Start: date(2025, time.January, 10), Period: model.BillingPeriodMonth, Interval: 1, Price: 1000,
+				Pauses: []model.SubscriptionPause{{PausedFrom: model.DayMonthYear(date(2025, time.February, 10)), ResumedOn: &resumed}},
+			},
+			want: []Charge{{date(2025, time.January, 10), 1000}},
+		},
+		{
+			name: "открытая пауза",
+			plan: Plan{
+				Start: date(2025, time.January, 10), Period: model.BillingPeriodMonth, Interval: 1, Price: 1000,
+				Pauses: []model.SubscriptionPause{{PausedFrom: model.DayMonthYear(date(2025, time.March, 1))}},
+			},
+			want: []Charge{{date(2025, time.January, 10), 1000}, {date(2025, time.February, 10), 1000}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.plan.Charges(date(2025, time.January, 1), date(2025, time.April, 30))
+			if err != nil {
+				t.Fatalf("Charges: %v", err)
+			}
+			if !slices.EqualFunc(got, test.want, func(a, b Charge) bool { return a.Date.Equal(b.Date) && a.Price == b.Price }) {
+				t.Errorf("оплаты %v, ожидались %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlanPriceOn(t *testing.T) {
+	plan := Plan{
+		Price: 1000,
+		PriceChanges: []model.SubscriptionPrice{
+			{Price: 1500, EffectiveFrom: model.DayMonthYear(date(2025, time.March, 1))},
+			{Price: 1700, EffectiveFrom: model.DayMonthYear(date(2025, time.March, 1))},
+			{Price: 900, EffectiveFrom: model.DayMonthYear(date(2025, time.February, 1))},
+		},
+	}
+
+	tests := []struct {
+		on   time.Time
+		want int
+	}{
+		{date(2025, time.January, 31), 1000},
+		{date(2025, time.February, 1), 900},
+		{date(2025, time.February, 28), 900},
+		// из изменений на одну дату действует записанное последним
+		{date(2025, time.March, 1), 1700},
+		{date(2026, time.January, 1), 1700},
+	}
+	for _, test := range tests {
+		if got := plan.PriceOn(test.on); got != test.want {
+			t.Errorf("PriceOn(%s) = %d, ожидалось %d", test.on.Format(time.DateOnly), got, test.want)
+		}
+	}
+}
@@ -5,14 +5,26 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"log"
+	_ "modernc.org/sqlite"
+)
+
+// Драйверы БД, которые можно выбрать в databaseConfig.driver
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
 type Database struct {
 	*sqlx.DB
 }
 
+// DatabaseConfig
+// Driver - postgres или sqlite. Для sqlite DSN - путь к файлу базы, например
+// file:subscriptions.db; с SQLite работают только подписки, без каталога, бюджетов,
+// аудита, вебхуков и напоминаний
 type DatabaseConfig struct {
-	DSN string `yaml:"dsn"`
+	Driver string `yaml:"driver"`
+	DSN    string `yaml:"dsn"`
 }
 
 func NewDatabaseConnection(dbDriverName, connectionString string) (*Database, error) {
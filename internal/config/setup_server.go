@@ -1,15 +1,105 @@
 package config
 
 import (
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"gopkg.in/yaml.v3"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
 type AppConfig struct {
-	DatabaseConfig DatabaseConfig `yaml:"databaseConfig"`
-	ServerAddr     string         `yaml:"serverAddr"`
+	DatabaseConfig    DatabaseConfig    `yaml:"databaseConfig"`
+	ServerAddr        string            `yaml:"serverAddr"`
+	IdempotencyConfig IdempotencyConfig `yaml:"idempotencyConfig"`
+	SoftDeleteConfig  SoftDeleteConfig  `yaml:"softDeleteConfig"`
+	CurrencyConfig    CurrencyConfig    `yaml:"currencyConfig"`
+	ReminderConfig    ReminderConfig    `yaml:"reminderConfig"`
+	WebhookConfig     WebhookConfig     `yaml:"webhookConfig"`
+	OutboxConfig      OutboxConfig      `yaml:"outboxConfig"`
+	CalendarConfig    CalendarConfig    `yaml:"calendarConfig"`
+}
+
+type IdempotencyConfig struct {
+	TTL time.Duration `yaml:"ttl"`
+}
+
+// SoftDeleteConfig
+// Retention - сколько удаленная подписка хранится до окончательного удаления,
+// PurgeInterval - как часто запускается очистка удаленных подписок и просроченных ключей
+// идемпотентности. Нулевой Retention отключает окончательное удаление подписок
+type SoftDeleteConfig struct {
+	Retention     time.Duration `yaml:"retention"`
+	PurgeInterval time.Duration `yaml:"purgeInterval"`
+}
+
+// CurrencyConfig
+// ExchangeRatesFile - CSV-файл курсов валют, загружаемый при старте; пустой путь
+// означает, что курсы ведутся только через API
+type CurrencyConfig struct {
+	ExchangeRatesFile string `yaml:"exchangeRatesFile"`
+}
+
+// ReminderConfig
+// DaysBefore - за сколько дней до оплаты создается напоминание, Interval - как часто
+// планировщик создает и доставляет напоминания, BatchSize - сколько напоминаний доставляется
+// за один проход, Timeout - таймаут доставки одного напоминания. Нулевой DaysBefore
+// отключает напоминания
+type ReminderConfig struct {
+	DaysBefore int           `yaml:"daysBefore"`
+	Interval   time.Duration `yaml:"interval"`
+	BatchSize  int           `yaml:"batchSize"`
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// WebhookConfig
+// Interval - как часто проверяется очередь доставок, BatchSize - сколько доставок
+// отправляется за проход, Timeout - таймаут запроса к получателю. Задержка перед повтором
+// удваивается от InitialBackoff до MaxBackoff; после MaxAttempts неудачных попыток
+// доставка переходит в dead. AllowPrivateNetworks разрешает вебхуки на localhost и
+// адреса внутренней сети, например для локальной разработки
+type WebhookConfig struct {
+	Interval             time.Duration `yaml:"interval"`
+	BatchSize            int           `yaml:"batchSize"`
+	Timeout              time.Duration `yaml:"timeout"`
+	MaxAttempts          int           `yaml:"maxAttempts"`
+	InitialBackoff       time.Duration `yaml:"initialBackoff"`
+	MaxBackoff           time.Duration `yaml:"maxBackoff"`
+	AllowPrivateNetworks bool          `yaml:"allowPrivateNetworks"`
+}
+
+// OutboxConfig
+// Publishers - куда релей публикует события подписок: webhook, log, nats. Interval - как
+// часто релей проверяет outbox, BatchSize - сколько событий публикуется за проход,
+// Timeout - таймаут публикации одного события всем издателям; после MaxAttempts
+// неудачных попыток событие переходит в dead. Retention - сколько хранятся отправленные
+// события; нулевой Retention их не удаляет
+type OutboxConfig struct {
+	Publishers  []string      `yaml:"publishers"`
+	Interval    time.Duration `yaml:"interval"`
+	BatchSize   int           `yaml:"batchSize"`
+	Timeout     time.Duration `yaml:"timeout"`
+	MaxAttempts int           `yaml:"maxAttempts"`
+	Retention   time.Duration `yaml:"retention"`
+	NATS        NATSConfig    `yaml:"nats"`
+}
+
+// NATSConfig
+// URL - адрес сервера вида nats://host:4222, SubjectPrefix - префикс темы перед типом
+// события, Timeout - таймаут подключения и подтверждения публикации
+type NATSConfig struct {
+	URL           string        `yaml:"url"`
+	SubjectPrefix string        `yaml:"subjectPrefix"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+// CalendarConfig
+// PublicURL - адрес сервиса для ссылок на календари оплат, например
+// https://subscriptions.example.com; пустой адрес берется из запроса
+type CalendarConfig struct {
+	PublicURL string `yaml:"publicURL"`
 }
 
 func LoadConfig(path string) (*AppConfig, error) {
@@ -23,11 +113,93 @@ func LoadConfig(path string) (*AppConfig, error) {
 		return nil, err
 	}
 
+	if cfg.DatabaseConfig.Driver == "" {
+		cfg.DatabaseConfig.Driver = DriverPostgres
+	}
+	if cfg.IdempotencyConfig.TTL <= 0 {
+		cfg.IdempotencyConfig.TTL = 24 * time.Hour
+	}
+	if cfg.SoftDeleteConfig.PurgeInterval <= 0 {
+		cfg.SoftDeleteConfig.PurgeInterval = time.Hour
+	}
+	if cfg.ReminderConfig.Interval <= 0 {
+		cfg.ReminderConfig.Interval = 15 * time.Minute
+	}
+	if cfg.ReminderConfig.BatchSize <= 0 {
+		cfg.ReminderConfig.BatchSize = 100
+	}
+	if cfg.ReminderConfig.Timeout <= 0 {
+		cfg.ReminderConfig.Timeout = 10 * time.Second
+	}
+	if cfg.WebhookConfig.Interval <= 0 {
+		cfg.WebhookConfig.Interval = 10 * time.Second
+	}
+	if cfg.WebhookConfig.BatchSize <= 0 {
+		cfg.WebhookConfig.BatchSize = 50
+	}
+	if cfg.WebhookConfig.Timeout <= 0 {
+		cfg.WebhookConfig.Timeout = 10 * time.Second
+	}
+	if cfg.WebhookConfig.MaxAttempts <= 0 {
+		cfg.WebhookConfig.MaxAttempts = 8
+	}
+	if cfg.WebhookConfig.InitialBackoff <= 0 {
+		cfg.WebhookConfig.InitialBackoff = 30 * time.Second
+	}
+	if cfg.WebhookConfig.MaxBackoff <= 0 {
+		cfg.WebhookConfig.MaxBackoff = 6 * time.Hour
+	}
+	if len(cfg.OutboxConfig.Publishers) == 0 {
+		cfg.OutboxConfig.Publishers = []string{"webhook"}
+	}
+	if cfg.OutboxConfig.Interval <= 0 {
+		cfg.OutboxConfig.Interval = time.Second
+	}
+	if cfg.OutboxConfig.BatchSize <= 0 {
+		cfg.OutboxConfig.BatchSize = 100
+	}
+	if cfg.OutboxConfig.Timeout <= 0 {
+		cfg.OutboxConfig.Timeout = 10 * time.Second
+	}
+	if cfg.OutboxConfig.MaxAttempts <= 0 {
+		cfg.OutboxConfig.MaxAttempts = 20
+	}
+	if cfg.OutboxConfig.NATS.Timeout <= 0 {
+		cfg.OutboxConfig.NATS.Timeout = 5 * time.Second
+	}
+
 	return &cfg, nil
 }
 
-func SetupDatabase(dsn string) (*Database, error) {
-	return NewDatabaseConnection("postgres", dsn)
+func SetupDatabase(cfg DatabaseConfig) (*Database, error) {
+	switch cfg.Driver {
+	case DriverPostgres:
+		return NewDatabaseConnection(DriverPostgres, cfg.DSN)
+	case DriverSQLite:
+		return setupSQLite(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("неизвестный драйвер БД %q, ожидается %s или %s", cfg.Driver, DriverPostgres, DriverSQLite)
+	}
+}
+
+// setupSQLite открывает файл базы SQLite с включенными внешними ключами. Соединение
+// одно: SQLite все равно выполняет записи по одной, а на одном соединении транзакции
+// не получают SQLITE_BUSY и видят одну и ту же базу, даже если она в памяти
+func setupSQLite(dsn string) (*Database, error) {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	if !strings.Contains(dsn, "foreign_keys") {
+		dsn += separator + "_pragma=foreign_keys(1)"
+	}
+
+	database, err := NewDatabaseConnection(DriverSQLite, dsn)
+	if err != nil {
+		return nil, err
+	}
+	database.SetMaxOpenConns(1)
+	return database, nil
 }
 
 func SetupRestServer(addr string) (*http.Server, *chi.Mux) {
@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxBudgetReportMonths - наибольшее число месяцев в одном отчете по бюджетам
+const maxBudgetReportMonths = 36
+
+type BudgetHandler struct {
+	*service.BudgetService
+}
+
+func NewBudgetHandler(s *service.BudgetService) *BudgetHandler {
+	return &BudgetHandler{s}
+}
+
+// BudgetRequest
+// Структура запроса на создание и изменение бюджета
+// (для документации)
+type BudgetRequest struct {
+	UserID    string  `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba" description:"UUID пользователя"`
+	Scope     string  `json:"scope" example:"category" enums:"total,category,service" description:"Область бюджета"`
+	Category  *string `json:"category,omitempty" example:"streaming" description:"Категория, только для области category"`
+	ServiceID *int    `json:"service_id,omitempty" example:"1" description:"ID сервиса каталога, только для области service"`
+	Amount    int     `json:"amount" example:"150000" description:"Бюджет на месяц в минимальных единицах валюты"`
+	Currency  string  `json:"currency,omitempty" example:"RUB" description:"Код валюты ISO 4217, по умолчанию RUB"`
+}
+
+// BudgetReportResponse
+// Структура ответа с помесячным исполнением бюджетов пользователя
+type BudgetReportResponse struct {
+	UserID     string               `json:"user_id"`
+	StartMonth model.MonthYear      `json:"start_month"`
+	EndMonth   model.MonthYear      `json:"end_month"`
+	Budgets    []model.BudgetReport `json:"budgets"`
+}
+
+// CreateBudget godoc
+// @Summary      Задать бюджет
+// @Description  Задает месячный бюджет пользователя на все подписки, на категорию или на сервис каталога. На каждую область у пользователя может быть только один бюджет
+// @Tags         Бюджеты
+// @Accept       json
+// @Produce      json
+// @Param        budget  body      BudgetRequest  true  "Бюджет"
+// @Success      201     {object}  model.Budget
+// @Failure      400     {object}  Problem  "неверный формат запроса"
+// @Failure      422     {object}  Problem  "бюджет не прошел проверку или уже задан"
+// @Failure      500     {object}  Problem  "не удалось создать бюджет"
+// @Failure      503     {object}  Problem  "хранилище временно недоступно"
+// @Router       /budgets [post]
+func (handler *BudgetHandler) CreateBudget(w http.ResponseWriter, r *http.Request) {
+	var input model.Budget
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат запроса")
+		return
+	}
+
+	if err := handler.BudgetService.CreateBudget(r.Context(), &input); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось создать бюджет")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(input)
+}
+
+// ListBudgets godoc
+// @Summary      Бюджеты пользователя
+// @Description  Возвращает все бюджеты пользователя
+// @Tags         Бюджеты
+// @Produce      json
+// @Param        user_id  query     string  true  "UUID пользователя"
+// @Success      200      {array}   model.Budget
+// @Failure      400      {object}  Problem  "не передан user_id"
+// @Failure      500      {object}  Problem  "не удалось получить бюджеты"
+// @Failure      503      {object}  Problem  "хранилище временно недоступно"
+// @Router       /budgets [get]
+func (handler *BudgetHandler) ListBudgets(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "обязательный параметр user_id отсутствует")
+		return
+	}
+
+	budgets, err := handler.GetBudgetsByUserUUID(r.Context(), userID)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить бюджеты")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(budgets)
+}
+
+// GetBudgetReport godoc
+// @Summary      Исполнение бюджетов
+// @Description  Сравнивает траты пользователя за каждый месяц периода с каждым его бюджетом. Траты считаются по тем же правилам, что и /subscriptions/total-cost, в валюте бюджета. Отрицательный remaining - сумма перерасхода
+// @Tags         Бюджеты
+// @Produce      json
+// @Param        user_id      query     string  true   "UUID пользователя"
+// @Param        start_month  query     string  false  "Первый месяц (формат MM-YYYY, по умолчанию текущий)"
+// @Param        end_month    query     string  false  "Последний месяц (формат MM-YYYY, по умолчанию равен start_month)"
+// @Success      200  {object}  BudgetReportResponse
+// @Failure      400  {object}  Problem  "ошибка параметров запроса"
+// @Failure      422  {object}  Problem  "нет курса валюты на дату оплаты"
+// @Failure      500  {object}  Problem  "ошибка сервера"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /budgets/report [get]
+func (handler *BudgetHandler) GetBudgetReport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userID := query.Get("user_id")
+	if userID == "" {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "обязательный параметр user_id отсутствует")
+		return
+	}
+
+	now := time.Now().UTC()
+	startMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if value := query.Get("start_month"); value != "" {
+		month, err := time.Parse("01-2006", value)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат start_month, ожидается MM-YYYY")
+			return
+		}
+		startMonth = month
+	}
+
+	endMonth := startMonth
+	if value := query.Get("end_month"); value != "" {
+		month, err := time.Parse("01-2006", value)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат end_month, ожидается MM-YYYY")
+			return
+		}
+		endMonth = month
+	}
+
+	if err := checkBudgetReportPeriod(startMonth, endMonth); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	reports, err := handler.GetBudgetReports(r.Context(), userID, startMonth, endMonth)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось посчитать исполнение бюджетов")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(BudgetReportResponse{
+		UserID:     userID,
+		StartMonth: model.MonthYear(startMonth),
+		EndMonth:   model.MonthYear(endMonth),
+		Budgets:    reports,
+	})
+}
+
+func checkBudgetReportPeriod(startMonth, endMonth time.Time) error {
+	if endMonth.Before(startMonth) {
+		return errors.New("end_month раньше start_month")
+	}
+	months := (endMonth.Year()-startMonth.Year())*12 + int(endMonth.Month()-startMonth.Month()) + 1
+	if months > maxBudgetReportMonths {
+		return fmt.Errorf("отчет можно построить не больше чем за %d месяцев", maxBudgetReportMonths)
+	}
+	return nil
+}
+
+// GetBudget godoc
+// @Summary      Получить бюджет
+// @Description  Возвращает бюджет по его идентификатору
+// @Tags         Бюджеты
+// @Produce      json
+// @Param        id   path      int  true  "ID бюджета"
+// @Success      200  {object}  model.Budget
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "бюджет не найден"
+// @Failure      500  {object}  Problem  "не удалось получить бюджет"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /budgets/{id} [get]
+func (handler *BudgetHandler) GetBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	budget, err := handler.GetBudgetByID(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить бюджет")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(budget)
+}
+
+// UpdateBudget godoc
+// @Summary      Изменить бюджет
+// @Description  Перезаписывает бюджет целиком
+// @Tags         Бюджеты
+// @Accept       json
+// @Produce      json
+// @Param        id      path      int            true  "ID бюджета"
+// @Param        budget  body      BudgetRequest  true  "Бюджет"
+// @Success      200     {object}  model.Budget
+// @Failure      400     {object}  Problem  "неверный ID или формат запроса"
+// @Failure      404     {object}  Problem  "бюджет не найден"
+// @Failure      422     {object}  Problem  "бюджет не прошел проверку или уже задан"
+// @Failure      500     {object}  Problem  "не удалось обновить бюджет"
+// @Failure      503     {object}  Problem  "хранилище временно недоступно"
+// @Router       /budgets/{id} [put]
+func (handler *BudgetHandler) UpdateBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	var input model.Budget
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "проверьте правильность переданных данных")
+		return
+	}
+
+	if err := handler.UpdateBudgetByID(r.Context(), &input, id); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось обновить бюджет")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(input)
+}
+
+// DeleteBudget godoc
+// @Summary      Удалить бюджет
+// @Description  Удаляет бюджет по его идентификатору
+// @Tags         Бюджеты
+// @Param        id   path  int  true  "ID бюджета"
+// @Success      204  "бюджет удален"
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "бюджет не найден"
+// @Failure      500  {object}  Problem  "не удалось удалить бюджет"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /budgets/{id} [delete]
+func (handler *BudgetHandler) DeleteBudget(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	if err := handler.DeleteBudgetByID(r.Context(), id); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось удалить бюджет")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/service"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type CalendarHandler struct {
+	*service.CalendarService
+	publicURL string
+}
+
+func NewCalendarHandler(s *service.CalendarService, publicURL string) *CalendarHandler {
+	return &CalendarHandler{CalendarService: s, publicURL: strings.TrimSuffix(publicURL, "/")}
+}
+
+// CalendarLinkResponse
+// Структура ответа со ссылкой на календарь оплат пользователя
+type CalendarLinkResponse struct {
+	Token string `json:"token" example:"k3JX0p0eZ7o6m1m6y2Q8m6Vt0a7c1nqH0wz8m9l9x4Y" description:"Токен календаря"`
+	URL   string `json:"url" example:"https://subscriptions.example.com/users/60601fee-2bf1-4721-ae6f-7636e79a0cba/renewals.ics?token=k3JX0p0eZ7o6m1m6y2Q8m6Vt0a7c1nqH0wz8m9l9x4Y" description:"Ссылка для подписки из приложения календаря"`
+}
+
+// IssueLink godoc
+// @Summary      Выпустить ссылку на календарь оплат
+// @Description  Выпускает пользователю новый случайный токен календаря и возвращает ссылку с ним. Прежняя ссылка перестает работать. Сервис хранит только хеш токена, поэтому токен показывается один раз; потерянную ссылку нужно выпустить заново. Сервис не проверяет, кто вызывает эндпоинт: как и остальные эндпоинты с UUID пользователя, он должен быть доступен только самому пользователю через шлюз, который его аутентифицирует
+// @Tags         Календарь
+// @Produce      json
+// @Param        uuid  path      string  true  "UUID пользователя"
+// @Success      201   {object}  CalendarLinkResponse
+// @Failure      422   {object}  Problem  "неверный UUID пользователя"
+// @Failure      500   {object}  Problem  "не удалось выпустить ссылку"
+// @Failure      503   {object}  Problem  "хранилище временно недоступно"
+// @Router       /users/{uuid}/calendar [post]
+func (handler *CalendarHandler) IssueLink(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+
+	token, err := handler.IssueCalendarToken(r.Context(), uuid)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось выпустить ссылку на календарь")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(CalendarLinkResponse{
+		Token: token,
+		URL:   handler.baseURL(r) + "/users/" + url.PathEscape(uuid) + "/renewals.ics?token=" + url.QueryEscape(token),
+	})
+}
+
+// RevokeLink godoc
+// @Summary      Отозвать ссылку на календарь оплат
+// @Description  Отзывает токен календаря пользователя: ссылка на календарь перестает работать, пока не будет выпущена новая
+// @Tags         Календарь
+// @Param        uuid  path  string  true  "UUID пользователя"
+// @Success      204   "ссылка отозвана"
+// @Failure      404   {object}  Problem  "у пользователя нет ссылки на календарь"
+// @Failure      422   {object}  Problem  "неверный UUID пользователя"
+// @Failure      500   {object}  Problem  "не удалось отозвать ссылку"
+// @Failure      503   {object}  Problem  "хранилище временно недоступно"
+// @Router       /users/{uuid}/calendar [delete]
+func (handler *CalendarHandler) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	if err := handler.RevokeCalendarToken(r.Context(), chi.URLParam(r, "uuid")); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось отозвать ссылку на календарь")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetRenewals godoc
+// @Summary      Календарь оплат
+// @Description  Календарь iCalendar (RFC 5545) с повторяющимся событием на каждую подписку пользователя: даты оплат по периоду оплаты до end_date, без оплат в пробный период и на паузе, с ценой в описании. На календарь можно подписаться из приложения календаря по ссылке из POST /users/{uuid}/calendar
+// @Tags         Календарь
+// @Produce      text/calendar
+// @Param        uuid   path      string  true  "UUID пользователя"
+// @Param        token  query     string  true  "Токен календаря"
+// @Success      200    {file}    file     "календарь оплат"
+// @Failure      404    {object}  Problem  "календарь не найден, токен неверный или отозван"
+// @Failure      500    {object}  Problem  "не удалось собрать календарь"
+// @Failure      503    {object}  Problem  "хранилище временно недоступно"
+// @Router       /users/{uuid}/renewals.ics [get]
+func (handler *CalendarHandler) GetRenewals(w http.ResponseWriter, r *http.Request) {
+	uuid := chi.URLParam(r, "uuid")
+
+	calendar, err := handler.RenewalsCalendar(r.Context(), uuid, r.URL.Query().Get("token"))
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось собрать календарь оплат")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="renewals.ics"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	_, _ = w.Write(calendar)
+}
+
+// baseURL возвращает адрес сервиса для ссылок: из конфигурации, а если он не задан -
+// из запроса с учетом прокси, который передает исходную схему в X-Forwarded-Proto
+func (handler *CalendarHandler) baseURL(r *http.Request) string {
+	if handler.publicURL != "" {
+		return handler.publicURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
+}
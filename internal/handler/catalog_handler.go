@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/service"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type CatalogHandler struct {
+	*service.CatalogService
+}
+
+func NewCatalogHandler(s *service.CatalogService) *CatalogHandler {
+	return &CatalogHandler{s}
+}
+
+// CatalogServiceRequest
+// Структура запроса на добавление и изменение сервиса каталога
+// (для документации)
+type CatalogServiceRequest struct {
+	Name         string   `json:"name" example:"Yandex Plus" description:"Каноническое название сервиса"`
+	Aliases      []string `json:"aliases,omitempty" example:"Яндекс Плюс" description:"Другие написания, по которым находится сервис"`
+	Category     *string  `json:"category,omitempty" example:"streaming" description:"Категория сервиса"`
+	DefaultPrice *int     `json:"default_price,omitempty" example:"39900" description:"Цена по умолчанию в минимальных единицах валюты"`
+	Currency     string   `json:"currency,omitempty" example:"RUB" description:"Код валюты ISO 4217, по умолчанию RUB"`
+	LogoURL      *string  `json:"logo_url,omitempty" example:"https://example.com/yandex-plus.png" description:"Ссылка на логотип"`
+}
+
+// CreateService godoc
+// @Summary      Добавить сервис в каталог
+// @Description  Добавляет сервис с каноническим названием и написаниями. Названия сравниваются без учета регистра, пробелов и знаков препинания; одно написание может принадлежать только одному сервису
+// @Tags         Каталог сервисов
+// @Accept       json
+// @Produce      json
+// @Param        service  body      CatalogServiceRequest  true  "Сервис каталога"
+// @Success      201      {object}  model.CatalogService
+// @Failure      400      {object}  Problem  "неверный формат запроса"
+// @Failure      422      {object}  Problem  "сервис не прошел проверку или написание уже занято"
+// @Failure      500      {object}  Problem  "не удалось добавить сервис в каталог"
+// @Failure      503      {object}  Problem  "хранилище временно недоступно"
+// @Router       /services [post]
+func (handler *CatalogHandler) CreateService(w http.ResponseWriter, r *http.Request) {
+	var input model.CatalogService
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат запроса")
+		return
+	}
+
+	if err := handler.CreateCatalogService(r.Context(), &input); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось добавить сервис в каталог")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(input)
+}
+
+// ListServices godoc
+// @Summary      Каталог сервисов
+// @Description  Возвращает сервисы каталога, отсортированные по названию
+// @Tags         Каталог сервисов
+// @Produce      json
+// @Param        category  query     string  false  "Категория"
+// @Param        q         query     string  false  "Часть любого написания сервиса"
+// @Success      200       {array}   model.CatalogService
+// @Failure      500       {object}  Problem  "не удалось получить каталог сервисов"
+// @Failure      503       {object}  Problem  "хранилище временно недоступно"
+// @Router       /services [get]
+func (handler *CatalogHandler) ListServices(w http.ResponseWriter, r *http.Request) {
+	var filter model.CatalogServiceFilter
+	filter.Category = service.NormalizeCategory(optionalParam(r.URL.Query().Get("category")))
+	if query := r.URL.Query().Get("q"); query != "" {
+		filter.Query = &query
+	}
+
+	services, err := handler.ListCatalogServices(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить каталог сервисов")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(services)
+}
+
+// GetService godoc
+// @Summary      Получить сервис каталога
+// @Description  Возвращает сервис каталога по его идентификатору
+// @Tags         Каталог сервисов
+// @Produce      json
+// @Param        id   path      int  true  "ID сервиса"
+// @Success      200  {object}  model.CatalogService
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "сервис не найден"
+// @Failure      500  {object}  Problem  "не удалось получить сервис"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /services/{id} [get]
+func (handler *CatalogHandler) GetService(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	service, err := handler.GetCatalogServiceByID(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить сервис")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(service)
+}
+
+// UpdateService godoc
+// @Summary      Изменить сервис каталога
+// @Description  Перезаписывает сервис целиком, включая список написаний. Новое название сразу показывается во всех подписках сервиса, включая удаленные; каждая из них получает новую версию, запись в истории изменений и событие subscription.updated
+// @Tags         Каталог сервисов
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                    true  "ID сервиса"
+// @Param        service  body      CatalogServiceRequest  true  "Сервис каталога"
+// @Success      200      {object}  model.CatalogService
+// @Failure      400      {object}  Problem  "неверный ID или формат запроса"
+// @Failure      404      {object}  Problem  "сервис не найден"
+// @Failure      422      {object}  Problem  "сервис не прошел проверку или написание уже занято"
+// @Failure      500      {object}  Problem  "не удалось обновить сервис"
+// @Failure      503      {object}  Problem  "хранилище временно недоступно"
+// @Router       /services/{id} [put]
+func (handler *CatalogHandler) UpdateService(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	var input model.CatalogService
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "проверьте правильность переданных данных")
+		return
+	}
+
+	if err := handler.UpdateCatalogServiceByID(r.Context(), &input, id); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось обновить сервис")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(input)
+}
+
+// DeleteService godoc
+// @Summary      Удалить сервис из каталога
+// @Description  Удаляет сервис вместе с его написаниями. Сервис, на который ссылаются подписки, в том числе удаленные, удалить нельзя
+// @Tags         Каталог сервисов
+// @Param        id   path  int  true  "ID сервиса"
+// @Success      204  "сервис удален"
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "сервис не найден"
+// @Failure      409  {object}  Problem  "на сервис ссылаются подписки"
+// @Failure      500  {object}  Problem  "не удалось удалить сервис"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /services/{id} [delete]
+func (handler *CatalogHandler) DeleteService(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	if err := handler.DeleteCatalogServiceByID(r.Context(), id); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось удалить сервис")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errIfMatchRequired = errors.New("для изменения подписки требуется заголовок If-Match с ее ETag")
+	// If-Match сравнивает ETag строго (RFC 9110, 13.1.1), поэтому слабый ETag не совпадает ни с одной версией
+	errIfMatchWeak = errors.New("слабый ETag не подходит для If-Match, передайте ETag подписки без W/")
+)
+
+// setETag отдает версию подписки как сильный ETag
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", formatETag(version))
+}
+
+func formatETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch возвращает ожидаемую версию подписки из If-Match.
+// Значение * означает любую версию и возвращается как nil
+func parseIfMatch(r *http.Request) (*int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return nil, errIfMatchRequired
+	}
+	if header == "*" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(header, "W/") {
+		return nil, errIfMatchWeak
+	}
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		return nil, errors.New("неверный формат If-Match, ожидается ETag подписки")
+	}
+	return &version, nil
+}
+
+// writeIfMatchError отвечает 428, если If-Match не передан, 412 на слабый ETag и 400, если он некорректен
+func writeIfMatchError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errIfMatchRequired):
+		writeProblem(w, r, http.StatusPreconditionRequired, codePreconditionRequired, err.Error())
+		return
+	case errors.Is(err, errIfMatchWeak):
+		writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, err.Error())
+		return
+	}
+	writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	version := func(value int) *int { return &value }
+
+	tests := []struct {
+		name    string
+		header  string
+		want    *int
+		invalid bool
+	}{
+		{name: "сильный ETag", header: `"3"`, want: version(3)},
+		{name: "пробелы вокруг", header: ` "5" `, want: version(5)},
+		{name: "любая версия", header: `*`},
+		{name: "без кавычек", header: `3`, invalid: true},
+		{name: "открывающая кавычка", header: `"3`, invalid: true},
+		{name: "не число", header: `"abc"`, invalid: true},
+		{name: "список ETag", header: `"1", "2"`, invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", nil)
+			r.Header.Set("If-Match", test.header)
+
+			got, err := parseIfMatch(r)
+			if test.invalid {
+				if err == nil || errors.Is(err, errIfMatchRequired) {
+					t.Fatalf("parseIfMatch(%q) = %v, ожидалась ошибка формата", test.header, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseIfMatch(%q): %v", test.header, err)
+			}
+			if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+				t.Errorf("parseIfMatch(%q) = %v, ожидалось %v", test.header, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseIfMatchRequired(t *testing.T) {
+	r := httptest.NewRequest(http.MethodDelete, "/subscriptions/1", nil)
+	if _, err := parseIfMatch(r); !errors.Is(err, errIfMatchRequired) {
+		t.Fatalf("без If-Match ожидалась errIfMatchRequired, получено %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	writeIfMatchError(w, r, errIfMatchRequired)
+	if w.Code != http.StatusPreconditionRequired {
+		t.Errorf("статус %d, ожидался %d", w.Code, http.StatusPreconditionRequired)
+	}
+}
+
+func TestParseIfMatchWeak(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "/subscriptions/1", nil)
+	r.Header.Set("If-Match", `W/"12"`)
+	_, err := parseIfMatch(r)
+	if !errors.Is(err, errIfMatchWeak) {
+		t.Fatalf("слабый ETag: ожидалась errIfMatchWeak, получено %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	writeIfMatchError(w, r, err)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("статус %d, ожидался %d", w.Code, http.StatusPreconditionFailed)
+	}
+}
+
+func TestETagRoundTrip(t *testing.T) {
+	for _, version := range []int{1, 2, 100} {
+		w := httptest.NewRecorder()
+		setETag(w, version)
+
+		r := httptest.NewRequest(http.MethodPatch, "/subscriptions/1", nil)
+		r.Header.Set("If-Match", w.Header().Get("ETag"))
+		got, err := parseIfMatch(r)
+		if err != nil || got == nil || *got != version {
+			t.Errorf("ETag %q разобран как %v, ошибка %v", w.Header().Get("ETag"), got, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/service"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+type ExchangeRateHandler struct {
+	*service.ExchangeRateService
+}
+
+func NewExchangeRateHandler(s *service.ExchangeRateService) *ExchangeRateHandler {
+	return &ExchangeRateHandler{s}
+}
+
+// SaveRates godoc
+// @Summary      Загрузить курсы валют
+// @Description  Добавляет курсы валют одним пакетом; курс той же пары на ту же дату перезаписывается. Курс действует с rate_date до следующего курса пары и используется в обе стороны
+// @Tags         Валюты
+// @Accept       json
+// @Param        rates  body  []model.ExchangeRate  true  "Курсы валют"
+// @Success      204
+// @Failure      400  {object}  Problem  "неверный формат запроса"
+// @Failure      422  {object}  Problem  "курсы не прошли проверку"
+// @Failure      500  {object}  Problem  "не удалось сохранить курсы валют"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /admin/exchange-rates [put]
+func (handler *ExchangeRateHandler) SaveRates(w http.ResponseWriter, r *http.Request) {
+	var rates []model.ExchangeRate
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&rates); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "ожидается массив курсов валют")
+		return
+	}
+
+	if err := handler.SaveExchangeRates(r.Context(), rates); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось сохранить курсы валют")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRates godoc
+// @Summary      Список курсов валют
+// @Description  Возвращает сохраненные курсы валют, отсортированные по паре и дате
+// @Tags         Валюты
+// @Produce      json
+// @Param        base_currency   query     string  false  "Базовая валюта"
+// @Param        quote_currency  query     string  false  "Котируемая валюта"
+// @Success      200  {array}   model.ExchangeRate
+// @Failure      500  {object}  Problem  "не удалось получить курсы валют"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /admin/exchange-rates [get]
+func (handler *ExchangeRateHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	var filter model.ExchangeRateFilter
+	if base := strings.ToUpper(r.URL.Query().Get("base_currency")); base != "" {
+		filter.BaseCurrency = &base
+	}
+	if quote := strings.ToUpper(r.URL.Query().Get("quote_currency")); quote != "" {
+		filter.QuoteCurrency = &quote
+	}
+
+	rates, err := handler.GetExchangeRates(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить курсы валют")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rates)
+}
+
+// ListCurrencies godoc
+// @Summary      Справочник валют
+// @Description  Возвращает валюты, в которых можно задавать цены подписок и считать стоимость
+// @Tags         Валюты
+// @Produce      json
+// @Success      200  {array}   model.Currency
+// @Failure      500  {object}  Problem  "не удалось получить справочник валют"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /currencies [get]
+func (handler *ExchangeRateHandler) ListCurrencies(w http.ResponseWriter, r *http.Request) {
+	currencies, err := handler.GetCurrencies(r.Context())
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить справочник валют")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(currencies)
+}
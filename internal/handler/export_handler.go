@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/service"
+	"log"
+	"net/http"
+)
+
+// Export godoc
+// @Summary      Выгрузка подписок
+// @Description  Выгружает все подписки, подходящие под фильтры GET /subscriptions, в CSV, JSON Lines или XLSX, в порядке sort. Ответ пишется потоком: подписки читаются страницами по 500 по курсору списка, каждая отдельным коротким запросом, поэтому размер выгрузки не ограничен памятью сервера, а выгрузка не блокирует изменения подписок. Выгрузка не снимок: подписка, измененная во время выгрузки, попадает в нее в состоянии на момент чтения ее страницы; в XLSX помещается не больше 1048575 подписок. Колонки CSV и XLSX совпадают с колонками импорта, поэтому выгрузку в CSV можно импортировать обратно. Значения service_name, category и tags, начинающиеся с =, +, -, @, табуляции или возврата каретки, выгружаются с апострофом в начале, чтобы табличный редактор не исполнил их как формулу; импорт снимает апостроф. Если выгрузка прервалась после начала ответа, соединение разрывается, и клиент получает неполный файл с ошибкой передачи
+// @Tags         Подписки
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        format        query     string  false  "Формат выгрузки (по умолчанию csv)" Enums(csv, jsonl, xlsx)
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_id    query     int     false  "ID сервиса из каталога"
+// @Param        service_name  query     string  false  "Любое написание сервиса из каталога"
+// @Param        category      query     string  false  "Категория подписки или ее сервиса"
+// @Param        tag           query     []string  false  "Тег; при нескольких tag подписка должна иметь все" collectionFormat(multi)
+// @Param        price_min     query     int     false  "Минимальная цена"
+// @Param        price_max     query     int     false  "Максимальная цена"
+// @Param        active_on     query     string  false  "Подписка активна на дату (DD-MM-YYYY)"
+// @Param        start_from    query     string  false  "Дата начала не раньше (DD-MM-YYYY)"
+// @Param        start_to      query     string  false  "Дата начала не позже (DD-MM-YYYY)"
+// @Param        end_from      query     string  false  "Дата окончания не раньше (DD-MM-YYYY)"
+// @Param        end_to        query     string  false  "Дата окончания не позже (DD-MM-YYYY)"
+// @Param        status        query     string  false  "Статус подписки на сегодня" Enums(trial, active, paused, cancelled, expired)
+// @Param        sort          query     string  false  "Поле сортировки: id, service_name, price, start_date, end_date; префикс - для убывания (по умолчанию id)"
+// @Success      200  {file}    file     "подписки в выбранном формате"
+// @Failure      400  {object}  Problem  "ошибка параметров запроса"
+// @Failure      500  {object}  Problem  "ошибка сервера"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/export [get]
+func (handler *SubscriptionHandler) Export(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("limit") || query.Has("cursor") {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "выгрузка не делится на страницы, limit и cursor не поддерживаются")
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = service.ExportFormatCSV
+	}
+	contentType, ok := service.ExportContentTypes[format]
+	if !ok {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный format, ожидается csv, jsonl или xlsx")
+		return
+	}
+
+	filter, err := parseListFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	// ответ начинается с первой подписки: до нее об ошибке еще можно сообщить статусом
+	var writer service.SubscriptionWriter
+	started := false
+	start := func() (err error) {
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="subscriptions.`+format+`"`)
+		w.WriteHeader(http.StatusOK)
+		writer, err = service.NewSubscriptionWriter(format, w)
+		return err
+	}
+
+	err = handler.ExportSubscriptions(r.Context(), filter, func(subscription *model.SubscriptionDetails) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write(subscription)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	log.Println(err)
+	if !started {
+		writeError(w, r, err, "не удалось выгрузить подписки")
+		return
+	}
+	// статус уже отправлен: разрываем соединение, чтобы клиент не принял обрезанный файл за полный
+	panic(http.ErrAbortHandler)
+}
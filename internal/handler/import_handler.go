@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/service"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const maxImportBodySize = 10 << 20
+
+// importParsers разбирают тело запроса импорта по его Content-Type
+var importParsers = map[string]func(r io.Reader) ([]model.ImportRow, error){
+	"text/csv":             service.ParseSubscriptionsCSV,
+	"application/x-ndjson": service.ParseSubscriptionsJSONL,
+	"application/jsonl":    service.ParseSubscriptionsJSONL,
+}
+
+// Import godoc
+// @Summary      Импорт подписок
+// @Description  Создает подписки из CSV (text/csv) или JSON Lines (application/x-ndjson, application/jsonl) одной транзакцией: либо все, либо ни одной. В CSV первая строка - заголовок с колонками service_id, service_name, category, tags, price, currency, billing_period, billing_interval, user_id, start_date, end_date, trial_end в любом порядке, теги перечисляются через точку с запятой. В JSON Lines каждая строка - подписка в формате POST /subscriptions/create. Каждая подписка проверяется как при создании, нарушения возвращаются с номерами строк. С dry_run=true подписки только проверяются. Не больше 10000 подписок и 10 МиБ за запрос
+// @Tags         Подписки
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        dry_run  query     bool    false  "Только проверить подписки, ничего не записывая"
+// @Param        file     body      string  true   "Подписки в CSV или JSON Lines"
+// @Success      200      {object}  model.ImportReport  "пробный импорт: все подписки прошли проверку"
+// @Success      201      {object}  model.ImportReport  "подписки созданы"
+// @Failure      400      {object}  Problem  "файл не удалось прочитать"
+// @Failure      415      {object}  Problem  "неподдерживаемый Content-Type"
+// @Failure      422      {object}  model.ImportReport  "строки, не прошедшие проверку; ни одна подписка не создана"
+// @Failure      500      {object}  Problem  "не удалось импортировать подписки"
+// @Failure      503      {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/import [post]
+func (handler *SubscriptionHandler) Import(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	parse := importParsers[mediaType]
+	if err != nil || parse == nil {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "ожидается Content-Type text/csv или application/x-ndjson")
+		return
+	}
+
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат dry_run, ожидается true или false")
+			return
+		}
+	}
+
+	rows, err := parse(http.MaxBytesReader(w, r.Body, maxImportBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "файл импорта больше 10 МиБ")
+			return
+		}
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "не удалось прочитать файл импорта: "+err.Error())
+		return
+	}
+
+	report, err := handler.ImportSubscriptions(r.Context(), rows, dryRun)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось импортировать подписки")
+		return
+	}
+
+	status := http.StatusCreated
+	switch {
+	case len(report.Errors) > 0:
+		status = http.StatusUnprocessableEntity
+	case dryRun:
+		status = http.StatusOK
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Pause godoc
+// @Summary      Приостановить подписку
+// @Description  Открывает паузу с текущего дня: оплаты внутри паузы не списываются. Доступно для активной подписки
+// @Tags         Жизненный цикл подписки
+// @Produce      json
+// @Param        id        path      int     true   "ID подписки"
+// @Param        If-Match  header    string  true   "ETag подписки, полученный при чтении"
+// @Success      200  {object}  model.SubscriptionDetails
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem  "неверный ID или If-Match"
+// @Failure      404  {object}  Problem  "подписка не найдена"
+// @Failure      409  {object}  Problem  "переход недоступен из текущего статуса"
+// @Failure      412  {object}  Problem  "подписка была изменена другим запросом"
+// @Failure      428  {object}  Problem  "не передан If-Match"
+// @Failure      500  {object}  Problem  "не удалось приостановить подписку"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/pause [post]
+func (handler *SubscriptionHandler) Pause(w http.ResponseWriter, r *http.Request) {
+	handler.transition(w, r, handler.PauseSubscription, "не удалось приостановить подписку")
+}
+
+// Resume godoc
+// @Summary      Возобновить подписку
+// @Description  Завершает паузу текущим днем; с него оплаты снова списываются. Доступно для приостановленной подписки
+// @Tags         Жизненный цикл подписки
+// @Produce      json
+// @Param        id        path      int     true   "ID подписки"
+// @Param        If-Match  header    string  true   "ETag подписки, полученный при чтении"
+// @Success      200  {object}  model.SubscriptionDetails
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem  "неверный ID или If-Match"
+// @Failure      404  {object}  Problem  "подписка не найдена"
+// @Failure      409  {object}  Problem  "переход недоступен из текущего статуса"
+// @Failure      412  {object}  Problem  "подписка была изменена другим запросом"
+// @Failure      428  {object}  Problem  "не передан If-Match"
+// @Failure      500  {object}  Problem  "не удалось возобновить подписку"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/resume [post]
+func (handler *SubscriptionHandler) Resume(w http.ResponseWriter, r *http.Request) {
+	handler.transition(w, r, handler.ResumeSubscription, "не удалось возобновить подписку")
+}
+
+// Cancel godoc
+// @Summary      Отменить подписку
+// @Description  Отменяет подписку с текущего дня: end_date сдвигается на сегодня, открытая пауза завершается. Доступно для подписки на пробном периоде, активной или приостановленной
+// @Tags         Жизненный цикл подписки
+// @Produce      json
+// @Param        id        path      int     true   "ID подписки"
+// @Param        If-Match  header    string  true   "ETag подписки, полученный при чтении"
+// @Success      200  {object}  model.SubscriptionDetails
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem  "неверный ID или If-Match"
+// @Failure      404  {object}  Problem  "подписка не найдена"
+// @Failure      409  {object}  Problem  "переход недоступен из текущего статуса"
+// @Failure      412  {object}  Problem  "подписка была изменена другим запросом"
+// @Failure      428  {object}  Problem  "не передан If-Match"
+// @Failure      500  {object}  Problem  "не удалось отменить подписку"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/cancel [post]
+func (handler *SubscriptionHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	handler.transition(w, r, handler.CancelSubscription, "не удалось отменить подписку")
+}
+
+// GetPauses godoc
+// @Summary      Паузы подписки
+// @Description  Возвращает паузы подписки в хронологическом порядке; у текущей паузы нет resumed_on
+// @Tags         Жизненный цикл подписки
+// @Produce      json
+// @Param        id   path      int  true  "ID подписки"
+// @Success      200  {array}   model.SubscriptionPause
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "подписка не найдена"
+// @Failure      500  {object}  Problem  "не удалось получить паузы подписки"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/pauses [get]
+func (handler *SubscriptionHandler) GetPauses(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	pauses, err := handler.GetSubscriptionPauses(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить паузы подписки")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(pauses)
+}
+
+// transition разбирает ID и обязательный If-Match и выполняет переход жизненного цикла
+func (handler *SubscriptionHandler) transition(
+	w http.ResponseWriter,
+	r *http.Request,
+	apply func(ctx context.Context, id int, expectedVersion *int) (*model.SubscriptionDetails, error),
+	detail string,
+) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		return
+	}
+
+	subscription, err := apply(r.Context(), id, expectedVersion)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, detail)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, subscription.Version)
+	_ = json.NewEncoder(w).Encode(subscription)
+}
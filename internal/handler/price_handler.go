@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// SchedulePriceRequest
+// Структура запроса на изменение цены подписки с будущей даты
+// (для документации)
+type SchedulePriceRequest struct {
+	Price         int                `json:"price" example:"40000" description:"Новая цена подписки в минимальных единицах ее валюты"`
+	EffectiveFrom model.DayMonthYear `json:"effective_from" example:"01-07-2025" description:"Дата, с которой действует новая цена"`
+}
+
+// SchedulePrice godoc
+// @Summary      Запланировать изменение цены подписки
+// @Description  Новая цена применяется к оплатам начиная с effective_from; прошлые оплаты считаются по прежним ценам. Повторный запрос на ту же дату заменяет запланированную цену
+// @Tags         Цены подписок
+// @Accept       json
+// @Produce      json
+// @Param        id     path      int                   true  "ID подписки"
+// @Param        price  body      SchedulePriceRequest  true  "Новая цена и дата вступления в силу"
+// @Success      201    {object}  model.SubscriptionPrice
+// @Failure      400    {object}  Problem  "неверный ID или формат запроса"
+// @Failure      404    {object}  Problem  "подписка не найдена"
+// @Failure      422    {object}  Problem  "изменение цены не прошло проверку"
+// @Failure      500    {object}  Problem  "не удалось запланировать изменение цены"
+// @Failure      503    {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/prices [post]
+func (handler *SubscriptionHandler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	var input model.SubscriptionPrice
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "проверьте правильность переданных данных")
+		return
+	}
+
+	err = handler.ScheduleSubscriptionPrice(r.Context(), id, &input)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось запланировать изменение цены")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(input)
+}
+
+// GetPrices godoc
+// @Summary      История цен подписки
+// @Description  Возвращает прошлые и запланированные изменения цены подписки в порядке вступления в силу. До первого изменения действует цена из самой подписки
+// @Tags         Цены подписок
+// @Produce      json
+// @Param        id   path      int  true  "ID подписки"
+// @Success      200  {array}   model.SubscriptionPrice
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "подписка не найдена"
+// @Failure      500  {object}  Problem  "не удалось получить историю цен"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/prices [get]
+func (handler *SubscriptionHandler) GetPrices(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	prices, err := handler.GetSubscriptionPrices(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить историю цен")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(prices)
+}
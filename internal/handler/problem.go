@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Стабильные коды ошибок API. Клиенты должны опираться на них, а не на текст detail
+const (
+	codeBadRequest           = "bad_request"
+	codeNotFound             = "not_found"
+	codeConflict             = "conflict"
+	codeValidationFailed     = "validation_failed"
+	codeIdempotencyKeyReused = "idempotency_key_reused"
+	codePreconditionFailed   = "precondition_failed"
+	codePreconditionRequired = "precondition_required"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeServiceUnavailable   = "service_unavailable"
+	codeInternalError        = "internal_error"
+)
+
+var problemTitles = map[string]string{
+	codeBadRequest:           "Некорректный запрос",
+	codeNotFound:             "Ресурс не найден",
+	codeConflict:             "Конфликт с текущим состоянием ресурса",
+	codeValidationFailed:     "Данные не прошли проверку",
+	codeIdempotencyKeyReused: "Ключ идемпотентности использован с другим запросом",
+	codePreconditionFailed:   "Ресурс был изменен другим запросом",
+	codePreconditionRequired: "Требуется условный запрос",
+	codeUnsupportedMediaType: "Неподдерживаемый формат тела запроса",
+	codeServiceUnavailable:   "Сервис временно недоступен",
+	codeInternalError:        "Внутренняя ошибка сервера",
+}
+
+// Problem
+// Описание ошибки в формате RFC 7807 (application/problem+json)
+type Problem struct {
+	Type     string             `json:"type" example:"/problems/not_found"`
+	Title    string             `json:"title" example:"Ресурс не найден"`
+	Status   int                `json:"status" example:"404"`
+	Detail   string             `json:"detail,omitempty" example:"не удалось получить подписку"`
+	Instance string             `json:"instance,omitempty" example:"/subscriptions/get/42"`
+	Code     string             `json:"code" example:"not_found"`
+	Errors   []model.FieldError `json:"errors,omitempty"`
+}
+
+// writeProblem отвечает ошибкой с явно заданными статусом и кодом
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblemBody(w, Problem{
+		Type:     "/problems/" + code,
+		Title:    problemTitles[code],
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+		Code:     code,
+	})
+}
+
+// writeError сопоставляет доменную ошибку из сервиса с HTTP-статусом и кодом.
+// detail описывает операцию и отдается клиенту вместо внутреннего текста ошибки
+func writeError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	var validationErrors model.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		problem := Problem{
+			Type:     "/problems/" + codeValidationFailed,
+			Title:    problemTitles[codeValidationFailed],
+			Status:   http.StatusUnprocessableEntity,
+			Detail:   detail,
+			Instance: r.URL.Path,
+			Code:     codeValidationFailed,
+			Errors:   validationErrors,
+		}
+		writeProblemBody(w, problem)
+		return
+	}
+
+	status, code := http.StatusInternalServerError, codeInternalError
+	switch {
+	case errors.Is(err, model.ErrVersionMismatch):
+		status, code = http.StatusPreconditionFailed, codePreconditionFailed
+	case errors.Is(err, model.ErrIdempotencyKeyReused):
+		status, code = http.StatusUnprocessableEntity, codeIdempotencyKeyReused
+	case errors.Is(err, model.ErrNotFound):
+		status, code = http.StatusNotFound, codeNotFound
+	case errors.Is(err, model.ErrConflict):
+		status, code = http.StatusConflict, codeConflict
+	case errors.Is(err, model.ErrValidation):
+		status, code = http.StatusUnprocessableEntity, codeValidationFailed
+	case errors.Is(err, model.ErrUnavailable):
+		status, code = http.StatusServiceUnavailable, codeServiceUnavailable
+	}
+
+	writeProblem(w, r, status, code, detail)
+}
+
+func writeProblemBody(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{name: "не найдено", err: model.ErrNotFound, status: http.StatusNotFound, code: codeNotFound},
+		{name: "версия не совпадает", err: model.ErrVersionMismatch, status: http.StatusPreconditionFailed, code: codePreconditionFailed},
+		{name: "ключ идемпотентности", err: model.ErrIdempotencyKeyReused, status: http.StatusUnprocessableEntity, code: codeIdempotencyKeyReused},
+		{name: "конфликт", err: model.ErrConflict, status: http.StatusConflict, code: codeConflict},
+		{name: "некорректные данные", err: model.ErrValidation, status: http.StatusUnprocessableEntity, code: codeValidationFailed},
+		{name: "хранилище недоступно", err: model.ErrUnavailable, status: http.StatusServiceUnavailable, code: codeServiceUnavailable},
+		{name: "обернутая ошибка", err: fmt.Errorf("не удалось обновить подписку: %w", model.ErrNotFound), status: http.StatusNotFound, code: codeNotFound},
+		{name: "неизвестная ошибка", err: errors.New("сбой"), status: http.StatusInternalServerError, code: codeInternalError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/subscriptions/get/42", nil)
+			writeError(w, r, test.err, "не удалось выполнить операцию")
+
+			problem := decodeProblem(t, w)
+			if w.Code != test.status || problem.Status != test.status || problem.Code != test.code {
+				t.Errorf("статус %d, problem %+v; ожидались %d и %s", w.Code, problem, test.status, test.code)
+			}
+			if problem.Type != "/problems/"+test.code || problem.Title == "" || problem.Instance != "/subscriptions/get/42" {
+				t.Errorf("problem %+v", problem)
+			}
+			if problem.Detail != "не удалось выполнить операцию" {
+				t.Errorf("detail %q, клиенту не должен уходить текст внутренней ошибки", problem.Detail)
+			}
+		})
+	}
+}
+
+func TestWriteErrorValidation(t *testing.T) {
+	var errs model.ValidationErrors
+	errs.Add("price", "negative", "цена не может быть отрицательной")
+	errs.Add("user_id", "required", "UUID пользователя обязателен")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/subscriptions/create", nil)
+	writeError(w, r, fmt.Errorf("не удалось создать подписку: %w", errs), "подписка не прошла проверку")
+
+	problem := decodeProblem(t, w)
+	if w.Code != http.StatusUnprocessableEntity || problem.Code != codeValidationFailed {
+		t.Fatalf("статус %d, код %s", w.Code, problem.Code)
+	}
+	if len(problem.Errors) != 2 || problem.Errors[0].Field != "price" || problem.Errors[1].Code != "required" {
+		t.Errorf("ошибки полей %+v", problem.Errors)
+	}
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+
+	if contentType := w.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Content-Type %q", contentType)
+	}
+	var problem Problem
+	if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+		t.Fatalf("тело ответа не problem+json: %v", err)
+	}
+	return problem
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultUpcomingWithinDays = 7
+	maxUpcomingWithinDays     = 366
+)
+
+// GetUpcoming godoc
+// @Summary      Предстоящие оплаты
+// @Description  Возвращает ближайшую оплату каждой подписки пользователя в следующие within дней, включая сегодняшний. Даты оплаты отсчитываются от start_date с учетом периода оплаты; оплаты в пробный период и на паузе пропускаются, отмененные подписки не оплачиваются
+// @Tags         Подписки
+// @Produce      json
+// @Param        user_id  query     string  true   "UUID пользователя"
+// @Param        within   query     string  false  "Горизонт в днях или неделях: 7d, 2w или число дней (по умолчанию 7d, не больше 366 дней)"
+// @Success      200      {array}   model.UpcomingRenewal
+// @Failure      400      {object}  Problem  "ошибка параметров запроса"
+// @Failure      500      {object}  Problem  "не удалось получить предстоящие оплаты"
+// @Failure      503      {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/upcoming [get]
+func (handler *SubscriptionHandler) GetUpcoming(w http.ResponseWriter, r *http.Request) {
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "обязательный параметр user_id отсутствует")
+		return
+	}
+
+	within, err := parseWithinDays(r.URL.Query().Get("within"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	renewals, err := handler.GetUpcomingRenewals(r.Context(), userID, within)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить предстоящие оплаты")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(renewals)
+}
+
+// parseWithinDays разбирает горизонт вида 7d, 2w или 10 в число дней
+func parseWithinDays(value string) (int, error) {
+	if value == "" {
+		return defaultUpcomingWithinDays, nil
+	}
+
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "d"):
+		value = strings.TrimSuffix(value, "d")
+	case strings.HasSuffix(value, "w"):
+		value = strings.TrimSuffix(value, "w")
+		multiplier = 7
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, errors.New("неверный формат within, ожидается число дней (7d) или недель (2w)")
+	}
+	if count > maxUpcomingWithinDays || count*multiplier > maxUpcomingWithinDays {
+		return 0, errors.New("within не может превышать 366 дней")
+	}
+	return count * multiplier, nil
+}
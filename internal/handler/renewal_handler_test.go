@@ -0,0 +1,29 @@
+package handler
+
+import "testing"
+
+func TestParseWithinDays(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: defaultUpcomingWithinDays},
+		{value: "10", want: 10},
+		{value: "7d", want: 7},
+		{value: "2w", want: 14},
+		{value: "0d", want: 0},
+		{value: "366d", want: 366},
+		{value: "367", wantErr: true},
+		{value: "53w", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "неделя", wantErr: true},
+		{value: "1m", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseWithinDays(test.value)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("parseWithinDays(%q) = %d, %v; ожидалось %d, ошибка %t", test.value, got, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/util"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"strings"
+)
+
+// actorHeader передает инициатора изменения, который попадает в журнал аудита
+const actorHeader = "X-Actor"
+
+// RequestMeta кладет в контекст запроса инициатора из заголовка X-Actor и ID запроса,
+// выданный middleware.RequestID, чтобы сервис мог записать их в журнал аудита
+func RequestMeta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := strings.TrimSpace(r.Header.Get(actorHeader))
+		requestID := middleware.GetReqID(r.Context())
+		if requestID != "" {
+			w.Header().Set(middleware.RequestIDHeader, requestID)
+		}
+
+		ctx := util.WithRequestMeta(r.Context(), actor, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/util"
+	"github.com/go-chi/chi/v5/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestMeta(t *testing.T) {
+	tests := []struct {
+		name      string
+		actor     string
+		wantActor string
+	}{
+		{name: "инициатор из заголовка", actor: " operator@example.com ", wantActor: "operator@example.com"},
+		{name: "без заголовка", wantActor: util.AnonymousActor},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var actor, requestID string
+			handler := middleware.RequestID(RequestMeta(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				actor = util.ActorFromContext(r.Context())
+				requestID = util.RequestIDFromContext(r.Context())
+			})))
+
+			r := httptest.NewRequest(http.MethodPost, "/subscriptions/create", nil)
+			r.Header.Set(middleware.RequestIDHeader, "req-42")
+			if test.actor != "" {
+				r.Header.Set(actorHeader, test.actor)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if actor != test.wantActor {
+				t.Errorf("инициатор %q, ожидался %q", actor, test.wantActor)
+			}
+			if requestID != "req-42" || w.Header().Get(middleware.RequestIDHeader) != "req-42" {
+				t.Errorf("ID запроса в контексте %q, в ответе %q", requestID, w.Header().Get(middleware.RequestIDHeader))
+			}
+		})
+	}
+}
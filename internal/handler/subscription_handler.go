@@ -3,14 +3,26 @@ package handler
 import (
 	"Effective_Mobile_Test_Project/internal/model"
 	"Effective_Mobile_Test_Project/internal/service"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
+const (
+	maxRequestBodySize      = 1 << 20
+	maxIdempotencyKeyLength = 255
+)
+
 type SubscriptionHandler struct {
 	*service.SubscriptionService
 }
@@ -23,11 +35,36 @@ func NewSubscriptionHandler(s *service.SubscriptionService) *SubscriptionHandler
 // Структура запроса на создание подписки для документации
 // (для документации)
 type CreateUpdateSubscriptionRequest struct {
-	ServiceName string              `json:"service_name" example:"Yandex Plus" description:"Название сервиса подписки"`
-	Price       int                 `json:"price" example:"400" description:"Цена подписки"`
-	UserID      string              `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba" description:"UUID пользователя"`
-	StartDate   model.DayMonthYear  `json:"start_date" example:"07-01-2025" description:"Дата начала подписки"`
-	EndDate     *model.DayMonthYear `json:"end_date,omitempty" example:"10-12-2027" description:"Дата окончания подписки"`
+	ServiceID       int                 `json:"service_id,omitempty" example:"1" description:"ID сервиса из каталога; важнее service_name"`
+	ServiceName     string              `json:"service_name" example:"Yandex Plus" description:"Название сервиса подписки, любое написание из каталога"`
+	Category        *string             `json:"category,omitempty" example:"streaming" description:"Категория подписки, по умолчанию категория сервиса из каталога"`
+	Tags            []string            `json:"tags,omitempty" example:"family" description:"Теги подписки"`
+	Price           int                 `json:"price" example:"40000" description:"Цена подписки в минимальных единицах валюты"`
+	Currency        string              `json:"currency,omitempty" example:"RUB" description:"Код валюты ISO 4217, по умолчанию RUB"`
+	BillingPeriod   string              `json:"billing_period,omitempty" example:"month" enums:"day,week,month,year" description:"Период оплаты, по умолчанию month"`
+	BillingInterval int                 `json:"billing_interval,omitempty" example:"1" description:"Сколько периодов между оплатами, по умолчанию 1 (3 месяца - ежеквартально)"`
+	UserID          string              `json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba" description:"UUID пользователя"`
+	StartDate       model.DayMonthYear  `json:"start_date" example:"07-01-2025" description:"Дата начала подписки"`
+	EndDate         *model.DayMonthYear `json:"end_date,omitempty" example:"10-12-2027" description:"Дата окончания подписки"`
+	TrialEnd        *model.DayMonthYear `json:"trial_end,omitempty" example:"06-02-2025" description:"Последний день пробного периода"`
+}
+
+// PatchSubscriptionRequest
+// Структура запроса на частичное обновление подписки (JSON Merge Patch)
+// (для документации)
+type PatchSubscriptionRequest struct {
+	ServiceID       *int                `json:"service_id,omitempty" example:"1" description:"ID сервиса из каталога; важнее service_name"`
+	ServiceName     *string             `json:"service_name,omitempty" example:"Yandex Plus" description:"Название сервиса подписки, любое написание из каталога"`
+	Category        *string             `json:"category,omitempty" example:"streaming" description:"Категория подписки, null возвращает категорию сервиса из каталога"`
+	Tags            []string            `json:"tags,omitempty" example:"family" description:"Теги подписки целиком, null удаляет все теги"`
+	Price           *int                `json:"price,omitempty" example:"50000" description:"Цена подписки в минимальных единицах валюты"`
+	Currency        *string             `json:"currency,omitempty" example:"RUB" description:"Код валюты ISO 4217"`
+	BillingPeriod   *string             `json:"billing_period,omitempty" example:"year" enums:"day,week,month,year" description:"Период оплаты"`
+	BillingInterval *int                `json:"billing_interval,omitempty" example:"1" description:"Сколько периодов между оплатами"`
+	UserID          *string             `json:"user_id,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba" description:"UUID пользователя"`
+	StartDate       *model.DayMonthYear `json:"start_date,omitempty" example:"07-01-2025" description:"Дата начала подписки"`
+	EndDate         *model.DayMonthYear `json:"end_date,omitempty" example:"10-12-2027" description:"Дата окончания подписки, null очищает дату"`
+	TrialEnd        *model.DayMonthYear `json:"trial_end,omitempty" example:"06-02-2025" description:"Последний день пробного периода, null очищает дату"`
 }
 
 // SubscriptionCreateResponse
@@ -35,53 +72,126 @@ type CreateUpdateSubscriptionRequest struct {
 type SubscriptionCreateResponse struct {
 	Message      string                    `json:"message" example:"подписка успешно создана"`
 	Subscription model.SubscriptionDetails `json:"subscription"`
+	Warnings     []model.BudgetWarning     `json:"warnings,omitempty"`
 }
 
 // TotalCostResponse
-// Структура для вывода инф-ии по общей стоимости всех подписок
+// Структура для вывода инф-ии по общей стоимости всех подписок; с group_by
+// стоимость дополнительно делится на группы
 type TotalCostResponse struct {
-	UserID    string `json:"user_id"`
-	TotalCost int    `json:"общая_стоимость"`
+	UserID       string            `json:"user_id"`
+	TotalCost    int               `json:"общая_стоимость"`
+	Currency     string            `json:"currency" example:"RUB"`
+	Charges      int               `json:"charges" example:"10"`
+	BilledMonths int               `json:"billed_months" example:"10"`
+	GroupBy      string            `json:"group_by,omitempty" example:"category"`
+	Groups       []model.CostGroup `json:"groups,omitempty"`
+}
+
+// CostBreakdownResponse
+// Структура для вывода помесячной стоимости подписок в разрезе сервисов, категорий или тегов
+type CostBreakdownResponse struct {
+	UserID    string              `json:"user_id"`
+	Currency  string              `json:"currency" example:"RUB"`
+	GroupBy   string              `json:"group_by" example:"service"`
+	Breakdown []model.MonthlyCost `json:"breakdown"`
 }
 
 // SubscriptionUpdateResponse
 // Структура ответа для обновления инф-ии по подписке
 type SubscriptionUpdateResponse struct {
-	Message string `json:"message" example:"подписка успешно обновлена"`
+	Message  string                `json:"message" example:"подписка успешно обновлена"`
+	Warnings []model.BudgetWarning `json:"warnings,omitempty"`
+}
+
+// SubscriptionPatchResponse
+// Структура ответа для частичного обновления подписки: подписка после изменения
+// и предупреждения о бюджетах
+type SubscriptionPatchResponse struct {
+	model.SubscriptionDetails
+	Warnings []model.BudgetWarning `json:"warnings,omitempty"`
 }
 
 // Create godoc
 // @Summary      Создание подписки
-// @Description  Создаёт новую подписку с указанием пользователя, сервиса, стоимости и периода
+// @Description  Создаёт новую подписку с указанием пользователя, сервиса, стоимости и периода. Сервис задается service_id или любым написанием из каталога в service_name; неизвестное название добавляется в каталог как новый сервис. С заголовком Idempotency-Key повторный запрос с тем же ключом и тем же содержимым возвращает сохраненный исходный ответ вместо создания дубликата; ключ действует в пределах user_id подписки, а пробелы и порядок полей в JSON на сравнение не влияют. Если подписка приведет к превышению бюджета в будущем месяце, ответ содержит warnings
 // @Tags         Подписки
 // @Accept       json
 // @Produce      json
-// @Param        subscription  body      CreateUpdateSubscriptionRequest         true  "Детали подписки"
+// @Param        Idempotency-Key  header    string                           false  "Ключ идемпотентности запроса"
+// @Param        subscription     body      CreateUpdateSubscriptionRequest  true   "Детали подписки"
 // @Success      201           {object}  SubscriptionCreateResponse
-// @Failure      400           {string}  string  "неверный формат запроса"
-// @Failure      500           {string}  string  "ошибка создания подписки"
+// @Header       201           {string}  ETag                 "версия подписки"
+// @Header       201           {string}  Idempotent-Replayed  "true, если ответ взят из сохраненного результата"
+// @Failure      400           {object}  Problem  "неверный формат запроса"
+// @Failure      422           {object}  Problem  "подписка не прошла проверку или ключ идемпотентности использован с другим запросом"
+// @Failure      500           {object}  Problem  "ошибка создания подписки"
+// @Failure      503           {object}  Problem  "хранилище временно недоступно"
 // @Router       /subscriptions/create [post]
 func (handler *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var input model.SubscriptionDetails
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBodySize))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат запроса")
+		return
+	}
 
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "неверный формат запроса", http.StatusBadRequest)
+	var input model.SubscriptionDetails
+	if err := json.Unmarshal(body, &input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат запроса")
 		return
 	}
 
-	err := handler.CreateSubscription(r.Context(), &input)
+	var response *model.StoredResponse
+	replayed := false
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if len(key) > maxIdempotencyKeyLength {
+			writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "слишком длинный Idempotency-Key")
+			return
+		}
+		response, replayed, err = handler.CreateSubscriptionIdempotent(r.Context(), key, &input, renderCreateResponse)
+	} else {
+		var warnings []model.BudgetWarning
+		if warnings, err = handler.CreateSubscription(r.Context(), &input); err == nil {
+			response, err = renderCreateResponse(&input, warnings)
+		}
+	}
 	if err != nil {
 		log.Println(err)
-		http.Error(w, "ошибка создания подписки", http.StatusInternalServerError)
+		writeError(w, r, err, "ошибка создания подписки")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(SubscriptionCreateResponse{
+	for name, value := range response.Header {
+		w.Header().Set(name, value)
+	}
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.WriteHeader(response.StatusCode)
+	_, _ = w.Write(response.Body)
+}
+
+// renderCreateResponse строит ответ на создание подписки. С ключом идемпотентности он
+// сохраняется и возвращается повторам без изменений
+func renderCreateResponse(subscription *model.SubscriptionDetails, warnings []model.BudgetWarning) (*model.StoredResponse, error) {
+	var body bytes.Buffer
+	err := json.NewEncoder(&body).Encode(SubscriptionCreateResponse{
 		Message:      "подписка успешно создана",
-		Subscription: input,
+		Subscription: *subscription,
+		Warnings:     warnings,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.StoredResponse{
+		StatusCode: http.StatusCreated,
+		Header: map[string]string{
+			"Content-Type": "application/json",
+			"ETag":         formatETag(subscription.Version),
+		},
+		Body: body.Bytes(),
+	}, nil
 }
 
 // GetByUserUUID godoc
@@ -91,7 +201,9 @@ func (handler *SubscriptionHandler) Create(w http.ResponseWriter, r *http.Reques
 // @Produce      json
 // @Param        uuid  path      string  true  "UUID пользователя"
 // @Success      200   {array}   model.SubscriptionDetails
-// @Failure      404   {string}  string  "не удалось получить подписки"
+// @Failure      422   {object}  Problem  "неверный UUID пользователя"
+// @Failure      500   {object}  Problem  "не удалось получить подписки"
+// @Failure      503   {object}  Problem  "хранилище временно недоступно"
 // @Router       /subscriptions/user/{uuid} [get]
 func (handler *SubscriptionHandler) GetByUserUUID(w http.ResponseWriter, r *http.Request) {
 	uuid := chi.URLParam(r, "uuid")
@@ -99,7 +211,7 @@ func (handler *SubscriptionHandler) GetByUserUUID(w http.ResponseWriter, r *http
 	subscriptions, err := handler.GetSubscriptionsByUserUUID(r.Context(), uuid)
 	if err != nil {
 		log.Println(err)
-		http.Error(w, "не удалось получить подписки", http.StatusNotFound)
+		writeError(w, r, err, "не удалось получить подписки")
 		return
 	}
 
@@ -107,54 +219,227 @@ func (handler *SubscriptionHandler) GetByUserUUID(w http.ResponseWriter, r *http
 	_ = json.NewEncoder(w).Encode(subscriptions)
 }
 
+// List godoc
+// @Summary      Список подписок
+// @Description  Возвращает подписки всех пользователей постранично с keyset-пагинацией. Для следующей страницы передайте next_cursor из ответа в параметре cursor, сохранив остальные параметры
+// @Tags         Подписки
+// @Produce      json
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_id    query     int     false  "ID сервиса из каталога"
+// @Param        service_name  query     string  false  "Любое написание сервиса из каталога"
+// @Param        category      query     string  false  "Категория подписки или ее сервиса"
+// @Param        tag           query     []string  false  "Тег; при нескольких tag подписка должна иметь все" collectionFormat(multi)
+// @Param        price_min     query     int     false  "Минимальная цена"
+// @Param        price_max     query     int     false  "Максимальная цена"
+// @Param        active_on     query     string  false  "Подписка активна на дату (DD-MM-YYYY)"
+// @Param        start_from    query     string  false  "Дата начала не раньше (DD-MM-YYYY)"
+// @Param        start_to      query     string  false  "Дата начала не позже (DD-MM-YYYY)"
+// @Param        end_from      query     string  false  "Дата окончания не раньше (DD-MM-YYYY)"
+// @Param        end_to        query     string  false  "Дата окончания не позже (DD-MM-YYYY)"
+// @Param        status        query     string  false  "Статус подписки на сегодня" Enums(trial, active, paused, cancelled, expired)
+// @Param        sort          query     string  false  "Поле сортировки: id, service_name, price, start_date, end_date; префикс - для убывания (по умолчанию id)"
+// @Param        limit         query     int     false  "Размер страницы (по умолчанию 50, максимум 500)"
+// @Param        cursor        query     string  false  "Курсор следующей страницы"
+// @Success      200  {object}  model.SubscriptionPage
+// @Failure      400  {object}  Problem  "ошибка параметров запроса"
+// @Failure      500  {object}  Problem  "ошибка сервера"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions [get]
+func (handler *SubscriptionHandler) List(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseListFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	page, err := handler.ListSubscriptions(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить список подписок")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// ListDeleted godoc
+// @Summary      Список удаленных подписок
+// @Description  Возвращает мягко удаленные подписки (корзину) с теми же фильтрами, сортировкой и курсором, что и GET /subscriptions. Удаленные подписки хранятся до окончательной очистки
+// @Tags         Подписки
+// @Produce      json
+// @Param        user_id       query     string  false  "UUID пользователя"
+// @Param        service_name  query     string  false  "Название сервиса"
+// @Param        sort          query     string  false  "Поле сортировки: id, service_name, price, start_date, end_date; префикс - для убывания (по умолчанию id)"
+// @Param        limit         query     int     false  "Размер страницы (по умолчанию 50, максимум 500)"
+// @Param        cursor        query     string  false  "Курсор следующей страницы"
+// @Success      200  {object}  model.SubscriptionPage
+// @Failure      400  {object}  Problem  "ошибка параметров запроса"
+// @Failure      500  {object}  Problem  "ошибка сервера"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/deleted [get]
+func (handler *SubscriptionHandler) ListDeleted(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseListFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	filter.Deleted = true
+
+	page, err := handler.ListSubscriptions(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить список удаленных подписок")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
 // GetByID godoc
 // @Summary      Получение подписки по ID
-// @Description  Возвращает подписку по её уникальному идентификатору
+// @Description  Возвращает подписку по её уникальному идентификатору. Версия подписки передается в заголовке ETag и нужна в If-Match для изменения и удаления
 // @Tags         Подписки
 // @Produce      json
 // @Param        id   path      int  true  "ID подписки"
 // @Success      200  {object}  model.SubscriptionDetails
-// @Failure      400  {string}  string  "неверный ID"
-// @Failure      404  {string}  string  "не удалось поулчить подписку"
+// @Header       200  {string}  ETag  "версия подписки"
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "подписка не найдена"
+// @Failure      500  {object}  Problem  "не удалось получить подписку"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
 // @Router       /subscriptions/get/{id} [get]
 func (handler *SubscriptionHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "неверный ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
 		return
 	}
 
 	subscription, err := handler.GetSubscriptionByID(r.Context(), id)
 	if err != nil {
 		log.Println(err)
-		http.Error(w, "не удалось поулчить подписку", http.StatusNotFound)
+		writeError(w, r, err, "не удалось получить подписку")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	setETag(w, subscription.Version)
 	_ = json.NewEncoder(w).Encode(subscription)
 }
 
 // GetTotalCost godoc
 // @Summary      Получение общей стоимости подписок пользователя
-// @Description  Возвращает сумму всех подписок пользователя с возможностью фильтрации по сервису и диапазону дат
+// @Description  Возвращает стоимость подписок пользователя за период в минимальных единицах валюты currency: складываются цены, действовавшие на каждую дату оплаты внутри периода, переведенные по курсу на эту дату. Даты оплаты отсчитываются от start_date по периоду оплаты подписки. Бессрочные подписки учитываются до конца периода. С group_by в groups возвращается та же стоимость по сервисам, категориям или тегам
 // @Tags         Подписки
 // @Produce      json
 // @Param        user_id      query     string  true   "UUID пользователя"
-// @Param        service_name query     string  false  "Название сервиса (опционально)"
+// @Param        service_name query     string  false  "Любое написание сервиса из каталога (опционально)"
 // @Param        start_date   query     string  false  "Дата начала (формат DD-MM-YYYY, по умолчанию 01-01-2000)"
-// @Param        end_date     query     string  false  "Дата окончания (формат DD-MM-YYYY)"
+// @Param        end_date     query     string  false  "Дата окончания (формат DD-MM-YYYY, по умолчанию текущая дата)"
+// @Param        category     query     string  false  "Категория подписки или ее сервиса (опционально)"
+// @Param        tag          query     string  false  "Тег подписки (опционально)"
+// @Param        currency     query     string  false  "Валюта расчета ISO 4217 (по умолчанию RUB)"
+// @Param        group_by     query     string  false  "Разделить стоимость на группы" Enums(service, category, tag)
 // @Success      200  {object}  TotalCostResponse
-// @Failure      400  {string}  string  "ошибка параметров запроса"
-// @Failure      500  {string}  string  "ошибка сервера"
+// @Failure      400  {object}  Problem  "ошибка параметров запроса"
+// @Failure      422  {object}  Problem  "неизвестная валюта или нет курса на дату оплаты"
+// @Failure      500  {object}  Problem  "ошибка сервера"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
 // @Router       /subscriptions/total-cost [get]
 func (handler *SubscriptionHandler) GetTotalCost(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseCostFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+
+	cost, err := handler.GetSubscriptionsCostByUserDetails(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить подписки")
+		return
+	}
+
+	var groups []model.CostGroup
+	if filter.GroupBy != "" {
+		groups, err = handler.GetSubscriptionsCostGroups(r.Context(), filter)
+		if err != nil {
+			log.Println(err)
+			writeError(w, r, err, "не удалось сгруппировать стоимость подписок")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(TotalCostResponse{
+		UserID:       filter.UserID,
+		TotalCost:    cost.Total,
+		Currency:     filter.Currency,
+		Charges:      cost.Charges,
+		BilledMonths: cost.BilledMonths,
+		GroupBy:      filter.GroupBy,
+		Groups:       groups,
+	})
+}
+
+// GetCostBreakdown godoc
+// @Summary      Помесячная стоимость подписок по сервисам
+// @Description  Возвращает суммы списаний по каждому календарному месяцу и сервису, категории или тегу за период. Оплата подписки с несколькими тегами входит в группу каждого тега, подписки без категории или тегов собираются в группу без ключа. Фильтры совпадают с /subscriptions/total-cost
+// @Tags         Подписки
+// @Produce      json
+// @Param        user_id      query     string  true   "UUID пользователя"
+// @Param        service_name query     string  false  "Любое написание сервиса из каталога (опционально)"
+// @Param        start_date   query     string  false  "Дата начала (формат DD-MM-YYYY, по умолчанию 01-01-2000)"
+// @Param        end_date     query     string  false  "Дата окончания (формат DD-MM-YYYY, по умолчанию текущая дата)"
+// @Param        category     query     string  false  "Категория подписки или ее сервиса (опционально)"
+// @Param        tag          query     string  false  "Тег подписки (опционально)"
+// @Param        currency     query     string  false  "Валюта расчета ISO 4217 (по умолчанию RUB)"
+// @Param        group_by     query     string  false  "Разрез группировки внутри месяца (по умолчанию service)" Enums(service, category, tag)
+// @Success      200  {object}  CostBreakdownResponse
+// @Failure      400  {object}  Problem  "ошибка параметров запроса"
+// @Failure      422  {object}  Problem  "неизвестная валюта или нет курса на дату оплаты"
+// @Failure      500  {object}  Problem  "ошибка сервера"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/cost-breakdown [get]
+func (handler *SubscriptionHandler) GetCostBreakdown(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseCostFilter(r)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	if filter.GroupBy == "" {
+		filter.GroupBy = model.CostGroupByService
+	}
+
+	breakdown, err := handler.GetSubscriptionsCostBreakdown(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить помесячную стоимость подписок")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(CostBreakdownResponse{
+		UserID:    filter.UserID,
+		Currency:  filter.Currency,
+		GroupBy:   filter.GroupBy,
+		Breakdown: breakdown,
+	})
+}
+
+// parseCostFilter разбирает параметры запроса, общие для эндпоинтов расчета стоимости.
+// Текст возвращаемой ошибки предназначен для ответа клиенту
+func parseCostFilter(r *http.Request) (model.CostFilter, error) {
 	query := r.URL.Query()
 	userID := query.Get("user_id")
 	if userID == "" {
-		http.Error(w, "обязательный параметр user_id отсутствует", http.StatusBadRequest)
-		return
+		return model.CostFilter{}, errors.New("обязательный параметр user_id отсутствует")
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return model.CostFilter{}, errors.New("неверный формат user_id, ожидается UUID")
 	}
 
 	serviceName := query.Get("service_name")
@@ -163,26 +448,22 @@ func (handler *SubscriptionHandler) GetTotalCost(w http.ResponseWriter, r *http.
 
 	var startDate model.DayMonthYear
 	var endDate model.DayMonthYear
-	var err error
 
 	if startStr != "" {
-		err = startDate.UnmarshalJSON([]byte(`"` + startStr + `"`))
-		if err != nil {
-			http.Error(w, "неверный формат start_date, ожидается DD-MM-YYYY", http.StatusBadRequest)
-			return
+		if err := startDate.UnmarshalJSON([]byte(`"` + startStr + `"`)); err != nil {
+			return model.CostFilter{}, errors.New("неверный формат start_date, ожидается DD-MM-YYYY")
 		}
 	} else {
 		startDate = model.DayMonthYear(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
 	}
 
 	if endStr != "" {
-		err = endDate.UnmarshalJSON([]byte(`"` + endStr + `"`))
-		if err != nil {
-			http.Error(w, "неверный формат end_date, ожидается DD-MM-YYYY", http.StatusBadRequest)
-			return
+		if err := endDate.UnmarshalJSON([]byte(`"` + endStr + `"`)); err != nil {
+			return model.CostFilter{}, errors.New("неверный формат end_date, ожидается DD-MM-YYYY")
 		}
 	} else {
-		endDate = model.DayMonthYear(time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC))
+		now := time.Now().UTC()
+		endDate = model.DayMonthYear(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC))
 	}
 
 	var serviceNamePtr *string
@@ -190,88 +471,367 @@ func (handler *SubscriptionHandler) GetTotalCost(w http.ResponseWriter, r *http.
 		serviceNamePtr = &serviceName
 	}
 
-	total, err := handler.GetSubscriptionsCostByUserDetails(
-		r.Context(),
-		userID,
-		serviceNamePtr,
-		startDate.ToTime(),
-		endDate.ToTime(),
-	)
-	if err != nil {
-		log.Println(err)
-		http.Error(w, "не удалось получить подписки", http.StatusInternalServerError)
-		return
+	currency := service.NormalizeCurrency(query.Get("currency"))
+	if !service.IsCurrencyCode(currency) {
+		return model.CostFilter{}, errors.New("неверный формат currency, ожидается код ISO 4217")
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(TotalCostResponse{
-		UserID:    userID,
-		TotalCost: total,
-	})
+	groupBy := query.Get("group_by")
+	if groupBy != "" && !model.CostGroupings[groupBy] {
+		return model.CostFilter{}, fmt.Errorf("группировка %q не поддерживается, ожидается service, category или tag", groupBy)
+	}
+
+	var tag *string
+	if value := strings.ToLower(strings.TrimSpace(query.Get("tag"))); value != "" {
+		tag = &value
+	}
+
+	return model.CostFilter{
+		UserID:      userID,
+		ServiceName: serviceNamePtr,
+		Category:    service.NormalizeCategory(optionalParam(query.Get("category"))),
+		Tag:         tag,
+		GroupBy:     groupBy,
+		StartPeriod: startDate.ToTime(),
+		EndPeriod:   endDate.ToTime(),
+		Currency:    currency,
+	}, nil
 }
 
 // UpdateByID godoc
 // @Summary      Обновить подписку по ID
-// @Description  Обновляет информацию о подписке по заданному ID
+// @Description  Обновляет информацию о подписке по заданному ID. Новая price исправляет цену с start_date и записывается в историю цен, запланированные изменения цены продолжают действовать со своих дат. Если подписка приведет к превышению бюджета в будущем месяце, ответ содержит warnings
 // @Tags         Подписки
 // @Accept       json
 // @Produce      json
 // @Param        id            path      int                       true  "ID подписки"
+// @Param        If-Match      header    string                    true  "ETag подписки, полученный при чтении"
 // @Param        subscription  body      CreateUpdateSubscriptionRequest true  "Обновлённые данные подписки"
 // @Success      200           {object}  SubscriptionUpdateResponse
-// @Failure      400           {string}  string  "неверный ID или формат запроса"
-// @Failure      500           {string}  string  "не удалось обновить информацию по подписке"
+// @Header       200           {string}  ETag  "новая версия подписки"
+// @Failure      400           {object}  Problem  "неверный ID или формат запроса"
+// @Failure      404           {object}  Problem  "подписка не найдена"
+// @Failure      412           {object}  Problem  "подписка была изменена другим запросом"
+// @Failure      422           {object}  Problem  "подписка не прошла проверку"
+// @Failure      428           {object}  Problem  "не передан If-Match"
+// @Failure      500           {object}  Problem  "не удалось обновить информацию по подписке"
+// @Failure      503           {object}  Problem  "хранилище временно недоступно"
 // @Router       /subscriptions/update/{id} [put]
 func (handler *SubscriptionHandler) UpdateByID(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "неверный ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, r, err)
 		return
 	}
 
 	var input model.SubscriptionDetails
-	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "проверьте правильность переданных данных", http.StatusBadRequest)
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "проверьте правильность переданных данных")
 		return
 	}
 
-	err = handler.UpdateSubscriptionByID(r.Context(), &input, id)
+	warnings, err := handler.UpdateSubscriptionByID(r.Context(), &input, id, expectedVersion)
 	if err != nil {
 		log.Println(err)
-		http.Error(w, "не удалось обновить информацию по подписке", http.StatusInternalServerError)
+		writeError(w, r, err, "не удалось обновить информацию по подписке")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	setETag(w, input.Version)
 	_ = json.NewEncoder(w).Encode(SubscriptionUpdateResponse{
-		Message: "подписка успешно обновлена",
+		Message:  "подписка успешно обновлена",
+		Warnings: warnings,
+	})
+}
+
+// PatchByID godoc
+// @Summary      Частично обновить подписку по ID
+// @Description  Применяет JSON Merge Patch (RFC 7396): меняются только переданные поля, null в end_date очищает дату окончания. price, как и в PUT, исправляет цену с start_date и записывается в историю цен. Возвращает подписку после изменения; если она приведет к превышению бюджета в будущем месяце, ответ содержит warnings
+// @Tags         Подписки
+// @Accept       json
+// @Accept       application/merge-patch+json
+// @Produce      json
+// @Param        id        path      int                       true  "ID подписки"
+// @Param        If-Match  header    string                    true  "ETag подписки, полученный при чтении"
+// @Param        patch     body      PatchSubscriptionRequest  true  "Изменяемые поля подписки"
+// @Success      200       {object}  SubscriptionPatchResponse
+// @Header       200       {string}  ETag  "новая версия подписки"
+// @Failure      400       {object}  Problem  "неверный ID или формат запроса"
+// @Failure      404       {object}  Problem  "подписка не найдена"
+// @Failure      412       {object}  Problem  "подписка была изменена другим запросом"
+// @Failure      415       {object}  Problem  "неподдерживаемый Content-Type"
+// @Failure      422       {object}  Problem  "подписка после изменения не прошла проверку"
+// @Failure      428       {object}  Problem  "не передан If-Match"
+// @Failure      500       {object}  Problem  "не удалось обновить информацию по подписке"
+// @Failure      503       {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id} [patch]
+func (handler *SubscriptionHandler) PatchByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "ожидается Content-Type application/merge-patch+json")
+			return
+		}
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		return
+	}
+
+	var patch model.SubscriptionPatch
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&patch); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "проверьте правильность переданных данных")
+		return
+	}
+
+	subscription, warnings, err := handler.PatchSubscriptionByID(r.Context(), id, patch, expectedVersion)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось обновить информацию по подписке")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, subscription.Version)
+	_ = json.NewEncoder(w).Encode(SubscriptionPatchResponse{
+		SubscriptionDetails: *subscription,
+		Warnings:            warnings,
 	})
 }
 
 // DeleteByID godoc
 // @Summary      Удалить подписку по ID
-// @Description  Удаляет подписку по её идентификатору
+// @Description  Мягко удаляет подписку по её идентификатору: она пропадает из выборок и расчетов стоимости, но ее можно восстановить до окончательной очистки
 // @Tags         Подписки
-// @Param        id   path      int  true  "ID подписки"
+// @Param        id        path      int     true  "ID подписки"
+// @Param        If-Match  header    string  true  "ETag подписки, полученный при чтении"
 // @Success      204  "подписка успешно удалена"
-// @Failure      400  {string}  string  "неверный ID"
-// @Failure      500  {string}  string  "не удалось удалить подписку"
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "подписка не найдена"
+// @Failure      412  {object}  Problem  "подписка была изменена другим запросом"
+// @Failure      428  {object}  Problem  "не передан If-Match"
+// @Failure      500  {object}  Problem  "не удалось удалить подписку"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
 // @Router       /subscriptions/delete/{id} [delete]
 func (handler *SubscriptionHandler) DeleteByID(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "неверный ID", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
 		return
 	}
 
-	err = handler.DeleteSubscriptionByID(r.Context(), id)
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		writeIfMatchError(w, r, err)
+		return
+	}
+
+	err = handler.DeleteSubscriptionByID(r.Context(), id, expectedVersion)
 	if err != nil {
 		log.Println(err)
-		http.Error(w, "не удалось удалить подписку", http.StatusInternalServerError)
+		writeError(w, r, err, "не удалось удалить подписку")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// RestoreByID godoc
+// @Summary      Восстановить удаленную подписку
+// @Description  Возвращает мягко удаленную подписку в выборки и расчеты стоимости
+// @Tags         Подписки
+// @Produce      json
+// @Param        id   path      int  true  "ID подписки"
+// @Success      200  {object}  model.SubscriptionDetails
+// @Header       200  {string}  ETag  "новая версия подписки"
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "удаленная подписка не найдена"
+// @Failure      409  {object}  Problem  "подписка не удалена"
+// @Failure      500  {object}  Problem  "не удалось восстановить подписку"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/restore [post]
+func (handler *SubscriptionHandler) RestoreByID(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	subscription, err := handler.RestoreSubscriptionByID(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось восстановить подписку")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	setETag(w, subscription.Version)
+	_ = json.NewEncoder(w).Encode(subscription)
+}
+
+// GetHistory godoc
+// @Summary      История изменений подписки
+// @Description  Возвращает журнал создания, изменений, удаления и восстановления подписки в порядке записи
+// @Tags         Подписки
+// @Produce      json
+// @Param        id   path      int  true  "ID подписки"
+// @Success      200  {array}   model.SubscriptionAuditRecord
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "история подписки не найдена"
+// @Failure      500  {object}  Problem  "не удалось получить историю подписки"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /subscriptions/{id}/history [get]
+func (handler *SubscriptionHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	history, err := handler.GetSubscriptionHistory(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить историю подписки")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(history)
+}
+
+// parseListFilter разбирает параметры постраничного списка подписок.
+// Текст возвращаемой ошибки предназначен для ответа клиенту
+func parseListFilter(r *http.Request) (model.SubscriptionListFilter, error) {
+	query := r.URL.Query()
+	filter := model.SubscriptionListFilter{
+		Sort:  "id",
+		Limit: model.DefaultListLimit,
+	}
+
+	if userID := query.Get("user_id"); userID != "" {
+		if _, err := uuid.Parse(userID); err != nil {
+			return filter, errors.New("неверный формат user_id, ожидается UUID")
+		}
+		filter.UserID = &userID
+	}
+	if serviceName := query.Get("service_name"); serviceName != "" {
+		filter.ServiceName = &serviceName
+	}
+	filter.Category = service.NormalizeCategory(optionalParam(query.Get("category")))
+	if tags := service.NormalizeTags(query["tag"]); len(tags) > 0 {
+		if tags[0] == "" {
+			return filter, errors.New("tag не может быть пустым")
+		}
+		filter.Tags = tags
+	}
+	if status := query.Get("status"); status != "" {
+		if !model.SubscriptionStatuses[status] {
+			return filter, fmt.Errorf("неизвестный статус %q", status)
+		}
+		filter.Status = &status
+	}
+
+	var err error
+	if filter.ServiceID, err = parseIntParam(query.Get("service_id"), "service_id"); err != nil {
+		return filter, err
+	}
+	if filter.PriceMin, err = parseIntParam(query.Get("price_min"), "price_min"); err != nil {
+		return filter, err
+	}
+	if filter.PriceMax, err = parseIntParam(query.Get("price_max"), "price_max"); err != nil {
+		return filter, err
+	}
+
+	dates := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"active_on", &filter.ActiveOn},
+		{"start_from", &filter.StartFrom},
+		{"start_to", &filter.StartTo},
+		{"end_from", &filter.EndFrom},
+		{"end_to", &filter.EndTo},
+	}
+	for _, date := range dates {
+		value := query.Get(date.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse("02-01-2006", value)
+		if err != nil {
+			return filter, fmt.Errorf("неверный формат %s, ожидается DD-MM-YYYY", date.name)
+		}
+		*date.target = &parsed
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		filter.Desc = strings.HasPrefix(sort, "-")
+		filter.Sort = strings.TrimPrefix(sort, "-")
+		if !model.SubscriptionSortFields[filter.Sort] {
+			return filter, fmt.Errorf("сортировка по полю %q не поддерживается", filter.Sort)
+		}
+	}
+
+	limit, err := parseIntParam(query.Get("limit"), "limit")
+	if err != nil {
+		return filter, err
+	}
+	if limit != nil {
+		if *limit < 1 || *limit > model.MaxListLimit {
+			return filter, fmt.Errorf("limit должен быть от 1 до %d", model.MaxListLimit)
+		}
+		filter.Limit = *limit
+	}
+
+	if encoded := query.Get("cursor"); encoded != "" {
+		cursor, err := model.DecodeListCursor(encoded)
+		if err != nil {
+			return filter, errors.New("неверный cursor")
+		}
+		if cursor.Sort != filter.Sort || cursor.Desc != filter.Desc {
+			return filter, errors.New("cursor получен для другой сортировки")
+		}
+		filter.After = cursor
+	}
+
+	return filter, nil
+}
+
+// optionalParam возвращает nil для незаданного параметра запроса
+func optionalParam(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func parseIntParam(value, name string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("неверный формат %s, ожидается целое число", name)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCostFilter(t *testing.T) {
+	const userID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		query       string
+		serviceName string
+		start, end  time.Time
+		invalid     bool
+	}{
+		{
+			name:  "период по умолчанию",
+			query: "user_id=" + userID,
+			start: time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), end: today,
+		},
+		{
+			name:        "все параметры",
+			query:       "user_id=" + userID + "&service_name=Yandex+Plus&start_date=01-02-2025&end_date=30-04-2025",
+			serviceName: "Yandex Plus",
+			start:       time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+			end:         time.Date(2025, time.April, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{name: "без user_id", query: "start_date=01-02-2025", invalid: true},
+		{name: "user_id не UUID", query: "user_id=60601fee", invalid: true},
+		{name: "неверная дата начала", query: "user_id=" + userID + "&start_date=2025-02-01", invalid: true},
+		{name: "неверная дата окончания", query: "user_id=" + userID + "&end_date=31-02", invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := parseCostFilter(httptest.NewRequest(http.MethodGet, "/subscriptions/total-cost?"+test.query, nil))
+			if test.invalid {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получен фильтр %+v", filter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCostFilter: %v", err)
+			}
+
+			if filter.UserID != userID {
+				t.Errorf("user_id %q, ожидался %q", filter.UserID, userID)
+			}
+			serviceName := ""
+			if filter.ServiceName != nil {
+				serviceName = *filter.ServiceName
+			}
+			if serviceName != test.serviceName {
+				t.Errorf("service_name %q, ожидался %q", serviceName, test.serviceName)
+			}
+			if !filter.StartPeriod.Equal(test.start) || !filter.EndPeriod.Equal(test.end) {
+				t.Errorf("период %s - %s, ожидался %s - %s", filter.StartPeriod, filter.EndPeriod, test.start, test.end)
+			}
+		})
+	}
+}
+
+func TestParseCostFilterGrouping(t *testing.T) {
+	const userID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+	r := httptest.NewRequest(http.MethodGet, "/subscriptions/total-cost?user_id="+userID+"&group_by=tag&category=+Streaming+&tag=+Семья+", nil)
+	filter, err := parseCostFilter(r)
+	if err != nil {
+		t.Fatalf("parseCostFilter: %v", err)
+	}
+	if filter.GroupBy != model.CostGroupByTag {
+		t.Errorf("group_by %q, ожидался %q", filter.GroupBy, model.CostGroupByTag)
+	}
+	if filter.Category == nil || *filter.Category != "streaming" || filter.Tag == nil || *filter.Tag != "семья" {
+		t.Errorf("категория %v и тег %v должны быть приведены к нижнему регистру", filter.Category, filter.Tag)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/subscriptions/total-cost?user_id="+userID+"&group_by=month", nil)
+	if _, err := parseCostFilter(r); err == nil {
+		t.Error("неизвестная группировка должна вернуть ошибку")
+	}
+}
+
+func TestParseListFilter(t *testing.T) {
+	priceCursor := model.ListCursor{Sort: "price", Desc: true, Value: "400", ID: 7}
+
+	tests := []struct {
+		name    string
+		query   string
+		check   func(t *testing.T, filter model.SubscriptionListFilter)
+		invalid bool
+	}{
+		{
+			name:  "по умолчанию",
+			query: "",
+			check: func(t *testing.T, filter model.SubscriptionListFilter) {
+				if filter.Sort != "id" || filter.Desc || filter.Limit != model.DefaultListLimit || filter.After != nil {
+					t.Errorf("фильтр по умолчанию %+v", filter)
+				}
+			},
+		},
+		{
+			name:  "фильтры",
+			query: "user_id=60601fee-2bf1-4721-ae6f-7636e79a0cba&service_name=Yandex&price_min=100&price_max=500&active_on=15-03-2025&end_to=31-12-2025",
+			check: func(t *testing.T, filter model.SubscriptionListFilter) {
+				if filter.UserID == nil || *filter.UserID != "60601fee-2bf1-4721-ae6f-7636e79a0cba" ||
+					filter.ServiceName == nil || *filter.ServiceName != "Yandex" ||
+					filter.PriceMin == nil || *filter.PriceMin != 100 || filter.PriceMax == nil || *filter.PriceMax != 500 {
+					t.Errorf("фильтры разобраны неверно: %+v", filter)
+				}
+				if filter.ActiveOn == nil || !filter.ActiveOn.Equal(time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("active_on %v", filter.ActiveOn)
+				}
+				if filter.EndTo == nil || filter.StartFrom != nil || filter.StartTo != nil || filter.EndFrom != nil {
+					t.Errorf("даты разобраны неверно: %+v", filter)
+				}
+			},
+		},
+		{
+			name:  "сортировка по убыванию с курсором",
+			query: "sort=-price&limit=500&cursor=" + priceCursor.Encode(),
+			check: func(t *testing.T, filter model.SubscriptionListFilter) {
+				if filter.Sort != "price" || !filter.Desc || filter.Limit != model.MaxListLimit {
+					t.Errorf("сортировка %s, desc %v, limit %d", filter.Sort, filter.Desc, filter.Limit)
+				}
+				if filter.After == nil || *filter.After != priceCursor {
+					t.Errorf("курсор %+v, ожидался %+v", filter.After, priceCursor)
+				}
+			},
+		},
+		{name: "user_id не UUID", query: "user_id=user-1", invalid: true},
+		{name: "цена не число", query: "price_min=сто", invalid: true},
+		{name: "неверная дата", query: "start_from=2025-01-01", invalid: true},
+		{name: "неизвестное поле сортировки", query: "sort=user_id", invalid: true},
+		{name: "нулевой limit", query: "limit=0", invalid: true},
+		{name: "limit больше максимума", query: "limit=501", invalid: true},
+		{name: "неверный курсор", query: "cursor=abc", invalid: true},
+		{name: "курсор другой сортировки", query: "sort=price&cursor=" + priceCursor.Encode(), invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filter, err := parseListFilter(httptest.NewRequest(http.MethodGet, "/subscriptions?"+test.query, nil))
+			if test.invalid {
+				if err == nil {
+					t.Fatalf("ожидалась ошибка, получен фильтр %+v", filter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseListFilter: %v", err)
+			}
+			test.check(t, filter)
+		})
+	}
+}
+
+func TestInvalidUserIDIsBadRequest(t *testing.T) {
+	handler := &SubscriptionHandler{}
+	tests := []struct {
+		name   string
+		target string
+		serve  http.HandlerFunc
+	}{
+		{name: "список", target: "/subscriptions?user_id=user-1", serve: handler.List},
+		{name: "общая стоимость", target: "/subscriptions/total-cost?user_id=user-1", serve: handler.GetTotalCost},
+		{name: "помесячная стоимость", target: "/subscriptions/cost-breakdown?user_id=user-1", serve: handler.GetCostBreakdown},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			test.serve(w, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			problem := decodeProblem(t, w)
+			if w.Code != http.StatusBadRequest || problem.Code != codeBadRequest {
+				t.Errorf("статус %d, код %s; ожидался 400 %s", w.Code, problem.Code, codeBadRequest)
+			}
+		})
+	}
+}
+
+func TestUpdateBodyErrors(t *testing.T) {
+	handler := &SubscriptionHandler{}
+	tooLarge := `{"service_name": "` + strings.Repeat("a", maxRequestBodySize) + `"}`
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		serve  http.HandlerFunc
+	}{
+		{name: "PUT больше лимита", method: http.MethodPut, body: tooLarge, serve: handler.UpdateByID},
+		{name: "PATCH больше лимита", method: http.MethodPatch, body: tooLarge, serve: handler.PatchByID},
+		{name: "PATCH с неверным JSON", method: http.MethodPatch, body: `{"price": "сто"}`, serve: handler.PatchByID},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/subscriptions/1", strings.NewReader(test.body))
+			r.Header.Set("If-Match", `"1"`)
+			routeContext := chi.NewRouteContext()
+			routeContext.URLParams.Add("id", "1")
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeContext))
+
+			w := httptest.NewRecorder()
+			test.serve(w, r)
+
+			problem := decodeProblem(t, w)
+			if w.Code != http.StatusBadRequest || problem.Detail != "проверьте правильность переданных данных" {
+				t.Errorf("статус %d, detail %q", w.Code, problem.Detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/service"
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+type WebhookHandler struct {
+	*service.WebhookService
+}
+
+func NewWebhookHandler(s *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{s}
+}
+
+// WebhookRequest
+// Структура запроса на создание и изменение вебхука
+// (для документации)
+type WebhookRequest struct {
+	URL        string   `json:"url" example:"https://example.com/hooks/subscriptions" description:"Адрес получателя событий"`
+	Secret     string   `json:"secret,omitempty" example:"3f1c9a0e5b7d4c2a8e6f1b3d5a7c9e0f" description:"Секрет подписи HMAC-SHA256, от 16 символов; при создании без секрета он генерируется, при изменении остается прежний"`
+	EventTypes []string `json:"event_types" example:"subscription.created" description:"Типы событий: subscription.created, subscription.updated, subscription.deleted, subscription.renewal_due"`
+	Active     *bool    `json:"active,omitempty" example:"true" description:"Доставлять ли события, по умолчанию true"`
+}
+
+// CreateWebhook godoc
+// @Summary      Создать вебхук
+// @Description  Подписывает URL на события подписок. Каждая доставка - POST с телом события и заголовками X-Webhook-Event, X-Webhook-Event-ID, X-Webhook-Delivery, X-Webhook-Timestamp и X-Webhook-Signature: sha256=HMAC-SHA256 секретом от строки "<timestamp>.<тело>". URL должен указывать на публичный адрес: localhost и внутренние адреса отклоняются, если не включен webhookConfig.allowPrivateNetworks. Секрет возвращается только в этом ответе
+// @Tags         Вебхуки
+// @Accept       json
+// @Produce      json
+// @Param        webhook  body      WebhookRequest  true  "Вебхук"
+// @Success      201      {object}  model.Webhook
+// @Failure      400      {object}  Problem  "неверный формат запроса"
+// @Failure      422      {object}  Problem  "вебхук не прошел проверку"
+// @Failure      500      {object}  Problem  "не удалось создать вебхук"
+// @Failure      503      {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks [post]
+func (handler *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	input := model.Webhook{Active: true}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный формат запроса")
+		return
+	}
+
+	if err := handler.WebhookService.CreateWebhook(r.Context(), &input); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось создать вебхук")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(input)
+}
+
+// ListWebhooks godoc
+// @Summary      Список вебхуков
+// @Description  Возвращает все вебхуки без секретов
+// @Tags         Вебхуки
+// @Produce      json
+// @Success      200  {array}   model.Webhook
+// @Failure      500  {object}  Problem  "не удалось получить вебхуки"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks [get]
+func (handler *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := handler.WebhookService.ListWebhooks(r.Context())
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить вебхуки")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(webhooks)
+}
+
+// GetWebhook godoc
+// @Summary      Получить вебхук
+// @Description  Возвращает вебхук по его идентификатору без секрета
+// @Tags         Вебхуки
+// @Produce      json
+// @Param        id   path      int  true  "ID вебхука"
+// @Success      200  {object}  model.Webhook
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "вебхук не найден"
+// @Failure      500  {object}  Problem  "не удалось получить вебхук"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks/{id} [get]
+func (handler *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	webhook, err := handler.GetWebhookByID(r.Context(), id)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить вебхук")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary      Изменить вебхук
+// @Description  Перезаписывает вебхук целиком. Без secret в запросе остается прежний секрет; active=false приостанавливает доставку новых событий
+// @Tags         Вебхуки
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int             true  "ID вебхука"
+// @Param        webhook  body      WebhookRequest  true  "Вебхук"
+// @Success      200      {object}  model.Webhook
+// @Failure      400      {object}  Problem  "неверный ID или формат запроса"
+// @Failure      404      {object}  Problem  "вебхук не найден"
+// @Failure      422      {object}  Problem  "вебхук не прошел проверку"
+// @Failure      500      {object}  Problem  "не удалось обновить вебхук"
+// @Failure      503      {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks/{id} [put]
+func (handler *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	input := model.Webhook{Active: true}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&input); err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "проверьте правильность переданных данных")
+		return
+	}
+
+	if err := handler.UpdateWebhookByID(r.Context(), &input, id); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось обновить вебхук")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(input)
+}
+
+// DeleteWebhook godoc
+// @Summary      Удалить вебхук
+// @Description  Удаляет вебхук вместе с журналом его доставок
+// @Tags         Вебхуки
+// @Param        id   path  int  true  "ID вебхука"
+// @Success      204  "вебхук удален"
+// @Failure      400  {object}  Problem  "неверный ID"
+// @Failure      404  {object}  Problem  "вебхук не найден"
+// @Failure      500  {object}  Problem  "не удалось удалить вебхук"
+// @Failure      503  {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks/{id} [delete]
+func (handler *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	if err := handler.DeleteWebhookByID(r.Context(), id); err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось удалить вебхук")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries godoc
+// @Summary      Журнал доставок вебхука
+// @Description  Возвращает доставки событий вебхуку, начиная с последних. Доставка в статусе dead исчерпала попытки и ждет ручного повтора
+// @Tags         Вебхуки
+// @Produce      json
+// @Param        id      path      int     true   "ID вебхука"
+// @Param        status  query     string  false  "Статус доставки" Enums(pending, delivered, dead)
+// @Param        limit   query     int     false  "Сколько доставок вернуть (по умолчанию 100, не больше 1000)"
+// @Success      200     {array}   model.WebhookDelivery
+// @Failure      400     {object}  Problem  "ошибка параметров запроса"
+// @Failure      404     {object}  Problem  "вебхук не найден"
+// @Failure      500     {object}  Problem  "не удалось получить журнал доставок"
+// @Failure      503     {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks/{id}/deliveries [get]
+func (handler *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return
+	}
+
+	filter := model.WebhookDeliveryFilter{WebhookID: id}
+	query := r.URL.Query()
+	if status := query.Get("status"); status != "" {
+		if status != model.DeliveryStatusPending && status != model.DeliveryStatusDelivered && status != model.DeliveryStatusDead {
+			writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "status должен быть одним из: pending, delivered, dead")
+			return
+		}
+		filter.Status = &status
+	}
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 {
+			writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "limit должен быть положительным числом")
+			return
+		}
+	}
+
+	deliveries, err := handler.GetWebhookDeliveries(r.Context(), filter)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить журнал доставок")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(deliveries)
+}
+
+// GetDeliveryAttempts godoc
+// @Summary      Попытки доставки события
+// @Description  Возвращает все попытки доставки: код ответа получателя или ошибку соединения и длительность запроса
+// @Tags         Вебхуки
+// @Produce      json
+// @Param        id           path      int  true  "ID вебхука"
+// @Param        delivery_id  path      int  true  "ID доставки"
+// @Success      200          {array}   model.WebhookDeliveryAttempt
+// @Failure      400          {object}  Problem  "неверный ID"
+// @Failure      404          {object}  Problem  "попыток доставки не найдено"
+// @Failure      500          {object}  Problem  "не удалось получить попытки доставки"
+// @Failure      503          {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/attempts [get]
+func (handler *WebhookHandler) GetDeliveryAttempts(w http.ResponseWriter, r *http.Request) {
+	id, deliveryID, ok := parseDeliveryPath(w, r)
+	if !ok {
+		return
+	}
+
+	attempts, err := handler.GetWebhookDeliveryAttempts(r.Context(), id, deliveryID)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось получить попытки доставки")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(attempts)
+}
+
+// Redeliver godoc
+// @Summary      Повторить доставку
+// @Description  Возвращает доставку, в том числе из статуса dead, в очередь с немедленной попыткой и сбрасывает счетчик попыток
+// @Tags         Вебхуки
+// @Produce      json
+// @Param        id           path      int  true  "ID вебхука"
+// @Param        delivery_id  path      int  true  "ID доставки"
+// @Success      200          {object}  model.WebhookDelivery
+// @Failure      400          {object}  Problem  "неверный ID"
+// @Failure      404          {object}  Problem  "доставка не найдена"
+// @Failure      500          {object}  Problem  "не удалось повторить доставку"
+// @Failure      503          {object}  Problem  "хранилище временно недоступно"
+// @Router       /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (handler *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, deliveryID, ok := parseDeliveryPath(w, r)
+	if !ok {
+		return
+	}
+
+	delivery, err := handler.RedeliverWebhookDelivery(r.Context(), id, deliveryID)
+	if err != nil {
+		log.Println(err)
+		writeError(w, r, err, "не удалось повторить доставку")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(delivery)
+}
+
+func parseDeliveryPath(w http.ResponseWriter, r *http.Request) (int, int64, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID")
+		return 0, 0, false
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "delivery_id"), 10, 64)
+	if err != nil {
+		writeProblem(w, r, http.StatusBadRequest, codeBadRequest, "неверный ID доставки")
+		return 0, 0, false
+	}
+	return id, deliveryID, true
+}
@@ -0,0 +1,63 @@
+package model
+
+// Области, на которые задается месячный бюджет: все подписки пользователя,
+// подписки одной категории или одного сервиса каталога
+const (
+	BudgetScopeTotal    = "total"
+	BudgetScopeCategory = "category"
+	BudgetScopeService  = "service"
+)
+
+// Budget
+// Месячный бюджет пользователя в минимальных единицах валюты Currency. Category
+// задается только для области category, ServiceID - только для области service;
+// ServiceName - каноническое название сервиса, только для чтения
+type Budget struct {
+	ID          int     `db:"id" json:"id"`
+	UserID      string  `db:"user_id" json:"user_id" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Scope       string  `db:"scope" json:"scope" example:"category" enums:"total,category,service"`
+	Category    *string `db:"category" json:"category,omitempty" example:"streaming"`
+	ServiceID   *int    `db:"service_id" json:"service_id,omitempty"`
+	ServiceName *string `db:"service_name" json:"service_name,omitempty"`
+	Amount      int     `db:"amount" json:"amount" example:"150000"`
+	Currency    string  `db:"currency" json:"currency" example:"RUB"`
+}
+
+// MonthlySpend
+// Траты в области бюджета за календарный месяц; SubscriptionSpent - доля одной
+// подписки, на которую проверяется превышение
+type MonthlySpend struct {
+	Month             MonthYear `db:"month"`
+	Spent             int       `db:"spent"`
+	SubscriptionSpent int       `db:"subscription_spent"`
+}
+
+// BudgetMonth
+// Сравнение трат месяца с бюджетом. Отрицательный Remaining - сумма перерасхода
+type BudgetMonth struct {
+	Month     MonthYear `json:"month"`
+	Budget    int       `json:"budget" example:"150000"`
+	Spent     int       `json:"spent" example:"120000"`
+	Remaining int       `json:"remaining" example:"30000"`
+	Overspent bool      `json:"overspent" example:"false"`
+}
+
+// BudgetReport
+// Помесячное исполнение одного бюджета за период
+type BudgetReport struct {
+	Budget Budget        `json:"budget"`
+	Months []BudgetMonth `json:"months"`
+}
+
+// BudgetWarning
+// Предупреждение о том, что после создания или изменения подписки траты будущего
+// месяца превысят бюджет. Сохранению подписки предупреждение не мешает
+type BudgetWarning struct {
+	BudgetID int       `json:"budget_id"`
+	Scope    string    `json:"scope" example:"category"`
+	Month    MonthYear `json:"month"`
+	Budget   int       `json:"budget" example:"150000"`
+	Spent    int       `json:"spent" example:"180000"`
+	Currency string    `json:"currency" example:"RUB"`
+	Message  string    `json:"message" example:"траты за 03-2026 превысят бюджет категории streaming"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// CatalogService
+// Сервис из каталога. Name - каноническое название, под которым сервис показывается
+// в подписках и расчетах стоимости; Aliases - другие написания, по которым он находится.
+// DefaultPrice задается в минимальных единицах валюты Currency
+type CatalogService struct {
+	ID           int      `db:"id" json:"id"`
+	Name         string   `db:"name" json:"name" example:"Yandex Plus"`
+	Aliases      []string `db:"-" json:"aliases" example:"Яндекс Плюс"`
+	Category     *string  `db:"category" json:"category,omitempty" example:"streaming"`
+	DefaultPrice *int     `db:"default_price" json:"default_price,omitempty" example:"39900"`
+	Currency     string   `db:"currency" json:"currency" example:"RUB"`
+	LogoURL      *string  `db:"logo_url" json:"logo_url,omitempty" example:"https://example.com/yandex-plus.png"`
+}
+
+// CatalogServiceFilter
+// Фильтры списка сервисов каталога; nil-поле не ограничивает выборку.
+// Query ищется по всем написаниям сервиса без учета регистра и знаков препинания
+type CatalogServiceFilter struct {
+	Category *string
+	Query    *string
+}
@@ -0,0 +1,20 @@
+package model
+
+import "errors"
+
+// Типы доменных ошибок. Репозитории и сервисы оборачивают в них причину через %w,
+// а обработчики по errors.Is выбирают HTTP-ответ, не разбирая ошибки БД
+var (
+	ErrNotFound    = errors.New("не найдено")
+	ErrConflict    = errors.New("конфликт с текущим состоянием")
+	ErrValidation  = errors.New("данные не прошли проверку")
+	ErrUnavailable = errors.New("хранилище временно недоступно")
+)
+
+// ErrVersionMismatch возвращается, когда подписка изменилась после того,
+// как клиент получил ее версию (ETag)
+var ErrVersionMismatch = errors.New("версия подписки не совпадает")
+
+// ErrIdempotencyKeyReused возвращается, когда ключ идемпотентности
+// повторно использован с другим телом запроса
+var ErrIdempotencyKeyReused = errors.New("ключ идемпотентности уже использован с другим запросом")
@@ -0,0 +1,26 @@
+package model
+
+// Currency
+// Валюта из справочника: код ISO 4217 и число знаков минимальной единицы
+type Currency struct {
+	Code       string `db:"code" json:"code" example:"RUB"`
+	MinorUnits int    `db:"minor_units" json:"minor_units" example:"2"`
+	Name       string `db:"name" json:"name" example:"Российский рубль"`
+}
+
+// ExchangeRate
+// Курс на дату: 1 единица BaseCurrency стоит Rate единиц QuoteCurrency.
+// Курс действует с RateDate до следующего курса той же пары
+type ExchangeRate struct {
+	BaseCurrency  string       `db:"base_currency" json:"base_currency" example:"USD"`
+	QuoteCurrency string       `db:"quote_currency" json:"quote_currency" example:"RUB"`
+	RateDate      DayMonthYear `db:"rate_date" json:"rate_date"`
+	Rate          float64      `db:"rate" json:"rate" example:"92.5"`
+}
+
+// ExchangeRateFilter
+// Фильтры списка курсов; nil-поле не ограничивает выборку
+type ExchangeRateFilter struct {
+	BaseCurrency  *string
+	QuoteCurrency *string
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// IdempotencyRecord
+// Сохраненный результат первого запроса с ключом идемпотентности. Ключ действует
+// в пределах Scope. Пока запрос не завершился, StatusCode и Response пустые
+type IdempotencyRecord struct {
+	Scope           string    `db:"scope"`
+	Key             string    `db:"key"`
+	RequestHash     string    `db:"request_hash"`
+	StatusCode      *int      `db:"status_code"`
+	ResponseHeaders []byte    `db:"response_headers"`
+	Response        []byte    `db:"response"`
+	ExpiresAt       time.Time `db:"expires_at"`
+}
+
+// StoredResponse
+// Ответ на запрос с ключом идемпотентности: первый запрос сохраняет его,
+// а повторы возвращают байт в байт
+type StoredResponse struct {
+	StatusCode int
+	Header     map[string]string
+	Body       []byte
+}
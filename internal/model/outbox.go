@@ -0,0 +1,56 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Типы событий подписок. Восстановление, изменение цены и переходы жизненного цикла
+// публикуются как subscription.updated; subscription.renewal_due - доставленное
+// напоминание о предстоящей оплате
+const (
+	EventSubscriptionCreated    = "subscription.created"
+	EventSubscriptionUpdated    = "subscription.updated"
+	EventSubscriptionDeleted    = "subscription.deleted"
+	EventSubscriptionRenewalDue = "subscription.renewal_due"
+)
+
+// SubscriptionEvent
+// Тело события подписки. ID - ID события, по нему получатели отбрасывают повторы;
+// Subscription - снимок после изменения, а при удалении - до него
+type SubscriptionEvent struct {
+	ID           int64           `json:"id"`
+	Type         string          `json:"type" example:"subscription.updated"`
+	Action       string          `json:"action" example:"update"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Subscription json.RawMessage `json:"subscription" swaggertype:"object"`
+}
+
+// ReminderEvent
+// Тело события subscription.renewal_due. ID - ID события, Reminder.ID - ID напоминания:
+// на одну дату оплаты подписки публикуется одно событие
+type ReminderEvent struct {
+	ID         int64                `json:"id"`
+	Type       string               `json:"type" example:"subscription.renewal_due"`
+	OccurredAt time.Time            `json:"occurred_at"`
+	Reminder   SubscriptionReminder `json:"reminder"`
+}
+
+// Состояния события outbox. dead - попытки публикации исчерпаны
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+// OutboxEvent
+// Событие, записанное в outbox в транзакции породившего его изменения. EventID -
+// ID события для получателей, по нему они отбрасывают повторы публикации
+type OutboxEvent struct {
+	ID        int64           `db:"id"`
+	EventID   int64           `db:"event_id"`
+	Type      string          `db:"event_type"`
+	Payload   json.RawMessage `db:"payload"`
+	Attempts  int             `db:"attempts"`
+	CreatedAt time.Time       `db:"created_at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate      = "create"
+	AuditActionUpdate      = "update"
+	AuditActionDelete      = "delete"
+	AuditActionRestore     = "restore"
+	AuditActionPriceChange = "price_change"
+	AuditActionPause       = "pause"
+	AuditActionResume      = "resume"
+	AuditActionCancel      = "cancel"
+)
+
+// SubscriptionAuditRecord
+// Запись журнала изменений подписки: снимки до и после изменения, кто и в рамках
+// какого запроса его сделал. Before пуст при создании, After - при удалении
+type SubscriptionAuditRecord struct {
+	ID             int64           `db:"id" json:"id"`
+	SubscriptionID int             `db:"subscription_id" json:"subscription_id"`
+	Action         string          `db:"action" json:"action" example:"update"`
+	Before         json.RawMessage `db:"before" json:"before,omitempty" swaggertype:"object"`
+	After          json.RawMessage `db:"after" json:"after,omitempty" swaggertype:"object"`
+	Actor          string          `db:"actor" json:"actor" example:"operator@example.com"`
+	RequestID      *string         `db:"request_id" json:"request_id,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+}
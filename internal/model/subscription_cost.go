@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// Разрезы, по которым группируются расчеты стоимости подписок. Оплата подписки
+// с несколькими тегами попадает в группу каждого тега
+const (
+	CostGroupByService  = "service"
+	CostGroupByCategory = "category"
+	CostGroupByTag      = "tag"
+)
+
+// CostGroupings
+// Допустимые значения параметра group_by
+var CostGroupings = map[string]bool{
+	CostGroupByService:  true,
+	CostGroupByCategory: true,
+	CostGroupByTag:      true,
+}
+
+// CostFilter
+// Фильтры, общие для всех расчетов стоимости подписок. Суммы считаются
+// в минимальных единицах валюты Currency. GroupBy задает разрез группировки,
+// пустой GroupBy в общей стоимости означает расчет без групп
+type CostFilter struct {
+	UserID      string
+	ServiceName *string
+	Category    *string
+	Tag         *string
+	StartPeriod time.Time
+	EndPeriod   time.Time
+	Currency    string
+	GroupBy     string
+}
+
+// SubscriptionCost
+// Итоговая стоимость подписок за период, количество оплат и оплаченных месяцев,
+// из которых она сложилась
+type SubscriptionCost struct {
+	Total        int `db:"total" json:"total"`
+	Charges      int `db:"charges" json:"charges"`
+	BilledMonths int `db:"billed_months" json:"billed_months"`
+}
+
+// CostGroup
+// Стоимость подписок одной группы за период. Заполнено только поле разреза
+// группировки; nil в Category или Tag - группа подписок без категории или тегов
+type CostGroup struct {
+	ServiceName string  `db:"service_name" json:"service_name,omitempty"`
+	Category    *string `db:"category" json:"category,omitempty"`
+	Tag         *string `db:"tag" json:"tag,omitempty"`
+	Total       int     `db:"total" json:"total"`
+	Charges     int     `db:"charges" json:"charges"`
+}
+
+// MonthlyCost
+// Сумма, списанная за подписки одной группы в течение календарного месяца.
+// Группа задается так же, как в CostGroup
+type MonthlyCost struct {
+	Month       MonthYear `db:"month" json:"month"`
+	ServiceName string    `db:"service_name" json:"service_name,omitempty"`
+	Category    *string   `db:"category" json:"category,omitempty"`
+	Tag         *string   `db:"tag" json:"tag,omitempty"`
+	Amount      int       `db:"amount" json:"amount"`
+}
@@ -1,17 +1,49 @@
 package model
 
 import (
+	"fmt"
 	"strings"
 	"time"
 )
 
+// DefaultCurrency
+// Валюта подписок и расчетов стоимости, когда она не указана явно
+const DefaultCurrency = "RUB"
+
+// Периоды оплаты подписки. Оплата списывается каждые BillingInterval периодов,
+// начиная со StartDate
+const (
+	BillingPeriodDay   = "day"
+	BillingPeriodWeek  = "week"
+	BillingPeriodMonth = "month"
+	BillingPeriodYear  = "year"
+)
+
+// SubscriptionDetails
+// Подписка пользователя на сервис из каталога ServiceID; ServiceName - каноническое
+// название этого сервиса. Category - категория подписки, если она задана, иначе
+// категория сервиса из каталога; Tags - теги пользователя. Price задается в минимальных единицах валюты Currency
+// (копейках, центах), Currency - код ISO 4217. Цена списывается каждые
+// BillingInterval периодов BillingPeriod, отсчитывая от StartDate.
+// Status вычисляется при чтении и не записывается напрямую
 type SubscriptionDetails struct {
-	ID          int          `db:"id" json:"id"`
-	ServiceName string       `db:"service_name" json:"service_name"`
-	Price       int          `db:"price" json:"price"`
-	UserID      string       `db:"user_id" json:"user_id"`
-	StartDate   DayMonthYear `db:"start_date" json:"start_date"`
-	EndDate     DayMonthYear `db:"end_date" json:"end_date"`
+	ID              int           `db:"id" json:"id"`
+	ServiceID       int           `db:"service_id" json:"service_id"`
+	ServiceName     string        `db:"service_name" json:"service_name"`
+	Category        *string       `db:"category" json:"category,omitempty"`
+	Tags            Tags          `db:"tags" json:"tags"`
+	Price           int           `db:"price" json:"price"`
+	Currency        string        `db:"currency" json:"currency"`
+	BillingPeriod   string        `db:"billing_period" json:"billing_period"`
+	BillingInterval int           `db:"billing_interval" json:"billing_interval"`
+	UserID          string        `db:"user_id" json:"user_id"`
+	StartDate       DayMonthYear  `db:"start_date" json:"start_date"`
+	EndDate         DayMonthYear  `db:"end_date" json:"end_date"`
+	TrialEnd        *DayMonthYear `db:"trial_end" json:"trial_end,omitempty"`
+	Status          string        `db:"status" json:"status"`
+	CancelledAt     *time.Time    `db:"cancelled_at" json:"cancelled_at,omitempty"`
+	Version         int           `db:"version" json:"version"`
+	DeletedAt       *time.Time    `db:"deleted_at" json:"deleted_at,omitempty"`
 }
 
 type DayMonthYear time.Time
@@ -31,6 +63,30 @@ func (date DayMonthYear) MarshalJSON() ([]byte, error) {
 	return []byte(`"` + t.Format("02-01-2006") + `"`), nil
 }
 
+// Scan читает DATE из БД; NULL превращается в нулевую дату, как и незаданная end_date
+func (date *DayMonthYear) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*date = DayMonthYear{}
+	case time.Time:
+		*date = DayMonthYear(value)
+	default:
+		return fmt.Errorf("неподдерживаемый тип даты %T", src)
+	}
+	return nil
+}
+
 func (date DayMonthYear) ToTime() time.Time {
 	return time.Time(date)
 }
+
+type MonthYear time.Time
+
+func (month MonthYear) MarshalJSON() ([]byte, error) {
+	t := time.Time(month)
+	return []byte(`"` + t.Format("01-2006") + `"`), nil
+}
+
+func (month MonthYear) ToTime() time.Time {
+	return time.Time(month)
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDayMonthYearScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    time.Time
+		invalid bool
+	}{
+		{name: "NULL", src: nil},
+		{name: "дата", src: time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC), want: time.Date(2025, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{name: "число", src: int64(20250331), invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			date := DayMonthYear(time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC))
+			err := date.Scan(test.src)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("Scan(%v) должен вернуть ошибку", test.src)
+				}
+				return
+			}
+			if err != nil || !date.ToTime().Equal(test.want) {
+				t.Errorf("Scan(%v) = %v, ошибка %v, ожидалось %v", test.src, date.ToTime(), err, test.want)
+			}
+		})
+	}
+}
+
+func TestDayMonthYearJSON(t *testing.T) {
+	var date DayMonthYear
+	if err := date.UnmarshalJSON([]byte(`"29-02-2024"`)); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	encoded, err := date.MarshalJSON()
+	if err != nil || string(encoded) != `"29-02-2024"` {
+		t.Errorf("MarshalJSON = %s, ошибка %v", encoded, err)
+	}
+
+	for _, invalid := range []string{`"2024-02-29"`, `"29-02-2025"`, `"31-04-2025"`, `""`} {
+		if err := date.UnmarshalJSON([]byte(invalid)); err == nil {
+			t.Errorf("UnmarshalJSON(%s) должен вернуть ошибку", invalid)
+		}
+	}
+}
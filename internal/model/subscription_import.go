@@ -0,0 +1,29 @@
+package model
+
+// ImportRow
+// Подписка, прочитанная из строки Line файла импорта. Errors - нарушения, найденные
+// еще при разборе строки, например неверный формат даты
+type ImportRow struct {
+	Line         int
+	Subscription SubscriptionDetails
+	Errors       ValidationErrors
+}
+
+// ImportLineError
+// Нарушения в одной строке файла импорта; Line считается с 1, заголовок CSV - тоже строка
+type ImportLineError struct {
+	Line   int          `json:"line" example:"3"`
+	Errors []FieldError `json:"errors"`
+}
+
+// ImportReport
+// Результат импорта подписок. Импорт атомарный: если хотя бы одна строка не прошла
+// проверку, не записывается ни одна подписка, а Errors перечисляет нарушения по строкам.
+// С DryRun подписки только проверяются, Imported - сколько подписок было бы создано
+type ImportReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Total    int               `json:"total" example:"120"`
+	Imported int               `json:"imported" example:"120"`
+	IDs      []int             `json:"ids,omitempty"`
+	Errors   []ImportLineError `json:"errors,omitempty"`
+}
@@ -0,0 +1,32 @@
+package model
+
+// Статусы жизненного цикла подписки. Статус вычисляется из дат подписки и ее пауз
+// на текущий день: отмена важнее окончания срока, окончание - пробного периода,
+// пробный период - паузы
+const (
+	StatusTrial     = "trial"
+	StatusActive    = "active"
+	StatusPaused    = "paused"
+	StatusCancelled = "cancelled"
+	StatusExpired   = "expired"
+)
+
+// SubscriptionStatuses
+// Статусы, по которым разрешена фильтрация списка подписок
+var SubscriptionStatuses = map[string]bool{
+	StatusTrial:     true,
+	StatusActive:    true,
+	StatusPaused:    true,
+	StatusCancelled: true,
+	StatusExpired:   true,
+}
+
+// SubscriptionPause
+// Пауза подписки: оплаты с PausedFrom и до ResumedOn (не включая) не списываются.
+// У незавершенной паузы ResumedOn пуст
+type SubscriptionPause struct {
+	ID             int64         `db:"id" json:"id"`
+	SubscriptionID int           `db:"subscription_id" json:"subscription_id"`
+	PausedFrom     DayMonthYear  `db:"paused_from" json:"paused_from"`
+	ResumedOn      *DayMonthYear `db:"resumed_on" json:"resumed_on,omitempty"`
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// SubscriptionSortFields
+// Поля, по которым разрешена сортировка списка подписок
+var SubscriptionSortFields = map[string]bool{
+	"id":           true,
+	"service_name": true,
+	"price":        true,
+	"start_date":   true,
+	"end_date":     true,
+}
+
+// SubscriptionListFilter
+// Фильтры, сортировка и позиция курсора для постраничного списка подписок.
+// Nil-поля не участвуют в фильтрации, Tags отбирает подписки со всеми перечисленными
+// тегами; Deleted переключает список на удаленные подписки
+type SubscriptionListFilter struct {
+	UserID      *string
+	ServiceID   *int
+	ServiceName *string
+	Category    *string
+	Tags        []string
+	PriceMin    *int
+	PriceMax    *int
+	ActiveOn    *time.Time
+	StartFrom   *time.Time
+	StartTo     *time.Time
+	EndFrom     *time.Time
+	EndTo       *time.Time
+	Status      *string
+	Deleted     bool
+	Sort        string
+	Desc        bool
+	Limit       int
+	After       *ListCursor
+}
+
+// SubscriptionPage
+// Страница списка подписок; NextCursor пуст на последней странице
+type SubscriptionPage struct {
+	Items      []SubscriptionDetails `json:"items"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// ListCursor
+// Позиция в списке: значение поля сортировки и id последней выданной строки.
+// Сортировка хранится в курсоре, чтобы его нельзя было применить к другому порядку
+type ListCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func (cursor ListCursor) Encode() string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeListCursor(encoded string) (*ListCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor ListCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, err
+	}
+	if !SubscriptionSortFields[cursor.Sort] {
+		return nil, errors.New("неизвестное поле сортировки в курсоре")
+	}
+	return &cursor, nil
+}
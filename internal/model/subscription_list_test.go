@@ -0,0 +1,45 @@
+package model
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestListCursorRoundTrip(t *testing.T) {
+	cursors := []ListCursor{
+		{Sort: "id", ID: 1},
+		{Sort: "price", Desc: true, Value: "400", ID: 17},
+		{Sort: "service_name", Value: "Yandex Plus, семейная", ID: 3},
+		{Sort: "end_date", Value: "", ID: 42},
+	}
+
+	for _, cursor := range cursors {
+		decoded, err := DecodeListCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("DecodeListCursor(%+v): %v", cursor, err)
+		}
+		if *decoded != cursor {
+			t.Errorf("курсор %+v после декодирования %+v", cursor, *decoded)
+		}
+	}
+}
+
+func TestDecodeListCursorErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "не base64", encoded: "не курсор"},
+		{name: "не JSON", encoded: base64.RawURLEncoding.EncodeToString([]byte("id:1"))},
+		{name: "неизвестное поле сортировки", encoded: ListCursor{Sort: "user_id", ID: 1}.Encode()},
+		{name: "без поля сортировки", encoded: base64.RawURLEncoding.EncodeToString([]byte(`{"id":1}`))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cursor, err := DecodeListCursor(test.encoded); err == nil {
+				t.Errorf("ожидалась ошибка, получен курсор %+v", cursor)
+			}
+		})
+	}
+}
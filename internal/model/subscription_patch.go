@@ -0,0 +1,153 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SubscriptionPatch
+// Частичное обновление подписки в семантике JSON Merge Patch (RFC 7396):
+// nil-поле в запросе не передавалось и не меняется, а явный null в end_date
+// или trial_end очищает дату, в category - возвращает категорию сервиса из каталога,
+// в tags - удаляет все теги. Остальные поля обязательны и очистить их нельзя
+type SubscriptionPatch struct {
+	ServiceID       *int
+	ServiceName     *string
+	Category        *string
+	ClearCategory   bool
+	Tags            *Tags
+	Price           *int
+	Currency        *string
+	BillingPeriod   *string
+	BillingInterval *int
+	UserID          *string
+	StartDate       *DayMonthYear
+	EndDate         *DayMonthYear
+	ClearEndDate    bool
+	TrialEnd        *DayMonthYear
+	ClearTrialEnd   bool
+}
+
+func (patch *SubscriptionPatch) UnmarshalJSON(b []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil || fields == nil {
+		return errors.New("merge patch должен быть JSON-объектом")
+	}
+
+	for name, raw := range fields {
+		isNull := bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+		if isNull && name != "end_date" && name != "trial_end" && name != "category" && name != "tags" {
+			return fmt.Errorf("поле %s нельзя очистить", name)
+		}
+
+		var err error
+		switch name {
+		case "service_id":
+			err = json.Unmarshal(raw, &patch.ServiceID)
+		case "service_name":
+			err = json.Unmarshal(raw, &patch.ServiceName)
+		case "category":
+			if isNull {
+				patch.ClearCategory = true
+				continue
+			}
+			err = json.Unmarshal(raw, &patch.Category)
+		case "tags":
+			if isNull {
+				patch.Tags = &Tags{}
+				continue
+			}
+			err = json.Unmarshal(raw, &patch.Tags)
+		case "price":
+			err = json.Unmarshal(raw, &patch.Price)
+		case "currency":
+			err = json.Unmarshal(raw, &patch.Currency)
+		case "billing_period":
+			err = json.Unmarshal(raw, &patch.BillingPeriod)
+		case "billing_interval":
+			err = json.Unmarshal(raw, &patch.BillingInterval)
+		case "user_id":
+			err = json.Unmarshal(raw, &patch.UserID)
+		case "start_date":
+			err = json.Unmarshal(raw, &patch.StartDate)
+		case "end_date":
+			if isNull {
+				patch.ClearEndDate = true
+				continue
+			}
+			err = json.Unmarshal(raw, &patch.EndDate)
+		case "trial_end":
+			if isNull {
+				patch.ClearTrialEnd = true
+				continue
+			}
+			err = json.Unmarshal(raw, &patch.TrialEnd)
+		default:
+			return fmt.Errorf("поле %s не может быть изменено", name)
+		}
+		if err != nil {
+			return fmt.Errorf("неверное значение поля %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (patch SubscriptionPatch) IsEmpty() bool {
+	return patch.ServiceID == nil && patch.ServiceName == nil && patch.Category == nil && !patch.ClearCategory &&
+		patch.Tags == nil && patch.Price == nil && patch.Currency == nil &&
+		patch.BillingPeriod == nil && patch.BillingInterval == nil && patch.UserID == nil &&
+		patch.StartDate == nil && patch.EndDate == nil && !patch.ClearEndDate &&
+		patch.TrialEnd == nil && !patch.ClearTrialEnd
+}
+
+// Apply переносит переданные поля патча на подписку
+func (patch SubscriptionPatch) Apply(subscription *SubscriptionDetails) {
+	if patch.ServiceID != nil {
+		subscription.ServiceID = *patch.ServiceID
+	}
+	if patch.ServiceName != nil {
+		subscription.ServiceName = *patch.ServiceName
+	}
+	if patch.Category != nil {
+		subscription.Category = patch.Category
+	}
+	if patch.ClearCategory {
+		subscription.Category = nil
+	}
+	if patch.Tags != nil {
+		subscription.Tags = *patch.Tags
+	}
+	if patch.Price != nil {
+		subscription.Price = *patch.Price
+	}
+	if patch.Currency != nil {
+		subscription.Currency = *patch.Currency
+	}
+	if patch.BillingPeriod != nil {
+		subscription.BillingPeriod = *patch.BillingPeriod
+	}
+	if patch.BillingInterval != nil {
+		subscription.BillingInterval = *patch.BillingInterval
+	}
+	if patch.UserID != nil {
+		subscription.UserID = *patch.UserID
+	}
+	if patch.StartDate != nil {
+		subscription.StartDate = *patch.StartDate
+	}
+	if patch.EndDate != nil {
+		subscription.EndDate = *patch.EndDate
+	}
+	if patch.ClearEndDate {
+		subscription.EndDate = DayMonthYear{}
+	}
+	if patch.TrialEnd != nil {
+		subscription.TrialEnd = patch.TrialEnd
+	}
+	if patch.ClearTrialEnd {
+		subscription.TrialEnd = nil
+	}
+}
@@ -0,0 +1,192 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func day(year int, month time.Month, date int) DayMonthYear {
+	return DayMonthYear(time.Date(year, month, date, 0, 0, 0, 0, time.UTC))
+}
+
+func TestSubscriptionPatchUnmarshalJSON(t *testing.T) {
+	text := func(value string) *string { return &value }
+	number := func(value int) *int { return &value }
+	date := func(value DayMonthYear) *DayMonthYear { return &value }
+
+	tests := []struct {
+		name string
+		body string
+		want SubscriptionPatch
+	}{
+		{name: "пустой объект", body: `{}`},
+		{
+			name: "переданные поля",
+			body: `{"service_id":3,"service_name":"Музыка","category":"music","tags":["a","b"],"price":499,
+				"currency":"USD","billing_period":"week","billing_interval":2,
+				"user_id":"7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c01","start_date":"01-02-2025",
+				"end_date":"31-12-2025","trial_end":"15-02-2025"}`,
+			want: SubscriptionPatch{
+				ServiceID:       number(3),
+				ServiceName:     text("Музыка"),
+				Category:        text("music"),
+				Tags:            &Tags{"a", "b"},
+				Price:           number(499),
+				Currency:        text("USD"),
+				BillingPeriod:   text("week"),
+				BillingInterval: number(2),
+				UserID:          text("7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c01"),
+				StartDate:       date(day(2025, time.February, 1)),
+				EndDate:         date(day(2025, time.December, 31)),
+				TrialEnd:        date(day(2025, time.February, 15)),
+			},
+		},
+		{
+			name: "null очищает даты, категорию и теги",
+			body: `{"end_date":null,"trial_end": null ,"category":null,"tags":null}`,
+			want: SubscriptionPatch{ClearEndDate: true, ClearTrialEnd: true, ClearCategory: true, Tags: &Tags{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var patch SubscriptionPatch
+			if err := json.Unmarshal([]byte(test.body), &patch); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(patch, test.want) {
+				t.Errorf("патч %+v, ожидался %+v", patch, test.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionPatchUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "null вместо объекта", body: `null`},
+		{name: "массив", body: `[{"price":100}]`},
+		{name: "строка", body: `"price"`},
+		{name: "null в обязательном поле", body: `{"price":null}`},
+		{name: "null в дате начала", body: `{"start_date":null}`},
+		{name: "неизвестное поле", body: `{"id":5}`},
+		{name: "неверный тип", body: `{"price":"сто"}`},
+		{name: "неверный формат даты", body: `{"end_date":"2025-12-31"}`},
+		{name: "теги не массивом", body: `{"tags":"a"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var patch SubscriptionPatch
+			if err := json.Unmarshal([]byte(test.body), &patch); err == nil {
+				t.Errorf("патч %s должен вернуть ошибку, получено %+v", test.body, patch)
+			}
+		})
+	}
+}
+
+func TestSubscriptionPatchIsEmpty(t *testing.T) {
+	tests := []struct {
+		body  string
+		empty bool
+	}{
+		{`{}`, true},
+		{`{"price":100}`, false},
+		{`{"end_date":null}`, false},
+		{`{"trial_end":null}`, false},
+		{`{"category":null}`, false},
+		{`{"tags":[]}`, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.body, func(t *testing.T) {
+			var patch SubscriptionPatch
+			if err := json.Unmarshal([]byte(test.body), &patch); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got := patch.IsEmpty(); got != test.empty {
+				t.Errorf("IsEmpty = %v, ожидалось %v", got, test.empty)
+			}
+		})
+	}
+}
+
+func TestSubscriptionPatchApply(t *testing.T) {
+	category := "streaming"
+	trialEnd := day(2025, time.January, 15)
+	original := func() SubscriptionDetails {
+		return SubscriptionDetails{
+			ID:              7,
+			ServiceID:       1,
+			ServiceName:     "Видео",
+			Category:        &category,
+			Tags:            Tags{"семья"},
+			Price:           400,
+			Currency:        "RUB",
+			BillingPeriod:   BillingPeriodMonth,
+			BillingInterval: 1,
+			UserID:          "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c01",
+			StartDate:       day(2025, time.January, 1),
+			EndDate:         day(2025, time.December, 31),
+			TrialEnd:        &trialEnd,
+		}
+	}
+
+	music := "music"
+	newTrialEnd := day(2025, time.February, 1)
+	tests := []struct {
+		name   string
+		body   string
+		change func(subscription *SubscriptionDetails)
+	}{
+		{name: "пустой патч ничего не меняет", body: `{}`, change: func(*SubscriptionDetails) {}},
+		{
+			name: "меняются только переданные поля",
+			body: `{"price":500,"category":"music","trial_end":"01-02-2025"}`,
+			change: func(subscription *SubscriptionDetails) {
+				subscription.Price = 500
+				subscription.Category = &music
+				subscription.TrialEnd = &newTrialEnd
+			},
+		},
+		{
+			name: "очистка",
+			body: `{"end_date":null,"trial_end":null,"category":null,"tags":null}`,
+			change: func(subscription *SubscriptionDetails) {
+				subscription.EndDate = DayMonthYear{}
+				subscription.TrialEnd = nil
+				subscription.Category = nil
+				subscription.Tags = Tags{}
+			},
+		},
+		{
+			name: "смена сервиса и периода",
+			body: `{"service_id":2,"service_name":"Музыка","billing_period":"year","billing_interval":1,"start_date":"01-03-2025"}`,
+			change: func(subscription *SubscriptionDetails) {
+				subscription.ServiceID = 2
+				subscription.ServiceName = "Музыка"
+				subscription.BillingPeriod = BillingPeriodYear
+				subscription.StartDate = day(2025, time.March, 1)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var patch SubscriptionPatch
+			if err := json.Unmarshal([]byte(test.body), &patch); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			got, want := original(), original()
+			patch.Apply(&got)
+			test.change(&want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("подписка после патча %+v, ожидалась %+v", got, want)
+			}
+		})
+	}
+}
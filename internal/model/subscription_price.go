@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// SubscriptionPrice
+// Изменение цены подписки, действующее с effective_from, в минимальных единицах
+// валюты подписки. До первого изменения действует цена из самой подписки
+type SubscriptionPrice struct {
+	ID             int64        `db:"id" json:"id"`
+	SubscriptionID int          `db:"subscription_id" json:"subscription_id"`
+	Price          int          `db:"price" json:"price" example:"40000"`
+	EffectiveFrom  DayMonthYear `db:"effective_from" json:"effective_from"`
+	CreatedAt      time.Time    `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package model
+
+// UpcomingRenewal
+// Ближайшая оплата подписки: дата и цена, действующая на эту дату, в минимальных
+// единицах валюты подписки. DaysLeft - сколько дней осталось до оплаты
+type UpcomingRenewal struct {
+	SubscriptionID int          `db:"subscription_id" json:"subscription_id"`
+	UserID         string       `db:"user_id" json:"user_id"`
+	ServiceName    string       `db:"service_name" json:"service_name"`
+	Price          int          `db:"price" json:"price" example:"40000"`
+	Currency       string       `db:"currency" json:"currency" example:"RUB"`
+	BillingDate    DayMonthYear `db:"billing_date" json:"billing_date"`
+	DaysLeft       int          `db:"days_left" json:"days_left" example:"3"`
+}
+
+// SubscriptionReminder
+// Напоминание о предстоящей оплате. На каждую дату оплаты подписки создается
+// не больше одного напоминания; Attempts - число неудачных попыток доставки
+type SubscriptionReminder struct {
+	ID       int64 `db:"id" json:"id"`
+	Attempts int   `db:"attempts" json:"-"`
+	UpcomingRenewal
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Tags
+// Теги подписки, заданные пользователем. Из БД читаются JSON-массивом
+type Tags []string
+
+// Scan читает JSON-массив тегов; NULL превращается в пустой список
+func (tags *Tags) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*tags = Tags{}
+		return nil
+	case []byte:
+		return json.Unmarshal(value, (*[]string)(tags))
+	case string:
+		return json.Unmarshal([]byte(value), (*[]string)(tags))
+	default:
+		return fmt.Errorf("неподдерживаемый тип тегов %T", src)
+	}
+}
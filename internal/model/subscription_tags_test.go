@@ -0,0 +1,38 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagsScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    Tags
+		invalid bool
+	}{
+		{name: "NULL", src: nil, want: Tags{}},
+		{name: "пустой массив", src: []byte(`[]`), want: Tags{}},
+		{name: "байты", src: []byte(`["работа","семья"]`), want: Tags{"работа", "семья"}},
+		{name: "строка", src: `["семья"]`, want: Tags{"семья"}},
+		{name: "не массив", src: []byte(`{"tag":"семья"}`), invalid: true},
+		{name: "число", src: int64(1), invalid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var tags Tags
+			err := tags.Scan(test.src)
+			if test.invalid {
+				if err == nil {
+					t.Errorf("Scan(%v) должен вернуть ошибку", test.src)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(tags, test.want) {
+				t.Errorf("Scan(%v) = %q, %v; ожидалось %q", test.src, tags, err, test.want)
+			}
+		})
+	}
+}
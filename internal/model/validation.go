@@ -0,0 +1,32 @@
+package model
+
+import "strings"
+
+// FieldError
+// Нарушение правила валидации для одного поля
+type FieldError struct {
+	Field   string `json:"field" example:"price"`
+	Code    string `json:"code" example:"negative"`
+	Message string `json:"message" example:"цена не может быть отрицательной"`
+}
+
+// ValidationErrors
+// Все нарушения, найденные при проверке сущности
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, 0, len(errs))
+	for _, fieldError := range errs {
+		messages = append(messages, fieldError.Field+": "+fieldError.Message)
+	}
+	return "ошибка валидации: " + strings.Join(messages, "; ")
+}
+
+// Is позволяет проверять ошибки валидации через errors.Is(err, ErrValidation)
+func (errs ValidationErrors) Is(target error) bool {
+	return target == ErrValidation
+}
+
+func (errs *ValidationErrors) Add(field, code, message string) {
+	*errs = append(*errs, FieldError{Field: field, Code: code, Message: message})
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookEventTypes
+// Допустимые типы событий вебхука
+var WebhookEventTypes = map[string]bool{
+	EventSubscriptionCreated:    true,
+	EventSubscriptionUpdated:    true,
+	EventSubscriptionDeleted:    true,
+	EventSubscriptionRenewalDue: true,
+}
+
+// Состояния доставки события вебхуку. dead - попытки исчерпаны, доставку можно
+// повторить вручную
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusDead      = "dead"
+)
+
+// Webhook
+// Получатель событий подписок. Secret подписывает тело каждой доставки HMAC-SHA256
+// и возвращается только при создании вебхука
+type Webhook struct {
+	ID         int       `db:"id" json:"id"`
+	URL        string    `db:"url" json:"url" example:"https://example.com/hooks/subscriptions"`
+	Secret     string    `db:"secret" json:"secret,omitempty"`
+	EventTypes []string  `db:"-" json:"event_types" example:"subscription.created"`
+	Active     bool      `db:"active" json:"active"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// WebhookDelivery
+// Доставка одного события одному вебхуку. URL и Secret заполняются только для отправки
+type WebhookDelivery struct {
+	ID             int64           `db:"id" json:"id"`
+	WebhookID      int             `db:"webhook_id" json:"webhook_id"`
+	EventID        int64           `db:"event_id" json:"event_id"`
+	EventType      string          `db:"event_type" json:"event_type" example:"subscription.created"`
+	Payload        json.RawMessage `db:"payload" json:"payload" swaggertype:"object"`
+	Status         string          `db:"status" json:"status" example:"pending" enums:"pending,delivered,dead"`
+	Attempts       int             `db:"attempts" json:"attempts"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at"`
+	LastStatusCode *int            `db:"last_status_code" json:"last_status_code,omitempty"`
+	LastError      *string         `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	DeliveredAt    *time.Time      `db:"delivered_at" json:"delivered_at,omitempty"`
+	URL            string          `db:"url" json:"-"`
+	Secret         string          `db:"secret" json:"-"`
+}
+
+// WebhookDeliveryAttempt
+// Попытка доставки: код ответа получателя или ошибка соединения и длительность запроса
+type WebhookDeliveryAttempt struct {
+	ID          int64     `db:"id" json:"id"`
+	DeliveryID  int64     `db:"delivery_id" json:"delivery_id"`
+	AttemptedAt time.Time `db:"attempted_at" json:"attempted_at"`
+	StatusCode  *int      `db:"status_code" json:"status_code,omitempty" example:"200"`
+	Error       *string   `db:"error" json:"error,omitempty"`
+	DurationMS  int       `db:"duration_ms" json:"duration_ms" example:"120"`
+}
+
+// WebhookDeliveryFilter
+// Фильтр журнала доставок вебхука
+type WebhookDeliveryFilter struct {
+	WebhookID int
+	Status    *string
+	Limit     int
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuditRepository struct {
+	*config.Database
+}
+
+func NewAuditRepository(database *config.Database) *AuditRepository {
+	return &AuditRepository{database}
+}
+
+// SaveAuditRecord добавляет запись в журнал; вызывается в той же транзакции, что и изменение
+func (repo *AuditRepository) SaveAuditRecord(ctx context.Context, exec sqlx.ExtContext, record *model.SubscriptionAuditRecord) error {
+	query := `INSERT INTO subscription_audit
+		(subscription_id, action, before, after, actor, request_id)
+		VALUES ($1, $2, $3::jsonb, $4::jsonb, $5, $6)
+		RETURNING id, created_at
+	`
+	row := exec.QueryRowxContext(ctx, query,
+		record.SubscriptionID,
+		record.Action,
+		jsonArg(record.Before),
+		jsonArg(record.After),
+		record.Actor,
+		record.RequestID,
+	)
+
+	err := row.Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		return databaseError("ошибка записи в журнал изменений подписки", err)
+	}
+	return nil
+}
+
+func (repo *AuditRepository) GetSubscriptionHistory(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionAuditRecord, error) {
+	query := `SELECT id, subscription_id, action, before, after, actor, request_id, created_at
+		FROM subscription_audit
+		WHERE subscription_id = $1
+		ORDER BY id
+	`
+
+	history := []model.SubscriptionAuditRecord{}
+	err := sqlx.SelectContext(ctx, exec, &history, query, subscriptionID)
+	if err != nil {
+		return nil, databaseError("ошибка получения истории изменений подписки", err)
+	}
+	return history, nil
+}
+
+// jsonArg передает JSON в запрос строкой: lib/pq отправляет []byte как bytea,
+// который не приводится к jsonb. Пустой снимок записывается как NULL
+func jsonArg(raw []byte) *string {
+	if len(raw) == 0 {
+		return nil
+	}
+	value := string(raw)
+	return &value
+}
@@ -0,0 +1,59 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionAuditHistory(t *testing.T) {
+	database := openTestDatabase(t)
+	subscriptions := repository.NewSubscriptionRepository(database)
+	audit := repository.NewAuditRepository(database)
+	ctx := context.Background()
+
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 400, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: newTestUserID(t),
+		StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := subscriptions.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	requestID := "req-1"
+	records := []model.SubscriptionAuditRecord{
+		{SubscriptionID: subscription.ID, Action: model.AuditActionCreate, After: json.RawMessage(`{"price": 400}`), Actor: "operator", RequestID: &requestID},
+		{SubscriptionID: subscription.ID, Action: model.AuditActionDelete, Before: json.RawMessage(`{"price": 400}`), Actor: "anonymous"},
+	}
+	for i := range records {
+		if err := audit.SaveAuditRecord(ctx, database, &records[i]); err != nil {
+			t.Fatalf("SaveAuditRecord: %v", err)
+		}
+		if records[i].ID == 0 || records[i].CreatedAt.IsZero() {
+			t.Errorf("запись не получила id и время: %+v", records[i])
+		}
+	}
+
+	history, err := audit.GetSubscriptionHistory(ctx, database, subscription.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].Action != model.AuditActionCreate || history[1].Action != model.AuditActionDelete {
+		t.Fatalf("история %+v", history)
+	}
+	if history[0].Before != nil || history[0].RequestID == nil || *history[0].RequestID != requestID || history[0].Actor != "operator" {
+		t.Errorf("запись о создании %+v", history[0])
+	}
+	if history[1].After != nil || history[1].RequestID != nil {
+		t.Errorf("запись об удалении %+v", history[1])
+	}
+
+	empty, err := audit.GetSubscriptionHistory(ctx, database, -1)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("история несуществующей подписки %v, %v", empty, err)
+	}
+}
@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// budgetColumns собирает бюджет вместе с названием сервиса; используется с алиасом b
+// для budgets и LEFT JOIN services sv
+const budgetColumns = `b.id, b.user_id, b.scope, b.category, b.service_id, sv.name AS service_name, b.amount, b.currency`
+
+type BudgetRepository struct {
+	*config.Database
+}
+
+func NewBudgetRepository(database *config.Database) *BudgetRepository {
+	return &BudgetRepository{database}
+}
+
+func (repo *BudgetRepository) SaveBudget(ctx context.Context, exec sqlx.ExtContext, budget *model.Budget) error {
+	query := `INSERT INTO budgets (user_id, scope, category, service_id, amount, currency)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := sqlx.GetContext(ctx, exec, &budget.ID, query,
+		budget.UserID,
+		budget.Scope,
+		budget.Category,
+		budget.ServiceID,
+		budget.Amount,
+		budget.Currency,
+	)
+	if err != nil {
+		return databaseError("ошибка при сохранении бюджета", err)
+	}
+	return repo.reload(ctx, exec, budget)
+}
+
+func (repo *BudgetRepository) GetBudgetByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets b LEFT JOIN services sv ON sv.id = b.service_id WHERE b.id = $1`
+
+	var budget model.Budget
+	err := sqlx.GetContext(ctx, exec, &budget, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("бюджет с таким ID не найден", err)
+		}
+		return nil, databaseError("ошибка получения бюджета", err)
+	}
+	return &budget, nil
+}
+
+func (repo *BudgetRepository) GetBudgetsByUserUUID(ctx context.Context, exec sqlx.ExtContext, uuid string) ([]model.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets b LEFT JOIN services sv ON sv.id = b.service_id
+		WHERE b.user_id = $1
+		ORDER BY b.scope, b.category, sv.name, b.id`
+
+	budgets := []model.Budget{}
+	err := sqlx.SelectContext(ctx, exec, &budgets, query, uuid)
+	if err != nil {
+		return nil, databaseError("ошибка получения бюджетов пользователя", err)
+	}
+	return budgets, nil
+}
+
+// InSavepoint выполняет fn под точкой сохранения транзакции exec: проверка бюджетов,
+// которая не смогла перевести траты в валюту бюджета, не должна отменять запись подписки
+func (repo *BudgetRepository) InSavepoint(ctx context.Context, exec sqlx.ExtContext, fn func() error) error {
+	return inSavepoint(ctx, exec, "budget_check", fn)
+}
+
+// GetBudgetsForSubscription возвращает бюджеты пользователя, в область которых попадает
+// подписка: общий, бюджет ее категории и бюджет ее сервиса
+func (repo *BudgetRepository) GetBudgetsForSubscription(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) ([]model.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets b LEFT JOIN services sv ON sv.id = b.service_id
+		WHERE b.user_id = $1 AND (
+			b.scope = 'total' OR
+			(b.scope = 'category' AND b.category = $2) OR
+			(b.scope = 'service' AND b.service_id = $3)
+		)
+		ORDER BY b.id`
+
+	budgets := []model.Budget{}
+	err := sqlx.SelectContext(ctx, exec, &budgets, query, subscription.UserID, subscription.Category, subscription.ServiceID)
+	if err != nil {
+		return nil, databaseError("ошибка получения бюджетов подписки", err)
+	}
+	return budgets, nil
+}
+
+func (repo *BudgetRepository) UpdateBudgetByID(ctx context.Context, exec sqlx.ExtContext, budget *model.Budget, id int) error {
+	query := `UPDATE budgets
+			SET user_id = $1,
+			    scope = $2,
+			    category = $3,
+			    service_id = $4,
+			    amount = $5,
+			    currency = $6
+			WHERE id = $7`
+
+	result, err := exec.ExecContext(ctx, query,
+		budget.UserID,
+		budget.Scope,
+		budget.Category,
+		budget.ServiceID,
+		budget.Amount,
+		budget.Currency,
+		id,
+	)
+	if err != nil {
+		return databaseError("ошибка при обновлении бюджета", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество обновленных строк", err)
+	}
+	if rowsAffected == 0 {
+		return databaseError("бюджет с таким ID не найден", sql.ErrNoRows)
+	}
+
+	budget.ID = id
+	return repo.reload(ctx, exec, budget)
+}
+
+func (repo *BudgetRepository) DeleteBudgetByID(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	result, err := exec.ExecContext(ctx, `DELETE FROM budgets WHERE id = $1`, id)
+	if err != nil {
+		return databaseError("ошибка при удалении бюджета", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество удалённых строк", err)
+	}
+	if rowsAffected == 0 {
+		return databaseError("бюджет с таким ID не найден", sql.ErrNoRows)
+	}
+	return nil
+}
+
+func (repo *BudgetRepository) reload(ctx context.Context, exec sqlx.ExtContext, budget *model.Budget) error {
+	saved, err := repo.GetBudgetByID(ctx, exec, budget.ID)
+	if err != nil {
+		return err
+	}
+	*budget = *saved
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBudgetScopes(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewBudgetRepository(database)
+	ctx := context.Background()
+
+	text := func(value string) *string { return &value }
+	userID := newTestUserID(t)
+	serviceID := catalogServiceID(t, database, "Yandex Plus")
+
+	total := model.Budget{UserID: userID, Scope: model.BudgetScopeTotal, Amount: 100000, Currency: "RUB"}
+	category := model.Budget{UserID: userID, Scope: model.BudgetScopeCategory, Category: text("кино"), Amount: 50000, Currency: "RUB"}
+	service := model.Budget{UserID: userID, Scope: model.BudgetScopeService, ServiceID: &serviceID, Amount: 30000, Currency: "RUB"}
+	other := model.Budget{UserID: userID, Scope: model.BudgetScopeCategory, Category: text("музыка"), Amount: 10000, Currency: "RUB"}
+	for _, budget := range []*model.Budget{&total, &category, &service, &other} {
+		if err := repo.SaveBudget(ctx, database, budget); err != nil {
+			t.Fatalf("не удалось сохранить бюджет %s: %v", budget.Scope, err)
+		}
+	}
+	if service.ServiceName == nil || *service.ServiceName != "Yandex Plus" {
+		t.Errorf("у бюджета сервиса название %v", service.ServiceName)
+	}
+
+	// второй бюджет на ту же категорию нарушает budgets_scope_key
+	duplicate := model.Budget{UserID: userID, Scope: model.BudgetScopeCategory, Category: text("кино"), Amount: 1, Currency: "RUB"}
+	err := repo.SaveBudget(ctx, database, &duplicate)
+	var errs model.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != "duplicate_budget" {
+		t.Errorf("повторный бюджет категории: %v", err)
+	}
+
+	subscription := &model.SubscriptionDetails{UserID: userID, Category: text("кино"), ServiceID: serviceID}
+	budgets, err := repo.GetBudgetsForSubscription(ctx, database, subscription)
+	if err != nil {
+		t.Fatalf("GetBudgetsForSubscription: %v", err)
+	}
+	var ids []int
+	for _, budget := range budgets {
+		ids = append(ids, budget.ID)
+	}
+	if want := []int{total.ID, category.ID, service.ID}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("бюджеты подписки %v, ожидались %v", ids, want)
+	}
+
+	if err := repo.DeleteBudgetByID(ctx, database, other.ID); err != nil {
+		t.Fatalf("DeleteBudgetByID: %v", err)
+	}
+	if _, err := repo.GetBudgetByID(ctx, database, other.ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("удаленный бюджет: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestMonthlySpend(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	userID := newTestUserID(t)
+	subscriptions := []model.SubscriptionDetails{
+		{ServiceName: "Видео", Price: 1000, EndDate: model.DayMonthYear(date(2025, time.March, 31))},
+		{ServiceName: "Музыка", Price: 300, EndDate: model.DayMonthYear(date(2025, time.January, 31))},
+	}
+	for i := range subscriptions {
+		subscriptions[i].UserID = userID
+		subscriptions[i].Currency = "RUB"
+		subscriptions[i].BillingPeriod = model.BillingPeriodMonth
+		subscriptions[i].BillingInterval = 1
+		subscriptions[i].StartDate = model.DayMonthYear(date(2025, time.January, 1))
+		subscriptions[i].ServiceID = catalogServiceID(t, database, subscriptions[i].ServiceName)
+		if err := repo.SaveSubscription(ctx, database, &subscriptions[i]); err != nil {
+			t.Fatalf("не удалось сохранить подписку: %v", err)
+		}
+	}
+
+	filter := model.CostFilter{UserID: userID, Currency: "RUB", StartPeriod: date(2025, time.January, 1), EndPeriod: date(2025, time.April, 30)}
+	spends, err := repo.GetMonthlySpend(ctx, database, filter, subscriptions[1].ID)
+	if err != nil {
+		t.Fatalf("GetMonthlySpend: %v", err)
+	}
+
+	// месяцы без оплат в результат не попадают, доля второй подписки есть только в январе
+	want := []model.MonthlySpend{
+		{Month: model.MonthYear(date(2025, time.January, 1)), Spent: 1300, SubscriptionSpent: 300},
+		{Month: model.MonthYear(date(2025, time.February, 1)), Spent: 1000},
+		{Month: model.MonthYear(date(2025, time.March, 1)), Spent: 1000},
+	}
+	if len(spends) != len(want) {
+		t.Fatalf("траты %+v, ожидались %+v", spends, want)
+	}
+	for i := range want {
+		if !spends[i].Month.ToTime().Equal(want[i].Month.ToTime()) || spends[i].Spent != want[i].Spent || spends[i].SubscriptionSpent != want[i].SubscriptionSpent {
+			t.Errorf("траты месяца %d: %+v, ожидались %+v", i, spends[i], want[i])
+		}
+	}
+}
+
+func TestBudgetInSavepoint(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewBudgetRepository(database)
+	ctx := context.Background()
+
+	tx, err := database.BeginTxx(ctx, nil)
+	if err != nil {
+		t.Fatalf("не удалось начать транзакцию: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// курса на 1900 год нет: convert_amount прерывает запрос, но не транзакцию
+	err = repo.InSavepoint(ctx, tx, func() error {
+		var amount int
+		return tx.GetContext(ctx, &amount, `SELECT convert_amount(100, 'USD', 'RUB', DATE '1900-01-01')`)
+	})
+	if err == nil {
+		t.Fatal("ожидалась ошибка перевода без курса")
+	}
+
+	var one int
+	if err := tx.GetContext(ctx, &one, `SELECT 1`); err != nil || one != 1 {
+		t.Fatalf("после отката к точке сохранения транзакция должна продолжаться: %v", err)
+	}
+	if err := repo.InSavepoint(ctx, tx, func() error { return nil }); err != nil {
+		t.Fatalf("InSavepoint без ошибки: %v", err)
+	}
+}
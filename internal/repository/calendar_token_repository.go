@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+)
+
+type CalendarTokenRepository struct {
+	*config.Database
+}
+
+func NewCalendarTokenRepository(database *config.Database) *CalendarTokenRepository {
+	return &CalendarTokenRepository{database}
+}
+
+func (repo *CalendarTokenRepository) Executor() sqlx.ExtContext {
+	return repo.Database
+}
+
+// SaveCalendarToken сохраняет хеш нового токена календаря пользователя, заменяя прежний
+func (repo *CalendarTokenRepository) SaveCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string, tokenHash []byte) error {
+	query := `INSERT INTO calendar_tokens (user_id, token_hash)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = now()
+	`
+	if _, err := exec.ExecContext(ctx, query, userID, tokenHash); err != nil {
+		return databaseError("ошибка сохранения токена календаря", err)
+	}
+	return nil
+}
+
+func (repo *CalendarTokenRepository) GetCalendarTokenHash(ctx context.Context, exec sqlx.ExtContext, userID string) ([]byte, error) {
+	var tokenHash []byte
+	err := sqlx.GetContext(ctx, exec, &tokenHash, `SELECT token_hash FROM calendar_tokens WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, databaseError("ошибка получения токена календаря", err)
+	}
+	return tokenHash, nil
+}
+
+// DeleteCalendarToken отзывает токен календаря пользователя; если токена нет, возвращает ErrNotFound
+func (repo *CalendarTokenRepository) DeleteCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string) error {
+	result, err := exec.ExecContext(ctx, `DELETE FROM calendar_tokens WHERE user_id = $1`, userID)
+	if err != nil {
+		return databaseError("ошибка удаления токена календаря", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество удалённых строк", err)
+	}
+	if rowsAffected == 0 {
+		return databaseError("токен календаря не найден", sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strings"
+)
+
+// catalogServiceColumns собирает сервис каталога вместе с его написаниями, кроме
+// совпадающих с каноническим названием. Выражение ссылается на таблицу services без алиаса
+const catalogServiceColumns = `id, name, category, default_price, currency, logo_url,
+	ARRAY(
+		SELECT a.alias FROM service_aliases a
+		WHERE a.service_id = services.id AND a.normalized_alias <> normalize_service_name(services.name)
+		ORDER BY a.alias
+	) AS aliases`
+
+// catalogServiceRow читает массив написаний, который model.CatalogService не сканирует сам
+type catalogServiceRow struct {
+	model.CatalogService
+	Aliases pq.StringArray `db:"aliases"`
+}
+
+func (row catalogServiceRow) toModel() model.CatalogService {
+	service := row.CatalogService
+	service.Aliases = []string(row.Aliases)
+	if service.Aliases == nil {
+		service.Aliases = []string{}
+	}
+	return service
+}
+
+type CatalogRepository struct {
+	*config.Database
+}
+
+func NewCatalogRepository(database *config.Database) *CatalogRepository {
+	return &CatalogRepository{database}
+}
+
+// SaveCatalogService добавляет сервис в каталог вместе с написаниями и перечитывает его
+func (repo *CatalogRepository) SaveCatalogService(ctx context.Context, exec sqlx.ExtContext, service *model.CatalogService) error {
+	query := `INSERT INTO services (name, category, default_price, currency, logo_url)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	err := sqlx.GetContext(ctx, exec, &service.ID, query,
+		service.Name,
+		service.Category,
+		service.DefaultPrice,
+		service.Currency,
+		service.LogoURL,
+	)
+	if err != nil {
+		return databaseError("ошибка при добавлении сервиса в каталог", err)
+	}
+
+	if err := repo.replaceAliases(ctx, exec, service.ID, service.Name, service.Aliases); err != nil {
+		return err
+	}
+	return repo.reload(ctx, exec, service)
+}
+
+func (repo *CatalogRepository) GetCatalogServiceByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.CatalogService, error) {
+	query := `SELECT ` + catalogServiceColumns + ` FROM services WHERE id = $1`
+
+	var row catalogServiceRow
+	err := sqlx.GetContext(ctx, exec, &row, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("сервис с таким ID не найден в каталоге", err)
+		}
+		return nil, databaseError("ошибка получения сервиса из каталога", err)
+	}
+
+	service := row.toModel()
+	return &service, nil
+}
+
+// FindCatalogServiceByName ищет сервис, у которого есть написание name с точностью
+// до регистра и знаков препинания
+func (repo *CatalogRepository) FindCatalogServiceByName(ctx context.Context, exec sqlx.ExtContext, name string) (*model.CatalogService, error) {
+	query := `SELECT ` + catalogServiceColumns + ` FROM services
+		WHERE id = (SELECT service_id FROM service_aliases WHERE normalized_alias = normalize_service_name($1))`
+
+	var row catalogServiceRow
+	err := sqlx.GetContext(ctx, exec, &row, query, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("сервис "+name+" не найден в каталоге", err)
+		}
+		return nil, databaseError("ошибка поиска сервиса в каталоге", err)
+	}
+
+	service := row.toModel()
+	return &service, nil
+}
+
+func (repo *CatalogRepository) ListCatalogServices(ctx context.Context, exec sqlx.ExtContext, filter model.CatalogServiceFilter) ([]model.CatalogService, error) {
+	var args queryArgs
+	conditions := []string{"TRUE"}
+	if filter.Category != nil {
+		conditions = append(conditions, "category = "+args.add(*filter.Category))
+	}
+	if filter.Query != nil {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM service_aliases a
+			WHERE a.service_id = services.id AND
+				strpos(a.normalized_alias, normalize_service_name(`+args.add(*filter.Query)+`)) > 0
+		)`)
+	}
+
+	query := `SELECT ` + catalogServiceColumns + ` FROM services
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY name, id`
+
+	var rows []catalogServiceRow
+	err := sqlx.SelectContext(ctx, exec, &rows, query, args...)
+	if err != nil {
+		return nil, databaseError("ошибка получения каталога сервисов", err)
+	}
+
+	services := make([]model.CatalogService, 0, len(rows))
+	for _, row := range rows {
+		services = append(services, row.toModel())
+	}
+	return services, nil
+}
+
+// UpdateCatalogServiceByID перезаписывает сервис и его написания. Подписки сервиса не
+// меняются: новое название переносит в них RenameServiceSubscriptions
+func (repo *CatalogRepository) UpdateCatalogServiceByID(ctx context.Context, exec sqlx.ExtContext, service *model.CatalogService, id int) error {
+	query := `UPDATE services
+			SET name = $1,
+			    category = $2,
+			    default_price = $3,
+			    currency = $4,
+			    logo_url = $5
+			WHERE id = $6`
+
+	result, err := exec.ExecContext(ctx, query,
+		service.Name,
+		service.Category,
+		service.DefaultPrice,
+		service.Currency,
+		service.LogoURL,
+		id,
+	)
+	if err != nil {
+		return databaseError("ошибка при обновлении сервиса каталога", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество обновленных строк", err)
+	}
+	if rowsAffected == 0 {
+		return databaseError("сервис с таким ID не найден в каталоге", sql.ErrNoRows)
+	}
+
+	if err := repo.replaceAliases(ctx, exec, id, service.Name, service.Aliases); err != nil {
+		return err
+	}
+
+	service.ID = id
+	return repo.reload(ctx, exec, service)
+}
+
+// LockServiceSubscriptions читает и блокирует подписки сервиса, включая удаленные,
+// в которых название сервиса отличается от name
+func (repo *CatalogRepository) LockServiceSubscriptions(ctx context.Context, exec sqlx.ExtContext, id int, name string) ([]model.SubscriptionDetails, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions
+		WHERE service_id = $1 AND service_name <> $2
+		ORDER BY id
+		FOR UPDATE`
+
+	subscriptions := []model.SubscriptionDetails{}
+	if err := sqlx.SelectContext(ctx, exec, &subscriptions, query, id, name); err != nil {
+		return nil, databaseError("ошибка блокировки подписок сервиса", err)
+	}
+	return subscriptions, nil
+}
+
+// RenameServiceSubscriptions копирует название сервиса name в service_name его подписок,
+// включая удаленные, с увеличением их версии и возвращает измененные подписки
+func (repo *CatalogRepository) RenameServiceSubscriptions(ctx context.Context, exec sqlx.ExtContext, id int, name string) ([]model.SubscriptionDetails, error) {
+	query := `UPDATE subscriptions
+			SET service_name = $1,
+			    version = version + 1
+			WHERE service_id = $2 AND service_name <> $1
+			RETURNING ` + subscriptionColumns
+
+	subscriptions := []model.SubscriptionDetails{}
+	if err := sqlx.SelectContext(ctx, exec, &subscriptions, query, name, id); err != nil {
+		return nil, databaseError("ошибка при переименовании сервиса в подписках", err)
+	}
+	return subscriptions, nil
+}
+
+// DeleteCatalogServiceByID удаляет сервис из каталога. Сервис, на который ссылаются
+// подписки, в том числе удаленные, удалить нельзя
+func (repo *CatalogRepository) DeleteCatalogServiceByID(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	result, err := exec.ExecContext(ctx, `DELETE FROM services WHERE id = $1`, id)
+	if err != nil {
+		return databaseError("ошибка при удалении сервиса из каталога", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество удалённых строк", err)
+	}
+	if rowsAffected == 0 {
+		return databaseError("сервис с таким ID не найден в каталоге", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// replaceAliases заменяет написания сервиса на name и aliases. Написания с одинаковым
+// ключом сопоставления сохраняются один раз, первое в порядке передачи
+func (repo *CatalogRepository) replaceAliases(ctx context.Context, exec sqlx.ExtContext, id int, name string, aliases []string) error {
+	if _, err := exec.ExecContext(ctx, `DELETE FROM service_aliases WHERE service_id = $1`, id); err != nil {
+		return databaseError("ошибка при удалении написаний сервиса", err)
+	}
+
+	query := `INSERT INTO service_aliases (service_id, alias)
+		SELECT $1, alias
+		FROM (
+			SELECT DISTINCT ON (normalize_service_name(alias)) alias, position
+			FROM unnest($2::text[]) WITH ORDINALITY AS given (alias, position)
+			ORDER BY normalize_service_name(alias), position
+		) AS distinct_aliases
+		ORDER BY position`
+
+	_, err := exec.ExecContext(ctx, query, id, pq.StringArray(append([]string{name}, aliases...)))
+	if err != nil {
+		return databaseError("ошибка при сохранении написаний сервиса", err)
+	}
+	return nil
+}
+
+func (repo *CatalogRepository) reload(ctx context.Context, exec sqlx.ExtContext, service *model.CatalogService) error {
+	saved, err := repo.GetCatalogServiceByID(ctx, exec, service.ID)
+	if err != nil {
+		return err
+	}
+	*service = *saved
+	return nil
+}
+
+// LockCatalogServiceName берет до конца транзакции exec блокировку на ключ сопоставления
+// name, чтобы параллельные запросы не добавили в каталог один и тот же новый сервис
+func (repo *CatalogRepository) LockCatalogServiceName(ctx context.Context, exec sqlx.ExtContext, name string) error {
+	_, err := exec.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('services:' || normalize_service_name($1)))`, name)
+	if err != nil {
+		return databaseError("ошибка блокировки названия сервиса", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCatalogServiceAliases(t *testing.T) {
+	database := openTestDatabase(t)
+	catalog := repository.NewCatalogRepository(database)
+	ctx := context.Background()
+
+	suffix := newTestUserID(t)
+	service := &model.CatalogService{
+		Name:     "Сервис " + suffix,
+		Aliases:  []string{"Service " + suffix, "сервис-" + suffix},
+		Currency: "RUB",
+	}
+	if err := catalog.SaveCatalogService(ctx, database, service); err != nil {
+		t.Fatalf("SaveCatalogService: %v", err)
+	}
+	// написание с тем же ключом, что и название, отдельно не хранится
+	if len(service.Aliases) != 1 || service.Aliases[0] != "Service "+suffix {
+		t.Errorf("написания %v", service.Aliases)
+	}
+
+	for _, name := range []string{"СЕРВИС  " + suffix + "!", "service " + suffix} {
+		found, err := catalog.FindCatalogServiceByName(ctx, database, name)
+		if err != nil || found.ID != service.ID {
+			t.Errorf("FindCatalogServiceByName(%q) = %+v, %v", name, found, err)
+		}
+	}
+	if _, err := catalog.FindCatalogServiceByName(ctx, database, "Другой "+suffix); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("поиск неизвестного сервиса: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	query := suffix[:8]
+	listed, err := catalog.ListCatalogServices(ctx, database, model.CatalogServiceFilter{Query: &query})
+	if err != nil {
+		t.Fatalf("ListCatalogServices: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != service.ID {
+		t.Errorf("поиск по части написания вернул %+v", listed)
+	}
+
+	duplicate := &model.CatalogService{Name: "Дубль " + suffix, Aliases: []string{"service_" + suffix}, Currency: "RUB"}
+	err = catalog.SaveCatalogService(ctx, database, duplicate)
+	var errs model.ValidationErrors
+	if !errors.As(err, &errs) || errs[0].Code != "duplicate_alias" {
+		t.Errorf("чужое написание: ожидалась ошибка aliases/duplicate_alias, получено %v", err)
+	}
+}
+
+func TestCatalogServiceRenameAndDelete(t *testing.T) {
+	database := openTestDatabase(t)
+	catalog := repository.NewCatalogRepository(database)
+	subscriptions := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	name := "Сервис " + newTestUserID(t)
+	subscription := &model.SubscriptionDetails{
+		ServiceID: catalogServiceID(t, database, name), ServiceName: name, Price: 400, Currency: "RUB",
+		BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: newTestUserID(t),
+		StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+	}
+	if err := subscriptions.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	renamed := &model.CatalogService{Name: name + " Plus", Aliases: []string{name}, Currency: "RUB"}
+	if err := catalog.UpdateCatalogServiceByID(ctx, database, renamed, subscription.ServiceID); err != nil {
+		t.Fatalf("UpdateCatalogServiceByID: %v", err)
+	}
+	saved, err := subscriptions.GetSubscriptionByID(ctx, database, subscription.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionByID: %v", err)
+	}
+	if saved.ServiceName != renamed.Name || saved.Version != subscription.Version+1 {
+		t.Errorf("подписка после переименования сервиса %+v", saved)
+	}
+
+	if err := catalog.DeleteCatalogServiceByID(ctx, database, subscription.ServiceID); !errors.Is(err, model.ErrConflict) {
+		t.Errorf("удаление сервиса с подписками: ожидалась ErrConflict, получено %v", err)
+	}
+
+	unused := &model.CatalogService{Name: "Пустой " + newTestUserID(t), Currency: "RUB"}
+	if err := catalog.SaveCatalogService(ctx, database, unused); err != nil {
+		t.Fatalf("SaveCatalogService: %v", err)
+	}
+	if err := catalog.DeleteCatalogServiceByID(ctx, database, unused.ID); err != nil {
+		t.Errorf("DeleteCatalogServiceByID: %v", err)
+	}
+	if err := catalog.DeleteCatalogServiceByID(ctx, database, unused.ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("повторное удаление: ожидалась ErrNotFound, получено %v", err)
+	}
+}
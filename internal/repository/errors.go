@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"net"
+)
+
+// databaseError логирует ошибку обращения к БД и оборачивает ее в доменный тип
+// из model, чтобы верхние слои могли отличить отсутствие строки от сбоя БД
+func databaseError(message string, err error) error {
+	return util.LogError(message, classifyDatabaseError(err))
+}
+
+// constraintFields сопоставляет ограничения БД с полями запроса, чтобы их нарушение
+// возвращалось клиенту как ошибка валидации конкретного поля. Пустой Message
+// заменяется текстом ошибки из БД
+var constraintFields = map[string]model.FieldError{
+	"subscriptions_currency_fkey": {Field: "currency", Code: "unknown_currency", Message: "валюта не найдена в справочнике"},
+	"exchange_rate_required":      {Field: "currency", Code: "no_exchange_rate"},
+	"currency_required":           {Field: "currency", Code: "unknown_currency"},
+
+	"exchange_rates_base_currency_fkey":  {Field: "base_currency", Code: "unknown_currency", Message: "валюта не найдена в справочнике"},
+	"exchange_rates_quote_currency_fkey": {Field: "quote_currency", Code: "unknown_currency", Message: "валюта не найдена в справочнике"},
+
+	"services_currency_fkey":                 {Field: "currency", Code: "unknown_currency", Message: "валюта не найдена в справочнике"},
+	"service_aliases_normalized_alias_key":   {Field: "aliases", Code: "duplicate_alias", Message: "название или написание уже принадлежит другому сервису каталога"},
+	"service_aliases_normalized_alias_check": {Field: "aliases", Code: "empty_alias", Message: "написание сервиса не может быть пустым"},
+
+	"budgets_scope_key":       {Field: "scope", Code: "duplicate_budget", Message: "бюджет на эту область уже задан"},
+	"budgets_currency_fkey":   {Field: "currency", Code: "unknown_currency", Message: "валюта не найдена в справочнике"},
+	"budgets_service_id_fkey": {Field: "service_id", Code: "unknown_service", Message: "сервис не найден в каталоге"},
+}
+
+// ConstraintError возвращает нарушение ограничения схемы constraint в том же виде, в каком
+// classifyDatabaseError возвращает его для ошибки Postgres. Нужна хранилищам, которые
+// проверяют ограничения сами; message - текст, который вернула бы БД
+func ConstraintError(constraint, message string) error {
+	fieldError, ok := constraintFields[constraint]
+	if !ok {
+		return fmt.Errorf("%w: %s", model.ErrConflict, message)
+	}
+	if fieldError.Message == "" {
+		fieldError.Message = message
+	}
+	return fmt.Errorf("%w: %s", model.ValidationErrors{fieldError}, message)
+}
+
+func classifyDatabaseError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if fieldError, ok := constraintFields[pqErr.Constraint]; ok {
+			if fieldError.Message == "" {
+				fieldError.Message = pqErr.Message
+			}
+			return fmt.Errorf("%w: %w", model.ValidationErrors{fieldError}, err)
+		}
+	}
+
+	if kind := databaseErrorKind(err); kind != nil {
+		return fmt.Errorf("%w: %w", kind, err)
+	}
+	return err
+}
+
+func databaseErrorKind(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "40001" || pqErr.Code == "40P01":
+			// serialization_failure, deadlock_detected
+			return model.ErrConflict
+		case pqErr.Code.Class() == "23" && pqErr.Code != "23502" && pqErr.Code != "23514":
+			// нарушение уникальности, внешнего ключа или исключения
+			return model.ErrConflict
+		case pqErr.Code.Class() == "22" || pqErr.Code == "23502" || pqErr.Code == "23514":
+			// некорректные данные: неверный UUID, дата, переполнение, NOT NULL, CHECK
+			return model.ErrValidation
+		case pqErr.Code.Class() == "08" || pqErr.Code.Class() == "53" || pqErr.Code.Class() == "57":
+			// потеря соединения, нехватка ресурсов, остановка сервера или отмена запроса
+			return model.ErrUnavailable
+		}
+		return nil
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return model.ErrUnavailable
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"net"
+	"testing"
+)
+
+func TestClassifyDatabaseError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "нет строки", err: sql.ErrNoRows, want: model.ErrNotFound},
+		{name: "обернутое отсутствие строки", err: fmt.Errorf("запрос: %w", sql.ErrNoRows), want: model.ErrNotFound},
+		{name: "serialization_failure", err: &pq.Error{Code: "40001"}, want: model.ErrConflict},
+		{name: "deadlock_detected", err: &pq.Error{Code: "40P01"}, want: model.ErrConflict},
+		{name: "unique_violation", err: &pq.Error{Code: "23505"}, want: model.ErrConflict},
+		{name: "foreign_key_violation", err: &pq.Error{Code: "23503"}, want: model.ErrConflict},
+		{name: "exclusion_violation", err: &pq.Error{Code: "23P01"}, want: model.ErrConflict},
+		{name: "not_null_violation", err: &pq.Error{Code: "23502"}, want: model.ErrValidation},
+		{name: "check_violation", err: &pq.Error{Code: "23514"}, want: model.ErrValidation},
+		{name: "invalid_text_representation", err: &pq.Error{Code: "22P02"}, want: model.ErrValidation},
+		{name: "numeric_value_out_of_range", err: &pq.Error{Code: "22003"}, want: model.ErrValidation},
+		{name: "connection_failure", err: &pq.Error{Code: "08006"}, want: model.ErrUnavailable},
+		{name: "too_many_connections", err: &pq.Error{Code: "53300"}, want: model.ErrUnavailable},
+		{name: "query_canceled", err: &pq.Error{Code: "57014"}, want: model.ErrUnavailable},
+		{name: "синтаксическая ошибка", err: &pq.Error{Code: "42601"}},
+		{name: "разорванное соединение", err: driver.ErrBadConn, want: model.ErrUnavailable},
+		{name: "закрытое соединение", err: sql.ErrConnDone, want: model.ErrUnavailable},
+		{name: "истек таймаут", err: context.DeadlineExceeded, want: model.ErrUnavailable},
+		{name: "сетевая ошибка", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: model.ErrUnavailable},
+		{name: "неизвестная ошибка", err: errors.New("сбой")},
+	}
+
+	kinds := []error{model.ErrNotFound, model.ErrConflict, model.ErrValidation, model.ErrUnavailable}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := classifyDatabaseError(test.err)
+			if !errors.Is(got, test.err) {
+				t.Errorf("исходная ошибка потеряна: %v", got)
+			}
+			for _, kind := range kinds {
+				if errors.Is(got, kind) != (kind == test.want) {
+					t.Errorf("errors.Is(%v, %v) = %v", got, kind, errors.Is(got, kind))
+				}
+			}
+		})
+	}
+}
+
+func TestClassifyConstraintError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *pq.Error
+		want model.FieldError
+	}{
+		{
+			name: "неизвестная валюта подписки",
+			err:  &pq.Error{Code: "23503", Constraint: "subscriptions_currency_fkey", Message: "insert or update violates foreign key"},
+			want: model.FieldError{Field: "currency", Code: "unknown_currency", Message: "валюта не найдена в справочнике"},
+		},
+		{
+			name: "нет курса из триггера",
+			err:  &pq.Error{Code: "22023", Constraint: "exchange_rate_required", Message: "нет курса USD/RUB на 01-01-2025"},
+			want: model.FieldError{Field: "currency", Code: "no_exchange_rate", Message: "нет курса USD/RUB на 01-01-2025"},
+		},
+		{
+			name: "написание другого сервиса",
+			err:  &pq.Error{Code: "23505", Constraint: "service_aliases_normalized_alias_key", Message: "duplicate key value"},
+			want: model.FieldError{Field: "aliases", Code: "duplicate_alias", Message: "название или написание уже принадлежит другому сервису каталога"},
+		},
+		{
+			name: "второй бюджет на область",
+			err:  &pq.Error{Code: "23505", Constraint: "budgets_scope_key", Message: "duplicate key value"},
+			want: model.FieldError{Field: "scope", Code: "duplicate_budget", Message: "бюджет на эту область уже задан"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := classifyDatabaseError(test.err)
+
+			var errs model.ValidationErrors
+			if !errors.As(err, &errs) || !errors.Is(err, model.ErrValidation) {
+				t.Fatalf("ожидались model.ValidationErrors, получено %v", err)
+			}
+			if len(errs) != 1 || errs[0] != test.want {
+				t.Errorf("ошибки полей %+v, ожидалось %+v", errs, test.want)
+			}
+			if errors.Is(err, model.ErrConflict) {
+				t.Error("нарушение ограничения поля не должно считаться конфликтом")
+			}
+		})
+	}
+}
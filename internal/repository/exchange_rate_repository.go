@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+type ExchangeRateRepository struct {
+	*config.Database
+}
+
+func NewExchangeRateRepository(database *config.Database) *ExchangeRateRepository {
+	return &ExchangeRateRepository{database}
+}
+
+// SaveExchangeRates добавляет курсы; курс той же пары на ту же дату перезаписывается
+func (repo *ExchangeRateRepository) SaveExchangeRates(ctx context.Context, exec sqlx.ExtContext, rates []model.ExchangeRate) error {
+	query := `INSERT INTO exchange_rates (base_currency, quote_currency, rate_date, rate)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (base_currency, quote_currency, rate_date) DO UPDATE
+			SET rate = EXCLUDED.rate
+	`
+
+	for _, rate := range rates {
+		_, err := exec.ExecContext(ctx, query,
+			rate.BaseCurrency,
+			rate.QuoteCurrency,
+			rate.RateDate.ToTime(),
+			rate.Rate,
+		)
+		if err != nil {
+			return databaseError("ошибка при сохранении курса валют", err)
+		}
+	}
+	return nil
+}
+
+func (repo *ExchangeRateRepository) GetExchangeRates(ctx context.Context, exec sqlx.ExtContext, filter model.ExchangeRateFilter) ([]model.ExchangeRate, error) {
+	query := `SELECT base_currency, quote_currency, rate_date, rate
+		FROM exchange_rates
+		WHERE ($1::char(3) IS NULL OR base_currency = $1) AND
+			($2::char(3) IS NULL OR quote_currency = $2)
+		ORDER BY base_currency, quote_currency, rate_date
+	`
+
+	rates := []model.ExchangeRate{}
+	err := sqlx.SelectContext(ctx, exec, &rates, query, filter.BaseCurrency, filter.QuoteCurrency)
+	if err != nil {
+		return nil, databaseError("ошибка получения курсов валют", err)
+	}
+	return rates, nil
+}
+
+func (repo *ExchangeRateRepository) GetCurrencies(ctx context.Context, exec sqlx.ExtContext) ([]model.Currency, error) {
+	query := `SELECT code, minor_units, name FROM currencies ORDER BY code`
+
+	currencies := []model.Currency{}
+	err := sqlx.SelectContext(ctx, exec, &currencies, query)
+	if err != nil {
+		return nil, databaseError("ошибка получения справочника валют", err)
+	}
+	return currencies, nil
+}
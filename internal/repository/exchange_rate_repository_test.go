@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTotalCostConversion(t *testing.T) {
+	database := openTestDatabase(t)
+	subscriptions := repository.NewSubscriptionRepository(database)
+	rates := repository.NewExchangeRateRepository(database)
+	ctx := context.Background()
+
+	// курс действует с даты до следующего курса пары
+	err := rates.SaveExchangeRates(ctx, database, []model.ExchangeRate{
+		{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(date(2030, time.January, 1)), Rate: 90},
+		{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(date(2030, time.March, 1)), Rate: 100},
+	})
+	if err != nil {
+		t.Fatalf("SaveExchangeRates: %v", err)
+	}
+
+	userID := newTestUserID(t)
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 1000, Currency: "USD", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: userID,
+		StartDate: model.DayMonthYear(date(2030, time.January, 1)), EndDate: model.DayMonthYear(date(2030, time.April, 30)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := subscriptions.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	tests := []struct {
+		currency string
+		total    int
+	}{
+		// 10 долларов в январе и феврале по 90, в марте и апреле по 100
+		{currency: "RUB", total: 2*90000 + 2*100000},
+		{currency: "USD", total: 4000},
+	}
+	for _, test := range tests {
+		filter := model.CostFilter{UserID: userID, Currency: test.currency, StartPeriod: date(2030, time.January, 1), EndPeriod: date(2030, time.December, 31)}
+		cost, err := subscriptions.GetTotalSubscriptionCost(ctx, database, filter)
+		if err != nil {
+			t.Fatalf("GetTotalSubscriptionCost(%s): %v", test.currency, err)
+		}
+		if cost.Total != test.total || cost.BilledMonths != 4 {
+			t.Errorf("стоимость в %s %d за %d мес., ожидалось %d за 4 мес.", test.currency, cost.Total, cost.BilledMonths, test.total)
+		}
+	}
+}
+
+func TestTotalCostWithoutExchangeRate(t *testing.T) {
+	database := openTestDatabase(t)
+	subscriptions := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	userID := newTestUserID(t)
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 1000, Currency: "EUR", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: userID,
+		StartDate: model.DayMonthYear(date(1990, time.January, 1)), EndDate: model.DayMonthYear(date(1990, time.January, 31)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := subscriptions.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	filter := model.CostFilter{UserID: userID, Currency: "RUB", StartPeriod: date(1990, time.January, 1), EndPeriod: date(1990, time.December, 31)}
+	_, err := subscriptions.GetTotalSubscriptionCost(ctx, database, filter)
+
+	var errs model.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Code != "no_exchange_rate" {
+		t.Errorf("без курса ожидалась ошибка currency/no_exchange_rate, получено %v", err)
+	}
+}
+
+func TestSaveSubscriptionUnknownCurrency(t *testing.T) {
+	database := openTestDatabase(t)
+	subscriptions := repository.NewSubscriptionRepository(database)
+
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 1000, Currency: "XXX", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: newTestUserID(t),
+		StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	err := subscriptions.SaveSubscription(context.Background(), database, subscription)
+
+	var errs model.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "currency" || errs[0].Code != "unknown_currency" {
+		t.Errorf("ожидалась ошибка currency/unknown_currency, получено %v", err)
+	}
+}
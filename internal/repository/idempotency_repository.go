@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type IdempotencyRepository struct {
+	*config.Database
+}
+
+func NewIdempotencyRepository(database *config.Database) *IdempotencyRepository {
+	return &IdempotencyRepository{database}
+}
+
+// ReserveKey занимает ключ в области scope за текущим запросом. Если ключ уже занят другой
+// транзакцией, вставка дожидается ее завершения; false означает, что ключ использовался
+// раньше. Просроченная запись удаляется, и ключ можно использовать заново
+func (repo *IdempotencyRepository) ReserveKey(ctx context.Context, exec sqlx.ExtContext, scope, key, requestHash string, expiresAt time.Time) (bool, error) {
+	_, err := exec.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND expires_at <= now()`, scope, key)
+	if err != nil {
+		return false, databaseError("ошибка удаления просроченного ключа идемпотентности", err)
+	}
+
+	query := `INSERT INTO idempotency_keys (scope, key, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (scope, key) DO NOTHING
+		RETURNING key
+	`
+	var reserved string
+	err = sqlx.GetContext(ctx, exec, &reserved, query, scope, key, requestHash, expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, databaseError("ошибка сохранения ключа идемпотентности", err)
+	}
+
+	return true, nil
+}
+
+func (repo *IdempotencyRepository) GetRecord(ctx context.Context, exec sqlx.ExtContext, scope, key string) (*model.IdempotencyRecord, error) {
+	query := `SELECT scope, key, request_hash, status_code, response_headers, response, expires_at
+		FROM idempotency_keys WHERE scope = $1 AND key = $2`
+
+	var record model.IdempotencyRecord
+	err := sqlx.GetContext(ctx, exec, &record, query, scope, key)
+	if err != nil {
+		return nil, databaseError("ошибка получения ключа идемпотентности", err)
+	}
+
+	return &record, nil
+}
+
+// SaveResponse сохраняет ответ на первый запрос с ключом: статус, заголовки для повтора
+// в JSON и тело как есть
+func (repo *IdempotencyRepository) SaveResponse(ctx context.Context, exec sqlx.ExtContext, scope, key string, statusCode int, headers, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = $3, response_headers = $4, response = $5
+		WHERE scope = $1 AND key = $2`
+
+	_, err := exec.ExecContext(ctx, query, scope, key, statusCode, string(headers), body)
+	if err != nil {
+		return databaseError("ошибка сохранения ответа для ключа идемпотентности", err)
+	}
+	return nil
+}
+
+// DeleteExpiredKeys удаляет ключи, срок которых истек к expiredBefore. ReserveKey
+// освобождает только повторно использованный ключ, остальные удаляет фоновая очистка
+func (repo *IdempotencyRepository) DeleteExpiredKeys(ctx context.Context, exec sqlx.ExtContext, expiredBefore time.Time) (int64, error) {
+	result, err := exec.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, expiredBefore)
+	if err != nil {
+		return 0, databaseError("ошибка удаления просроченных ключей идемпотентности", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, databaseError("ошибка удаления просроченных ключей идемпотентности", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReserveKey(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewIdempotencyRepository(database)
+	ctx := context.Background()
+
+	scope, key := newTestUserID(t), "key-1"
+	reserved, err := repo.ReserveKey(ctx, database, scope, key, "hash", time.Now().Add(time.Hour))
+	if err != nil || !reserved {
+		t.Fatalf("первый ReserveKey = %v, %v, ожидался true", reserved, err)
+	}
+	headers, body := []byte(`{"Location":"/subscriptions/1"}`), []byte("{\"id\": 1}\n")
+	if err := repo.SaveResponse(ctx, database, scope, key, http.StatusCreated, headers, body); err != nil {
+		t.Fatalf("SaveResponse: %v", err)
+	}
+
+	reserved, err = repo.ReserveKey(ctx, database, scope, key, "hash", time.Now().Add(time.Hour))
+	if err != nil || reserved {
+		t.Fatalf("повторный ReserveKey = %v, %v, ожидался false", reserved, err)
+	}
+	// ключ действует в пределах пользователя
+	reserved, err = repo.ReserveKey(ctx, database, newTestUserID(t), key, "hash", time.Now().Add(time.Hour))
+	if err != nil || !reserved {
+		t.Fatalf("ReserveKey того же ключа другого пользователя = %v, %v, ожидался true", reserved, err)
+	}
+
+	record, err := repo.GetRecord(ctx, database, scope, key)
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	if record.RequestHash != "hash" || record.StatusCode == nil || *record.StatusCode != http.StatusCreated {
+		t.Errorf("сохраненная запись %+v", record)
+	}
+	// тело ответа возвращается байт в байт, без нормализации JSON
+	if string(record.Response) != string(body) || string(record.ResponseHeaders) != string(headers) {
+		t.Errorf("сохраненный ответ %q, заголовки %q", record.Response, record.ResponseHeaders)
+	}
+}
+
+func TestIdempotencyReserveExpiredKey(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewIdempotencyRepository(database)
+	ctx := context.Background()
+
+	scope, key := newTestUserID(t), "key-1"
+	if _, err := repo.ReserveKey(ctx, database, scope, key, "old", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("ReserveKey: %v", err)
+	}
+
+	reserved, err := repo.ReserveKey(ctx, database, scope, key, "new", time.Now().Add(time.Hour))
+	if err != nil || !reserved {
+		t.Fatalf("ReserveKey просроченного ключа = %v, %v, ожидался true", reserved, err)
+	}
+	record, err := repo.GetRecord(ctx, database, scope, key)
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	if record.RequestHash != "new" || record.StatusCode != nil {
+		t.Errorf("после повторного резерва запись %+v", record)
+	}
+}
+
+func TestIdempotencyDeleteExpiredKeys(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewIdempotencyRepository(database)
+	ctx := context.Background()
+
+	scope := newTestUserID(t)
+	if _, err := repo.ReserveKey(ctx, database, scope, "expired", "hash", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("ReserveKey: %v", err)
+	}
+	if _, err := repo.ReserveKey(ctx, database, scope, "active", "hash", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("ReserveKey: %v", err)
+	}
+
+	if _, err := repo.DeleteExpiredKeys(ctx, database, time.Now()); err != nil {
+		t.Fatalf("DeleteExpiredKeys: %v", err)
+	}
+	if _, err := repo.GetRecord(ctx, database, scope, "expired"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("просроченный ключ не удален: %v", err)
+	}
+	if _, err := repo.GetRecord(ctx, database, scope, "active"); err != nil {
+		t.Errorf("действующий ключ удален: %v", err)
+	}
+}
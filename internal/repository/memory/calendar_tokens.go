@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"Effective_Mobile_Test_Project/internal/repository"
+	"bytes"
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+// SaveCalendarToken сохраняет хеш нового токена календаря пользователя, заменяя прежний
+func (store *Store) SaveCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string, tokenHash []byte) error {
+	defer store.write(exec)()
+
+	userID, err := repository.CanonicalUUID(userID)
+	if err != nil {
+		return storeError("ошибка сохранения токена календаря", err)
+	}
+	if len(tokenHash) != 32 {
+		return invalid("ошибка сохранения токена календаря", "хеш токена календаря должен занимать 32 байта")
+	}
+	store.data.calendarTokens[userID] = bytes.Clone(tokenHash)
+	return nil
+}
+
+func (store *Store) GetCalendarTokenHash(ctx context.Context, exec sqlx.ExtContext, userID string) ([]byte, error) {
+	defer store.read(exec)()
+
+	userID, err := repository.CanonicalUUID(userID)
+	if err != nil {
+		return nil, storeError("ошибка получения токена календаря", err)
+	}
+	tokenHash, ok := store.data.calendarTokens[userID]
+	if !ok {
+		return nil, notFound("ошибка получения токена календаря")
+	}
+	return bytes.Clone(tokenHash), nil
+}
+
+// DeleteCalendarToken отзывает токен календаря пользователя; если токена нет, возвращает ErrNotFound
+func (store *Store) DeleteCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string) error {
+	defer store.write(exec)()
+
+	userID, err := repository.CanonicalUUID(userID)
+	if err != nil {
+		return storeError("ошибка удаления токена календаря", err)
+	}
+	if _, ok := store.data.calendarTokens[userID]; !ok {
+		return notFound("токен календаря не найден")
+	}
+	delete(store.data.calendarTokens, userID)
+	return nil
+}
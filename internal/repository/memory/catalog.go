@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"slices"
+)
+
+// SaveCatalogService добавляет сервис в каталог вместе с написаниями. Каноническое
+// название тоже считается написанием, поэтому два сервиса не могут называться одинаково
+func (store *Store) SaveCatalogService(ctx context.Context, exec sqlx.ExtContext, service *model.CatalogService) error {
+	defer store.write(exec)()
+
+	const message = "ошибка при добавлении сервиса в каталог"
+	if _, ok := store.data.currencies[service.Currency]; !ok {
+		return foreignKeyError(message, "services", "services_currency_fkey")
+	}
+	if service.DefaultPrice != nil && *service.DefaultPrice < 0 {
+		return invalid(message, "цена по умолчанию не может быть отрицательной")
+	}
+
+	// написания с одинаковым ключом сопоставления сохраняются один раз, первое в порядке передачи
+	keys := map[string]bool{}
+	var aliases []string
+	for _, alias := range append([]string{service.Name}, service.Aliases...) {
+		key := repository.NormalizeServiceName(alias)
+		if key == "" {
+			return storeError("ошибка при сохранении написаний сервиса",
+				repository.ConstraintError("service_aliases_normalized_alias_check",
+					`new row for relation "service_aliases" violates check constraint "service_aliases_normalized_alias_check"`))
+		}
+		if _, taken := store.data.aliases[key]; taken {
+			return uniqueError("ошибка при сохранении написаний сервиса", "service_aliases_normalized_alias_key")
+		}
+		if !keys[key] {
+			keys[key] = true
+			aliases = append(aliases, alias)
+		}
+	}
+
+	store.data.serviceSeq++
+	saved := *service
+	saved.ID = store.data.serviceSeq
+	saved.Aliases = aliases
+	store.data.services[saved.ID] = saved
+	for key := range keys {
+		store.data.aliases[key] = saved.ID
+	}
+
+	*service = store.data.catalogService(saved)
+	return nil
+}
+
+func (store *Store) GetCatalogServiceByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.CatalogService, error) {
+	defer store.read(exec)()
+
+	saved, ok := store.data.services[id]
+	if !ok {
+		return nil, notFound("сервис с таким ID не найден в каталоге")
+	}
+	service := store.data.catalogService(saved)
+	return &service, nil
+}
+
+// FindCatalogServiceByName ищет сервис, у которого есть написание name с точностью
+// до регистра и знаков препинания
+func (store *Store) FindCatalogServiceByName(ctx context.Context, exec sqlx.ExtContext, name string) (*model.CatalogService, error) {
+	defer store.read(exec)()
+
+	id, ok := store.data.aliases[repository.NormalizeServiceName(name)]
+	if !ok {
+		return nil, notFound("сервис " + name + " не найден в каталоге")
+	}
+	service := store.data.catalogService(store.data.services[id])
+	return &service, nil
+}
+
+// LockCatalogServiceName ничего не делает: транзакции хранилища выполняются по одной,
+// поэтому параллельно добавить в каталог один и тот же сервис невозможно
+func (store *Store) LockCatalogServiceName(ctx context.Context, exec sqlx.ExtContext, name string) error {
+	return nil
+}
+
+// SaveExchangeRates добавляет курсы; курс той же пары на ту же дату перезаписывается
+func (store *Store) SaveExchangeRates(ctx context.Context, exec sqlx.ExtContext, rates []model.ExchangeRate) error {
+	defer store.write(exec)()
+
+	const message = "ошибка при сохранении курса валют"
+	for _, rate := range rates {
+		if _, ok := store.data.currencies[rate.BaseCurrency]; !ok {
+			return foreignKeyError(message, "exchange_rates", "exchange_rates_base_currency_fkey")
+		}
+		if _, ok := store.data.currencies[rate.QuoteCurrency]; !ok {
+			return foreignKeyError(message, "exchange_rates", "exchange_rates_quote_currency_fkey")
+		}
+		if rate.Rate <= 0 || rate.BaseCurrency == rate.QuoteCurrency {
+			return invalid(message, "курс %s к %s нарушает ограничения таблицы курсов", rate.BaseCurrency, rate.QuoteCurrency)
+		}
+
+		rateDate := billing.Day(rate.RateDate.ToTime())
+		rate.RateDate = model.DayMonthYear(rateDate)
+		store.data.rates[rateKey{rate.BaseCurrency, rate.QuoteCurrency, rateDate}] = rate
+	}
+	return nil
+}
+
+// catalogService собирает копию сервиса для выдачи: написания, кроме совпадающих
+// с каноническим названием, по алфавиту
+func (d *data) catalogService(saved model.CatalogService) model.CatalogService {
+	service := saved
+	service.Category = clonePointer(saved.Category)
+	service.DefaultPrice = clonePointer(saved.DefaultPrice)
+	service.LogoURL = clonePointer(saved.LogoURL)
+	nameKey := repository.NormalizeServiceName(saved.Name)
+	service.Aliases = []string{}
+	for _, alias := range saved.Aliases {
+		if repository.NormalizeServiceName(alias) != nameKey {
+			service.Aliases = append(service.Aliases, alias)
+		}
+	}
+	slices.Sort(service.Aliases)
+	return service
+}
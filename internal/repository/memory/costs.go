@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"slices"
+	"sort"
+	"time"
+)
+
+// conversionConstraints сопоставляет причины ошибок перевода валют с ограничениями,
+// которые в Postgres выбрасывает convert_amount
+var conversionConstraints = map[error]string{
+	billing.ErrNoExchangeRate:  "exchange_rate_required",
+	billing.ErrUnknownCurrency: "currency_required",
+}
+
+// billedCharges разворачивает подходящие под фильтр подписки в оплаты внутри периода
+// по тем же правилам, что и billedChargesQuery в реализации для Postgres
+func (d *data) billedCharges(filter model.CostFilter) ([]billing.BilledCharge, error) {
+	userID, err := repository.CanonicalUUID(filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	converter := d.converter()
+	var charges []billing.BilledCharge
+	for _, row := range d.sortedSubscriptions() {
+		if row.DeletedAt != nil || row.UserID != userID {
+			continue
+		}
+		if filter.ServiceName != nil && !d.isService(row.ServiceID, *filter.ServiceName) {
+			continue
+		}
+		category := d.category(&row)
+		if filter.Category != nil && (category == nil || *category != *filter.Category) {
+			continue
+		}
+		if filter.Tag != nil && !slices.Contains(row.Tags, *filter.Tag) {
+			continue
+		}
+
+		planned, err := d.plan(&row).Charges(filter.StartPeriod, filter.EndPeriod)
+		if err != nil {
+			return nil, err
+		}
+		for _, charge := range planned {
+			amount, err := converter.Convert(charge.Price, row.Currency, filter.Currency, charge.Date)
+			if err != nil {
+				return nil, conversionError(err)
+			}
+			charges = append(charges, billing.BilledCharge{
+				SubscriptionID: row.ID,
+				ServiceName:    row.ServiceName,
+				Category:       category,
+				Tags:           row.Tags,
+				Date:           charge.Date,
+				Amount:         amount,
+			})
+		}
+	}
+	return charges, nil
+}
+
+// plan собирает план оплат подписки из ее истории цен и пауз
+func (d *data) plan(row *model.SubscriptionDetails) billing.Plan {
+	return billing.NewPlan(row, d.prices[row.ID], d.pauses[row.ID])
+}
+
+func (d *data) converter() *billing.Converter {
+	currencies := make([]model.Currency, 0, len(d.currencies))
+	for _, currency := range d.currencies {
+		currencies = append(currencies, currency)
+	}
+	rates := make([]model.ExchangeRate, 0, len(d.rates))
+	for _, rate := range d.rates {
+		rates = append(rates, rate)
+	}
+	return billing.NewConverter(currencies, rates)
+}
+
+// conversionError превращает ошибку перевода валют в нарушение ограничения, как в Postgres
+func conversionError(err error) error {
+	var conversion *billing.ConversionError
+	if errors.As(err, &conversion) {
+		return repository.ConstraintError(conversionConstraints[conversion.Reason], conversion.Message)
+	}
+	return err
+}
+
+// GetTotalSubscriptionCost считает стоимость как сумму цен, действовавших на каждую
+// дату оплаты внутри периода, в валюте filter.Currency
+func (store *Store) GetTotalSubscriptionCost(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) (*model.SubscriptionCost, error) {
+	defer store.read(exec)()
+
+	charges, err := store.data.billedCharges(filter)
+	if err != nil {
+		return nil, storeError("ошибка подсчета общей стоимости подписок", err)
+	}
+	cost := billing.Total(charges)
+	return &cost, nil
+}
+
+// GetSubscriptionCostGroups делит общую стоимость на группы по разрезу filter.GroupBy
+func (store *Store) GetSubscriptionCostGroups(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.CostGroup, error) {
+	defer store.read(exec)()
+
+	const message = "ошибка группировки стоимости подписок"
+	charges, err := store.data.billedCharges(filter)
+	if err != nil {
+		return nil, storeError(message, err)
+	}
+	groups, err := billing.Groups(charges, filter.GroupBy)
+	if err != nil {
+		return nil, storeError(message, err)
+	}
+	return groups, nil
+}
+
+// GetSubscriptionCostBreakdown возвращает суммы списаний, сгруппированные по календарному
+// месяцу и разрезу filter.GroupBy
+func (store *Store) GetSubscriptionCostBreakdown(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.MonthlyCost, error) {
+	defer store.read(exec)()
+
+	const message = "ошибка получения помесячной стоимости подписок"
+	charges, err := store.data.billedCharges(filter)
+	if err != nil {
+		return nil, storeError(message, err)
+	}
+	breakdown, err := billing.Breakdown(charges, filter.GroupBy)
+	if err != nil {
+		return nil, storeError(message, err)
+	}
+	return breakdown, nil
+}
+
+// GetMonthlySpend считает траты по календарным месяцам и долю подписки subscriptionID в них
+func (store *Store) GetMonthlySpend(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter, subscriptionID int) ([]model.MonthlySpend, error) {
+	defer store.read(exec)()
+
+	charges, err := store.data.billedCharges(filter)
+	if err != nil {
+		return nil, storeError("ошибка подсчета помесячных трат", err)
+	}
+	return billing.MonthlySpend(charges, subscriptionID), nil
+}
+
+// GetUpcomingRenewals возвращает ближайшие оплаты подписок пользователя в периоде [from, to]
+// в порядке дат оплаты. Отмененные и удаленные подписки не оплачиваются
+func (store *Store) GetUpcomingRenewals(ctx context.Context, exec sqlx.ExtContext, uuid string, from, to time.Time) ([]model.UpcomingRenewal, error) {
+	defer store.read(exec)()
+
+	const message = "ошибка получения предстоящих оплат"
+	userID, err := repository.CanonicalUUID(uuid)
+	if err != nil {
+		return nil, storeError(message, err)
+	}
+
+	renewals := []model.UpcomingRenewal{}
+	for _, row := range store.data.sortedSubscriptions() {
+		if row.DeletedAt != nil || row.CancelledAt != nil || row.UserID != userID {
+			continue
+		}
+		charges, err := store.data.plan(&row).Charges(from, to)
+		if err != nil {
+			return nil, storeError(message, err)
+		}
+		if len(charges) == 0 {
+			continue
+		}
+		renewals = append(renewals, model.UpcomingRenewal{
+			SubscriptionID: row.ID,
+			UserID:         row.UserID,
+			ServiceName:    row.ServiceName,
+			Price:          charges[0].Price,
+			Currency:       row.Currency,
+			BillingDate:    model.DayMonthYear(charges[0].Date),
+			DaysLeft:       int(charges[0].Date.Sub(billing.Day(from)).Hours() / 24),
+		})
+	}
+
+	sort.SliceStable(renewals, func(i, j int) bool {
+		return renewals[i].BillingDate.ToTime().Before(renewals[j].BillingDate.ToTime())
+	})
+	return renewals, nil
+}
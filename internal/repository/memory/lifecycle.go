@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"slices"
+	"sort"
+)
+
+// SaveSubscriptionPrice записывает изменение цены с даты effective_from. Повторное изменение
+// на ту же дату заменяет ранее запланированную цену
+func (store *Store) SaveSubscriptionPrice(ctx context.Context, exec sqlx.ExtContext, price *model.SubscriptionPrice) error {
+	defer store.write(exec)()
+
+	const message = "ошибка при сохранении изменения цены подписки"
+	if _, ok := store.data.subscriptions[price.SubscriptionID]; !ok {
+		return foreignKeyError(message, "subscription_prices", "subscription_prices_subscription_id_fkey")
+	}
+	if price.Price < 0 {
+		return invalid(message, "цена не может быть отрицательной")
+	}
+
+	effectiveFrom := billing.Day(price.EffectiveFrom.ToTime())
+	saved := model.SubscriptionPrice{
+		SubscriptionID: price.SubscriptionID,
+		Price:          price.Price,
+		EffectiveFrom:  model.DayMonthYear(effectiveFrom),
+		CreatedAt:      now(),
+	}
+
+	prices := slices.Clone(store.data.prices[price.SubscriptionID])
+	index := slices.IndexFunc(prices, func(existing model.SubscriptionPrice) bool {
+		return existing.EffectiveFrom.ToTime().Equal(effectiveFrom)
+	})
+	if index >= 0 {
+		saved.ID = prices[index].ID
+		prices[index] = saved
+	} else {
+		store.data.priceSeq++
+		saved.ID = store.data.priceSeq
+		prices = append(prices, saved)
+		sort.SliceStable(prices, func(i, j int) bool {
+			return prices[i].EffectiveFrom.ToTime().Before(prices[j].EffectiveFrom.ToTime())
+		})
+	}
+	store.data.prices[price.SubscriptionID] = prices
+
+	price.ID, price.CreatedAt = saved.ID, saved.CreatedAt
+	return nil
+}
+
+// GetSubscriptionPrices возвращает все изменения цены подписки, прошлые и запланированные,
+// в порядке вступления в силу
+func (store *Store) GetSubscriptionPrices(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPrice, error) {
+	defer store.read(exec)()
+
+	return append([]model.SubscriptionPrice{}, store.data.prices[subscriptionID]...), nil
+}
+
+// PauseSubscription открывает паузу подписки с текущего дня. У подписки не больше
+// одной незавершенной паузы
+func (store *Store) PauseSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	defer store.write(exec)()
+
+	const message = "ошибка при приостановке подписки"
+	if _, ok := store.data.subscriptions[id]; !ok {
+		return foreignKeyError(message, "subscription_pauses", "subscription_pauses_subscription_id_fkey")
+	}
+	pauses := store.data.pauses[id]
+	if slices.ContainsFunc(pauses, func(pause model.SubscriptionPause) bool { return pause.ResumedOn == nil }) {
+		return uniqueError(message, "idx_subscription_pauses_open")
+	}
+
+	store.data.pauseSeq++
+	store.data.pauses[id] = append(slices.Clone(pauses), model.SubscriptionPause{
+		ID:             store.data.pauseSeq,
+		SubscriptionID: id,
+		PausedFrom:     model.DayMonthYear(today()),
+	})
+	return nil
+}
+
+// ResumeSubscription завершает открытую паузу подписки текущим днем
+func (store *Store) ResumeSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	defer store.write(exec)()
+
+	store.data.resume(id)
+	return nil
+}
+
+// CancelSubscription отменяет подписку: оплаты после текущего дня больше не списываются,
+// открытая пауза завершается
+func (store *Store) CancelSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	defer store.write(exec)()
+
+	store.data.resume(id)
+
+	row, ok := store.data.subscriptions[id]
+	if !ok || row.DeletedAt != nil {
+		return nil
+	}
+	cancelledAt, day := now(), today()
+	row.CancelledAt = &cancelledAt
+	if endDate := row.EndDate.ToTime(); endDate.IsZero() || endDate.After(day) {
+		row.EndDate = model.DayMonthYear(day)
+	}
+	store.data.subscriptions[id] = row
+	return nil
+}
+
+// IncrementSubscriptionVersion увеличивает версию подписки после изменения ее состояния
+// и возвращает подписку в новом состоянии
+func (store *Store) IncrementSubscriptionVersion(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	defer store.write(exec)()
+
+	row, ok := store.data.subscriptions[id]
+	if !ok || row.DeletedAt != nil {
+		return nil, notFound("ошибка при обновлении версии подписки")
+	}
+	row.Version++
+	store.data.subscriptions[id] = row
+
+	subscription := store.data.details(row)
+	return &subscription, nil
+}
+
+// GetSubscriptionPauses возвращает паузы подписки в хронологическом порядке
+func (store *Store) GetSubscriptionPauses(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPause, error) {
+	defer store.read(exec)()
+
+	pauses := make([]model.SubscriptionPause, 0, len(store.data.pauses[subscriptionID]))
+	for _, pause := range store.data.pauses[subscriptionID] {
+		if pause.ResumedOn != nil {
+			resumedOn := *pause.ResumedOn
+			pause.ResumedOn = &resumedOn
+		}
+		pauses = append(pauses, pause)
+	}
+	sort.SliceStable(pauses, func(i, j int) bool {
+		if !pauses[i].PausedFrom.ToTime().Equal(pauses[j].PausedFrom.ToTime()) {
+			return pauses[i].PausedFrom.ToTime().Before(pauses[j].PausedFrom.ToTime())
+		}
+		return pauses[i].ID < pauses[j].ID
+	})
+	return pauses, nil
+}
+
+// resume завершает открытую паузу подписки id текущим днем, но не раньше ее начала
+func (d *data) resume(id int) {
+	pauses := slices.Clone(d.pauses[id])
+	for i := range pauses {
+		if pauses[i].ResumedOn != nil {
+			continue
+		}
+		resumedOn := model.DayMonthYear(today())
+		if resumedOn.ToTime().Before(pauses[i].PausedFrom.ToTime()) {
+			resumedOn = pauses[i].PausedFrom
+		}
+		pauses[i].ResumedOn = &resumedOn
+	}
+	if pauses != nil {
+		d.pauses[id] = pauses
+	}
+}
@@ -0,0 +1,208 @@
+// Package memory - хранилище подписок в памяти процесса с той же семантикой, что и
+// repository.SubscriptionRepository поверх Postgres: те же ошибки, версии, мягкое удаление
+// и правила расчета стоимости. Подходит для тестов и запуска без БД; данные теряются
+// при остановке процесса
+package memory
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"maps"
+	"sync"
+	"time"
+)
+
+// executor помечает, в какой транзакции выполняется метод. Запросов он не выполняет:
+// вложенный sqlx.ExtContext пуст и нужен только для совместимости сигнатур
+type executor struct {
+	sqlx.ExtContext
+}
+
+// Store
+// Хранилище подписок в памяти. Реализует repository.SubscriptionStore,
+// repository.ServiceCatalog и repository.CalendarTokenStore и безопасно для параллельного использования. Транзакции
+// выполняются по одной и видят только собственные изменения, как уровень SERIALIZABLE
+type Store struct {
+	mu      sync.RWMutex
+	outside *executor
+	data    *data
+}
+
+var (
+	_ repository.SubscriptionStore  = (*Store)(nil)
+	_ repository.ServiceCatalog     = (*Store)(nil)
+	_ repository.CalendarTokenStore = (*Store)(nil)
+)
+
+// defaultCurrencies повторяет справочник валют из миграции 009
+var defaultCurrencies = []model.Currency{
+	{Code: "RUB", MinorUnits: 2, Name: "Российский рубль"},
+	{Code: "USD", MinorUnits: 2, Name: "Доллар США"},
+	{Code: "EUR", MinorUnits: 2, Name: "Евро"},
+}
+
+// NewStore создает пустое хранилище со справочником валют RUB, USD и EUR
+func NewStore() *Store {
+	store := &Store{
+		outside: &executor{},
+		data: &data{
+			subscriptions:  map[int]model.SubscriptionDetails{},
+			prices:         map[int][]model.SubscriptionPrice{},
+			pauses:         map[int][]model.SubscriptionPause{},
+			services:       map[int]model.CatalogService{},
+			aliases:        map[string]int{},
+			currencies:     map[string]model.Currency{},
+			rates:          map[rateKey]model.ExchangeRate{},
+			calendarTokens: map[string][]byte{},
+		},
+	}
+	for _, currency := range defaultCurrencies {
+		store.data.currencies[currency.Code] = currency
+	}
+	return store
+}
+
+func (store *Store) Executor() sqlx.ExtContext {
+	return store.outside
+}
+
+// InTransaction выполняет fn под исключительной блокировкой хранилища на снимке данных:
+// если fn вернула ошибку или запаниковала, данные возвращаются к снимку
+func (store *Store) InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error {
+	if err := ctx.Err(); err != nil {
+		return util.LogError("не удалось начать транзакцию", fmt.Errorf("%w: %w", model.ErrUnavailable, err))
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	snapshot := store.data.clone()
+	committed := false
+	defer func() {
+		if !committed {
+			store.data = snapshot
+		}
+	}()
+
+	if err := fn(&executor{}); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// read берет блокировку на чтение, если метод вызван вне транзакции. Внутри транзакции
+// блокировка уже взята InTransaction
+func (store *Store) read(exec sqlx.ExtContext) func() {
+	if store.inTransaction(exec) {
+		return func() {}
+	}
+	store.mu.RLock()
+	return store.mu.RUnlock
+}
+
+// write берет блокировку на запись, если метод вызван вне транзакции
+func (store *Store) write(exec sqlx.ExtContext) func() {
+	if store.inTransaction(exec) {
+		return func() {}
+	}
+	store.mu.Lock()
+	return store.mu.Unlock
+}
+
+// inTransaction отличает исполнителя транзакции от Executor(). Методы внутри транзакции
+// получают либо исполнителя, переданного в fn, либо обертку над ним
+func (store *Store) inTransaction(exec sqlx.ExtContext) bool {
+	return exec != nil && exec != sqlx.ExtContext(store.outside)
+}
+
+// rateKey - первичный ключ курса валют
+type rateKey struct {
+	base  string
+	quote string
+	date  time.Time
+}
+
+// data
+// Содержимое хранилища. Срезы в значениях карт не меняются на месте, а заменяются
+// копиями, поэтому для снимка транзакции достаточно скопировать карты
+type data struct {
+	subscriptionSeq int
+	subscriptions   map[int]model.SubscriptionDetails
+	priceSeq        int64
+	prices          map[int][]model.SubscriptionPrice
+	pauseSeq        int64
+	pauses          map[int][]model.SubscriptionPause
+	serviceSeq      int
+	services        map[int]model.CatalogService
+	aliases         map[string]int
+	currencies      map[string]model.Currency
+	rates           map[rateKey]model.ExchangeRate
+	calendarTokens  map[string][]byte
+}
+
+func (d *data) clone() *data {
+	cloned := *d
+	cloned.subscriptions = maps.Clone(d.subscriptions)
+	cloned.prices = maps.Clone(d.prices)
+	cloned.pauses = maps.Clone(d.pauses)
+	cloned.services = maps.Clone(d.services)
+	cloned.aliases = maps.Clone(d.aliases)
+	cloned.currencies = maps.Clone(d.currencies)
+	cloned.rates = maps.Clone(d.rates)
+	cloned.calendarTokens = maps.Clone(d.calendarTokens)
+	return &cloned
+}
+
+// now возвращает текущее время с точностью TIMESTAMPTZ
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// today возвращает текущую дату, как current_date
+func today() time.Time {
+	return billing.Day(time.Now().UTC())
+}
+
+// clonePointer копирует значение под указателем, чтобы вызывающий не мог изменить
+// данные хранилища через выданную структуру
+func clonePointer[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+// storeError логирует ошибку хранилища так же, как databaseError в репозиториях Postgres
+func storeError(message string, err error) error {
+	return util.LogError(message, err)
+}
+
+// notFound - ошибка отсутствующей строки, как sql.ErrNoRows после classifyDatabaseError
+func notFound(message string) error {
+	return storeError(message, fmt.Errorf("%w: %w", model.ErrNotFound, sql.ErrNoRows))
+}
+
+// invalid - нарушение CHECK или неверный формат значения
+func invalid(message string, format string, args ...any) error {
+	return storeError(message, fmt.Errorf("%w: %s", model.ErrValidation, fmt.Sprintf(format, args...)))
+}
+
+// foreignKeyError - нарушение внешнего ключа constraint таблицы table
+func foreignKeyError(message, table, constraint string) error {
+	return storeError(message, repository.ConstraintError(constraint,
+		fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint)))
+}
+
+// uniqueError - нарушение уникальности constraint
+func uniqueError(message, constraint string) error {
+	return storeError(message, repository.ConstraintError(constraint,
+		fmt.Sprintf("duplicate key value violates unique constraint %q", constraint)))
+}
@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/repository/memory"
+	"Effective_Mobile_Test_Project/internal/repository/storetest"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		store := memory.NewStore()
+		return storetest.Backend{Store: store, Catalog: store, Rates: store, CalendarTokens: store}
+	})
+}
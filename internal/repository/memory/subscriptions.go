@@ -0,0 +1,561 @@
+package memory
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// farFuture заменяет пустую end_date при сортировке, как '9999-12-31' в Postgres
+var farFuture = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// sortValue возвращает значение поля сортировки строки и разбирает значение из курсора
+type sortValue struct {
+	of    func(subscription *model.SubscriptionDetails) any
+	parse func(value string) (any, error)
+}
+
+func parseInt(value string) (any, error) {
+	return strconv.Atoi(value)
+}
+
+func parseText(value string) (any, error) {
+	return value, nil
+}
+
+func parseDate(value string) (any, error) {
+	return time.Parse(time.DateOnly, value)
+}
+
+var sortValues = map[string]sortValue{
+	"id": {
+		of:    func(subscription *model.SubscriptionDetails) any { return subscription.ID },
+		parse: parseInt,
+	},
+	"service_name": {
+		of:    func(subscription *model.SubscriptionDetails) any { return subscription.ServiceName },
+		parse: parseText,
+	},
+	"price": {
+		of:    func(subscription *model.SubscriptionDetails) any { return subscription.Price },
+		parse: parseInt,
+	},
+	"start_date": {
+		of:    func(subscription *model.SubscriptionDetails) any { return subscription.StartDate.ToTime() },
+		parse: parseDate,
+	},
+	"end_date": {
+		of: func(subscription *model.SubscriptionDetails) any {
+			if subscription.EndDate.ToTime().IsZero() {
+				return farFuture
+			}
+			return subscription.EndDate.ToTime()
+		},
+		parse: parseDate,
+	},
+}
+
+// compareValues сравнивает значения поля сортировки одного типа. Строки сравниваются
+// побайтово, как при сортировке COLLATE "C"
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return a - b.(int)
+	case string:
+		switch {
+		case a < b.(string):
+			return -1
+		case a > b.(string):
+			return 1
+		}
+		return 0
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
+func (store *Store) SaveSubscription(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) error {
+	defer store.write(exec)()
+
+	row := *subscription
+	if err := store.data.checkSubscription(&row); err != nil {
+		return storeError("ошибка при вставке подписки", err)
+	}
+	tags, err := checkTags(subscription.Tags)
+	if err != nil {
+		return storeError("ошибка при сохранении тегов", err)
+	}
+
+	store.data.subscriptionSeq++
+	row.ID = store.data.subscriptionSeq
+	row.Tags = tags
+	row.Version = 1
+	row.CancelledAt = nil
+	row.DeletedAt = nil
+	store.data.subscriptions[row.ID] = row
+
+	// как и в Postgres, теги возвращаются в том виде, в каком их передали
+	given := subscription.Tags
+	*subscription = store.data.details(row)
+	subscription.Tags = given
+	return nil
+}
+
+func (store *Store) GetSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	defer store.read(exec)()
+
+	row, ok := store.data.subscriptions[id]
+	if !ok || row.DeletedAt != nil {
+		return nil, notFound("не удалось найти подписку по ее id")
+	}
+	subscription := store.data.details(row)
+	return &subscription, nil
+}
+
+// LockSubscriptionByID читает подписку. Отдельная блокировка строки не нужна: транзакции
+// хранилища выполняются по одной
+func (store *Store) LockSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	return store.GetSubscriptionByID(ctx, exec, id)
+}
+
+// LockDeletedSubscriptionByID читает мягко удаленную подписку
+func (store *Store) LockDeletedSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	defer store.read(exec)()
+
+	row, ok := store.data.subscriptions[id]
+	if !ok || row.DeletedAt == nil {
+		return nil, store.data.restoreConflict(id)
+	}
+	subscription := store.data.details(row)
+	return &subscription, nil
+}
+
+func (store *Store) GetSubscriptionsByUserUUID(ctx context.Context, exec sqlx.ExtContext, uuid string) ([]model.SubscriptionDetails, error) {
+	defer store.read(exec)()
+
+	userID, err := repository.CanonicalUUID(uuid)
+	if err != nil {
+		return nil, storeError("ошибка получения подписок по uuid пользователя", err)
+	}
+
+	subscriptions := []model.SubscriptionDetails{}
+	for _, row := range store.data.sortedSubscriptions() {
+		if row.UserID == userID && row.DeletedAt == nil {
+			subscriptions = append(subscriptions, store.data.details(row))
+		}
+	}
+	return subscriptions, nil
+}
+
+// ListSubscriptions возвращает страницу подписок с keyset-пагинацией по паре
+// (значение сортировки, id), как и реализация для Postgres
+func (store *Store) ListSubscriptions(ctx context.Context, exec sqlx.ExtContext, filter model.SubscriptionListFilter) (*model.SubscriptionPage, error) {
+	defer store.read(exec)()
+
+	const message = "ошибка получения списка подписок"
+	sortColumn, ok := sortValues[filter.Sort]
+	if !ok {
+		return nil, storeError(message, fmt.Errorf("неизвестное поле сортировки %q", filter.Sort))
+	}
+	items, err := store.data.list(&filter, sortColumn)
+	if err != nil {
+		return nil, storeError(message, err)
+	}
+
+	page := &model.SubscriptionPage{Items: []model.SubscriptionDetails{}}
+	if len(items) > filter.Limit {
+		page.Items = items[:filter.Limit]
+		last := &page.Items[len(page.Items)-1]
+		value := sortColumn.of(last)
+		page.NextCursor = model.ListCursor{
+			Sort:  filter.Sort,
+			Desc:  filter.Desc,
+			Value: formatValue(value),
+			ID:    last.ID,
+		}.Encode()
+	} else if items != nil {
+		page.Items = items
+	}
+	return page, nil
+}
+
+// list отбирает подписки, подходящие под фильтр списка, после курсора filter.After,
+// если он задан, и сортирует их; размер страницы не учитывается
+func (d *data) list(filter *model.SubscriptionListFilter, sortColumn sortValue) ([]model.SubscriptionDetails, error) {
+	var userID string
+	if filter.UserID != nil {
+		var err error
+		if userID, err = repository.CanonicalUUID(*filter.UserID); err != nil {
+			return nil, err
+		}
+	}
+	var after any
+	if filter.After != nil {
+		var err error
+		if after, err = sortColumn.parse(filter.After.Value); err != nil {
+			return nil, fmt.Errorf("%w: неверное значение курсора %q", model.ErrValidation, filter.After.Value)
+		}
+	}
+
+	var items []model.SubscriptionDetails
+	for _, row := range d.subscriptions {
+		if (row.DeletedAt != nil) != filter.Deleted {
+			continue
+		}
+		subscription := d.details(row)
+		if filter.UserID != nil && subscription.UserID != userID {
+			continue
+		}
+		if !d.matchesList(&subscription, filter) {
+			continue
+		}
+		if after != nil {
+			cmp := compareValues(sortColumn.of(&subscription), after)
+			if cmp == 0 {
+				cmp = subscription.ID - filter.After.ID
+			}
+			if (!filter.Desc && cmp <= 0) || (filter.Desc && cmp >= 0) {
+				continue
+			}
+		}
+		items = append(items, subscription)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		cmp := compareValues(sortColumn.of(&items[i]), sortColumn.of(&items[j]))
+		if cmp == 0 {
+			cmp = items[i].ID - items[j].ID
+		}
+		if filter.Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+	return items, nil
+}
+
+// formatValue записывает значение поля сортировки в курсор в том же виде, что и Postgres
+func formatValue(value any) string {
+	switch value := value.(type) {
+	case int:
+		return strconv.Itoa(value)
+	case time.Time:
+		return value.Format(time.DateOnly)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// matchesList проверяет фильтры списка, кроме пользователя и удаленности
+func (d *data) matchesList(subscription *model.SubscriptionDetails, filter *model.SubscriptionListFilter) bool {
+	startDate, endDate := subscription.StartDate.ToTime(), subscription.EndDate.ToTime()
+	switch {
+	case filter.ServiceID != nil && subscription.ServiceID != *filter.ServiceID:
+		return false
+	case filter.ServiceName != nil && !d.isService(subscription.ServiceID, *filter.ServiceName):
+		return false
+	case filter.Category != nil && (subscription.Category == nil || *subscription.Category != *filter.Category):
+		return false
+	case filter.PriceMin != nil && subscription.Price < *filter.PriceMin:
+		return false
+	case filter.PriceMax != nil && subscription.Price > *filter.PriceMax:
+		return false
+	case filter.ActiveOn != nil && (startDate.After(billing.Day(*filter.ActiveOn)) ||
+		(!endDate.IsZero() && endDate.Before(billing.Day(*filter.ActiveOn)))):
+		return false
+	case filter.StartFrom != nil && startDate.Before(billing.Day(*filter.StartFrom)):
+		return false
+	case filter.StartTo != nil && startDate.After(billing.Day(*filter.StartTo)):
+		return false
+	case filter.EndFrom != nil && (endDate.IsZero() || endDate.Before(billing.Day(*filter.EndFrom))):
+		return false
+	case filter.EndTo != nil && (endDate.IsZero() || endDate.After(billing.Day(*filter.EndTo))):
+		return false
+	case filter.Status != nil && subscription.Status != *filter.Status:
+		return false
+	}
+	for _, tag := range filter.Tags {
+		if !slices.Contains(subscription.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateSubscriptionByID перезаписывает подписку и увеличивает ее версию. Если expectedVersion
+// задана, обновление выполняется только при совпадении с текущей версией
+func (store *Store) UpdateSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails, id int, expectedVersion *int) error {
+	defer store.write(exec)()
+
+	// теги меняются до проверки версии, как и в Postgres, где они заменяются до UPDATE
+	if err := store.data.replaceTags(id, subscription.Tags); err != nil {
+		return err
+	}
+
+	current, ok := store.data.subscriptions[id]
+	if !ok || current.DeletedAt != nil || (expectedVersion != nil && *expectedVersion != current.Version) {
+		return store.data.versionConflict(id)
+	}
+
+	row := *subscription
+	if err := store.data.checkSubscription(&row); err != nil {
+		return storeError("ошибка при обновлении подписки", err)
+	}
+	row.ID = id
+	row.Tags = current.Tags
+	row.Version = current.Version + 1
+	row.CancelledAt = current.CancelledAt
+	row.DeletedAt = nil
+	store.data.subscriptions[id] = row
+
+	*subscription = store.data.details(row)
+	return nil
+}
+
+// PatchSubscriptionByID обновляет только переданные в патче поля и возвращает подписку
+// после изменения. Пустой патч ничего не меняет, но версия все равно проверяется
+func (store *Store) PatchSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, patch model.SubscriptionPatch, expectedVersion *int) (*model.SubscriptionDetails, error) {
+	if patch.IsEmpty() {
+		subscription, err := store.GetSubscriptionByID(ctx, exec, id)
+		if err != nil {
+			return nil, err
+		}
+		if expectedVersion != nil && *expectedVersion != subscription.Version {
+			return nil, storeError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+		}
+		return subscription, nil
+	}
+
+	defer store.write(exec)()
+
+	if patch.Tags != nil {
+		if err := store.data.replaceTags(id, *patch.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	current, ok := store.data.subscriptions[id]
+	if !ok || current.DeletedAt != nil || (expectedVersion != nil && *expectedVersion != current.Version) {
+		return nil, store.data.versionConflict(id)
+	}
+
+	row := current
+	patch.Apply(&row)
+	if err := store.data.checkSubscription(&row); err != nil {
+		return nil, storeError("ошибка при частичном обновлении подписки", err)
+	}
+	row.Tags = current.Tags
+	row.Version = current.Version + 1
+	store.data.subscriptions[id] = row
+
+	subscription := store.data.details(row)
+	return &subscription, nil
+}
+
+// DeleteSubscriptionByID мягко удаляет подписку; при заданной expectedVersion только если
+// версия не изменилась
+func (store *Store) DeleteSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, expectedVersion *int) error {
+	defer store.write(exec)()
+
+	row, ok := store.data.subscriptions[id]
+	if !ok || row.DeletedAt != nil || (expectedVersion != nil && *expectedVersion != row.Version) {
+		return store.data.versionConflict(id)
+	}
+
+	deletedAt := now()
+	row.DeletedAt = &deletedAt
+	row.Version++
+	store.data.subscriptions[id] = row
+	return nil
+}
+
+// RestoreSubscriptionByID возвращает мягко удаленную подписку и увеличивает ее версию
+func (store *Store) RestoreSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	defer store.write(exec)()
+
+	row, ok := store.data.subscriptions[id]
+	if !ok || row.DeletedAt == nil {
+		return nil, store.data.restoreConflict(id)
+	}
+
+	row.DeletedAt = nil
+	row.Version++
+	store.data.subscriptions[id] = row
+
+	subscription := store.data.details(row)
+	return &subscription, nil
+}
+
+// PurgeDeletedSubscriptions окончательно удаляет подписки, мягко удаленные раньше
+// deletedBefore, вместе с их ценами и паузами
+func (store *Store) PurgeDeletedSubscriptions(ctx context.Context, exec sqlx.ExtContext, deletedBefore time.Time) (int64, error) {
+	defer store.write(exec)()
+
+	var purged int64
+	for id, row := range store.data.subscriptions {
+		if row.DeletedAt == nil || !row.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+		delete(store.data.subscriptions, id)
+		delete(store.data.prices, id)
+		delete(store.data.pauses, id)
+		purged++
+	}
+	return purged, nil
+}
+
+// checkSubscription проверяет строку подписки ограничениями схемы и приводит даты
+// и UUID к виду, в котором их хранит Postgres
+func (d *data) checkSubscription(row *model.SubscriptionDetails) error {
+	userID, err := repository.CanonicalUUID(row.UserID)
+	if err != nil {
+		return err
+	}
+	row.UserID = userID
+
+	if !billingPeriods[row.BillingPeriod] {
+		return fmt.Errorf("%w: неизвестный период оплаты %q", model.ErrValidation, row.BillingPeriod)
+	}
+	if row.BillingInterval <= 0 {
+		return fmt.Errorf("%w: интервал оплаты должен быть положительным", model.ErrValidation)
+	}
+	if _, ok := d.currencies[row.Currency]; !ok {
+		return repository.ConstraintError("subscriptions_currency_fkey",
+			`insert or update on table "subscriptions" violates foreign key constraint "subscriptions_currency_fkey"`)
+	}
+	if _, ok := d.services[row.ServiceID]; !ok {
+		return repository.ConstraintError("subscriptions_service_id_fkey",
+			`insert or update on table "subscriptions" violates foreign key constraint "subscriptions_service_id_fkey"`)
+	}
+
+	row.StartDate = model.DayMonthYear(billing.Day(row.StartDate.ToTime()))
+	if !row.EndDate.ToTime().IsZero() {
+		row.EndDate = model.DayMonthYear(billing.Day(row.EndDate.ToTime()))
+	}
+	if row.TrialEnd != nil {
+		trialEnd := model.DayMonthYear(billing.Day(row.TrialEnd.ToTime()))
+		row.TrialEnd = &trialEnd
+	}
+	row.Status = ""
+	return nil
+}
+
+var billingPeriods = map[string]bool{
+	model.BillingPeriodDay:   true,
+	model.BillingPeriodWeek:  true,
+	model.BillingPeriodMonth: true,
+	model.BillingPeriodYear:  true,
+}
+
+// checkTags приводит теги к виду, в котором их возвращает Postgres: без повторов,
+// по алфавиту. Пустой тег нарушает CHECK справочника тегов
+func checkTags(tags []string) (model.Tags, error) {
+	checked := slices.Clone(tags)
+	if slices.Contains(checked, "") {
+		return nil, fmt.Errorf("%w: имя тега не может быть пустым", model.ErrValidation)
+	}
+	slices.Sort(checked)
+	return slices.Compact(checked), nil
+}
+
+// replaceTags заменяет теги подписки id
+func (d *data) replaceTags(id int, tags []string) error {
+	checked, err := checkTags(tags)
+	if err != nil {
+		return storeError("ошибка при сохранении тегов", err)
+	}
+
+	row, ok := d.subscriptions[id]
+	if !ok {
+		if len(checked) > 0 {
+			return foreignKeyError("ошибка при сохранении тегов подписки", "subscription_tags", "subscription_tags_subscription_id_fkey")
+		}
+		return nil
+	}
+	row.Tags = checked
+	d.subscriptions[id] = row
+	return nil
+}
+
+// details собирает подписку для выдачи: статус на сегодня, категорию сервиса вместо
+// пустой собственной и копии полей, которые вызывающий может изменить
+func (d *data) details(row model.SubscriptionDetails) model.SubscriptionDetails {
+	subscription := row
+	subscription.Tags = append(model.Tags{}, row.Tags...)
+	subscription.Status = d.status(&row, today())
+	subscription.Category = d.category(&row)
+	subscription.TrialEnd = clonePointer(row.TrialEnd)
+	subscription.CancelledAt = clonePointer(row.CancelledAt)
+	subscription.DeletedAt = clonePointer(row.DeletedAt)
+	return subscription
+}
+
+// category возвращает категорию подписки, а если она не задана - категорию ее сервиса
+func (d *data) category(row *model.SubscriptionDetails) *string {
+	category := row.Category
+	if category == nil {
+		category = d.services[row.ServiceID].Category
+	}
+	return clonePointer(category)
+}
+
+// status вычисляет статус подписки на день day по тем же правилам, что и subscriptionStatusExpr
+func (d *data) status(row *model.SubscriptionDetails, day time.Time) string {
+	endDate := row.EndDate.ToTime()
+	switch {
+	case row.CancelledAt != nil:
+		return model.StatusCancelled
+	case !endDate.IsZero() && endDate.Before(day):
+		return model.StatusExpired
+	case row.TrialEnd != nil && !row.TrialEnd.ToTime().Before(day):
+		return model.StatusTrial
+	}
+	for _, pause := range d.pauses[row.ID] {
+		if !pause.PausedFrom.ToTime().After(day) && (pause.ResumedOn == nil || pause.ResumedOn.ToTime().After(day)) {
+			return model.StatusPaused
+		}
+	}
+	return model.StatusActive
+}
+
+// isService проверяет, что name - одно из написаний сервиса serviceID
+func (d *data) isService(serviceID int, name string) bool {
+	id, ok := d.aliases[repository.NormalizeServiceName(name)]
+	return ok && id == serviceID
+}
+
+// sortedSubscriptions возвращает строки подписок в порядке id
+func (d *data) sortedSubscriptions() []model.SubscriptionDetails {
+	rows := make([]model.SubscriptionDetails, 0, len(d.subscriptions))
+	for _, row := range d.subscriptions {
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows
+}
+
+// versionConflict объясняет, почему условное изменение не затронуло подписку:
+// ее нет совсем или ее версия уже другая
+func (d *data) versionConflict(id int) error {
+	if row, ok := d.subscriptions[id]; !ok || row.DeletedAt != nil {
+		return notFound("подписка с таким ID не найдена")
+	}
+	return storeError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+}
+
+// restoreConflict объясняет, почему не нашлось удаленной подписки: ее нет совсем
+// или она не удалена
+func (d *data) restoreConflict(id int) error {
+	if _, ok := d.subscriptions[id]; ok {
+		return storeError("подписка не удалена", model.ErrConflict)
+	}
+	return notFound("удаленная подписка с таким ID не найдена")
+}
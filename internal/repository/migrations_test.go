@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"errors"
+	"github.com/golang-migrate/migrate/v4"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+// migrateSchema применяет миграции до версии version в отдельной схеме тестовой базы,
+// чтобы проверить миграцию на данных, записанных по прежней схеме. Схема удаляется
+// после теста
+func migrateSchema(t *testing.T, version uint) (*migrate.Migrate, *config.Database) {
+	t.Helper()
+
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s не задана, проверка миграций Postgres пропущена", postgresDSNEnv)
+	}
+
+	admin, err := config.NewDatabaseConnection("postgres", dsn)
+	if err != nil {
+		t.Fatalf("не удалось подключиться к БД: %v", err)
+	}
+	t.Cleanup(func() { _ = admin.Close() })
+
+	schema := "migration_" + strings.ReplaceAll(newTestUserID(t), "-", "")
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("не удалось создать схему: %v", err)
+	}
+	t.Cleanup(func() { _, _ = admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("DSN тестовой базы должен быть URL: %v", err)
+	}
+	query := parsed.Query()
+	query.Set("search_path", schema)
+	parsed.RawQuery = query.Encode()
+
+	m, err := migrate.New("file://../../migrations", parsed.String())
+	if err != nil {
+		t.Fatalf("не удалось подготовить миграции: %v", err)
+	}
+	t.Cleanup(func() { _, _ = m.Close() })
+	if err := m.Migrate(version); err != nil {
+		t.Fatalf("ошибка миграции до версии %d: %v", version, err)
+	}
+
+	database, err := config.NewDatabaseConnection("postgres", parsed.String())
+	if err != nil {
+		t.Fatalf("не удалось подключиться к схеме %s: %v", schema, err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	return m, database
+}
+
+func TestServiceCatalogMigrationBlankNames(t *testing.T) {
+	m, database := migrateSchema(t, 11)
+
+	userID := newTestUserID(t)
+	_, err := database.Exec(`INSERT INTO subscriptions (service_name, price, user_id, start_date)
+		VALUES ('', 100, $1, '2025-01-01'), ('   ', 200, $1, '2025-01-01'), ('Netflix', 300, $1, '2025-01-01')`, userID)
+	if err != nil {
+		t.Fatalf("не удалось записать подписки по прежней схеме: %v", err)
+	}
+
+	if err := m.Migrate(12); err != nil {
+		t.Fatalf("миграция каталога сервисов на пустых названиях: %v", err)
+	}
+
+	var names []string
+	err = database.Select(&names, `SELECT s.name FROM subscriptions sub JOIN services s ON s.id = sub.service_id
+		WHERE sub.user_id = $1 ORDER BY sub.price`, userID)
+	if err != nil {
+		t.Fatalf("не удалось прочитать подписки: %v", err)
+	}
+	if want := []string{"unknown", "unknown", "Netflix"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("сервисы подписок %q, ожидались %q", names, want)
+	}
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		t.Fatalf("ошибка остальных миграций: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// NormalizeServiceName строит ключ сопоставления названий сервиса так же, как функция
+// normalize_service_name: нижний регистр, ё как е, любые последовательности знаков
+// кроме букв и цифр схлопываются в один пробел. Название только из знаков
+// сравнивается целиком в нижнем регистре
+func NormalizeServiceName(name string) string {
+	var key strings.Builder
+	separated := false
+	for _, r := range strings.ToLower(name) {
+		if r == 'ё' {
+			r = 'е'
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			separated = true
+			continue
+		}
+		if separated && key.Len() > 0 {
+			key.WriteByte(' ')
+		}
+		separated = false
+		key.WriteRune(r)
+	}
+
+	if key.Len() == 0 {
+		return strings.ToLower(strings.Trim(name, " "))
+	}
+	return key.String()
+}
+
+// CanonicalUUID приводит UUID к виду, в котором его возвращает Postgres: 32 шестнадцатеричные
+// цифры в нижнем регистре, разделенные дефисами 8-4-4-4-12. Как и тип uuid, принимает
+// фигурные скобки, заглавные буквы и дефис после любой группы из четырех цифр
+func CanonicalUUID(value string) (string, error) {
+	invalid := fmt.Errorf("%w: неверный синтаксис UUID: %q", model.ErrValidation, value)
+
+	digits := value
+	if strings.HasPrefix(digits, "{") {
+		if !strings.HasSuffix(digits, "}") {
+			return "", invalid
+		}
+		digits = digits[1 : len(digits)-1]
+	}
+
+	var hex strings.Builder
+	for i, r := range digits {
+		switch {
+		case r == '-':
+			if hex.Len() == 0 || hex.Len()%4 != 0 || i == len(digits)-1 || digits[i+1] == '-' {
+				return "", invalid
+			}
+		case '0' <= r && r <= '9', 'a' <= r && r <= 'f', 'A' <= r && r <= 'F':
+			hex.WriteRune(unicode.ToLower(r))
+		default:
+			return "", invalid
+		}
+	}
+	if hex.Len() != 32 {
+		return "", invalid
+	}
+
+	h := hex.String()
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], nil
+}
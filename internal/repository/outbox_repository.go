@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+type OutboxRepository struct {
+	*config.Database
+}
+
+func NewOutboxRepository(database *config.Database) *OutboxRepository {
+	return &OutboxRepository{database}
+}
+
+// NextEventID выдает ID следующего события
+func (repo *OutboxRepository) NextEventID(ctx context.Context, exec sqlx.ExtContext) (int64, error) {
+	var id int64
+	if err := exec.QueryRowxContext(ctx, `SELECT nextval('outbox_event_id_seq')`).Scan(&id); err != nil {
+		return 0, databaseError("ошибка получения ID события", err)
+	}
+	return id, nil
+}
+
+// SaveOutboxEvent записывает событие в outbox; вызывается в транзакции изменения,
+// породившего событие
+func (repo *OutboxRepository) SaveOutboxEvent(ctx context.Context, exec sqlx.ExtContext, event *model.OutboxEvent) error {
+	query := `INSERT INTO outbox (event_id, event_type, payload)
+		VALUES ($1, $2, $3::jsonb)
+		RETURNING id, created_at`
+
+	row := exec.QueryRowxContext(ctx, query, event.EventID, event.Type, string(event.Payload))
+	if err := row.Scan(&event.ID, &event.CreatedAt); err != nil {
+		return databaseError("ошибка записи события в outbox", err)
+	}
+	return nil
+}
+
+// ClaimUnsentEvents забирает до limit неотправленных событий, время попытки которых
+// наступило, в порядке записи и откладывает их следующую попытку на lease. Пока идет
+// публикация, другой релей их не заберет, а строки не остаются заблокированными:
+// вызывается вне долгой транзакции. Если релей не отметил результат за lease, событие
+// публикуется снова
+func (repo *OutboxRepository) ClaimUnsentEvents(ctx context.Context, exec sqlx.ExtContext, limit int, lease time.Duration) ([]model.OutboxEvent, error) {
+	query := `WITH claimed AS (
+			UPDATE outbox
+			SET next_attempt_at = now() + make_interval(secs => $2)
+			WHERE id IN (
+				SELECT id FROM outbox
+				WHERE status = 'pending' AND next_attempt_at <= now()
+				ORDER BY id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, event_id, event_type, payload, attempts, created_at
+		)
+		SELECT * FROM claimed ORDER BY id`
+
+	events := []model.OutboxEvent{}
+	err := sqlx.SelectContext(ctx, exec, &events, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, databaseError("ошибка получения событий outbox", err)
+	}
+	return events, nil
+}
+
+// MarkEventSent отмечает событие отправленным. Событие, которое уже отметил другой
+// релей или которое перешло в dead, не меняется
+func (repo *OutboxRepository) MarkEventSent(ctx context.Context, exec sqlx.ExtContext, id int64) error {
+	query := `UPDATE outbox
+			SET status = 'sent', sent_at = now(), last_error = NULL
+			WHERE id = $1 AND status = 'pending'`
+
+	if _, err := exec.ExecContext(ctx, query, id); err != nil {
+		return databaseError("ошибка при отметке отправки события outbox", err)
+	}
+	return nil
+}
+
+// MarkEventFailed записывает неудачную попытку публикации и переводит событие в status:
+// pending откладывает следующую попытку до nextAttemptAt, dead прекращает попытки
+func (repo *OutboxRepository) MarkEventFailed(ctx context.Context, exec sqlx.ExtContext, id int64, reason, status string, nextAttemptAt time.Time) error {
+	query := `UPDATE outbox
+			SET status = $3, attempts = attempts + 1, last_error = $2, next_attempt_at = $4
+			WHERE id = $1 AND status = 'pending'`
+
+	if _, err := exec.ExecContext(ctx, query, id, reason, status, nextAttemptAt); err != nil {
+		return databaseError("ошибка при отметке неудачной публикации события outbox", err)
+	}
+	return nil
+}
+
+// DeleteSentEvents удаляет события, отправленные раньше before
+func (repo *OutboxRepository) DeleteSentEvents(ctx context.Context, exec sqlx.ExtContext, before time.Time) (int64, error) {
+	result, err := exec.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at < $1`, before)
+	if err != nil {
+		return 0, databaseError("ошибка при удалении отправленных событий outbox", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, databaseError("не удалось получить количество удалённых строк", err)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,124 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+// claimedEvent ищет среди забранных событий событие с идентификатором id
+func claimedEvent(events []model.OutboxEvent, id int64) *model.OutboxEvent {
+	for i := range events {
+		if events[i].ID == id {
+			return &events[i]
+		}
+	}
+	return nil
+}
+
+func TestOutboxEvents(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewOutboxRepository(database)
+	ctx := context.Background()
+
+	event := model.OutboxEvent{EventID: time.Now().UnixNano(), Type: model.EventSubscriptionCreated, Payload: []byte(`{"id":1}`)}
+	if err := repo.SaveOutboxEvent(ctx, database, &event); err != nil {
+		t.Fatalf("SaveOutboxEvent: %v", err)
+	}
+
+	events, err := repo.ClaimUnsentEvents(ctx, database, 10000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimUnsentEvents: %v", err)
+	}
+	if claimed := claimedEvent(events, event.ID); claimed == nil || claimed.EventID != event.EventID || string(claimed.Payload) != `{"id": 1}` {
+		t.Fatalf("забранное событие %+v", claimed)
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i-1].ID > events[i].ID {
+			t.Fatalf("события забраны не в порядке записи: %d после %d", events[i].ID, events[i-1].ID)
+		}
+	}
+
+	// пока действует аренда, событие не забирает другой релей
+	events, err = repo.ClaimUnsentEvents(ctx, database, 10000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimUnsentEvents: %v", err)
+	}
+	if claimedEvent(events, event.ID) != nil {
+		t.Error("событие забрали два релея")
+	}
+
+	// после неудачи событие ждет следующей попытки
+	if err := repo.MarkEventFailed(ctx, database, event.ID, "брокер недоступен", model.OutboxStatusPending, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("MarkEventFailed: %v", err)
+	}
+	events, err = repo.ClaimUnsentEvents(ctx, database, 10000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimUnsentEvents: %v", err)
+	}
+	if claimed := claimedEvent(events, event.ID); claimed == nil || claimed.Attempts != 1 {
+		t.Fatalf("событие после неудачной попытки %+v", claimed)
+	}
+
+	if err := repo.MarkEventSent(ctx, database, event.ID); err != nil {
+		t.Fatalf("MarkEventSent: %v", err)
+	}
+	// отметка о неудаче от релея с истекшей арендой не возвращает отправленное событие
+	if err := repo.MarkEventFailed(ctx, database, event.ID, "таймаут", model.OutboxStatusPending, time.Now()); err != nil {
+		t.Fatalf("MarkEventFailed: %v", err)
+	}
+	var status string
+	if err := database.GetContext(ctx, &status, `SELECT status FROM outbox WHERE id = $1`, event.ID); err != nil {
+		t.Fatalf("не удалось прочитать событие: %v", err)
+	}
+	if status != model.OutboxStatusSent {
+		t.Errorf("состояние отправленного события %q", status)
+	}
+
+	if _, err := repo.DeleteSentEvents(ctx, database, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("DeleteSentEvents: %v", err)
+	}
+	var count int
+	if err := database.GetContext(ctx, &count, `SELECT count(*) FROM outbox WHERE id = $1`, event.ID); err != nil {
+		t.Fatalf("не удалось найти событие: %v", err)
+	}
+	if count != 0 {
+		t.Error("отправленное событие не удалено")
+	}
+}
+
+func TestOutboxDeadEvents(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewOutboxRepository(database)
+	ctx := context.Background()
+
+	event := model.OutboxEvent{EventID: time.Now().UnixNano(), Type: model.EventSubscriptionCreated, Payload: []byte(`{}`)}
+	if err := repo.SaveOutboxEvent(ctx, database, &event); err != nil {
+		t.Fatalf("SaveOutboxEvent: %v", err)
+	}
+	if err := repo.MarkEventFailed(ctx, database, event.ID, "брокер недоступен", model.OutboxStatusDead, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("MarkEventFailed: %v", err)
+	}
+
+	events, err := repo.ClaimUnsentEvents(ctx, database, 10000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimUnsentEvents: %v", err)
+	}
+	if claimedEvent(events, event.ID) != nil {
+		t.Error("событие в dead забрано на публикацию")
+	}
+
+	// dead не удаляется вместе с отправленными событиями
+	if _, err := repo.DeleteSentEvents(ctx, database, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("DeleteSentEvents: %v", err)
+	}
+	var lastError string
+	if err := database.GetContext(ctx, &lastError, `SELECT last_error FROM outbox WHERE id = $1 AND status = 'dead'`, event.ID); err != nil {
+		t.Fatalf("событие в dead не найдено: %v", err)
+	}
+	if lastError != "брокер недоступен" {
+		t.Errorf("последняя ошибка %q", lastError)
+	}
+}
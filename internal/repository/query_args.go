@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"strconv"
+	"time"
+)
+
+// queryArgs собирает аргументы динамически строящегося запроса и
+// выдает для каждого следующий плейсхолдер $N
+type queryArgs []any
+
+func (args *queryArgs) add(value any) string {
+	*args = append(*args, value)
+	return "$" + strconv.Itoa(len(*args))
+}
+
+// dateArg передает необязательную дату в запрос; nil записывается как NULL
+func dateArg(date *model.DayMonthYear) *time.Time {
+	if date == nil {
+		return nil
+	}
+	value := date.ToTime()
+	return &value
+}
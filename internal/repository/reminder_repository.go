@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// upcomingRenewalsQuery выбирает для каждой подписки первую оплату в периоде [$2, $3]
+// по тем же правилам, что и расчет стоимости: без пробного периода и пауз. Отмененные
+// и удаленные подписки не оплачиваются. $1 - UUID пользователя или NULL для всех
+const upcomingRenewalsQuery = `
+	SELECT s.id AS subscription_id, s.user_id, s.service_name, s.currency,
+		next_charge.charged_on AS billing_date,
+		next_charge.charged_on - $2::date AS days_left,
+		COALESCE((
+			SELECT p.price FROM subscription_prices p
+			WHERE p.subscription_id = s.id AND p.effective_from <= next_charge.charged_on
+			ORDER BY p.effective_from DESC
+			LIMIT 1
+		), s.price) AS price
+	FROM subscriptions s
+	CROSS JOIN LATERAL (
+		SELECT charged_on
+		FROM subscription_charge_dates(
+			s.start_date, NULLIF(s.end_date, '0001-01-01'), $2::date, $3::date,
+			s.billing_period, s.billing_interval
+		) AS charged_on
+		WHERE (s.trial_end IS NULL OR charged_on > s.trial_end) AND
+			NOT EXISTS (
+				SELECT 1 FROM subscription_pauses sp
+				WHERE sp.subscription_id = s.id AND charged_on >= sp.paused_from
+					AND (sp.resumed_on IS NULL OR charged_on < sp.resumed_on)
+			)
+		ORDER BY charged_on
+		LIMIT 1
+	) AS next_charge
+	WHERE
+		s.deleted_at IS NULL AND
+		s.cancelled_at IS NULL AND
+		($1::uuid IS NULL OR s.user_id = $1::uuid) AND
+		s.start_date <= $3 AND (s.end_date IS NULL OR s.end_date >= $2 OR s.end_date = '0001-01-01')
+`
+
+// GetUpcomingRenewals возвращает ближайшие оплаты подписок пользователя в периоде [from, to]
+// в порядке дат оплаты
+func (repo *SubscriptionRepository) GetUpcomingRenewals(ctx context.Context, exec sqlx.ExtContext, uuid string, from, to time.Time) ([]model.UpcomingRenewal, error) {
+	query := upcomingRenewalsQuery + ` ORDER BY billing_date, subscription_id`
+
+	renewals := []model.UpcomingRenewal{}
+	err := sqlx.SelectContext(ctx, exec, &renewals, query, uuid, from, to)
+	if err != nil {
+		return nil, databaseError("ошибка получения предстоящих оплат", err)
+	}
+	return renewals, nil
+}
+
+type ReminderRepository struct {
+	*config.Database
+}
+
+func NewReminderRepository(database *config.Database) *ReminderRepository {
+	return &ReminderRepository{database}
+}
+
+// EnqueueReminders создает напоминания о ближайших оплатах всех подписок в периоде
+// [from, to]. Напоминание на уже известную дату оплаты не создается повторно
+func (repo *ReminderRepository) EnqueueReminders(ctx context.Context, exec sqlx.ExtContext, from, to time.Time) (int64, error) {
+	query := `INSERT INTO subscription_reminders (subscription_id, billing_date)
+		SELECT subscription_id, billing_date FROM (` + upcomingRenewalsQuery + `) AS renewals
+		ON CONFLICT (subscription_id, billing_date) DO NOTHING`
+
+	result, err := exec.ExecContext(ctx, query, nil, from, to)
+	if err != nil {
+		return 0, databaseError("ошибка при создании напоминаний", err)
+	}
+
+	created, err := result.RowsAffected()
+	if err != nil {
+		return 0, databaseError("не удалось получить количество созданных напоминаний", err)
+	}
+	return created, nil
+}
+
+// ClaimDueReminders забирает до limit недоставленных напоминаний, время попытки которых
+// наступило, и откладывает их следующую попытку на lease. Пока идет доставка, другой
+// процесс их не заберет, а строки не остаются заблокированными: вызывается вне
+// долгой транзакции. Если процесс не отметил результат за lease, напоминание
+// доставляется снова
+func (repo *ReminderRepository) ClaimDueReminders(ctx context.Context, exec sqlx.ExtContext, limit int, lease time.Duration) ([]model.SubscriptionReminder, error) {
+	query := `UPDATE subscription_reminders r
+		SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM subscriptions s
+		WHERE s.id = r.subscription_id AND r.id IN (
+			SELECT due.id
+			FROM subscription_reminders due
+			JOIN subscriptions ds ON ds.id = due.subscription_id
+			WHERE due.status = 'pending' AND due.next_attempt_at <= now() AND
+				due.billing_date >= current_date AND
+				ds.deleted_at IS NULL AND ds.cancelled_at IS NULL
+			ORDER BY due.next_attempt_at, due.id
+			LIMIT $1
+			FOR UPDATE OF due SKIP LOCKED
+		)
+		RETURNING r.id, r.attempts, r.subscription_id, s.user_id, s.service_name, s.currency,
+			r.billing_date, r.billing_date - current_date AS days_left,
+			COALESCE((
+				SELECT p.price FROM subscription_prices p
+				WHERE p.subscription_id = s.id AND p.effective_from <= r.billing_date
+				ORDER BY p.effective_from DESC
+				LIMIT 1
+			), s.price) AS price`
+
+	reminders := []model.SubscriptionReminder{}
+	err := sqlx.SelectContext(ctx, exec, &reminders, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, databaseError("ошибка получения напоминаний к отправке", err)
+	}
+	return reminders, nil
+}
+
+// MarkReminderSent отмечает напоминание отправленным. false означает, что его уже
+// отметил другой процесс, забравший напоминание после истечения аренды
+func (repo *ReminderRepository) MarkReminderSent(ctx context.Context, exec sqlx.ExtContext, id int64) (bool, error) {
+	query := `UPDATE subscription_reminders
+			SET status = 'sent', sent_at = now(), last_error = NULL
+			WHERE id = $1 AND status = 'pending'`
+
+	result, err := exec.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, databaseError("ошибка при отметке отправки напоминания", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, databaseError("не удалось получить количество обновленных строк", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// MarkReminderFailed откладывает следующую попытку доставки напоминания до nextAttemptAt
+func (repo *ReminderRepository) MarkReminderFailed(ctx context.Context, exec sqlx.ExtContext, id int64, reason string, nextAttemptAt time.Time) error {
+	query := `UPDATE subscription_reminders
+			SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+			WHERE id = $1 AND status = 'pending'`
+
+	if _, err := exec.ExecContext(ctx, query, id, reason, nextAttemptAt); err != nil {
+		return databaseError("ошибка при отметке неудачной отправки напоминания", err)
+	}
+	return nil
+}
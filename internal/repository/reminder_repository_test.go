@@ -0,0 +1,114 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+// claimedReminder ищет среди заблокированных напоминаний напоминание подписки subscriptionID
+func claimedReminder(reminders []model.SubscriptionReminder, subscriptionID int) *model.SubscriptionReminder {
+	for i := range reminders {
+		if reminders[i].SubscriptionID == subscriptionID {
+			return &reminders[i]
+		}
+	}
+	return nil
+}
+
+func TestReminderDelivery(t *testing.T) {
+	database := openTestDatabase(t)
+	subscriptions := repository.NewSubscriptionRepository(database)
+	repo := repository.NewReminderRepository(database)
+	ctx := context.Background()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	subscription := model.SubscriptionDetails{
+		ServiceName:     "Yandex Plus",
+		Price:           40000,
+		Currency:        "RUB",
+		BillingPeriod:   model.BillingPeriodMonth,
+		BillingInterval: 1,
+		UserID:          newTestUserID(t),
+		StartDate:       model.DayMonthYear(today),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := subscriptions.SaveSubscription(ctx, database, &subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	renewals, err := subscriptions.GetUpcomingRenewals(ctx, database, subscription.UserID, today, today.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatalf("GetUpcomingRenewals: %v", err)
+	}
+	if len(renewals) != 1 || !renewals[0].BillingDate.ToTime().Equal(today) || renewals[0].DaysLeft != 0 || renewals[0].Price != 40000 {
+		t.Fatalf("предстоящие оплаты %+v", renewals)
+	}
+
+	// повторный проход планировщика не создает второе напоминание на ту же дату
+	for range 2 {
+		if _, err := repo.EnqueueReminders(ctx, database, today, today.AddDate(0, 0, 3)); err != nil {
+			t.Fatalf("EnqueueReminders: %v", err)
+		}
+	}
+	var count int
+	if err := database.GetContext(ctx, &count, `SELECT count(*) FROM subscription_reminders WHERE subscription_id = $1`, subscription.ID); err != nil {
+		t.Fatalf("не удалось посчитать напоминания: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("напоминаний подписки %d, ожидалось одно", count)
+	}
+
+	claimed, err := repo.ClaimDueReminders(ctx, database, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimDueReminders: %v", err)
+	}
+	reminder := claimedReminder(claimed, subscription.ID)
+	if reminder == nil {
+		t.Fatal("напоминание подписки не забрано")
+	}
+
+	// пока не истекла аренда, напоминание не забирает другой процесс
+	claimed, err = repo.ClaimDueReminders(ctx, database, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimDueReminders: %v", err)
+	}
+	if claimedReminder(claimed, subscription.ID) != nil {
+		t.Error("напоминание забрано повторно до истечения аренды")
+	}
+
+	// после неудачной доставки напоминание ждет следующей попытки
+	if err := repo.MarkReminderFailed(ctx, database, reminder.ID, "сбой доставки", time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("MarkReminderFailed: %v", err)
+	}
+	claimed, err = repo.ClaimDueReminders(ctx, database, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimDueReminders: %v", err)
+	}
+	if retried := claimedReminder(claimed, subscription.ID); retried == nil || retried.Attempts != 1 {
+		t.Fatalf("повторная попытка: %+v", retried)
+	}
+
+	// отметить отправку может только один процесс
+	marked, err := repo.MarkReminderSent(ctx, database, reminder.ID)
+	if err != nil || !marked {
+		t.Fatalf("MarkReminderSent = %v, %v, ожидалось true", marked, err)
+	}
+	marked, err = repo.MarkReminderSent(ctx, database, reminder.ID)
+	if err != nil || marked {
+		t.Errorf("повторный MarkReminderSent = %v, %v, ожидалось false", marked, err)
+	}
+
+	if _, err := database.ExecContext(ctx, `UPDATE subscription_reminders SET next_attempt_at = now() WHERE id = $1`, reminder.ID); err != nil {
+		t.Fatalf("не удалось перенести попытку: %v", err)
+	}
+	claimed, err = repo.ClaimDueReminders(ctx, database, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimDueReminders: %v", err)
+	}
+	if claimedReminder(claimed, subscription.ID) != nil {
+		t.Error("отправленное напоминание забрано повторно")
+	}
+}
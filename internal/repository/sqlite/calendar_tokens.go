@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"database/sql"
+	"github.com/jmoiron/sqlx"
+)
+
+// SaveCalendarToken сохраняет хеш нового токена календаря пользователя, заменяя прежний
+func (store *Store) SaveCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string, tokenHash []byte) error {
+	userID, err := repository.CanonicalUUID(userID)
+	if err != nil {
+		return databaseError("ошибка сохранения токена календаря", err)
+	}
+
+	query := `INSERT INTO calendar_tokens (user_id, token_hash, created_at)
+		VALUES (?1, ?2, ?3)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = excluded.token_hash, created_at = excluded.created_at
+	`
+	if _, err = exec.ExecContext(ctx, query, userID, tokenHash, now()); err != nil {
+		return databaseError("ошибка сохранения токена календаря", err)
+	}
+	return nil
+}
+
+func (store *Store) GetCalendarTokenHash(ctx context.Context, exec sqlx.ExtContext, userID string) ([]byte, error) {
+	userID, err := repository.CanonicalUUID(userID)
+	if err != nil {
+		return nil, databaseError("ошибка получения токена календаря", err)
+	}
+
+	var tokenHash []byte
+	err = sqlx.GetContext(ctx, exec, &tokenHash, `SELECT token_hash FROM calendar_tokens WHERE user_id = ?1`, userID)
+	if err != nil {
+		return nil, databaseError("ошибка получения токена календаря", err)
+	}
+	return tokenHash, nil
+}
+
+// DeleteCalendarToken отзывает токен календаря пользователя; если токена нет, возвращает ErrNotFound
+func (store *Store) DeleteCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string) error {
+	userID, err := repository.CanonicalUUID(userID)
+	if err != nil {
+		return databaseError("ошибка удаления токена календаря", err)
+	}
+
+	result, err := exec.ExecContext(ctx, `DELETE FROM calendar_tokens WHERE user_id = ?1`, userID)
+	if err != nil {
+		return databaseError("ошибка удаления токена календаря", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество удалённых строк", err)
+	}
+	if rowsAffected == 0 {
+		return databaseError("токен календаря не найден", sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package sqlite
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+)
+
+// SaveCatalogService добавляет сервис в каталог вместе с написаниями и перечитывает его.
+// Каноническое название тоже считается написанием, поэтому два сервиса не могут
+// называться одинаково
+func (store *Store) SaveCatalogService(ctx context.Context, exec sqlx.ExtContext, service *model.CatalogService) error {
+	const message = "ошибка при добавлении сервиса в каталог"
+	query := `INSERT INTO services (name, category, default_price, currency, logo_url)
+		VALUES (?1, ?2, ?3, ?4, ?5)`
+
+	result, err := exec.ExecContext(ctx, query,
+		service.Name,
+		service.Category,
+		service.DefaultPrice,
+		service.Currency,
+		service.LogoURL,
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			if missing, checkErr := missingCurrency(ctx, exec, service.Currency); checkErr == nil && missing {
+				return util.LogError(message, currencyError("services", "services_currency_fkey"))
+			}
+		}
+		return databaseError(message, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return databaseError(message, err)
+	}
+
+	if err := insertAliases(ctx, exec, int(id), service.Name, service.Aliases); err != nil {
+		return err
+	}
+
+	saved, err := store.GetCatalogServiceByID(ctx, exec, int(id))
+	if err != nil {
+		return err
+	}
+	*service = *saved
+	return nil
+}
+
+func (store *Store) GetCatalogServiceByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.CatalogService, error) {
+	service, err := getCatalogService(ctx, exec, `id = ?1`, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("сервис с таким ID не найден в каталоге", err)
+		}
+		return nil, databaseError("ошибка получения сервиса из каталога", err)
+	}
+	return service, nil
+}
+
+// FindCatalogServiceByName ищет сервис, у которого есть написание name с точностью
+// до регистра и знаков препинания
+func (store *Store) FindCatalogServiceByName(ctx context.Context, exec sqlx.ExtContext, name string) (*model.CatalogService, error) {
+	condition := `id = (SELECT service_id FROM service_aliases WHERE normalized_alias = ?1)`
+	service, err := getCatalogService(ctx, exec, condition, repository.NormalizeServiceName(name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("сервис "+name+" не найден в каталоге", err)
+		}
+		return nil, databaseError("ошибка поиска сервиса в каталоге", err)
+	}
+	return service, nil
+}
+
+// LockCatalogServiceName ничего не делает: пишущие транзакции SQLite выполняются по одной,
+// поэтому параллельно добавить в каталог один и тот же сервис невозможно
+func (store *Store) LockCatalogServiceName(ctx context.Context, exec sqlx.ExtContext, name string) error {
+	return nil
+}
+
+// getCatalogService читает сервис, подходящий под условие condition, вместе с написаниями,
+// кроме совпадающих с каноническим названием, по алфавиту
+func getCatalogService(ctx context.Context, exec sqlx.ExtContext, condition string, arg any) (*model.CatalogService, error) {
+	query := `SELECT id, name, category, default_price, currency, logo_url FROM services WHERE ` + condition
+
+	var service model.CatalogService
+	if err := sqlx.GetContext(ctx, exec, &service, query, arg); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT alias FROM service_aliases WHERE service_id = ?1 AND normalized_alias <> ?2 ORDER BY alias`
+	service.Aliases = []string{}
+	if err := sqlx.SelectContext(ctx, exec, &service.Aliases, query, service.ID, repository.NormalizeServiceName(service.Name)); err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+// insertAliases сохраняет написания сервиса id: name и aliases. Написания с одинаковым
+// ключом сопоставления сохраняются один раз, первое в порядке передачи. Ключ считается
+// здесь же, как его считает normalize_service_name в Postgres
+func insertAliases(ctx context.Context, exec sqlx.ExtContext, id int, name string, aliases []string) error {
+	query := `INSERT INTO service_aliases (service_id, alias, normalized_alias) VALUES (?1, ?2, ?3)`
+
+	seen := map[string]bool{}
+	for _, alias := range append([]string{name}, aliases...) {
+		key := repository.NormalizeServiceName(alias)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if _, err := exec.ExecContext(ctx, query, id, alias, key); err != nil {
+			return databaseError("ошибка при сохранении написаний сервиса", err)
+		}
+	}
+	return nil
+}
+
+// SaveExchangeRates добавляет курсы; курс той же пары на ту же дату перезаписывается
+func (store *Store) SaveExchangeRates(ctx context.Context, exec sqlx.ExtContext, rates []model.ExchangeRate) error {
+	const message = "ошибка при сохранении курса валют"
+	query := `INSERT INTO exchange_rates (base_currency, quote_currency, rate_date, rate)
+		VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT (base_currency, quote_currency, rate_date) DO UPDATE
+			SET rate = excluded.rate
+	`
+
+	for _, rate := range rates {
+		_, err := exec.ExecContext(ctx, query,
+			rate.BaseCurrency,
+			rate.QuoteCurrency,
+			dayArg(rate.RateDate.ToTime()),
+			rate.Rate,
+		)
+		if err == nil {
+			continue
+		}
+		if isForeignKeyViolation(err) {
+			if missing, checkErr := missingCurrency(ctx, exec, rate.BaseCurrency); checkErr == nil && missing {
+				return util.LogError(message, currencyError("exchange_rates", "exchange_rates_base_currency_fkey"))
+			}
+			if missing, checkErr := missingCurrency(ctx, exec, rate.QuoteCurrency); checkErr == nil && missing {
+				return util.LogError(message, currencyError("exchange_rates", "exchange_rates_quote_currency_fkey"))
+			}
+		}
+		return databaseError(message, err)
+	}
+	return nil
+}
+
+func (store *Store) GetExchangeRates(ctx context.Context, exec sqlx.ExtContext, filter model.ExchangeRateFilter) ([]model.ExchangeRate, error) {
+	query := `SELECT base_currency, quote_currency, rate_date, rate
+		FROM exchange_rates
+		WHERE (?1 IS NULL OR base_currency = ?1) AND
+			(?2 IS NULL OR quote_currency = ?2)
+		ORDER BY base_currency, quote_currency, rate_date
+	`
+
+	rates := []model.ExchangeRate{}
+	err := sqlx.SelectContext(ctx, exec, &rates, query, filter.BaseCurrency, filter.QuoteCurrency)
+	if err != nil {
+		return nil, databaseError("ошибка получения курсов валют", err)
+	}
+	return rates, nil
+}
+
+func (store *Store) GetCurrencies(ctx context.Context, exec sqlx.ExtContext) ([]model.Currency, error) {
+	currencies, err := selectCurrencies(ctx, exec)
+	if err != nil {
+		return nil, databaseError("ошибка получения справочника валют", err)
+	}
+	return currencies, nil
+}
+
+func selectCurrencies(ctx context.Context, exec sqlx.ExtContext) ([]model.Currency, error) {
+	currencies := []model.Currency{}
+	err := sqlx.SelectContext(ctx, exec, &currencies, `SELECT code, minor_units, name FROM currencies ORDER BY code`)
+	return currencies, err
+}
@@ -0,0 +1,261 @@
+package sqlite
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"sort"
+	"strings"
+	"time"
+)
+
+// conversionConstraints сопоставляет причины ошибок перевода валют с ограничениями,
+// которые в Postgres выбрасывает convert_amount
+var conversionConstraints = map[error]string{
+	billing.ErrNoExchangeRate:  "exchange_rate_required",
+	billing.ErrUnknownCurrency: "currency_required",
+}
+
+// conversionError превращает ошибку перевода валют в нарушение ограничения, как в Postgres
+func conversionError(err error) error {
+	var conversion *billing.ConversionError
+	if errors.As(err, &conversion) {
+		return repository.ConstraintError(conversionConstraints[conversion.Reason], conversion.Message)
+	}
+	return err
+}
+
+// billedCharges разворачивает подходящие под фильтр подписки в оплаты внутри периода
+// по тем же правилам, что и billedChargesQuery в реализации для Postgres. В SQLite нет
+// generate_series и хранимых функций, поэтому подписки отбираются запросом, а даты оплат,
+// цены и перевод валют считает пакет billing
+func billedCharges(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]billing.BilledCharge, error) {
+	userID, err := repository.CanonicalUUID(filter.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var args queryArgs
+	conditions := []string{
+		"deleted_at IS NULL",
+		"user_id = " + args.add(userID),
+		"start_date <= " + args.add(dayArg(filter.EndPeriod)),
+		"(end_date IS NULL OR end_date >= " + args.add(dayArg(filter.StartPeriod)) + ")",
+	}
+	if filter.ServiceName != nil {
+		conditions = append(conditions, "service_id IN (SELECT service_id FROM service_aliases "+
+			"WHERE normalized_alias = "+args.add(repository.NormalizeServiceName(*filter.ServiceName))+")")
+	}
+	if filter.Category != nil {
+		conditions = append(conditions, subscriptionCategoryExpr+" = "+args.add(*filter.Category))
+	}
+	if filter.Tag != nil {
+		conditions = append(conditions, `EXISTS (
+			SELECT 1 FROM subscription_tags st
+			JOIN tags t ON t.id = st.tag_id
+			WHERE st.subscription_id = subscriptions.id AND t.name = `+args.add(*filter.Tag)+`
+		)`)
+	}
+
+	subscriptions, err := selectPlans(ctx, exec, strings.Join(conditions, " AND "), args)
+	if err != nil {
+		return nil, err
+	}
+	converter, err := loadConverter(ctx, exec, filter.EndPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	var charges []billing.BilledCharge
+	for _, subscription := range subscriptions {
+		planned, err := subscription.plan.Charges(filter.StartPeriod, filter.EndPeriod)
+		if err != nil {
+			return nil, err
+		}
+		for _, charge := range planned {
+			amount, err := converter.Convert(charge.Price, subscription.Currency, filter.Currency, charge.Date)
+			if err != nil {
+				return nil, conversionError(err)
+			}
+			charges = append(charges, billing.BilledCharge{
+				SubscriptionID: subscription.ID,
+				ServiceName:    subscription.ServiceName,
+				Category:       subscription.Category,
+				Tags:           subscription.Tags,
+				Date:           charge.Date,
+				Amount:         amount,
+			})
+		}
+	}
+	return charges, nil
+}
+
+// plannedSubscription - подписка вместе с планом ее оплат
+type plannedSubscription struct {
+	model.SubscriptionDetails
+	plan billing.Plan
+}
+
+// selectPlans читает подписки, подходящие под условие condition, в порядке id и собирает
+// их планы оплат из истории цен и пауз
+func selectPlans(ctx context.Context, exec sqlx.ExtContext, condition string, args queryArgs) ([]plannedSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE ` + condition + ` ORDER BY id`
+
+	var rows []model.SubscriptionDetails
+	if err := sqlx.SelectContext(ctx, exec, &rows, query, args...); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	var prices []model.SubscriptionPrice
+	query = `SELECT id, subscription_id, price, effective_from, created_at
+		FROM subscription_prices
+		WHERE subscription_id IN (SELECT value FROM json_each(?1))
+		ORDER BY subscription_id, effective_from`
+	if err := sqlx.SelectContext(ctx, exec, &prices, query, jsonArray(ids)); err != nil {
+		return nil, err
+	}
+
+	var pauses []model.SubscriptionPause
+	query = `SELECT id, subscription_id, paused_from, resumed_on
+		FROM subscription_pauses
+		WHERE subscription_id IN (SELECT value FROM json_each(?1))
+		ORDER BY subscription_id, paused_from, id`
+	if err := sqlx.SelectContext(ctx, exec, &pauses, query, jsonArray(ids)); err != nil {
+		return nil, err
+	}
+
+	pricesOf := map[int][]model.SubscriptionPrice{}
+	for _, price := range prices {
+		pricesOf[price.SubscriptionID] = append(pricesOf[price.SubscriptionID], price)
+	}
+	pausesOf := map[int][]model.SubscriptionPause{}
+	for _, pause := range pauses {
+		pausesOf[pause.SubscriptionID] = append(pausesOf[pause.SubscriptionID], pause)
+	}
+
+	planned := make([]plannedSubscription, 0, len(rows))
+	for i := range rows {
+		planned = append(planned, plannedSubscription{
+			SubscriptionDetails: rows[i],
+			plan:                billing.NewPlan(&rows[i], pricesOf[rows[i].ID], pausesOf[rows[i].ID]),
+		})
+	}
+	return planned, nil
+}
+
+// loadConverter читает справочник валют и курсы, действовавшие не позже until
+func loadConverter(ctx context.Context, exec sqlx.ExtContext, until time.Time) (*billing.Converter, error) {
+	currencies, err := selectCurrencies(ctx, exec)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []model.ExchangeRate
+	query := `SELECT base_currency, quote_currency, rate_date, rate FROM exchange_rates WHERE rate_date <= ?1`
+	if err := sqlx.SelectContext(ctx, exec, &rates, query, dayArg(until)); err != nil {
+		return nil, err
+	}
+	return billing.NewConverter(currencies, rates), nil
+}
+
+// GetTotalSubscriptionCost считает стоимость как сумму цен, действовавших на каждую
+// дату оплаты внутри периода, в валюте filter.Currency
+func (store *Store) GetTotalSubscriptionCost(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) (*model.SubscriptionCost, error) {
+	charges, err := billedCharges(ctx, exec, filter)
+	if err != nil {
+		return nil, databaseError("ошибка подсчета общей стоимости подписок", err)
+	}
+	cost := billing.Total(charges)
+	return &cost, nil
+}
+
+// GetSubscriptionCostGroups делит общую стоимость на группы по разрезу filter.GroupBy
+func (store *Store) GetSubscriptionCostGroups(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.CostGroup, error) {
+	const message = "ошибка группировки стоимости подписок"
+	charges, err := billedCharges(ctx, exec, filter)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+	groups, err := billing.Groups(charges, filter.GroupBy)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+	return groups, nil
+}
+
+// GetSubscriptionCostBreakdown возвращает суммы списаний, сгруппированные по календарному
+// месяцу и разрезу filter.GroupBy
+func (store *Store) GetSubscriptionCostBreakdown(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.MonthlyCost, error) {
+	const message = "ошибка получения помесячной стоимости подписок"
+	charges, err := billedCharges(ctx, exec, filter)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+	breakdown, err := billing.Breakdown(charges, filter.GroupBy)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+	return breakdown, nil
+}
+
+// GetMonthlySpend считает траты по календарным месяцам и долю подписки subscriptionID в них
+func (store *Store) GetMonthlySpend(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter, subscriptionID int) ([]model.MonthlySpend, error) {
+	charges, err := billedCharges(ctx, exec, filter)
+	if err != nil {
+		return nil, databaseError("ошибка подсчета помесячных трат", err)
+	}
+	return billing.MonthlySpend(charges, subscriptionID), nil
+}
+
+// GetUpcomingRenewals возвращает ближайшие оплаты подписок пользователя в периоде [from, to]
+// в порядке дат оплаты. Отмененные и удаленные подписки не оплачиваются
+func (store *Store) GetUpcomingRenewals(ctx context.Context, exec sqlx.ExtContext, uuid string, from, to time.Time) ([]model.UpcomingRenewal, error) {
+	const message = "ошибка получения предстоящих оплат"
+	userID, err := repository.CanonicalUUID(uuid)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+
+	var args queryArgs
+	condition := "deleted_at IS NULL AND cancelled_at IS NULL AND user_id = " + args.add(userID)
+	subscriptions, err := selectPlans(ctx, exec, condition, args)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+
+	renewals := []model.UpcomingRenewal{}
+	for _, subscription := range subscriptions {
+		charges, err := subscription.plan.Charges(from, to)
+		if err != nil {
+			return nil, databaseError(message, err)
+		}
+		if len(charges) == 0 {
+			continue
+		}
+		renewals = append(renewals, model.UpcomingRenewal{
+			SubscriptionID: subscription.ID,
+			UserID:         subscription.UserID,
+			ServiceName:    subscription.ServiceName,
+			Price:          charges[0].Price,
+			Currency:       subscription.Currency,
+			BillingDate:    model.DayMonthYear(charges[0].Date),
+			DaysLeft:       int(charges[0].Date.Sub(billing.Day(from)).Hours() / 24),
+		})
+	}
+
+	sort.SliceStable(renewals, func(i, j int) bool {
+		return renewals[i].BillingDate.ToTime().Before(renewals[j].BillingDate.ToTime())
+	})
+	return renewals, nil
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// ReserveKey занимает ключ в области scope за текущим запросом; false означает, что ключ
+// использовался раньше. SQLite выполняет пишущие транзакции по одной, поэтому запрос с
+// тем же ключом видит ключ, только когда первый запрос уже завершился. Просроченная
+// запись удаляется, и ключ можно использовать заново
+func (store *Store) ReserveKey(ctx context.Context, exec sqlx.ExtContext, scope, key, requestHash string, expiresAt time.Time) (bool, error) {
+	_, err := exec.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE scope = ?1 AND key = ?2 AND expires_at <= ?3`, scope, key, now())
+	if err != nil {
+		return false, databaseError("ошибка удаления просроченного ключа идемпотентности", err)
+	}
+
+	query := `INSERT INTO idempotency_keys (scope, key, request_hash, created_at, expires_at)
+		VALUES (?1, ?2, ?3, ?4, ?5)
+		ON CONFLICT (scope, key) DO NOTHING
+		RETURNING key
+	`
+	var reserved string
+	err = sqlx.GetContext(ctx, exec, &reserved, query, scope, key, requestHash, now(), timestampArg(expiresAt))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return false, databaseError("ошибка сохранения ключа идемпотентности", err)
+	}
+
+	return true, nil
+}
+
+func (store *Store) GetRecord(ctx context.Context, exec sqlx.ExtContext, scope, key string) (*model.IdempotencyRecord, error) {
+	query := `SELECT scope, key, request_hash, status_code, response_headers, response, expires_at
+		FROM idempotency_keys WHERE scope = ?1 AND key = ?2`
+
+	var record model.IdempotencyRecord
+	err := sqlx.GetContext(ctx, exec, &record, query, scope, key)
+	if err != nil {
+		return nil, databaseError("ошибка получения ключа идемпотентности", err)
+	}
+
+	return &record, nil
+}
+
+// SaveResponse сохраняет ответ на первый запрос с ключом: статус, заголовки для повтора
+// в JSON и тело как есть
+func (store *Store) SaveResponse(ctx context.Context, exec sqlx.ExtContext, scope, key string, statusCode int, headers, body []byte) error {
+	query := `UPDATE idempotency_keys SET status_code = ?3, response_headers = ?4, response = ?5
+		WHERE scope = ?1 AND key = ?2`
+
+	_, err := exec.ExecContext(ctx, query, scope, key, statusCode, string(headers), body)
+	if err != nil {
+		return databaseError("ошибка сохранения ответа для ключа идемпотентности", err)
+	}
+	return nil
+}
+
+// DeleteExpiredKeys удаляет ключи, срок которых истек к expiredBefore. ReserveKey
+// освобождает только повторно использованный ключ, остальные удаляет фоновая очистка
+func (store *Store) DeleteExpiredKeys(ctx context.Context, exec sqlx.ExtContext, expiredBefore time.Time) (int64, error) {
+	result, err := exec.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?1`, timestampArg(expiredBefore))
+	if err != nil {
+		return 0, databaseError("ошибка удаления просроченных ключей идемпотентности", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, databaseError("ошибка удаления просроченных ключей идемпотентности", err)
+	}
+	return deleted, nil
+}
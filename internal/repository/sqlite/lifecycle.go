@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"time"
+)
+
+// SaveSubscriptionPrice записывает изменение цены с даты effective_from. Повторное изменение
+// на ту же дату заменяет ранее запланированную цену
+func (store *Store) SaveSubscriptionPrice(ctx context.Context, exec sqlx.ExtContext, price *model.SubscriptionPrice) error {
+	query := `INSERT INTO subscription_prices (subscription_id, price, effective_from, created_at)
+		VALUES (?1, ?2, ?3, ?4)
+		ON CONFLICT (subscription_id, effective_from) DO UPDATE
+			SET price = excluded.price, created_at = excluded.created_at
+		RETURNING id
+	`
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	row := exec.QueryRowxContext(ctx, query,
+		price.SubscriptionID,
+		price.Price,
+		dayArg(price.EffectiveFrom.ToTime()),
+		timestampArg(createdAt),
+	)
+	if err := row.Scan(&price.ID); err != nil {
+		return databaseError("ошибка при сохранении изменения цены подписки", err)
+	}
+	price.CreatedAt = createdAt
+	return nil
+}
+
+// GetSubscriptionPrices возвращает все изменения цены подписки, прошлые и запланированные,
+// в порядке вступления в силу
+func (store *Store) GetSubscriptionPrices(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPrice, error) {
+	query := `SELECT id, subscription_id, price, effective_from, created_at
+		FROM subscription_prices
+		WHERE subscription_id = ?1
+		ORDER BY effective_from
+	`
+
+	prices := []model.SubscriptionPrice{}
+	if err := sqlx.SelectContext(ctx, exec, &prices, query, subscriptionID); err != nil {
+		return nil, databaseError("ошибка получения истории цен подписки", err)
+	}
+	return prices, nil
+}
+
+// PauseSubscription открывает паузу подписки с текущего дня
+func (store *Store) PauseSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	query := `INSERT INTO subscription_pauses (subscription_id, paused_from) VALUES (?1, ?2)`
+
+	if _, err := exec.ExecContext(ctx, query, id, today()); err != nil {
+		return databaseError("ошибка при приостановке подписки", err)
+	}
+	return nil
+}
+
+// ResumeSubscription завершает открытую паузу подписки текущим днем
+func (store *Store) ResumeSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	query := `UPDATE subscription_pauses
+			SET resumed_on = max(paused_from, ?2)
+			WHERE subscription_id = ?1 AND resumed_on IS NULL`
+
+	if _, err := exec.ExecContext(ctx, query, id, today()); err != nil {
+		return databaseError("ошибка при возобновлении подписки", err)
+	}
+	return nil
+}
+
+// CancelSubscription отменяет подписку: оплаты после текущего дня больше не списываются,
+// открытая пауза завершается
+func (store *Store) CancelSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	if err := store.ResumeSubscription(ctx, exec, id); err != nil {
+		return err
+	}
+
+	query := `UPDATE subscriptions
+			SET cancelled_at = ?2,
+			    end_date = CASE
+			        WHEN end_date IS NULL OR end_date > ?3 THEN ?3
+			        ELSE end_date
+			    END
+			WHERE id = ?1 AND deleted_at IS NULL`
+
+	if _, err := exec.ExecContext(ctx, query, id, now(), today()); err != nil {
+		return databaseError("ошибка при отмене подписки", err)
+	}
+	return nil
+}
+
+// IncrementSubscriptionVersion увеличивает версию подписки после изменения ее состояния
+// в связанных таблицах и возвращает подписку в новом состоянии
+func (store *Store) IncrementSubscriptionVersion(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	const message = "ошибка при обновлении версии подписки"
+	query := `UPDATE subscriptions SET version = version + 1 WHERE id = ?1 AND deleted_at IS NULL`
+
+	if _, err := exec.ExecContext(ctx, query, id); err != nil {
+		return nil, databaseError(message, err)
+	}
+
+	subscription, err := getSubscription(ctx, exec, id, "deleted_at IS NULL")
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+	return subscription, nil
+}
+
+// GetSubscriptionPauses возвращает паузы подписки в хронологическом порядке
+func (store *Store) GetSubscriptionPauses(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPause, error) {
+	query := `SELECT id, subscription_id, paused_from, resumed_on
+		FROM subscription_pauses
+		WHERE subscription_id = ?1
+		ORDER BY paused_from, id
+	`
+
+	pauses := []model.SubscriptionPause{}
+	if err := sqlx.SelectContext(ctx, exec, &pauses, query, subscriptionID); err != nil {
+		return nil, databaseError("ошибка получения пауз подписки", err)
+	}
+	return pauses, nil
+}
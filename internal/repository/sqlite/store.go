@@ -0,0 +1,194 @@
+// Package sqlite - хранилище подписок поверх SQLite для запуска одним бинарником, без
+// Postgres. Схему создают миграции migrations/sqlite; поведение совпадает с
+// repository.SubscriptionRepository: те же ошибки, версии, мягкое удаление и правила
+// расчета стоимости. Даты хранятся текстом YYYY-MM-DD, UUID - в каноническом виде,
+// который возвращает Postgres
+package sqlite
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"log"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestampLayout - формат моментов времени в базе. Ширина у всех значений одинаковая,
+// поэтому строки сравниваются в том же порядке, что и моменты
+const timestampLayout = "2006-01-02 15:04:05.000000"
+
+// Store
+// Хранилище подписок в базе SQLite. Реализует repository.SubscriptionStore,
+// repository.ServiceCatalog, repository.ExchangeRateStore, repository.IdempotencyStore
+// и repository.CalendarTokenStore
+type Store struct {
+	*config.Database
+}
+
+var (
+	_ repository.SubscriptionStore  = (*Store)(nil)
+	_ repository.ServiceCatalog     = (*Store)(nil)
+	_ repository.ExchangeRateStore  = (*Store)(nil)
+	_ repository.IdempotencyStore   = (*Store)(nil)
+	_ repository.CalendarTokenStore = (*Store)(nil)
+)
+
+func NewStore(database *config.Database) *Store {
+	return &Store{database}
+}
+
+func (store *Store) Executor() sqlx.ExtContext {
+	return store.Database
+}
+
+// InTransaction выполняет fn в транзакции SQLite: коммитит при успехе и откатывает при ошибке.
+// SQLite выполняет пишущие транзакции по одной, поэтому отдельные блокировки строк не нужны
+func (store *Store) InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error {
+	tx, err := store.BeginTxx(ctx, nil)
+	if err != nil {
+		return databaseError("не удалось начать транзакцию", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("ошибка отката транзакции: %v", rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return databaseError("не удалось зафиксировать транзакцию", err)
+	}
+	return nil
+}
+
+// queryArgs собирает аргументы динамически строящегося запроса и выдает для каждого
+// следующий плейсхолдер ?N
+type queryArgs []any
+
+func (args *queryArgs) add(value any) string {
+	*args = append(*args, value)
+	return "?" + strconv.Itoa(len(*args))
+}
+
+// dayArg записывает дату в том виде, в каком ее хранит база
+func dayArg(date time.Time) string {
+	return billing.Day(date).Format(time.DateOnly)
+}
+
+// optionalDayArg записывает необязательную дату; nil и нулевая дата записываются как NULL,
+// как незаданная end_date
+func optionalDayArg(date *model.DayMonthYear) *string {
+	if date == nil || date.ToTime().IsZero() {
+		return nil
+	}
+	value := dayArg(date.ToTime())
+	return &value
+}
+
+// timestampArg записывает момент времени в UTC с точностью до микросекунд, как TIMESTAMPTZ
+func timestampArg(t time.Time) string {
+	return t.UTC().Format(timestampLayout)
+}
+
+// now возвращает текущий момент в том виде, в каком его хранит база
+func now() string {
+	return timestampArg(time.Now())
+}
+
+// today возвращает текущую дату, как current_date
+func today() string {
+	return dayArg(time.Now().UTC())
+}
+
+// databaseError логирует ошибку обращения к БД и оборачивает ее в доменный тип из model
+func databaseError(message string, err error) error {
+	return util.LogError(message, classifyDatabaseError(err))
+}
+
+// namedConstraints сопоставляет нарушения из текста ошибок SQLite с ограничениями схемы
+// Postgres: CHECK SQLite называет по имени ограничения, а UNIQUE - по колонкам
+var namedConstraints = map[string]string{
+	"service_aliases.normalized_alias":       "service_aliases_normalized_alias_key",
+	"service_aliases_normalized_alias_check": "service_aliases_normalized_alias_check",
+}
+
+func classifyDatabaseError(err error) error {
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) {
+		// текст ошибки: "constraint failed: UNIQUE constraint failed: <нарушение> (<код>)"
+		const prefix = "constraint failed: "
+		message := sqliteErr.Error()
+		if i := strings.LastIndex(message, prefix); i >= 0 {
+			detail, _, _ := strings.Cut(message[i+len(prefix):], " (")
+			if constraint, ok := namedConstraints[detail]; ok {
+				return repository.ConstraintError(constraint, message)
+			}
+		}
+	}
+
+	if kind := databaseErrorKind(err); kind != nil {
+		return fmt.Errorf("%w: %w", kind, err)
+	}
+	return err
+}
+
+func databaseErrorKind(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrNotFound
+	}
+
+	var sqliteErr *sqlitedriver.Error
+	if errors.As(err, &sqliteErr) {
+		switch code := sqliteErr.Code(); {
+		case code == sqlite3.SQLITE_CONSTRAINT_CHECK || code == sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+			return model.ErrValidation
+		case code&0xff == sqlite3.SQLITE_CONSTRAINT:
+			// нарушение уникальности, первичного или внешнего ключа
+			return model.ErrConflict
+		case code&0xff == sqlite3.SQLITE_BUSY || code&0xff == sqlite3.SQLITE_LOCKED:
+			return model.ErrUnavailable
+		}
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrConnDone) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return model.ErrUnavailable
+	}
+	return nil
+}
+
+// isForeignKeyViolation проверяет, что запрос нарушил внешний ключ. SQLite не называет
+// нарушенный ключ, поэтому ссылки на справочник валют проверяются отдельно: их нарушение,
+// как и в Postgres, возвращается клиенту ошибкой поля
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
+
+// missingCurrency проверяет, что валюты code нет в справочнике
+func missingCurrency(ctx context.Context, exec sqlx.ExtContext, code string) (bool, error) {
+	var exists bool
+	err := sqlx.GetContext(ctx, exec, &exists, `SELECT EXISTS(SELECT 1 FROM currencies WHERE code = ?1)`, code)
+	if err != nil {
+		return false, classifyDatabaseError(err)
+	}
+	return !exists, nil
+}
+
+// currencyError - нарушение ссылки constraint таблицы table на справочник валют
+func currencyError(table, constraint string) error {
+	return repository.ConstraintError(constraint,
+		fmt.Sprintf("insert or update on table %q violates foreign key constraint %q", table, constraint))
+}
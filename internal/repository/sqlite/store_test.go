@@ -0,0 +1,122 @@
+package sqlite_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository/sqlite"
+	"Effective_Mobile_Test_Project/internal/repository/storetest"
+	"Effective_Mobile_Test_Project/migrations"
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newStore возвращает хранилище поверх новой базы во временном файле с примененными миграциями
+func newStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+
+	database, err := config.SetupDatabase(config.DatabaseConfig{
+		Driver: config.DriverSQLite,
+		DSN:    "file:" + filepath.Join(t.TempDir(), "subscriptions.db"),
+	})
+	if err != nil {
+		t.Fatalf("не удалось открыть базу SQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	if err := migrations.RunSQLiteMigrations(database.DB.DB); err != nil {
+		t.Fatalf("ошибка миграции SQLite: %v", err)
+	}
+	return sqlite.NewStore(database)
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		store := newStore(t)
+		return storetest.Backend{Store: store, Catalog: store, Rates: store, CalendarTokens: store}
+	})
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	const scope = "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c01"
+	ctx := context.Background()
+	store := newStore(t)
+	exec := store.Executor()
+
+	reserved, err := store.ReserveKey(ctx, exec, scope, "key", "hash", time.Now().Add(time.Hour))
+	if err != nil || !reserved {
+		t.Fatalf("первый ReserveKey: reserved=%v, err=%v", reserved, err)
+	}
+	body := []byte("{\"message\": \"ok\"}\n")
+	if err := store.SaveResponse(ctx, exec, scope, "key", 201, []byte(`{"ETag":"\"1\""}`), body); err != nil {
+		t.Fatalf("SaveResponse: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		scope     string
+		key       string
+		expiresAt time.Time
+		reserved  bool
+	}{
+		{"тот же ключ занят", scope, "key", time.Now().Add(time.Hour), false},
+		{"ключ другого пользователя", "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c02", "key", time.Now().Add(time.Hour), true},
+		{"другой ключ", scope, "other", time.Now().Add(-time.Hour), true},
+		{"просроченный ключ освобождается", scope, "other", time.Now().Add(time.Hour), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reserved, err := store.ReserveKey(ctx, exec, tt.scope, tt.key, "hash", tt.expiresAt)
+			if err != nil {
+				t.Fatalf("ReserveKey: %v", err)
+			}
+			if reserved != tt.reserved {
+				t.Fatalf("reserved=%v, ожидалось %v", reserved, tt.reserved)
+			}
+		})
+	}
+
+	record, err := store.GetRecord(ctx, exec, scope, "key")
+	if err != nil {
+		t.Fatalf("GetRecord: %v", err)
+	}
+	if record.RequestHash != "hash" || record.StatusCode == nil || *record.StatusCode != 201 {
+		t.Fatalf("неверная запись: %+v", record)
+	}
+	if !bytes.Equal(record.Response, body) {
+		t.Fatalf("тело ответа изменилось: %q", record.Response)
+	}
+	if string(record.ResponseHeaders) != `{"ETag":"\"1\""}` {
+		t.Fatalf("заголовки изменились: %s", record.ResponseHeaders)
+	}
+}
+
+func TestDeleteExpiredIdempotencyKeys(t *testing.T) {
+	const scope = "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c01"
+	ctx := context.Background()
+	store := newStore(t)
+	exec := store.Executor()
+
+	for key, expiresAt := range map[string]time.Time{
+		"expired": time.Now().Add(-time.Hour),
+		"active":  time.Now().Add(time.Hour),
+	} {
+		if _, err := store.ReserveKey(ctx, exec, scope, key, "hash", expiresAt); err != nil {
+			t.Fatalf("ReserveKey(%s): %v", key, err)
+		}
+	}
+
+	deleted, err := store.DeleteExpiredKeys(ctx, exec, time.Now())
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpiredKeys: удалено %d, ошибка %v; ожидался один ключ", deleted, err)
+	}
+	if _, err := store.GetRecord(ctx, exec, scope, "expired"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("просроченный ключ не удален: %v", err)
+	}
+	if _, err := store.GetRecord(ctx, exec, scope, "active"); err != nil {
+		t.Errorf("действующий ключ удален: %v", err)
+	}
+}
@@ -0,0 +1,620 @@
+package sqlite
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// subscriptionStatusExpr вычисляет статус подписки на текущий день по тем же правилам,
+// что и выражение для Postgres. Незаданная end_date хранится как NULL
+const subscriptionStatusExpr = `CASE
+		WHEN subscriptions.cancelled_at IS NOT NULL THEN 'cancelled'
+		WHEN subscriptions.end_date < date('now') THEN 'expired'
+		WHEN subscriptions.trial_end >= date('now') THEN 'trial'
+		WHEN EXISTS (
+			SELECT 1 FROM subscription_pauses sp
+			WHERE sp.subscription_id = subscriptions.id AND sp.paused_from <= date('now')
+				AND (sp.resumed_on IS NULL OR sp.resumed_on > date('now'))
+		) THEN 'paused'
+		ELSE 'active'
+	END`
+
+// subscriptionCategoryExpr - категория подписки, а если она не задана, категория ее сервиса
+const subscriptionCategoryExpr = `COALESCE(subscriptions.category,
+		(SELECT sv.category FROM services sv WHERE sv.id = subscriptions.service_id))`
+
+// subscriptionTagsExpr собирает теги подписки в JSON-массив, отсортированный по имени
+const subscriptionTagsExpr = `(
+		SELECT json_group_array(t.name ORDER BY t.name)
+		FROM subscription_tags st
+		JOIN tags t ON t.id = st.tag_id
+		WHERE st.subscription_id = subscriptions.id
+	)`
+
+// subscriptionColumns перечисляет колонки, из которых собирается model.SubscriptionDetails.
+// Колонки дат выбираются без выражений: драйвер разбирает в time.Time только значения
+// колонок, объявленных как DATE и TIMESTAMP
+const subscriptionColumns = `id, service_id, service_name, price, currency, billing_period, billing_interval, user_id,
+	start_date, end_date, trial_end, cancelled_at, version, deleted_at, ` + subscriptionStatusExpr + ` AS status, ` +
+	subscriptionCategoryExpr + ` AS category, ` + subscriptionTagsExpr + ` AS tags`
+
+// subscriptionSortColumn описывает поле сортировки списка: SQL-выражение, разбор значения
+// из курсора и способ получить это значение из строки
+type subscriptionSortColumn struct {
+	expr     string
+	parse    func(value string) (any, error)
+	valueFor func(subscription *model.SubscriptionDetails) string
+}
+
+func parseInt(value string) (any, error) {
+	return strconv.Atoi(value)
+}
+
+func parseText(value string) (any, error) {
+	return value, nil
+}
+
+func parseDate(value string) (any, error) {
+	date, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return nil, err
+	}
+	return dayArg(date), nil
+}
+
+var subscriptionSortColumns = map[string]subscriptionSortColumn{
+	"id": {
+		expr:  "id",
+		parse: parseInt,
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return strconv.Itoa(subscription.ID)
+		},
+	},
+	"service_name": {
+		expr:  "service_name",
+		parse: parseText,
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return subscription.ServiceName
+		},
+	},
+	"price": {
+		expr:  "price",
+		parse: parseInt,
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return strconv.Itoa(subscription.Price)
+		},
+	},
+	"start_date": {
+		expr:  "start_date",
+		parse: parseDate,
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return subscription.StartDate.ToTime().Format(time.DateOnly)
+		},
+	},
+	"end_date": {
+		expr:  "COALESCE(end_date, '9999-12-31')",
+		parse: parseDate,
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			if subscription.EndDate.ToTime().IsZero() {
+				return "9999-12-31"
+			}
+			return subscription.EndDate.ToTime().Format(time.DateOnly)
+		},
+	},
+}
+
+func (store *Store) SaveSubscription(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) error {
+	const message = "ошибка при вставке подписки"
+	userID, err := repository.CanonicalUUID(subscription.UserID)
+	if err != nil {
+		return databaseError(message, err)
+	}
+
+	query := `INSERT INTO subscriptions
+		(service_name, price, user_id, start_date, end_date, currency, billing_period, billing_interval, trial_end, service_id, category)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)`
+
+	result, err := exec.ExecContext(ctx, query,
+		subscription.ServiceName,
+		subscription.Price,
+		userID,
+		dayArg(subscription.StartDate.ToTime()),
+		optionalDayArg(&subscription.EndDate),
+		subscription.Currency,
+		subscription.BillingPeriod,
+		subscription.BillingInterval,
+		optionalDayArg(subscription.TrialEnd),
+		subscription.ServiceID,
+		subscription.Category,
+	)
+	if err != nil {
+		return foreignKeyError(ctx, exec, message, err, subscription.Currency)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return databaseError(message, err)
+	}
+
+	tags := subscription.Tags
+	if err := replaceSubscriptionTags(ctx, exec, int(id), tags); err != nil {
+		return err
+	}
+
+	saved, err := getSubscription(ctx, exec, int(id), "deleted_at IS NULL")
+	if err != nil {
+		return databaseError(message, err)
+	}
+	// как и в Postgres, теги возвращаются в том виде, в каком их передали
+	*subscription = *saved
+	subscription.Tags = tags
+	return nil
+}
+
+func (store *Store) GetSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	subscription, err := getSubscription(ctx, exec, id, "deleted_at IS NULL")
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("не удалось найти подписку по ее id", err)
+		}
+		return nil, databaseError("ошибка получения таблицы подписок", err)
+	}
+	return subscription, nil
+}
+
+// LockSubscriptionByID читает подписку. Отдельная блокировка строки не нужна: пишущие
+// транзакции SQLite выполняются по одной
+func (store *Store) LockSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	return store.GetSubscriptionByID(ctx, exec, id)
+}
+
+// LockDeletedSubscriptionByID читает мягко удаленную подписку
+func (store *Store) LockDeletedSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	subscription, err := getSubscription(ctx, exec, id, "deleted_at IS NOT NULL")
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, restoreConflict(ctx, exec, id)
+		}
+		return nil, databaseError("ошибка чтения удаленной подписки", err)
+	}
+	return subscription, nil
+}
+
+func (store *Store) GetSubscriptionsByUserUUID(ctx context.Context, exec sqlx.ExtContext, uuid string) ([]model.SubscriptionDetails, error) {
+	const message = "ошибка получения подписок по uuid пользователя"
+	userID, err := repository.CanonicalUUID(uuid)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE user_id = ?1 AND deleted_at IS NULL ORDER BY id`
+
+	subscriptions := []model.SubscriptionDetails{}
+	if err := sqlx.SelectContext(ctx, exec, &subscriptions, query, userID); err != nil {
+		return nil, databaseError(message, err)
+	}
+	return subscriptions, nil
+}
+
+// ListSubscriptions возвращает страницу подписок с keyset-пагинацией: следующая страница
+// начинается строго после пары (значение сортировки, id) из курсора
+func (store *Store) ListSubscriptions(ctx context.Context, exec sqlx.ExtContext, filter model.SubscriptionListFilter) (*model.SubscriptionPage, error) {
+	const message = "ошибка получения списка подписок"
+	sortColumn, ok := subscriptionSortColumns[filter.Sort]
+	if !ok {
+		return nil, databaseError(message, fmt.Errorf("неизвестное поле сортировки %q", filter.Sort))
+	}
+
+	query, args, err := subscriptionListQuery(filter, sortColumn)
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+	query += ` LIMIT ` + args.add(filter.Limit+1)
+
+	subscriptions := []model.SubscriptionDetails{}
+	if err := sqlx.SelectContext(ctx, exec, &subscriptions, query, args...); err != nil {
+		return nil, databaseError(message, err)
+	}
+
+	page := &model.SubscriptionPage{Items: subscriptions}
+	if len(subscriptions) > filter.Limit {
+		page.Items = subscriptions[:filter.Limit]
+		last := &page.Items[len(page.Items)-1]
+		page.NextCursor = model.ListCursor{
+			Sort:  filter.Sort,
+			Desc:  filter.Desc,
+			Value: sortColumn.valueFor(last),
+			ID:    last.ID,
+		}.Encode()
+	}
+	return page, nil
+}
+
+// subscriptionListQuery строит запрос подписок, подходящих под фильтр списка, в порядке
+// его сортировки и после курсора filter.After, если он задан. Размер страницы не
+// ограничивается: его добавляет вызывающий
+func subscriptionListQuery(filter model.SubscriptionListFilter, sortColumn subscriptionSortColumn) (string, queryArgs, error) {
+	var args queryArgs
+	conditions := []string{"deleted_at IS NULL"}
+	if filter.Deleted {
+		conditions = []string{"deleted_at IS NOT NULL"}
+	}
+	if filter.UserID != nil {
+		userID, err := repository.CanonicalUUID(*filter.UserID)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "user_id = "+args.add(userID))
+	}
+	if filter.ServiceID != nil {
+		conditions = append(conditions, "service_id = "+args.add(*filter.ServiceID))
+	}
+	if filter.ServiceName != nil {
+		conditions = append(conditions, "service_id IN (SELECT service_id FROM service_aliases "+
+			"WHERE normalized_alias = "+args.add(repository.NormalizeServiceName(*filter.ServiceName))+")")
+	}
+	if filter.Category != nil {
+		conditions = append(conditions, subscriptionCategoryExpr+" = "+args.add(*filter.Category))
+	}
+	if len(filter.Tags) > 0 {
+		conditions = append(conditions, `id IN (
+			SELECT st.subscription_id FROM subscription_tags st
+			JOIN tags t ON t.id = st.tag_id
+			WHERE t.name IN (SELECT value FROM json_each(`+args.add(jsonArray(filter.Tags))+`))
+			GROUP BY st.subscription_id
+			HAVING count(*) = `+args.add(len(filter.Tags))+`
+		)`)
+	}
+	if filter.PriceMin != nil {
+		conditions = append(conditions, "price >= "+args.add(*filter.PriceMin))
+	}
+	if filter.PriceMax != nil {
+		conditions = append(conditions, "price <= "+args.add(*filter.PriceMax))
+	}
+	if filter.ActiveOn != nil {
+		activeOn := args.add(dayArg(*filter.ActiveOn))
+		conditions = append(conditions, "start_date <= "+activeOn+" AND (end_date IS NULL OR end_date >= "+activeOn+")")
+	}
+	if filter.StartFrom != nil {
+		conditions = append(conditions, "start_date >= "+args.add(dayArg(*filter.StartFrom)))
+	}
+	if filter.StartTo != nil {
+		conditions = append(conditions, "start_date <= "+args.add(dayArg(*filter.StartTo)))
+	}
+	if filter.EndFrom != nil {
+		conditions = append(conditions, "end_date >= "+args.add(dayArg(*filter.EndFrom)))
+	}
+	if filter.EndTo != nil {
+		conditions = append(conditions, "end_date <= "+args.add(dayArg(*filter.EndTo)))
+	}
+	if filter.Status != nil {
+		conditions = append(conditions, subscriptionStatusExpr+" = "+args.add(*filter.Status))
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.After != nil {
+		after, err := sortColumn.parse(filter.After.Value)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: неверное значение курсора %q", model.ErrValidation, filter.After.Value)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)",
+			sortColumn.expr, comparison, args.add(after), args.add(filter.After.ID)))
+	}
+
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + sortColumn.expr + ` ` + direction + `, id ` + direction
+	return query, args, nil
+}
+
+// UpdateSubscriptionByID перезаписывает подписку и увеличивает ее версию. Если expectedVersion
+// задана, обновление выполняется только при совпадении с текущей версией строки
+func (store *Store) UpdateSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails, id int, expectedVersion *int) error {
+	const message = "ошибка при обновлении подписки"
+	userID, err := repository.CanonicalUUID(subscription.UserID)
+	if err != nil {
+		return databaseError(message, err)
+	}
+
+	query := `UPDATE subscriptions
+			SET service_name = ?1,
+			    price = ?2,
+			    user_id = ?3,
+			    start_date = ?4,
+			    end_date = ?5,
+			    currency = ?6,
+			    billing_period = ?7,
+			    billing_interval = ?8,
+			    trial_end = ?9,
+			    service_id = ?10,
+			    category = ?11,
+			    version = version + 1
+			WHERE id = ?12 AND deleted_at IS NULL AND (?13 IS NULL OR version = ?13)`
+
+	// теги меняются до UPDATE, как и в реализации для Postgres
+	if err := replaceSubscriptionTags(ctx, exec, id, subscription.Tags); err != nil {
+		return err
+	}
+
+	result, err := exec.ExecContext(ctx, query,
+		subscription.ServiceName,
+		subscription.Price,
+		userID,
+		dayArg(subscription.StartDate.ToTime()),
+		optionalDayArg(&subscription.EndDate),
+		subscription.Currency,
+		subscription.BillingPeriod,
+		subscription.BillingInterval,
+		optionalDayArg(subscription.TrialEnd),
+		subscription.ServiceID,
+		subscription.Category,
+		id,
+		expectedVersion,
+	)
+	if err != nil {
+		return foreignKeyError(ctx, exec, message, err, subscription.Currency)
+	}
+	if err := expectUpdated(ctx, exec, result, id); err != nil {
+		return err
+	}
+
+	updated, err := getSubscription(ctx, exec, id, "deleted_at IS NULL")
+	if err != nil {
+		return databaseError(message, err)
+	}
+	*subscription = *updated
+	return nil
+}
+
+// PatchSubscriptionByID обновляет только переданные в патче колонки и возвращает подписку
+// после изменения. Пустой патч ничего не меняет, но версия все равно проверяется
+func (store *Store) PatchSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, patch model.SubscriptionPatch, expectedVersion *int) (*model.SubscriptionDetails, error) {
+	if patch.IsEmpty() {
+		subscription, err := store.GetSubscriptionByID(ctx, exec, id)
+		if err != nil {
+			return nil, err
+		}
+		if expectedVersion != nil && *expectedVersion != subscription.Version {
+			return nil, databaseError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+		}
+		return subscription, nil
+	}
+
+	const message = "ошибка при частичном обновлении подписки"
+	var args queryArgs
+	var assignments []string
+	if patch.ServiceID != nil {
+		assignments = append(assignments, "service_id = "+args.add(*patch.ServiceID))
+	}
+	if patch.ServiceName != nil {
+		assignments = append(assignments, "service_name = "+args.add(*patch.ServiceName))
+	}
+	if patch.Category != nil {
+		assignments = append(assignments, "category = "+args.add(*patch.Category))
+	}
+	if patch.ClearCategory {
+		assignments = append(assignments, "category = NULL")
+	}
+	if patch.Price != nil {
+		assignments = append(assignments, "price = "+args.add(*patch.Price))
+	}
+	if patch.Currency != nil {
+		assignments = append(assignments, "currency = "+args.add(*patch.Currency))
+	}
+	if patch.BillingPeriod != nil {
+		assignments = append(assignments, "billing_period = "+args.add(*patch.BillingPeriod))
+	}
+	if patch.BillingInterval != nil {
+		assignments = append(assignments, "billing_interval = "+args.add(*patch.BillingInterval))
+	}
+	if patch.UserID != nil {
+		userID, err := repository.CanonicalUUID(*patch.UserID)
+		if err != nil {
+			return nil, databaseError(message, err)
+		}
+		assignments = append(assignments, "user_id = "+args.add(userID))
+	}
+	if patch.StartDate != nil {
+		assignments = append(assignments, "start_date = "+args.add(dayArg(patch.StartDate.ToTime())))
+	}
+	if patch.EndDate != nil {
+		assignments = append(assignments, "end_date = "+args.add(optionalDayArg(patch.EndDate)))
+	}
+	if patch.ClearEndDate {
+		assignments = append(assignments, "end_date = NULL")
+	}
+	if patch.TrialEnd != nil {
+		assignments = append(assignments, "trial_end = "+args.add(optionalDayArg(patch.TrialEnd)))
+	}
+	if patch.ClearTrialEnd {
+		assignments = append(assignments, "trial_end = NULL")
+	}
+	assignments = append(assignments, "version = version + 1")
+
+	versionArg := args.add(expectedVersion)
+	query := `UPDATE subscriptions
+			SET ` + strings.Join(assignments, ", ") + `
+			WHERE id = ` + args.add(id) + ` AND deleted_at IS NULL AND (` + versionArg + ` IS NULL OR version = ` + versionArg + `)`
+
+	if patch.Tags != nil {
+		if err := replaceSubscriptionTags(ctx, exec, id, *patch.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := exec.ExecContext(ctx, query, args...)
+	if err != nil {
+		currency := ""
+		if patch.Currency != nil {
+			currency = *patch.Currency
+		}
+		return nil, foreignKeyError(ctx, exec, message, err, currency)
+	}
+	if err := expectUpdated(ctx, exec, result, id); err != nil {
+		return nil, err
+	}
+
+	subscription, err := getSubscription(ctx, exec, id, "deleted_at IS NULL")
+	if err != nil {
+		return nil, databaseError(message, err)
+	}
+	return subscription, nil
+}
+
+// DeleteSubscriptionByID мягко удаляет подписку, проставляя deleted_at; при заданной
+// expectedVersion только если версия строки не изменилась
+func (store *Store) DeleteSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, expectedVersion *int) error {
+	query := `UPDATE subscriptions
+			SET deleted_at = ?1,
+			    version = version + 1
+			WHERE id = ?2 AND deleted_at IS NULL AND (?3 IS NULL OR version = ?3)`
+
+	result, err := exec.ExecContext(ctx, query, now(), id, expectedVersion)
+	if err != nil {
+		return databaseError("ошибка при удалении подписки по ID", err)
+	}
+	return expectUpdated(ctx, exec, result, id)
+}
+
+// RestoreSubscriptionByID возвращает мягко удаленную подписку и увеличивает ее версию
+func (store *Store) RestoreSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	query := `UPDATE subscriptions
+			SET deleted_at = NULL,
+			    version = version + 1
+			WHERE id = ?1 AND deleted_at IS NOT NULL`
+
+	result, err := exec.ExecContext(ctx, query, id)
+	if err != nil {
+		return nil, databaseError("ошибка при восстановлении подписки", err)
+	}
+	restored, err := result.RowsAffected()
+	if err != nil {
+		return nil, databaseError("не удалось получить количество восстановленных строк", err)
+	}
+	if restored == 0 {
+		return nil, restoreConflict(ctx, exec, id)
+	}
+
+	return store.GetSubscriptionByID(ctx, exec, id)
+}
+
+// PurgeDeletedSubscriptions окончательно удаляет подписки, мягко удаленные раньше deletedBefore;
+// цены, паузы и теги подписок удаляются каскадно
+func (store *Store) PurgeDeletedSubscriptions(ctx context.Context, exec sqlx.ExtContext, deletedBefore time.Time) (int64, error) {
+	query := `DELETE FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < ?1`
+
+	result, err := exec.ExecContext(ctx, query, timestampArg(deletedBefore))
+	if err != nil {
+		return 0, databaseError("ошибка очистки удаленных подписок", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, databaseError("не удалось получить количество удалённых строк", err)
+	}
+	return purged, nil
+}
+
+// getSubscription читает подписку id, подходящую под условие condition на deleted_at
+func getSubscription(ctx context.Context, exec sqlx.ExtContext, id int, condition string) (*model.SubscriptionDetails, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id = ?1 AND ` + condition
+
+	var subscription model.SubscriptionDetails
+	if err := sqlx.GetContext(ctx, exec, &subscription, query, id); err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// replaceSubscriptionTags заменяет теги подписки на tags, добавляя новые имена в справочник тегов
+func replaceSubscriptionTags(ctx context.Context, exec sqlx.ExtContext, id int, tags []string) error {
+	names := jsonArray(tags)
+
+	// WHERE true отделяет SELECT от ON CONFLICT, иначе SQLite примет его за условие соединения
+	query := `INSERT INTO tags (name) SELECT value FROM json_each(?1) WHERE true ON CONFLICT (name) DO NOTHING`
+	if _, err := exec.ExecContext(ctx, query, names); err != nil {
+		return databaseError("ошибка при сохранении тегов", err)
+	}
+	if _, err := exec.ExecContext(ctx, `DELETE FROM subscription_tags WHERE subscription_id = ?1`, id); err != nil {
+		return databaseError("ошибка при удалении тегов подписки", err)
+	}
+
+	query = `INSERT INTO subscription_tags (subscription_id, tag_id)
+		SELECT ?1, id FROM tags WHERE name IN (SELECT value FROM json_each(?2))`
+	if _, err := exec.ExecContext(ctx, query, id, names); err != nil {
+		return databaseError("ошибка при сохранении тегов подписки", err)
+	}
+	return nil
+}
+
+// jsonArray передает список значений в запрос JSON-массивом для json_each; nil передается
+// пустым массивом
+func jsonArray[T any](values []T) string {
+	encoded, _ := json.Marshal(append([]T{}, values...))
+	return string(encoded)
+}
+
+// expectUpdated проверяет, что условное изменение затронуло подписку id
+func expectUpdated(ctx context.Context, exec sqlx.ExtContext, result sql.Result, id int) error {
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество измененных строк", err)
+	}
+	if updated == 0 {
+		return versionConflict(ctx, exec, id)
+	}
+	return nil
+}
+
+// foreignKeyError уточняет ошибку записи подписки: нарушенный внешний ключ на валюту
+// currency возвращается как subscriptions_currency_fkey, остальные - как конфликт
+func foreignKeyError(ctx context.Context, exec sqlx.ExtContext, message string, err error, currency string) error {
+	if currency != "" && isForeignKeyViolation(err) {
+		if missing, checkErr := missingCurrency(ctx, exec, currency); checkErr == nil && missing {
+			return util.LogError(message, currencyError("subscriptions", "subscriptions_currency_fkey"))
+		}
+	}
+	return databaseError(message, err)
+}
+
+// versionConflict объясняет, почему условное изменение не затронуло строк:
+// подписки нет совсем или ее версия уже другая
+func versionConflict(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	var exists bool
+	err := sqlx.GetContext(ctx, exec, &exists, `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = ?1 AND deleted_at IS NULL)`, id)
+	if err != nil {
+		return databaseError("ошибка проверки существования подписки", err)
+	}
+
+	if !exists {
+		return databaseError("подписка с таким ID не найдена", sql.ErrNoRows)
+	}
+	return databaseError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+}
+
+// restoreConflict объясняет, почему не нашлось удаленной подписки: ее нет совсем
+// или она не удалена
+func restoreConflict(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	var active bool
+	err := sqlx.GetContext(ctx, exec, &active, `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = ?1)`, id)
+	if err != nil {
+		return databaseError("ошибка проверки существования подписки", err)
+	}
+
+	if active {
+		return databaseError("подписка не удалена", model.ErrConflict)
+	}
+	return databaseError("удаленная подписка с таким ID не найдена", sql.ErrNoRows)
+}
@@ -0,0 +1,31 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/repository/storetest"
+	"testing"
+)
+
+// cleanupQuery возвращает базу к состоянию после миграций: без подписок, курсов
+// и сервисов, которые добавляет набор storetest
+const cleanupQuery = `
+	TRUNCATE subscriptions, subscription_audit, idempotency_keys, exchange_rates, tags, outbox, calendar_tokens
+		RESTART IDENTITY CASCADE;
+	DELETE FROM services WHERE name LIKE 'Storetest %';
+`
+
+func TestSubscriptionRepository(t *testing.T) {
+	database := openTestDatabase(t)
+
+	storetest.Run(t, func(t *testing.T) storetest.Backend {
+		if _, err := database.Exec(cleanupQuery); err != nil {
+			t.Fatalf("не удалось очистить базу: %v", err)
+		}
+		return storetest.Backend{
+			Store:          repository.NewSubscriptionRepository(database),
+			Catalog:        repository.NewCatalogRepository(database),
+			Rates:          repository.NewExchangeRateRepository(database),
+			CalendarTokens: repository.NewCalendarTokenRepository(database),
+		}
+	})
+}
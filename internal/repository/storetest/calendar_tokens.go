@@ -0,0 +1,51 @@
+package storetest
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+func testCalendarTokens(t *testing.T, s *suite) {
+	tokens := s.CalendarTokens
+	exec := tokens.Executor()
+	first := sha256.Sum256([]byte("первый токен"))
+	second := sha256.Sum256([]byte("второй токен"))
+
+	_, err := tokens.GetCalendarTokenHash(s.ctx, exec, userA)
+	expectError(t, err, model.ErrNotFound)
+	expectError(t, tokens.DeleteCalendarToken(s.ctx, exec, userA), model.ErrNotFound)
+
+	steps := []struct {
+		name string
+		save []byte
+		user string
+		want []byte
+	}{
+		{name: "выпуск", save: first[:], user: userA, want: first[:]},
+		{name: "UUID в другом написании", user: strings.ToUpper(userA), want: first[:]},
+		{name: "замена", save: second[:], user: userA, want: second[:]},
+	}
+	for _, step := range steps {
+		if step.save != nil {
+			if err := tokens.SaveCalendarToken(s.ctx, exec, step.user, step.save); err != nil {
+				t.Fatalf("%s: не удалось сохранить токен календаря: %v", step.name, err)
+			}
+		}
+		got, err := tokens.GetCalendarTokenHash(s.ctx, exec, step.user)
+		if err != nil || !bytes.Equal(got, step.want) {
+			t.Errorf("%s: хеш токена %x, ошибка %v, ожидался %x", step.name, got, err, step.want)
+		}
+	}
+
+	if _, err := tokens.GetCalendarTokenHash(s.ctx, exec, userB); err == nil {
+		t.Error("токен одного пользователя не должен находиться у другого")
+	}
+	if err := tokens.DeleteCalendarToken(s.ctx, exec, userA); err != nil {
+		t.Fatalf("не удалось отозвать токен календаря: %v", err)
+	}
+	_, err = tokens.GetCalendarTokenHash(s.ctx, exec, userA)
+	expectError(t, err, model.ErrNotFound)
+}
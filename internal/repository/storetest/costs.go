@@ -0,0 +1,273 @@
+package storetest
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testTotalCostOverlap: в стоимость за период попадают только оплаты внутри периода
+// и срока подписки; дата оплаты после 28 числа сдвигается на конец короткого месяца
+func testTotalCostOverlap(t *testing.T, s *suite) {
+	// оплаты 28.02, 31.03 и 30.04
+	endOfMonth := s.newSubscription(date(2025, time.January, 31), 1000)
+	endOfMonth.Tags = model.Tags{"home"}
+	s.save(endOfMonth)
+
+	// закончилась до периода
+	ended := s.newSubscription(date(2024, time.June, 1), 700)
+	ended.EndDate = model.DayMonthYear(date(2025, time.January, 15))
+	s.save(ended)
+
+	// начинается после периода
+	s.save(s.newSubscription(date(2025, time.May, 1), 700))
+
+	// оплаты 15.02 и 15.03, 15.04 уже после окончания
+	endsInside := s.newSubscription(date(2024, time.December, 15), 500)
+	endsInside.EndDate = model.DayMonthYear(date(2025, time.March, 20))
+	s.save(endsInside)
+
+	// раз в две недели: 10.02, 24.02, 10.03, 24.03, 07.04 и 21.04
+	biweekly := s.newSubscription(date(2025, time.January, 27), 100)
+	biweekly.ServiceID, biweekly.ServiceName = s.music.ID, musicService
+	biweekly.BillingPeriod, biweekly.BillingInterval = model.BillingPeriodWeek, 2
+	s.save(biweekly)
+
+	// раз в год с 29.02.2024: оплата 28.02.2025
+	leap := s.newSubscription(date(2024, time.February, 29), 5000)
+	leap.BillingPeriod = model.BillingPeriodYear
+	s.save(leap)
+
+	// удаленные подписки и подписки других пользователей не считаются
+	deleted := s.save(s.newSubscription(date(2025, time.January, 1), 9000))
+	if err := s.Store.DeleteSubscriptionByID(s.ctx, s.exec(), deleted.ID, nil); err != nil {
+		t.Fatalf("не удалось удалить подписку: %v", err)
+	}
+	foreign := s.newSubscription(date(2025, time.January, 1), 9000)
+	foreign.UserID = userB
+	s.save(foreign)
+
+	from, to := date(2025, time.February, 1), date(2025, time.April, 30)
+	expectCost(t, "период", s.totalCost(costFilter(from, to)), model.SubscriptionCost{Total: 9600, Charges: 12, BilledMonths: 9})
+
+	oneDay := costFilter(date(2025, time.February, 28), date(2025, time.February, 28))
+	expectCost(t, "один день", s.totalCost(oneDay), model.SubscriptionCost{Total: 6000, Charges: 2, BilledMonths: 2})
+
+	byService := costFilter(from, to)
+	byService.ServiceName = ptr("STORETEST-VIDEO")
+	expectCost(t, "service_name", s.totalCost(byService), model.SubscriptionCost{Total: 9000, Charges: 6, BilledMonths: 6})
+
+	byCategory := costFilter(from, to)
+	byCategory.Category = ptr("streaming")
+	expectCost(t, "category", s.totalCost(byCategory), model.SubscriptionCost{Total: 9000, Charges: 6, BilledMonths: 6})
+
+	byTag := costFilter(from, to)
+	byTag.Tag = ptr("home")
+	expectCost(t, "tag", s.totalCost(byTag), model.SubscriptionCost{Total: 3000, Charges: 3, BilledMonths: 3})
+
+	empty := costFilter(date(2023, time.January, 1), date(2023, time.December, 31))
+	expectCost(t, "период без оплат", s.totalCost(empty), model.SubscriptionCost{})
+}
+
+// testCostPricesAndTrial: оплата считается по цене, действующей на дату оплаты;
+// оплаты пробного периода, включая его последний день, не списываются
+func testCostPricesAndTrial(t *testing.T, s *suite) {
+	subscription := s.newSubscription(date(2025, time.January, 10), 1000)
+	subscription.TrialEnd = (*model.DayMonthYear)(ptr(date(2025, time.February, 10)))
+	s.save(subscription)
+
+	for _, change := range []model.SubscriptionPrice{
+		{SubscriptionID: subscription.ID, Price: 1500, EffectiveFrom: model.DayMonthYear(date(2025, time.March, 1))},
+		{SubscriptionID: subscription.ID, Price: 2000, EffectiveFrom: model.DayMonthYear(date(2025, time.April, 10))},
+	} {
+		if err := s.Store.SaveSubscriptionPrice(s.ctx, s.exec(), &change); err != nil {
+			t.Fatalf("не удалось сохранить изменение цены: %v", err)
+		}
+	}
+
+	// 10.02 - последний день пробного периода, 10.03 - по 1500, 10.04 - уже по 2000
+	cost := s.totalCost(costFilter(date(2025, time.February, 1), date(2025, time.April, 30)))
+	expectCost(t, "цены и пробный период", cost, model.SubscriptionCost{Total: 3500, Charges: 2, BilledMonths: 2})
+
+	// 10.01 попадает в пробный период
+	cost = s.totalCost(costFilter(date(2025, time.January, 1), date(2025, time.January, 31)))
+	expectCost(t, "пробный период", cost, model.SubscriptionCost{})
+}
+
+// costGroupsFixture сохраняет подписки, оплачиваемые 5-8 числа каждого месяца с января 2025:
+// видео за 1000 с тегами a и b, видео за 500 без тегов, музыку за 300 с тегом b
+// и категорией education и музыку за 200 без тегов и категории
+func costGroupsFixture(s *suite) (videoTagged *model.SubscriptionDetails) {
+	videoTagged = s.newSubscription(date(2025, time.January, 5), 1000)
+	videoTagged.Tags = model.Tags{"b", "a"}
+	s.save(videoTagged)
+
+	s.save(s.newSubscription(date(2025, time.January, 6), 500))
+
+	education := "education"
+	musicTagged := s.newSubscription(date(2025, time.January, 7), 300)
+	musicTagged.ServiceID, musicTagged.ServiceName = s.music.ID, musicService
+	musicTagged.Category, musicTagged.Tags = &education, model.Tags{"b"}
+	s.save(musicTagged)
+
+	music := s.newSubscription(date(2025, time.January, 8), 200)
+	music.ServiceID, music.ServiceName = s.music.ID, musicService
+	s.save(music)
+	return videoTagged
+}
+
+// testCostGroups: группы идут в порядке значения, группа без значения - последней;
+// оплата подписки с несколькими тегами попадает в группу каждого тега
+func testCostGroups(t *testing.T, s *suite) {
+	costGroupsFixture(s)
+
+	groups := func(groupBy string) []model.CostGroup {
+		t.Helper()
+		filter := costFilter(date(2025, time.March, 1), date(2025, time.March, 31))
+		filter.GroupBy = groupBy
+		result, err := s.Store.GetSubscriptionCostGroups(s.ctx, s.exec(), filter)
+		if err != nil {
+			t.Fatalf("не удалось сгруппировать стоимость по %s: %v", groupBy, err)
+		}
+		return result
+	}
+
+	expectGroups(t, model.CostGroupByService, groups(model.CostGroupByService), []model.CostGroup{
+		{ServiceName: musicService, Total: 500, Charges: 2},
+		{ServiceName: videoService, Total: 1500, Charges: 2},
+	})
+	expectGroups(t, model.CostGroupByCategory, groups(model.CostGroupByCategory), []model.CostGroup{
+		{Category: ptr("education"), Total: 300, Charges: 1},
+		{Category: ptr("streaming"), Total: 1500, Charges: 2},
+		{Total: 200, Charges: 1},
+	})
+	expectGroups(t, model.CostGroupByTag, groups(model.CostGroupByTag), []model.CostGroup{
+		{Tag: ptr("a"), Total: 1000, Charges: 1},
+		{Tag: ptr("b"), Total: 1300, Charges: 2},
+		{Total: 700, Charges: 2},
+	})
+}
+
+// testCostBreakdownAndMonthlySpend: помесячные суммы идут в порядке месяца и группы,
+// месяцы без оплат не выдаются
+func testCostBreakdownAndMonthlySpend(t *testing.T, s *suite) {
+	videoTagged := costGroupsFixture(s)
+
+	filter := costFilter(date(2024, time.December, 1), date(2025, time.February, 28))
+	filter.GroupBy = model.CostGroupByService
+	breakdown, err := s.Store.GetSubscriptionCostBreakdown(s.ctx, s.exec(), filter)
+	if err != nil {
+		t.Fatalf("не удалось получить помесячную стоимость: %v", err)
+	}
+	january, february := date(2025, time.January, 1), date(2025, time.February, 1)
+	want := []model.MonthlyCost{
+		{Month: model.MonthYear(january), ServiceName: musicService, Amount: 500},
+		{Month: model.MonthYear(january), ServiceName: videoService, Amount: 1500},
+		{Month: model.MonthYear(february), ServiceName: musicService, Amount: 500},
+		{Month: model.MonthYear(february), ServiceName: videoService, Amount: 1500},
+	}
+	if len(breakdown) != len(want) {
+		t.Fatalf("ожидалось %d строк помесячной стоимости, получено %+v", len(want), breakdown)
+	}
+	for i := range want {
+		got := breakdown[i]
+		if !sameDay(got.Month.ToTime(), want[i].Month.ToTime()) || got.ServiceName != want[i].ServiceName || got.Amount != want[i].Amount {
+			t.Errorf("строка %d: получено %+v, ожидалось %+v", i, got, want[i])
+		}
+	}
+
+	filter.GroupBy = ""
+	spend, err := s.Store.GetMonthlySpend(s.ctx, s.exec(), filter, videoTagged.ID)
+	if err != nil {
+		t.Fatalf("не удалось посчитать помесячные траты: %v", err)
+	}
+	if len(spend) != 2 {
+		t.Fatalf("ожидались траты за январь и февраль, получено %+v", spend)
+	}
+	for i, month := range []time.Time{january, february} {
+		if !sameDay(spend[i].Month.ToTime(), month) || spend[i].Spent != 2000 || spend[i].SubscriptionSpent != 1000 {
+			t.Errorf("траты за %s: получено %+v, ожидались 2000 и 1000 на подписку", month.Format("01-2006"), spend[i])
+		}
+	}
+}
+
+// testCurrencyConversion: оплата переводится по последнему курсу на дату оплаты,
+// прямому или обратному; без курса расчет отклоняется
+func testCurrencyConversion(t *testing.T, s *suite) {
+	dollars := s.newSubscription(date(2025, time.January, 15), 1000)
+	dollars.Currency = "USD"
+	s.save(dollars)
+
+	filter := costFilter(date(2025, time.February, 1), date(2025, time.February, 28))
+	_, err := s.Store.GetTotalSubscriptionCost(s.ctx, s.exec(), filter)
+	expectFieldError(t, err, "currency", "no_exchange_rate")
+
+	err = s.Rates.SaveExchangeRates(s.ctx, s.exec(), []model.ExchangeRate{
+		{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(date(2025, time.February, 1)), Rate: 90.5},
+		// курс после даты оплаты 15.02 не применяется
+		{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(date(2025, time.February, 20)), Rate: 100},
+		// для евро есть только обратный курс
+		{BaseCurrency: "RUB", QuoteCurrency: "EUR", RateDate: model.DayMonthYear(date(2025, time.January, 1)), Rate: 0.01},
+	})
+	if err != nil {
+		t.Fatalf("не удалось сохранить курсы: %v", err)
+	}
+
+	euros := s.newSubscription(date(2025, time.January, 20), 1000)
+	euros.Currency = "EUR"
+	s.save(euros)
+
+	// 10.00 USD по 90.5 и 10.00 EUR по 1 / 0.01
+	expectCost(t, "перевод в рубли", s.totalCost(filter), model.SubscriptionCost{Total: 90500 + 100000, Charges: 2, BilledMonths: 2})
+
+	// оплата в валюте расчета не переводится
+	dollarsOnly := s.totalCost(model.CostFilter{UserID: userA, StartPeriod: filter.StartPeriod, EndPeriod: date(2025, time.February, 16), Currency: "USD"})
+	expectCost(t, "без перевода", dollarsOnly, model.SubscriptionCost{Total: 1000, Charges: 1, BilledMonths: 1})
+
+	err = s.Rates.SaveExchangeRates(s.ctx, s.exec(), []model.ExchangeRate{
+		{BaseCurrency: "USD", QuoteCurrency: "XXX", RateDate: model.DayMonthYear(date(2025, time.February, 1)), Rate: 1},
+	})
+	expectFieldError(t, err, "quote_currency", "unknown_currency")
+}
+
+func expectCost(t *testing.T, name string, got, want model.SubscriptionCost) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: стоимость %+v, ожидалась %+v", name, got, want)
+	}
+}
+
+func expectGroups(t *testing.T, groupBy string, got, want []model.CostGroup) {
+	t.Helper()
+	equal := slices.EqualFunc(got, want, func(a, b model.CostGroup) bool {
+		return a.ServiceName == b.ServiceName && equalPointers(a.Category, b.Category) &&
+			equalPointers(a.Tag, b.Tag) && a.Total == b.Total && a.Charges == b.Charges
+	})
+	if !equal {
+		t.Errorf("группы по %s: получено %s, ожидалось %s", groupBy, formatGroups(got), formatGroups(want))
+	}
+}
+
+func equalPointers(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func formatGroups(groups []model.CostGroup) []string {
+	result := make([]string, 0, len(groups))
+	for _, group := range groups {
+		key := group.ServiceName
+		if group.Category != nil {
+			key = *group.Category
+		}
+		if group.Tag != nil {
+			key = *group.Tag
+		}
+		result = append(result, fmt.Sprintf("%s=%d/%d", key, group.Total, group.Charges))
+	}
+	return result
+}
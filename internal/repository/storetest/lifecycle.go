@@ -0,0 +1,230 @@
+package storetest
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"testing"
+	"time"
+)
+
+// testPrices: повторное изменение цены на ту же дату заменяет запланированное,
+// история выдается в порядке вступления в силу
+func testPrices(t *testing.T, s *suite) {
+	subscription := s.save(s.newSubscription(date(2025, time.January, 1), 1000))
+
+	save := func(price int, effective time.Time) model.SubscriptionPrice {
+		t.Helper()
+		change := model.SubscriptionPrice{SubscriptionID: subscription.ID, Price: price, EffectiveFrom: model.DayMonthYear(effective)}
+		if err := s.Store.SaveSubscriptionPrice(s.ctx, s.exec(), &change); err != nil {
+			t.Fatalf("не удалось сохранить изменение цены: %v", err)
+		}
+		return change
+	}
+
+	april := save(1500, date(2025, time.April, 1))
+	march := save(1200, date(2025, time.March, 1))
+	replaced := save(1700, date(2025, time.April, 1))
+	if replaced.ID != april.ID {
+		t.Errorf("изменение на ту же дату должно заменить запись %d, получена запись %d", april.ID, replaced.ID)
+	}
+
+	prices, err := s.Store.GetSubscriptionPrices(s.ctx, s.exec(), subscription.ID)
+	if err != nil {
+		t.Fatalf("не удалось получить историю цен: %v", err)
+	}
+	if len(prices) != 2 || prices[0].ID != march.ID || prices[1].ID != april.ID || prices[1].Price != 1700 {
+		t.Errorf("ожидались изменения 01.03 по 1200 и 01.04 по 1700, получено %+v", prices)
+	}
+
+	err = s.Store.SaveSubscriptionPrice(s.ctx, s.exec(), &model.SubscriptionPrice{
+		SubscriptionID: subscription.ID, Price: -1, EffectiveFrom: model.DayMonthYear(date(2025, time.May, 1)),
+	})
+	expectError(t, err, model.ErrValidation)
+
+	err = s.Store.SaveSubscriptionPrice(s.ctx, s.exec(), &model.SubscriptionPrice{
+		SubscriptionID: subscription.ID + 1000, Price: 1, EffectiveFrom: model.DayMonthYear(date(2025, time.May, 1)),
+	})
+	expectError(t, err, model.ErrConflict)
+
+	empty, err := s.Store.GetSubscriptionPrices(s.ctx, s.exec(), subscription.ID+1000)
+	if err != nil || len(empty) != 0 {
+		t.Errorf("у несуществующей подписки история цен пуста, получено %+v, ошибка %v", empty, err)
+	}
+}
+
+// testLifecycle: пауза, возобновление и отмена меняют статус подписки; открытая
+// пауза у подписки может быть только одна
+func testLifecycle(t *testing.T, s *suite) {
+	subscription := s.save(s.newSubscription(today().AddDate(0, -1, 0), 1000))
+
+	if err := s.Store.PauseSubscription(s.ctx, s.exec(), subscription.ID); err != nil {
+		t.Fatalf("не удалось приостановить подписку: %v", err)
+	}
+	if got := s.get(subscription.ID); got.Status != model.StatusPaused {
+		t.Errorf("статус приостановленной подписки %q, ожидался %q", got.Status, model.StatusPaused)
+	}
+	err := s.Store.PauseSubscription(s.ctx, s.exec(), subscription.ID)
+	expectError(t, err, model.ErrConflict)
+
+	if err := s.Store.ResumeSubscription(s.ctx, s.exec(), subscription.ID); err != nil {
+		t.Fatalf("не удалось возобновить подписку: %v", err)
+	}
+	if got := s.get(subscription.ID); got.Status != model.StatusActive {
+		t.Errorf("статус возобновленной подписки %q, ожидался %q", got.Status, model.StatusActive)
+	}
+
+	pauses, err := s.Store.GetSubscriptionPauses(s.ctx, s.exec(), subscription.ID)
+	if err != nil {
+		t.Fatalf("не удалось получить паузы подписки: %v", err)
+	}
+	if len(pauses) != 1 || !sameDay(pauses[0].PausedFrom.ToTime(), today()) ||
+		pauses[0].ResumedOn == nil || !sameDay(pauses[0].ResumedOn.ToTime(), today()) {
+		t.Errorf("ожидалась пауза с сегодняшнего дня, завершенная сегодня, получено %+v", pauses)
+	}
+
+	if err := s.Store.PauseSubscription(s.ctx, s.exec(), subscription.ID); err != nil {
+		t.Fatalf("не удалось снова приостановить подписку: %v", err)
+	}
+	if err := s.Store.CancelSubscription(s.ctx, s.exec(), subscription.ID); err != nil {
+		t.Fatalf("не удалось отменить подписку: %v", err)
+	}
+	got := s.get(subscription.ID)
+	if got.Status != model.StatusCancelled || got.CancelledAt == nil || !sameDay(got.EndDate.ToTime(), today()) {
+		t.Errorf("отмена должна проставить cancelled_at и закончить подписку сегодня, получено %+v", got)
+	}
+	pauses, err = s.Store.GetSubscriptionPauses(s.ctx, s.exec(), subscription.ID)
+	if err != nil {
+		t.Fatalf("не удалось получить паузы подписки: %v", err)
+	}
+	if len(pauses) != 2 || pauses[1].ResumedOn == nil {
+		t.Errorf("отмена должна завершить открытую паузу, получено %+v", pauses)
+	}
+
+	incremented, err := s.Store.IncrementSubscriptionVersion(s.ctx, s.exec(), subscription.ID)
+	if err != nil {
+		t.Fatalf("не удалось увеличить версию подписки: %v", err)
+	}
+	if incremented.Version != got.Version+1 {
+		t.Errorf("версия %d, ожидалась %d", incremented.Version, got.Version+1)
+	}
+	_, err = s.Store.IncrementSubscriptionVersion(s.ctx, s.exec(), subscription.ID+1000)
+	expectError(t, err, model.ErrNotFound)
+
+	ended := s.newSubscription(today().AddDate(0, -2, 0), 1000)
+	ended.EndDate = model.DayMonthYear(today().AddDate(0, 0, -3))
+	s.save(ended)
+	if err := s.Store.CancelSubscription(s.ctx, s.exec(), ended.ID); err != nil {
+		t.Fatalf("не удалось отменить подписку: %v", err)
+	}
+	if got := s.get(ended.ID); !sameDay(got.EndDate.ToTime(), today().AddDate(0, 0, -3)) {
+		t.Errorf("отмена не должна переносить прошедшую дату окончания, получено %v", got.EndDate.ToTime())
+	}
+}
+
+// testPausedCharges: оплаты открытой паузы не списываются, а пауза, завершенная в день
+// начала, ничего не исключает
+func testPausedCharges(t *testing.T, s *suite) {
+	daily := s.newSubscription(today().AddDate(0, 0, -5), 100)
+	daily.BillingPeriod = model.BillingPeriodDay
+	s.save(daily)
+
+	filter := costFilter(today().AddDate(0, 0, -5), today().AddDate(0, 0, 5))
+	expectCost(t, "до паузы", s.totalCost(filter), model.SubscriptionCost{Total: 1100, Charges: 11, BilledMonths: billedMonths(filter)})
+
+	if err := s.Store.PauseSubscription(s.ctx, s.exec(), daily.ID); err != nil {
+		t.Fatalf("не удалось приостановить подписку: %v", err)
+	}
+	paused := s.totalCost(filter)
+	if paused.Total != 500 || paused.Charges != 5 {
+		t.Errorf("во время паузы ожидались 5 оплат до сегодняшнего дня, получено %+v", paused)
+	}
+
+	if err := s.Store.ResumeSubscription(s.ctx, s.exec(), daily.ID); err != nil {
+		t.Fatalf("не удалось возобновить подписку: %v", err)
+	}
+	expectCost(t, "после паузы", s.totalCost(filter), model.SubscriptionCost{Total: 1100, Charges: 11, BilledMonths: billedMonths(filter)})
+}
+
+// billedMonths - число календарных месяцев, которые задевает период фильтра
+func billedMonths(filter model.CostFilter) int {
+	from, to := filter.StartPeriod, filter.EndPeriod
+	return (to.Year()-from.Year())*12 + int(to.Month()-from.Month()) + 1
+}
+
+// testUpcomingRenewals: для каждой подписки выдается ближайшая оплата в периоде;
+// отмененные подписки и подписки других пользователей не выдаются
+func testUpcomingRenewals(t *testing.T, s *suite) {
+	weekly := s.newSubscription(today().AddDate(0, 0, -7), 300)
+	weekly.BillingPeriod = model.BillingPeriodWeek
+	s.save(weekly)
+
+	future := s.save(s.newSubscription(today().AddDate(0, 0, 3), 500))
+
+	cancelled := s.save(s.newSubscription(today().AddDate(0, 0, -7), 700))
+	if err := s.Store.CancelSubscription(s.ctx, s.exec(), cancelled.ID); err != nil {
+		t.Fatalf("не удалось отменить подписку: %v", err)
+	}
+	foreign := s.newSubscription(today(), 900)
+	foreign.UserID = userB
+	s.save(foreign)
+
+	renewals, err := s.Store.GetUpcomingRenewals(s.ctx, s.exec(), userA, today(), today().AddDate(0, 0, 10))
+	if err != nil {
+		t.Fatalf("не удалось получить предстоящие оплаты: %v", err)
+	}
+	if len(renewals) != 2 {
+		t.Fatalf("ожидались две предстоящие оплаты, получено %+v", renewals)
+	}
+	if renewals[0].SubscriptionID != weekly.ID || !sameDay(renewals[0].BillingDate.ToTime(), today()) ||
+		renewals[0].DaysLeft != 0 || renewals[0].Price != 300 {
+		t.Errorf("первой ожидалась сегодняшняя оплата подписки %d, получено %+v", weekly.ID, renewals[0])
+	}
+	if renewals[1].SubscriptionID != future.ID || renewals[1].DaysLeft != 3 || renewals[1].UserID != userA {
+		t.Errorf("второй ожидалась оплата подписки %d через 3 дня, получено %+v", future.ID, renewals[1])
+	}
+}
+
+// testTransaction: изменения транзакции видны только после успешного завершения fn
+func testTransaction(t *testing.T, s *suite) {
+	subscription := s.save(s.newSubscription(today(), 100))
+	rollback := errors.New("откат")
+
+	err := s.Store.InTransaction(s.ctx, func(exec sqlx.ExtContext) error {
+		locked, err := s.Store.LockSubscriptionByID(s.ctx, exec, subscription.ID)
+		if err != nil {
+			return err
+		}
+		locked.Price = 200
+		if err := s.Store.UpdateSubscriptionByID(s.ctx, exec, locked, locked.ID, &locked.Version); err != nil {
+			return err
+		}
+		if err := s.Store.SaveSubscription(s.ctx, exec, s.newSubscription(today(), 300)); err != nil {
+			return err
+		}
+		if got, err := s.Store.GetSubscriptionByID(s.ctx, exec, subscription.ID); err != nil || got.Price != 200 {
+			t.Errorf("транзакция должна видеть собственные изменения, получено %+v, ошибка %v", got, err)
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("InTransaction должна вернуть ошибку fn, получено: %v", err)
+	}
+
+	if got := s.get(subscription.ID); got.Price != 100 || got.Version != 1 {
+		t.Errorf("после отката подписка не должна меняться, получено %+v", got)
+	}
+	byUser, err := s.Store.GetSubscriptionsByUserUUID(s.ctx, s.exec(), userA)
+	if err != nil || len(byUser) != 1 {
+		t.Fatalf("после отката должна остаться одна подписка, получено %+v, ошибка %v", byUser, err)
+	}
+
+	err = s.Store.InTransaction(s.ctx, func(exec sqlx.ExtContext) error {
+		return s.Store.DeleteSubscriptionByID(s.ctx, exec, subscription.ID, nil)
+	})
+	if err != nil {
+		t.Fatalf("не удалось удалить подписку в транзакции: %v", err)
+	}
+	_, err = s.Store.GetSubscriptionByID(s.ctx, s.exec(), subscription.ID)
+	expectError(t, err, model.ErrNotFound)
+}
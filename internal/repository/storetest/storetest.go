@@ -0,0 +1,197 @@
+// Package storetest - общий набор проверок контракта repository.SubscriptionStore.
+// Его должна проходить каждая реализация хранилища подписок, чтобы сервис вел себя
+// одинаково поверх любой из них. Набор вызывается из теста реализации:
+//
+//	storetest.Run(t, func(t *testing.T) storetest.Backend {
+//		store := memory.NewStore()
+//		return storetest.Backend{Store: store, Catalog: store, Rates: store, CalendarTokens: store}
+//	})
+//
+// Для Postgres фабрика должна возвращать репозитории поверх чистой БД с примененными миграциями
+package storetest
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"testing"
+	"time"
+)
+
+// ExchangeRateSaver
+// Справочник курсов, через который набор задает курсы для проверок перевода валют
+type ExchangeRateSaver interface {
+	SaveExchangeRates(ctx context.Context, exec sqlx.ExtContext, rates []model.ExchangeRate) error
+}
+
+// Backend
+// Хранилище под проверкой и справочники, через которые набор готовит данные. Фабрика
+// должна возвращать хранилище без подписок, со справочником валют RUB, USD и EUR
+type Backend struct {
+	Store          repository.SubscriptionStore
+	Catalog        repository.ServiceCatalog
+	Rates          ExchangeRateSaver
+	CalendarTokens repository.CalendarTokenStore
+}
+
+// Run проверяет хранилища, которые возвращает newBackend; каждая проверка получает
+// новое хранилище
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, s *suite)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"SchemaConstraints", testSchemaConstraints},
+		{"UpdateVersioning", testUpdateVersioning},
+		{"Patch", testPatch},
+		{"SoftDelete", testSoftDelete},
+		{"ListFilters", testListFilters},
+		{"ListPagination", testListPagination},
+		{"TotalCostOverlap", testTotalCostOverlap},
+		{"CostPricesAndTrial", testCostPricesAndTrial},
+		{"CostGroups", testCostGroups},
+		{"CostBreakdownAndMonthlySpend", testCostBreakdownAndMonthlySpend},
+		{"CurrencyConversion", testCurrencyConversion},
+		{"Prices", testPrices},
+		{"Lifecycle", testLifecycle},
+		{"PausedCharges", testPausedCharges},
+		{"UpcomingRenewals", testUpcomingRenewals},
+		{"Transaction", testTransaction},
+		{"CalendarTokens", testCalendarTokens},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			backend := newBackend(t)
+			s := &suite{Backend: backend, t: t, ctx: context.Background()}
+			s.seedCatalog()
+			c.run(t, s)
+		})
+	}
+}
+
+// Пользователи и сервисы каталога, на которых строятся проверки. Названия сервисов
+// не совпадают с сервисами, которые добавляют миграции Postgres
+const (
+	userA = "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c01"
+	userB = "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c02"
+
+	videoService = "Storetest Video"
+	musicService = "Storetest Music"
+)
+
+type suite struct {
+	Backend
+	t   *testing.T
+	ctx context.Context
+
+	video model.CatalogService
+	music model.CatalogService
+}
+
+func (s *suite) exec() sqlx.ExtContext {
+	return s.Store.Executor()
+}
+
+// seedCatalog добавляет сервисы каталога: видео с категорией streaming и написанием
+// "Стортест Видео", музыку без категории
+func (s *suite) seedCatalog() {
+	s.t.Helper()
+
+	streaming := "streaming"
+	s.video = model.CatalogService{Name: videoService, Aliases: []string{"Стортест Видео"}, Category: &streaming, Currency: "RUB"}
+	s.music = model.CatalogService{Name: musicService, Currency: "RUB"}
+	for _, service := range []*model.CatalogService{&s.video, &s.music} {
+		if err := s.Catalog.SaveCatalogService(s.ctx, s.exec(), service); err != nil {
+			s.t.Fatalf("не удалось добавить сервис %q в каталог: %v", service.Name, err)
+		}
+	}
+}
+
+// newSubscription возвращает месячную подписку пользователя userA на видеосервис
+// в рублях без даты окончания
+func (s *suite) newSubscription(start time.Time, price int) *model.SubscriptionDetails {
+	return &model.SubscriptionDetails{
+		ServiceID:       s.video.ID,
+		ServiceName:     s.video.Name,
+		Tags:            model.Tags{},
+		Price:           price,
+		Currency:        "RUB",
+		BillingPeriod:   model.BillingPeriodMonth,
+		BillingInterval: 1,
+		UserID:          userA,
+		StartDate:       model.DayMonthYear(start),
+	}
+}
+
+// save сохраняет подписку вне транзакции и завершает проверку при ошибке
+func (s *suite) save(subscription *model.SubscriptionDetails) *model.SubscriptionDetails {
+	s.t.Helper()
+	if err := s.Store.SaveSubscription(s.ctx, s.exec(), subscription); err != nil {
+		s.t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+	return subscription
+}
+
+func (s *suite) get(id int) *model.SubscriptionDetails {
+	s.t.Helper()
+	subscription, err := s.Store.GetSubscriptionByID(s.ctx, s.exec(), id)
+	if err != nil {
+		s.t.Fatalf("не удалось прочитать подписку id=%d: %v", id, err)
+	}
+	return subscription
+}
+
+// costFilter - фильтр расчета стоимости подписок userA в рублях за период [from, to]
+func costFilter(from, to time.Time) model.CostFilter {
+	return model.CostFilter{UserID: userA, StartPeriod: from, EndPeriod: to, Currency: "RUB"}
+}
+
+func (s *suite) totalCost(filter model.CostFilter) model.SubscriptionCost {
+	s.t.Helper()
+	cost, err := s.Store.GetTotalSubscriptionCost(s.ctx, s.exec(), filter)
+	if err != nil {
+		s.t.Fatalf("не удалось посчитать стоимость: %v", err)
+	}
+	return *cost
+}
+
+// expectError проверяет, что ошибка относится к доменному типу kind
+func expectError(t *testing.T, err error, kind error) {
+	t.Helper()
+	if !errors.Is(err, kind) {
+		t.Fatalf("ожидалась ошибка %q, получено: %v", kind, err)
+	}
+}
+
+// expectFieldError проверяет, что ошибка - нарушение правила code для поля field
+func expectFieldError(t *testing.T, err error, field, code string) {
+	t.Helper()
+	var errs model.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ожидалась ошибка валидации поля %s, получено: %v", field, err)
+	}
+	for _, fieldError := range errs {
+		if fieldError.Field == field && fieldError.Code == code {
+			return
+		}
+	}
+	t.Fatalf("ожидалось нарушение %s поля %s, получено: %v", code, field, errs)
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// today - текущая дата, как current_date
+func today() time.Time {
+	now := time.Now().UTC()
+	return date(now.Year(), now.Month(), now.Day())
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
@@ -0,0 +1,385 @@
+package storetest
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testSaveAndGet: подписка читается так, как сохранена; UUID приводится к каноническому
+// виду, теги сортируются, пустая категория заменяется категорией сервиса
+func testSaveAndGet(t *testing.T, s *suite) {
+	start := today().AddDate(0, -1, 0)
+	subscription := s.newSubscription(start, 39900)
+	subscription.UserID = "{7F3C2A10-5B4E-4D8A-9C61-0E2F4A6B8C01}"
+	subscription.Tags = model.Tags{"family", "work"}
+	s.save(subscription)
+
+	if subscription.ID == 0 || subscription.Version != 1 {
+		t.Fatalf("сохраненная подписка должна получить id и версию 1, получено id=%d версия=%d", subscription.ID, subscription.Version)
+	}
+
+	got := s.get(subscription.ID)
+	if got.UserID != userA {
+		t.Errorf("user_id = %q, ожидался %q", got.UserID, userA)
+	}
+	if got.ServiceID != s.video.ID || got.ServiceName != videoService || got.Price != 39900 || got.Currency != "RUB" {
+		t.Errorf("поля подписки не совпадают с сохраненными: %+v", got)
+	}
+	if !sameDay(got.StartDate.ToTime(), start) || !got.EndDate.ToTime().IsZero() {
+		t.Errorf("даты подписки не совпадают с сохраненными: начало %v, окончание %v", got.StartDate.ToTime(), got.EndDate.ToTime())
+	}
+	if !slices.Equal(got.Tags, model.Tags{"family", "work"}) {
+		t.Errorf("теги = %v, ожидались [family work]", got.Tags)
+	}
+	if got.Category == nil || *got.Category != "streaming" {
+		t.Errorf("подписка без категории должна получить категорию сервиса, получено %v", got.Category)
+	}
+	if got.Status != model.StatusActive {
+		t.Errorf("статус = %q, ожидался %q", got.Status, model.StatusActive)
+	}
+
+	own := "entertainment"
+	music := s.newSubscription(start, 29900)
+	music.ServiceID, music.ServiceName, music.Category = s.music.ID, musicService, &own
+	s.save(music)
+	if got := s.get(music.ID); got.Category == nil || *got.Category != own {
+		t.Errorf("собственная категория подписки должна сохраняться, получено %v", got.Category)
+	}
+
+	byUser, err := s.Store.GetSubscriptionsByUserUUID(s.ctx, s.exec(), userA)
+	if err != nil {
+		t.Fatalf("не удалось получить подписки пользователя: %v", err)
+	}
+	if len(byUser) != 2 || byUser[0].ID != subscription.ID || byUser[1].ID != music.ID {
+		t.Errorf("подписки пользователя должны идти в порядке id, получено %+v", byUser)
+	}
+
+	_, err = s.Store.GetSubscriptionByID(s.ctx, s.exec(), music.ID+1000)
+	expectError(t, err, model.ErrNotFound)
+	_, err = s.Store.LockSubscriptionByID(s.ctx, s.exec(), music.ID+1000)
+	expectError(t, err, model.ErrNotFound)
+}
+
+// testSchemaConstraints: хранилище само проверяет ограничения схемы и возвращает
+// те же ошибки, что и Postgres
+func testSchemaConstraints(t *testing.T, s *suite) {
+	start := today()
+
+	unknownCurrency := s.newSubscription(start, 100)
+	unknownCurrency.Currency = "XXX"
+	err := s.Store.SaveSubscription(s.ctx, s.exec(), unknownCurrency)
+	expectFieldError(t, err, "currency", "unknown_currency")
+
+	unknownService := s.newSubscription(start, 100)
+	unknownService.ServiceID = s.music.ID + 1000
+	err = s.Store.SaveSubscription(s.ctx, s.exec(), unknownService)
+	expectError(t, err, model.ErrConflict)
+
+	badUUID := s.newSubscription(start, 100)
+	badUUID.UserID = "not-a-uuid"
+	err = s.Store.SaveSubscription(s.ctx, s.exec(), badUUID)
+	expectError(t, err, model.ErrValidation)
+
+	badPeriod := s.newSubscription(start, 100)
+	badPeriod.BillingPeriod = "quarter"
+	err = s.Store.SaveSubscription(s.ctx, s.exec(), badPeriod)
+	expectError(t, err, model.ErrValidation)
+
+	badInterval := s.newSubscription(start, 100)
+	badInterval.BillingInterval = 0
+	err = s.Store.SaveSubscription(s.ctx, s.exec(), badInterval)
+	expectError(t, err, model.ErrValidation)
+
+	page, err := s.Store.ListSubscriptions(s.ctx, s.exec(), model.SubscriptionListFilter{Sort: "id", Limit: 10})
+	if err != nil {
+		t.Fatalf("не удалось получить список подписок: %v", err)
+	}
+	if len(page.Items) != 0 {
+		t.Errorf("подписки, нарушившие ограничения, не должны сохраняться, получено %+v", page.Items)
+	}
+}
+
+// testUpdateVersioning: обновление с устаревшей версией отклоняется, успешное -
+// увеличивает версию
+func testUpdateVersioning(t *testing.T, s *suite) {
+	subscription := s.save(s.newSubscription(today(), 100))
+
+	stale := 0
+	update := s.newSubscription(today(), 200)
+	err := s.Store.UpdateSubscriptionByID(s.ctx, s.exec(), update, subscription.ID, &stale)
+	expectError(t, err, model.ErrVersionMismatch)
+	if got := s.get(subscription.ID); got.Price != 100 || got.Version != 1 {
+		t.Fatalf("отклоненное обновление не должно менять подписку, получено %+v", got)
+	}
+
+	current := 1
+	update.Tags = model.Tags{"work"}
+	if err := s.Store.UpdateSubscriptionByID(s.ctx, s.exec(), update, subscription.ID, &current); err != nil {
+		t.Fatalf("не удалось обновить подписку: %v", err)
+	}
+	got := s.get(subscription.ID)
+	if got.Price != 200 || got.Version != 2 || !slices.Equal(got.Tags, model.Tags{"work"}) {
+		t.Errorf("после обновления ожидались цена 200, версия 2 и тег work, получено %+v", got)
+	}
+
+	// без ожидаемой версии обновление выполняется безусловно
+	update.Price = 300
+	if err := s.Store.UpdateSubscriptionByID(s.ctx, s.exec(), update, subscription.ID, nil); err != nil {
+		t.Fatalf("не удалось обновить подписку без версии: %v", err)
+	}
+	if got := s.get(subscription.ID); got.Price != 300 || got.Version != 3 {
+		t.Errorf("ожидались цена 300 и версия 3, получено %+v", got)
+	}
+
+	update.Tags = model.Tags{}
+	err = s.Store.UpdateSubscriptionByID(s.ctx, s.exec(), update, subscription.ID+1000, nil)
+	expectError(t, err, model.ErrNotFound)
+}
+
+// testPatch: патч меняет только переданные поля, явная очистка возвращает категорию
+// сервиса и снимает дату окончания
+func testPatch(t *testing.T, s *suite) {
+	own := "work"
+	subscription := s.newSubscription(today(), 100)
+	subscription.Category = &own
+	subscription.EndDate = model.DayMonthYear(today().AddDate(1, 0, 0))
+	subscription.Tags = model.Tags{"a"}
+	s.save(subscription)
+
+	price := 150
+	version := 1
+	patched, err := s.Store.PatchSubscriptionByID(s.ctx, s.exec(), subscription.ID,
+		model.SubscriptionPatch{Price: &price, ClearCategory: true, ClearEndDate: true}, &version)
+	if err != nil {
+		t.Fatalf("не удалось применить патч: %v", err)
+	}
+	if patched.Price != 150 || patched.Version != 2 || !patched.EndDate.ToTime().IsZero() {
+		t.Errorf("патч должен поменять цену, снять дату окончания и увеличить версию, получено %+v", patched)
+	}
+	if patched.Category == nil || *patched.Category != "streaming" {
+		t.Errorf("после очистки категории ожидалась категория сервиса, получено %v", patched.Category)
+	}
+	if !slices.Equal(patched.Tags, model.Tags{"a"}) || patched.Currency != "RUB" {
+		t.Errorf("поля, которых нет в патче, не должны меняться, получено %+v", patched)
+	}
+
+	_, err = s.Store.PatchSubscriptionByID(s.ctx, s.exec(), subscription.ID, model.SubscriptionPatch{Price: &price}, &version)
+	expectError(t, err, model.ErrVersionMismatch)
+
+	negative := -1
+	_, err = s.Store.PatchSubscriptionByID(s.ctx, s.exec(), subscription.ID, model.SubscriptionPatch{BillingInterval: &negative}, nil)
+	expectError(t, err, model.ErrValidation)
+}
+
+// testSoftDelete: удаленная подписка не читается, но восстанавливается; очистка
+// окончательно удаляет подписки, удаленные раньше срока
+func testSoftDelete(t *testing.T, s *suite) {
+	subscription := s.save(s.newSubscription(today(), 100))
+	kept := s.save(s.newSubscription(today(), 200))
+
+	stale := 5
+	err := s.Store.DeleteSubscriptionByID(s.ctx, s.exec(), subscription.ID, &stale)
+	expectError(t, err, model.ErrVersionMismatch)
+
+	if err := s.Store.DeleteSubscriptionByID(s.ctx, s.exec(), subscription.ID, nil); err != nil {
+		t.Fatalf("не удалось удалить подписку: %v", err)
+	}
+	_, err = s.Store.GetSubscriptionByID(s.ctx, s.exec(), subscription.ID)
+	expectError(t, err, model.ErrNotFound)
+	err = s.Store.DeleteSubscriptionByID(s.ctx, s.exec(), subscription.ID, nil)
+	expectError(t, err, model.ErrNotFound)
+
+	deleted, err := s.Store.LockDeletedSubscriptionByID(s.ctx, s.exec(), subscription.ID)
+	if err != nil {
+		t.Fatalf("удаленная подписка должна читаться для восстановления: %v", err)
+	}
+	if deleted.DeletedAt == nil || deleted.Version != 2 {
+		t.Errorf("удаление должно проставить deleted_at и увеличить версию, получено %+v", deleted)
+	}
+	_, err = s.Store.LockDeletedSubscriptionByID(s.ctx, s.exec(), kept.ID)
+	expectError(t, err, model.ErrConflict)
+
+	page, err := s.Store.ListSubscriptions(s.ctx, s.exec(), model.SubscriptionListFilter{Deleted: true, Sort: "id", Limit: 10})
+	if err != nil {
+		t.Fatalf("не удалось получить список удаленных подписок: %v", err)
+	}
+	if len(page.Items) != 1 || page.Items[0].ID != subscription.ID {
+		t.Errorf("в списке удаленных ожидалась подписка %d, получено %+v", subscription.ID, page.Items)
+	}
+
+	restored, err := s.Store.RestoreSubscriptionByID(s.ctx, s.exec(), subscription.ID)
+	if err != nil {
+		t.Fatalf("не удалось восстановить подписку: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != 3 {
+		t.Errorf("восстановление должно снять deleted_at и увеличить версию, получено %+v", restored)
+	}
+
+	if err := s.Store.DeleteSubscriptionByID(s.ctx, s.exec(), subscription.ID, nil); err != nil {
+		t.Fatalf("не удалось удалить подписку: %v", err)
+	}
+	purged, err := s.Store.PurgeDeletedSubscriptions(s.ctx, s.exec(), time.Now().Add(-time.Hour))
+	if err != nil || purged != 0 {
+		t.Fatalf("подписки, удаленные позже срока, не должны очищаться: удалено %d, ошибка %v", purged, err)
+	}
+	purged, err = s.Store.PurgeDeletedSubscriptions(s.ctx, s.exec(), time.Now().Add(time.Hour))
+	if err != nil || purged != 1 {
+		t.Fatalf("ожидалась очистка одной подписки: удалено %d, ошибка %v", purged, err)
+	}
+	_, err = s.Store.RestoreSubscriptionByID(s.ctx, s.exec(), subscription.ID)
+	expectError(t, err, model.ErrNotFound)
+	s.get(kept.ID)
+}
+
+// testListFilters: фильтры списка отбирают подписки так же, как в Postgres
+func testListFilters(t *testing.T, s *suite) {
+	base := today().AddDate(0, -2, 0)
+
+	video := s.newSubscription(base, 100)
+	video.Tags = model.Tags{"family", "work"}
+	s.save(video)
+
+	music := s.newSubscription(base.AddDate(0, 0, 10), 300)
+	music.ServiceID, music.ServiceName = s.music.ID, musicService
+	music.Tags = model.Tags{"work"}
+	music.EndDate = model.DayMonthYear(today().AddDate(0, 0, -1))
+	s.save(music)
+
+	other := s.newSubscription(base, 200)
+	other.UserID = userB
+	other.TrialEnd = (*model.DayMonthYear)(ptr(today().AddDate(0, 0, 7)))
+	s.save(other)
+
+	ids := func(filter model.SubscriptionListFilter) []int {
+		t.Helper()
+		filter.Sort, filter.Limit = "id", 10
+		page, err := s.Store.ListSubscriptions(s.ctx, s.exec(), filter)
+		if err != nil {
+			t.Fatalf("не удалось получить список подписок: %v", err)
+		}
+		result := []int{}
+		for _, item := range page.Items {
+			result = append(result, item.ID)
+		}
+		return result
+	}
+	check := func(name string, filter model.SubscriptionListFilter, want ...int) {
+		t.Helper()
+		if got := ids(filter); !slices.Equal(got, append([]int{}, want...)) {
+			t.Errorf("фильтр %s: получены подписки %v, ожидались %v", name, got, want)
+		}
+	}
+
+	check("без фильтров", model.SubscriptionListFilter{}, video.ID, music.ID, other.ID)
+	check("user_id", model.SubscriptionListFilter{UserID: ptr("7F3C2A10-5B4E-4D8A-9C61-0E2F4A6B8C02")}, other.ID)
+	check("service_id", model.SubscriptionListFilter{ServiceID: &s.music.ID}, music.ID)
+	check("service_name по написанию", model.SubscriptionListFilter{ServiceName: ptr("стортест  видео!")}, video.ID, other.ID)
+	check("category сервиса", model.SubscriptionListFilter{Category: ptr("streaming")}, video.ID, other.ID)
+	check("все теги", model.SubscriptionListFilter{Tags: []string{"work", "family"}}, video.ID)
+	check("один тег", model.SubscriptionListFilter{Tags: []string{"work"}}, video.ID, music.ID)
+	check("price", model.SubscriptionListFilter{PriceMin: ptr(150), PriceMax: ptr(300)}, music.ID, other.ID)
+	check("active_on", model.SubscriptionListFilter{ActiveOn: ptr(today())}, video.ID, other.ID)
+	check("start", model.SubscriptionListFilter{StartFrom: ptr(base.AddDate(0, 0, 1)), StartTo: ptr(base.AddDate(0, 0, 10))}, music.ID)
+	check("status trial", model.SubscriptionListFilter{Status: ptr(model.StatusTrial)}, other.ID)
+	check("status expired", model.SubscriptionListFilter{Status: ptr(model.StatusExpired)}, music.ID)
+	check("status active", model.SubscriptionListFilter{Status: ptr(model.StatusActive)}, video.ID)
+}
+
+// testListPagination: страницы по курсору не теряют и не повторяют подписки при
+// одинаковых значениях поля сортировки
+func testListPagination(t *testing.T, s *suite) {
+	prices := []int{300, 100, 200, 100, 300, 200, 100}
+	for i, price := range prices {
+		subscription := s.newSubscription(today().AddDate(0, 0, -i), price)
+		if i%2 == 0 {
+			subscription.EndDate = model.DayMonthYear(today().AddDate(0, 1, i))
+		}
+		s.save(subscription)
+	}
+
+	for _, sort := range []string{"id", "price", "start_date", "end_date", "service_name"} {
+		for _, desc := range []bool{false, true} {
+			filter := model.SubscriptionListFilter{Sort: sort, Desc: desc, Limit: 2}
+			var all []model.SubscriptionDetails
+			for pages := 0; ; pages++ {
+				if pages > len(prices) {
+					t.Fatalf("сортировка %s desc=%v: курсор зациклился", sort, desc)
+				}
+				page, err := s.Store.ListSubscriptions(s.ctx, s.exec(), filter)
+				if err != nil {
+					t.Fatalf("сортировка %s desc=%v: %v", sort, desc, err)
+				}
+				all = append(all, page.Items...)
+				if page.NextCursor == "" {
+					break
+				}
+				cursor, err := model.DecodeListCursor(page.NextCursor)
+				if err != nil {
+					t.Fatalf("сортировка %s desc=%v: неверный курсор: %v", sort, desc, err)
+				}
+				filter.After = cursor
+			}
+
+			if len(all) != len(prices) {
+				t.Fatalf("сортировка %s desc=%v: получено %d подписок из %d", sort, desc, len(all), len(prices))
+			}
+			seen := map[int]bool{}
+			for i, item := range all {
+				if seen[item.ID] {
+					t.Fatalf("сортировка %s desc=%v: подписка %d выдана дважды", sort, desc, item.ID)
+				}
+				seen[item.ID] = true
+				if i > 0 && !ordered(all[i-1], item, sort, desc) {
+					t.Errorf("сортировка %s desc=%v: нарушен порядок между %d и %d", sort, desc, all[i-1].ID, item.ID)
+				}
+			}
+		}
+	}
+}
+
+// ordered проверяет, что подписка b может идти после a: по значению поля сортировки,
+// при равенстве - по id в том же направлении. Подписки без даты окончания идут как
+// заканчивающиеся позже всех
+func ordered(a, b model.SubscriptionDetails, sort string, desc bool) bool {
+	var compared int
+	switch sort {
+	case "price":
+		compared = a.Price - b.Price
+	case "service_name":
+		compared = compareStrings(a.ServiceName, b.ServiceName)
+	case "start_date":
+		compared = a.StartDate.ToTime().Compare(b.StartDate.ToTime())
+	case "end_date":
+		compared = endOrFuture(a).Compare(endOrFuture(b))
+	}
+	if compared == 0 {
+		compared = a.ID - b.ID
+	}
+	if desc {
+		return compared > 0
+	}
+	return compared < 0
+}
+
+func endOrFuture(subscription model.SubscriptionDetails) time.Time {
+	if subscription.EndDate.ToTime().IsZero() {
+		return date(9999, time.December, 31)
+	}
+	return subscription.EndDate.ToTime()
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+// PauseSubscription открывает паузу подписки с текущего дня
+func (repo *SubscriptionRepository) PauseSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	query := `INSERT INTO subscription_pauses (subscription_id, paused_from) VALUES ($1, current_date)`
+
+	if _, err := exec.ExecContext(ctx, query, id); err != nil {
+		return databaseError("ошибка при приостановке подписки", err)
+	}
+	return nil
+}
+
+// ResumeSubscription завершает открытую паузу подписки текущим днем
+func (repo *SubscriptionRepository) ResumeSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	query := `UPDATE subscription_pauses
+			SET resumed_on = GREATEST(paused_from, current_date)
+			WHERE subscription_id = $1 AND resumed_on IS NULL`
+
+	if _, err := exec.ExecContext(ctx, query, id); err != nil {
+		return databaseError("ошибка при возобновлении подписки", err)
+	}
+	return nil
+}
+
+// CancelSubscription отменяет подписку: оплаты после текущего дня больше не списываются,
+// открытая пауза завершается
+func (repo *SubscriptionRepository) CancelSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	if err := repo.ResumeSubscription(ctx, exec, id); err != nil {
+		return err
+	}
+
+	query := `UPDATE subscriptions
+			SET cancelled_at = now(),
+			    end_date = CASE
+			        WHEN end_date IS NULL OR end_date = '0001-01-01' OR end_date > current_date THEN current_date
+			        ELSE end_date
+			    END
+			WHERE id = $1 AND deleted_at IS NULL`
+
+	if _, err := exec.ExecContext(ctx, query, id); err != nil {
+		return databaseError("ошибка при отмене подписки", err)
+	}
+	return nil
+}
+
+// IncrementSubscriptionVersion увеличивает версию подписки после изменения ее состояния
+// в связанных таблицах и возвращает подписку в новом состоянии
+func (repo *SubscriptionRepository) IncrementSubscriptionVersion(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	query := `UPDATE subscriptions
+			SET version = version + 1
+			WHERE id = $1 AND deleted_at IS NULL
+			RETURNING ` + subscriptionColumns
+
+	var subscription model.SubscriptionDetails
+	err := sqlx.GetContext(ctx, exec, &subscription, query, id)
+	if err != nil {
+		return nil, databaseError("ошибка при обновлении версии подписки", err)
+	}
+	return &subscription, nil
+}
+
+// GetSubscriptionPauses возвращает паузы подписки в хронологическом порядке
+func (repo *SubscriptionRepository) GetSubscriptionPauses(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPause, error) {
+	query := `SELECT id, subscription_id, paused_from, resumed_on
+		FROM subscription_pauses
+		WHERE subscription_id = $1
+		ORDER BY paused_from, id
+	`
+
+	pauses := []model.SubscriptionPause{}
+	err := sqlx.SelectContext(ctx, exec, &pauses, query, subscriptionID)
+	if err != nil {
+		return nil, databaseError("ошибка получения пауз подписки", err)
+	}
+	return pauses, nil
+}
@@ -0,0 +1,116 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionLifecycle(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 400, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: newTestUserID(t),
+		StartDate: model.DayMonthYear(today.AddDate(0, -1, 0)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	steps := []struct {
+		name   string
+		apply  func(ctx context.Context, id int) error
+		status string
+	}{
+		{name: "новая подписка", status: model.StatusActive},
+		{name: "пауза", apply: func(ctx context.Context, id int) error { return repo.PauseSubscription(ctx, database, id) }, status: model.StatusPaused},
+		{name: "возобновление", apply: func(ctx context.Context, id int) error { return repo.ResumeSubscription(ctx, database, id) }, status: model.StatusActive},
+		{name: "повторная пауза", apply: func(ctx context.Context, id int) error { return repo.PauseSubscription(ctx, database, id) }, status: model.StatusPaused},
+		{name: "отмена", apply: func(ctx context.Context, id int) error { return repo.CancelSubscription(ctx, database, id) }, status: model.StatusCancelled},
+	}
+
+	version := subscription.Version
+	for _, step := range steps {
+		current, err := repo.GetSubscriptionByID(ctx, database, subscription.ID)
+		if step.apply != nil {
+			if err := step.apply(ctx, subscription.ID); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			current, err = repo.IncrementSubscriptionVersion(ctx, database, subscription.ID)
+			version++
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if current.Status != step.status || current.Version != version {
+			t.Errorf("%s: статус %s версии %d, ожидался %s версии %d", step.name, current.Status, current.Version, step.status, version)
+		}
+	}
+
+	cancelled, err := repo.GetSubscriptionByID(ctx, database, subscription.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionByID: %v", err)
+	}
+	if cancelled.CancelledAt == nil || !cancelled.EndDate.ToTime().Equal(today) {
+		t.Errorf("отмена должна закончить подписку сегодня: %+v", cancelled)
+	}
+
+	pauses, err := repo.GetSubscriptionPauses(ctx, database, subscription.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionPauses: %v", err)
+	}
+	if len(pauses) != 2 {
+		t.Fatalf("паузы %+v, ожидалось две", pauses)
+	}
+	for _, pause := range pauses {
+		if pause.ResumedOn == nil {
+			t.Errorf("отмена должна завершить открытую паузу: %+v", pause)
+		}
+	}
+}
+
+func TestSubscriptionTrialStatus(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	tests := []struct {
+		name     string
+		trialEnd time.Time
+		end      time.Time
+		status   string
+	}{
+		{name: "пробный период идет", trialEnd: today.AddDate(0, 0, 7), status: model.StatusTrial},
+		{name: "пробный период закончился", trialEnd: today.AddDate(0, 0, -1), status: model.StatusActive},
+		{name: "срок истек", trialEnd: today.AddDate(0, 0, -20), end: today.AddDate(0, 0, -1), status: model.StatusExpired},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			trialEnd := model.DayMonthYear(test.trialEnd)
+			subscription := &model.SubscriptionDetails{
+				ServiceName: "Видео", Price: 400, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: newTestUserID(t),
+				StartDate: model.DayMonthYear(today.AddDate(0, -1, 0)), EndDate: model.DayMonthYear(test.end), TrialEnd: &trialEnd,
+			}
+			subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+			if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+				t.Fatalf("не удалось сохранить подписку: %v", err)
+			}
+
+			saved, err := repo.GetSubscriptionByID(ctx, database, subscription.ID)
+			if err != nil {
+				t.Fatalf("GetSubscriptionByID: %v", err)
+			}
+			if saved.Status != test.status {
+				t.Errorf("статус %s, ожидался %s", saved.Status, test.status)
+			}
+		})
+	}
+}
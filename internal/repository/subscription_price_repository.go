@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+)
+
+// SaveSubscriptionPrice записывает изменение цены с даты effective_from. Повторное изменение
+// на ту же дату заменяет ранее запланированную цену
+func (repo *SubscriptionRepository) SaveSubscriptionPrice(ctx context.Context, exec sqlx.ExtContext, price *model.SubscriptionPrice) error {
+	query := `INSERT INTO subscription_prices (subscription_id, price, effective_from)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (subscription_id, effective_from) DO UPDATE
+			SET price = EXCLUDED.price, created_at = now()
+		RETURNING id, created_at
+	`
+	row := exec.QueryRowxContext(ctx, query,
+		price.SubscriptionID,
+		price.Price,
+		price.EffectiveFrom.ToTime(),
+	)
+
+	err := row.Scan(&price.ID, &price.CreatedAt)
+	if err != nil {
+		return databaseError("ошибка при сохранении изменения цены подписки", err)
+	}
+	return nil
+}
+
+// GetSubscriptionPrices возвращает все изменения цены подписки, прошлые и запланированные,
+// в порядке вступления в силу
+func (repo *SubscriptionRepository) GetSubscriptionPrices(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPrice, error) {
+	query := `SELECT id, subscription_id, price, effective_from, created_at
+		FROM subscription_prices
+		WHERE subscription_id = $1
+		ORDER BY effective_from
+	`
+
+	prices := []model.SubscriptionPrice{}
+	err := sqlx.SelectContext(ctx, exec, &prices, query, subscriptionID)
+	if err != nil {
+		return nil, databaseError("ошибка получения истории цен подписки", err)
+	}
+	return prices, nil
+}
@@ -0,0 +1,55 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTotalCostWithPriceHistory(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	userID := newTestUserID(t)
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 100, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: userID,
+		StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	// с марта 200, с мая 300; повтор изменения на март заменяет цену 150
+	changes := []model.SubscriptionPrice{
+		{SubscriptionID: subscription.ID, Price: 300, EffectiveFrom: model.DayMonthYear(date(2025, time.May, 1))},
+		{SubscriptionID: subscription.ID, Price: 150, EffectiveFrom: model.DayMonthYear(date(2025, time.March, 1))},
+		{SubscriptionID: subscription.ID, Price: 200, EffectiveFrom: model.DayMonthYear(date(2025, time.March, 1))},
+	}
+	for i := range changes {
+		if err := repo.SaveSubscriptionPrice(ctx, database, &changes[i]); err != nil {
+			t.Fatalf("SaveSubscriptionPrice: %v", err)
+		}
+	}
+
+	prices, err := repo.GetSubscriptionPrices(ctx, database, subscription.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionPrices: %v", err)
+	}
+	if len(prices) != 2 || prices[0].Price != 200 || prices[1].Price != 300 {
+		t.Fatalf("история цен %+v", prices)
+	}
+
+	filter := model.CostFilter{UserID: userID, Currency: "RUB", StartPeriod: date(2025, time.January, 1), EndPeriod: date(2025, time.June, 30)}
+	cost, err := repo.GetTotalSubscriptionCost(ctx, database, filter)
+	if err != nil {
+		t.Fatalf("GetTotalSubscriptionCost: %v", err)
+	}
+	// январь и февраль по 100, март и апрель по 200, май и июнь по 300
+	if cost.Total != 1200 || cost.BilledMonths != 6 {
+		t.Errorf("стоимость %d за %d мес., ожидалось 1200 за 6 мес.", cost.Total, cost.BilledMonths)
+	}
+}
@@ -3,14 +3,99 @@ package repository
 import (
 	"Effective_Mobile_Test_Project/internal/config"
 	"Effective_Mobile_Test_Project/internal/model"
-	"Effective_Mobile_Test_Project/internal/util"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// subscriptionStatusExpr вычисляет статус подписки на текущий день. Выражение ссылается
+// на таблицу subscriptions без алиаса, поэтому используется только в запросах к ней
+const subscriptionStatusExpr = `CASE
+		WHEN subscriptions.cancelled_at IS NOT NULL THEN 'cancelled'
+		WHEN subscriptions.end_date IS NOT NULL AND subscriptions.end_date <> '0001-01-01'
+			AND subscriptions.end_date < current_date THEN 'expired'
+		WHEN subscriptions.trial_end >= current_date THEN 'trial'
+		WHEN EXISTS (
+			SELECT 1 FROM subscription_pauses sp
+			WHERE sp.subscription_id = subscriptions.id AND sp.paused_from <= current_date
+				AND (sp.resumed_on IS NULL OR sp.resumed_on > current_date)
+		) THEN 'paused'
+		ELSE 'active'
+	END`
+
+// subscriptionCategoryExpr - категория подписки, а если она не задана, категория ее сервиса
+// из каталога. Как и subscriptionStatusExpr, ссылается на subscriptions без алиаса
+const subscriptionCategoryExpr = `COALESCE(subscriptions.category,
+		(SELECT sv.category FROM services sv WHERE sv.id = subscriptions.service_id))`
+
+// subscriptionTagsExpr собирает теги подписки в JSON-массив, отсортированный по имени
+const subscriptionTagsExpr = `COALESCE((
+		SELECT json_agg(t.name ORDER BY t.name)
+		FROM subscription_tags st
+		JOIN tags t ON t.id = st.tag_id
+		WHERE st.subscription_id = subscriptions.id
+	), '[]')`
+
+// subscriptionColumns перечисляет колонки, из которых собирается model.SubscriptionDetails
+const subscriptionColumns = `id, service_id, service_name, price, currency, billing_period, billing_interval, user_id,
+	start_date, end_date, trial_end, cancelled_at, version, deleted_at, ` + subscriptionStatusExpr + ` AS status, ` +
+	subscriptionCategoryExpr + ` AS category, ` + subscriptionTagsExpr + ` AS tags`
+
+// subscriptionSortColumn описывает поле сортировки списка: SQL-выражение, тип для
+// приведения значения из курсора и способ получить это значение из строки
+type subscriptionSortColumn struct {
+	expr     string
+	sqlType  string
+	valueFor func(subscription *model.SubscriptionDetails) string
+}
+
+var subscriptionSortColumns = map[string]subscriptionSortColumn{
+	"id": {
+		expr:    "id",
+		sqlType: "int",
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return strconv.Itoa(subscription.ID)
+		},
+	},
+	"service_name": {
+		expr:    "service_name",
+		sqlType: "text",
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return subscription.ServiceName
+		},
+	},
+	"price": {
+		expr:    "price",
+		sqlType: "int",
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return strconv.Itoa(subscription.Price)
+		},
+	},
+	"start_date": {
+		expr:    "start_date",
+		sqlType: "date",
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			return subscription.StartDate.ToTime().Format(time.DateOnly)
+		},
+	},
+	"end_date": {
+		expr:    "COALESCE(NULLIF(end_date, '0001-01-01'), '9999-12-31'::date)",
+		sqlType: "date",
+		valueFor: func(subscription *model.SubscriptionDetails) string {
+			if subscription.EndDate.ToTime().IsZero() {
+				return "9999-12-31"
+			}
+			return subscription.EndDate.ToTime().Format(time.DateOnly)
+		},
+	},
+}
+
 type SubscriptionRepository struct {
 	*config.Database
 }
@@ -21,132 +106,623 @@ func NewSubscriptionRepository(database *config.Database) *SubscriptionRepositor
 
 func (repo *SubscriptionRepository) SaveSubscription(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) error {
 	query := `INSERT INTO subscriptions 
-        (service_name, price, user_id, start_date, end_date)
-        VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-    `
-	row := exec.QueryRowxContext(
+        (service_name, price, user_id, start_date, end_date, currency, billing_period, billing_interval, trial_end, service_id, category)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING ` + subscriptionColumns
+
+	tags := subscription.Tags
+	err := sqlx.GetContext(
 		ctx,
+		exec,
+		subscription,
 		query,
 		subscription.ServiceName,
 		subscription.Price,
 		subscription.UserID,
 		subscription.StartDate.ToTime(),
 		subscription.EndDate.ToTime(),
+		subscription.Currency,
+		subscription.BillingPeriod,
+		subscription.BillingInterval,
+		dateArg(subscription.TrialEnd),
+		subscription.ServiceID,
+		subscription.Category,
 	)
-
-	err := row.Scan(&subscription.ID)
 	if err != nil {
-		return util.LogError("ошибка при вставке подписки", err)
+		return databaseError("ошибка при вставке подписки", err)
 	}
+
+	if err := repo.replaceSubscriptionTags(ctx, exec, subscription.ID, tags); err != nil {
+		return err
+	}
+	subscription.Tags = tags
 	return nil
 }
 
 func (repo *SubscriptionRepository) GetSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
-	query := `SELECT * FROM subscriptions WHERE id=$1`
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id=$1 AND deleted_at IS NULL`
 
 	var returnedOrder model.SubscriptionDetails
 	err := sqlx.GetContext(ctx, exec, &returnedOrder, query, id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, util.LogError("не удалось найти подписку по ее id", err)
+			return nil, databaseError("не удалось найти подписку по ее id", err)
 		}
-		return nil, util.LogError("ошибка получения таблицы подписок", err)
+		return nil, databaseError("ошибка получения таблицы подписок", err)
 	}
 
 	return &returnedOrder, nil
 }
 
+// LockSubscriptionByID читает подписку и блокирует строку до конца транзакции exec,
+// чтобы ее можно было проверить и изменить без гонок
+func (repo *SubscriptionRepository) LockSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id=$1 AND deleted_at IS NULL FOR UPDATE`
+
+	var subscription model.SubscriptionDetails
+	err := sqlx.GetContext(ctx, exec, &subscription, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("не удалось найти подписку по ее id", err)
+		}
+		return nil, databaseError("ошибка блокировки подписки", err)
+	}
+
+	return &subscription, nil
+}
+
+// LockDeletedSubscriptionByID читает и блокирует мягко удаленную подписку
+func (repo *SubscriptionRepository) LockDeletedSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE id=$1 AND deleted_at IS NOT NULL FOR UPDATE`
+
+	var subscription model.SubscriptionDetails
+	err := sqlx.GetContext(ctx, exec, &subscription, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repo.restoreConflict(ctx, exec, id)
+		}
+		return nil, databaseError("ошибка блокировки удаленной подписки", err)
+	}
+
+	return &subscription, nil
+}
+
 func (repo *SubscriptionRepository) GetSubscriptionsByUserUUID(ctx context.Context, exec sqlx.ExtContext, uuid string) ([]model.SubscriptionDetails, error) {
-	query := `SELECT * FROM subscriptions WHERE user_id=$1`
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions WHERE user_id=$1 AND deleted_at IS NULL`
 
-	var subscriptions []model.SubscriptionDetails
+	subscriptions := []model.SubscriptionDetails{}
 	err := sqlx.SelectContext(ctx, exec, &subscriptions, query, uuid)
 	if err != nil {
-		return nil, util.LogError("ошибка получения подписок по uuid пользователя", err)
+		return nil, databaseError("ошибка получения подписок по uuid пользователя", err)
 	}
 
 	return subscriptions, nil
 }
 
-func (repo *SubscriptionRepository) GetTotalSubscriptionCost(
-	ctx context.Context,
-	exec sqlx.ExtContext,
-	userID string,
-	serviceName *string,
-	startPeriod time.Time,
-	endPeriod time.Time,
-) (int, error) {
-	query := `
-		SELECT COALESCE(SUM(price), 0) AS total
-		FROM subscriptions
+// ListSubscriptions возвращает страницу подписок с keyset-пагинацией: вместо OFFSET
+// следующая страница начинается строго после пары (значение сортировки, id) из курсора
+func (repo *SubscriptionRepository) ListSubscriptions(ctx context.Context, exec sqlx.ExtContext, filter model.SubscriptionListFilter) (*model.SubscriptionPage, error) {
+	sortColumn, ok := subscriptionSortColumns[filter.Sort]
+	if !ok {
+		return nil, databaseError("ошибка получения списка подписок", fmt.Errorf("неизвестное поле сортировки %q", filter.Sort))
+	}
+
+	query, args := subscriptionListQuery(filter, sortColumn)
+	query += ` LIMIT ` + args.add(filter.Limit+1)
+
+	subscriptions := []model.SubscriptionDetails{}
+	err := sqlx.SelectContext(ctx, exec, &subscriptions, query, args...)
+	if err != nil {
+		return nil, databaseError("ошибка получения списка подписок", err)
+	}
+
+	page := &model.SubscriptionPage{Items: subscriptions}
+	if len(subscriptions) > filter.Limit {
+		page.Items = subscriptions[:filter.Limit]
+		last := &page.Items[len(page.Items)-1]
+		page.NextCursor = model.ListCursor{
+			Sort:  filter.Sort,
+			Desc:  filter.Desc,
+			Value: sortColumn.valueFor(last),
+			ID:    last.ID,
+		}.Encode()
+	}
+
+	return page, nil
+}
+
+// subscriptionListQuery строит запрос подписок, подходящих под фильтр списка, в порядке
+// его сортировки и после курсора filter.After, если он задан. Размер страницы не
+// ограничивается: его добавляет вызывающий
+func subscriptionListQuery(filter model.SubscriptionListFilter, sortColumn subscriptionSortColumn) (string, queryArgs) {
+	var args queryArgs
+	conditions := []string{"deleted_at IS NULL"}
+	if filter.Deleted {
+		conditions = []string{"deleted_at IS NOT NULL"}
+	}
+	if filter.UserID != nil {
+		conditions = append(conditions, "user_id = "+args.add(*filter.UserID)+"::uuid")
+	}
+	if filter.ServiceID != nil {
+		conditions = append(conditions, "service_id = "+args.add(*filter.ServiceID))
+	}
+	if filter.ServiceName != nil {
+		conditions = append(conditions, "service_id IN (SELECT service_id FROM service_aliases "+
+			"WHERE normalized_alias = normalize_service_name("+args.add(*filter.ServiceName)+"))")
+	}
+	if filter.Category != nil {
+		conditions = append(conditions, subscriptionCategoryExpr+" = "+args.add(*filter.Category))
+	}
+	if len(filter.Tags) > 0 {
+		conditions = append(conditions, `id IN (
+			SELECT st.subscription_id FROM subscription_tags st
+			JOIN tags t ON t.id = st.tag_id
+			WHERE t.name = ANY(`+args.add(pq.StringArray(filter.Tags))+`::text[])
+			GROUP BY st.subscription_id
+			HAVING count(*) = `+args.add(len(filter.Tags))+`
+		)`)
+	}
+	if filter.PriceMin != nil {
+		conditions = append(conditions, "price >= "+args.add(*filter.PriceMin))
+	}
+	if filter.PriceMax != nil {
+		conditions = append(conditions, "price <= "+args.add(*filter.PriceMax))
+	}
+	if filter.ActiveOn != nil {
+		activeOn := args.add(*filter.ActiveOn)
+		conditions = append(conditions, "start_date <= "+activeOn+"::date AND "+
+			"(end_date IS NULL OR end_date = '0001-01-01' OR end_date >= "+activeOn+"::date)")
+	}
+	if filter.StartFrom != nil {
+		conditions = append(conditions, "start_date >= "+args.add(*filter.StartFrom)+"::date")
+	}
+	if filter.StartTo != nil {
+		conditions = append(conditions, "start_date <= "+args.add(*filter.StartTo)+"::date")
+	}
+	if filter.EndFrom != nil {
+		conditions = append(conditions, "end_date <> '0001-01-01' AND end_date >= "+args.add(*filter.EndFrom)+"::date")
+	}
+	if filter.EndTo != nil {
+		conditions = append(conditions, "end_date <> '0001-01-01' AND end_date <= "+args.add(*filter.EndTo)+"::date")
+	}
+	if filter.Status != nil {
+		conditions = append(conditions, subscriptionStatusExpr+" = "+args.add(*filter.Status))
+	}
+
+	direction, comparison := "ASC", ">"
+	if filter.Desc {
+		direction, comparison = "DESC", "<"
+	}
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s::%s, %s)",
+			sortColumn.expr, comparison, args.add(filter.After.Value), sortColumn.sqlType, args.add(filter.After.ID)))
+	}
+
+	query := `SELECT ` + subscriptionColumns + ` FROM subscriptions
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + sortColumn.expr + ` ` + direction + `, id ` + direction
+	return query, args
+}
+
+// billedChargesQuery разворачивает подходящие под фильтр подписки в строки по датам оплаты
+// внутри периода [$3, $4]. Фильтр $2 принимает любое написание сервиса из каталога. Даты оплаты отсчитываются от start_date подписки с шагом
+// billing_interval периодов billing_period; бессрочные подписки (end_date IS NULL или '0001-01-01') ограничиваются концом периода.
+// Цена каждой оплаты - последнее изменение из subscription_prices, вступившее в силу
+// к дате оплаты, а до первого изменения - цена самой подписки. Оплаты до конца
+// пробного периода (trial_end включительно) и внутри пауз не списываются. Сумма оплаты amount
+// переводится в валюту $5 по курсу на дату оплаты. $6 отбирает подписки по категории
+// (собственной или сервиса), $7 - по тегу.
+// Все расчеты стоимости строятся поверх этого запроса, чтобы фильтры в них совпадали
+const billedChargesQuery = `
+	SELECT id, service_name, category, charged_on,
+		convert_amount(price, currency, $5::char(3), charged_on) AS amount
+	FROM (
+		SELECT s.id, s.service_name, s.currency, charged_on,
+			COALESCE(s.category, sv.category) AS category,
+			COALESCE((
+				SELECT p.price FROM subscription_prices p
+				WHERE p.subscription_id = s.id AND p.effective_from <= charged_on
+				ORDER BY p.effective_from DESC
+				LIMIT 1
+			), s.price) AS price
+		FROM subscriptions s
+		JOIN services sv ON sv.id = s.service_id
+		CROSS JOIN LATERAL subscription_charge_dates(
+			s.start_date, NULLIF(s.end_date, '0001-01-01'), $3::date, $4::date,
+			s.billing_period, s.billing_interval
+		) AS charged_on
 		WHERE 
-			($1::uuid IS NULL OR user_id = $1::uuid) AND
-			($2::text IS NULL OR service_name = $2::text) AND
-			start_date <= $4 AND (end_date IS NULL OR end_date >= $3 OR end_date = '0001-01-01')
+			s.deleted_at IS NULL AND
+			($1::uuid IS NULL OR s.user_id = $1::uuid) AND
+			($2::text IS NULL OR s.service_id IN (
+				SELECT a.service_id FROM service_aliases a
+				WHERE a.normalized_alias = normalize_service_name($2::text)
+			)) AND
+			($6::text IS NULL OR COALESCE(s.category, sv.category) = $6::text) AND
+			($7::text IS NULL OR EXISTS (
+				SELECT 1 FROM subscription_tags st
+				JOIN tags t ON t.id = st.tag_id
+				WHERE st.subscription_id = s.id AND t.name = $7::text
+			)) AND
+			s.start_date <= $4 AND (s.end_date IS NULL OR s.end_date >= $3 OR s.end_date = '0001-01-01') AND
+			(s.trial_end IS NULL OR charged_on > s.trial_end) AND
+			NOT EXISTS (
+				SELECT 1 FROM subscription_pauses sp
+				WHERE sp.subscription_id = s.id AND charged_on >= sp.paused_from
+					AND (sp.resumed_on IS NULL OR charged_on < sp.resumed_on)
+			)
+	) AS priced
+`
+
+// costGrouping описывает разрез группировки стоимости: колонку с ключом группы и
+// соединение, которое добавляет ее к строкам billedChargesQuery
+type costGrouping struct {
+	column string
+	join   string
+}
+
+var costGroupings = map[string]costGrouping{
+	model.CostGroupByService:  {column: "service_name"},
+	model.CostGroupByCategory: {column: "category"},
+	model.CostGroupByTag: {
+		column: "tag",
+		join: `LEFT JOIN LATERAL (
+			SELECT t.name AS tag
+			FROM subscription_tags st
+			JOIN tags t ON t.id = st.tag_id
+			WHERE st.subscription_id = charges.id
+		) AS charge_tags ON TRUE`,
+	},
+}
+
+// costArgs передает фильтры расчета стоимости в плейсхолдеры billedChargesQuery
+func costArgs(filter model.CostFilter) []any {
+	return []any{
+		filter.UserID,
+		filter.ServiceName,
+		filter.StartPeriod,
+		filter.EndPeriod,
+		filter.Currency,
+		filter.Category,
+		filter.Tag,
+	}
+}
+
+// GetTotalSubscriptionCost считает стоимость как сумму цен, действовавших на каждую
+// дату оплаты внутри периода, в валюте filter.Currency. BilledMonths - число пар
+// (подписка, календарный месяц) хотя бы с одной оплатой
+func (repo *SubscriptionRepository) GetTotalSubscriptionCost(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) (*model.SubscriptionCost, error) {
+	query := `
+		SELECT COALESCE(SUM(amount), 0) AS total,
+			COUNT(*) AS charges,
+			COUNT(DISTINCT (id, date_trunc('month', charged_on))) AS billed_months
+		FROM (` + billedChargesQuery + `) AS charges
 	`
 
-	var total int
-	err := sqlx.GetContext(ctx, exec, &total, query, userID, serviceName, startPeriod, endPeriod)
+	var cost model.SubscriptionCost
+	err := sqlx.GetContext(ctx, exec, &cost, query, costArgs(filter)...)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, util.LogError("не удалось найти подписки", err)
+			return nil, databaseError("не удалось найти подписки", err)
 		}
-		return 0, util.LogError("ошибка подсчета общей стоимости подписок", err)
+		return nil, databaseError("ошибка подсчета общей стоимости подписок", err)
 	}
 
-	return total, nil
+	return &cost, nil
 }
 
-func (repo *SubscriptionRepository) UpdateSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails, id int) error {
+// GetSubscriptionCostGroups делит общую стоимость на группы по разрезу filter.GroupBy
+// с теми же фильтрами, что и GetTotalSubscriptionCost
+func (repo *SubscriptionRepository) GetSubscriptionCostGroups(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.CostGroup, error) {
+	grouping, ok := costGroupings[filter.GroupBy]
+	if !ok {
+		return nil, databaseError("ошибка группировки стоимости подписок", fmt.Errorf("неизвестный разрез группировки %q", filter.GroupBy))
+	}
+
+	query := `
+		SELECT ` + grouping.column + `, SUM(amount) AS total, COUNT(*) AS charges
+		FROM (` + billedChargesQuery + `) AS charges
+		` + grouping.join + `
+		GROUP BY ` + grouping.column + `
+		ORDER BY ` + grouping.column + ` NULLS LAST
+	`
+
+	groups := []model.CostGroup{}
+	err := sqlx.SelectContext(ctx, exec, &groups, query, costArgs(filter)...)
+	if err != nil {
+		return nil, databaseError("ошибка группировки стоимости подписок", err)
+	}
+
+	return groups, nil
+}
+
+// GetSubscriptionCostBreakdown возвращает суммы списаний, сгруппированные по календарному
+// месяцу и разрезу filter.GroupBy, с теми же фильтрами, что и GetTotalSubscriptionCost
+func (repo *SubscriptionRepository) GetSubscriptionCostBreakdown(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.MonthlyCost, error) {
+	grouping, ok := costGroupings[filter.GroupBy]
+	if !ok {
+		return nil, databaseError("ошибка получения помесячной стоимости подписок", fmt.Errorf("неизвестный разрез группировки %q", filter.GroupBy))
+	}
+
+	query := `
+		SELECT date_trunc('month', charged_on)::date AS month, ` + grouping.column + `, SUM(amount) AS amount
+		FROM (` + billedChargesQuery + `) AS charges
+		` + grouping.join + `
+		GROUP BY month, ` + grouping.column + `
+		ORDER BY month, ` + grouping.column + ` NULLS LAST
+	`
+
+	breakdown := []model.MonthlyCost{}
+	err := sqlx.SelectContext(ctx, exec, &breakdown, query, costArgs(filter)...)
+	if err != nil {
+		return nil, databaseError("ошибка получения помесячной стоимости подписок", err)
+	}
+
+	return breakdown, nil
+}
+
+// GetMonthlySpend считает траты по календарным месяцам с теми же правилами и фильтрами,
+// что и GetTotalSubscriptionCost. SubscriptionSpent - доля подписки subscriptionID в тратах
+// месяца; месяцы без оплат в результат не попадают
+func (repo *SubscriptionRepository) GetMonthlySpend(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter, subscriptionID int) ([]model.MonthlySpend, error) {
+	query := `
+		SELECT date_trunc('month', charged_on)::date AS month,
+			SUM(amount) AS spent,
+			COALESCE(SUM(amount) FILTER (WHERE id = $8::int), 0) AS subscription_spent
+		FROM (` + billedChargesQuery + `) AS charges
+		GROUP BY month
+		ORDER BY month
+	`
+
+	spends := []model.MonthlySpend{}
+	err := sqlx.SelectContext(ctx, exec, &spends, query, append(costArgs(filter), subscriptionID)...)
+	if err != nil {
+		return nil, databaseError("ошибка подсчета помесячных трат", err)
+	}
+	return spends, nil
+}
+
+// UpdateSubscriptionByID перезаписывает подписку и увеличивает ее версию. Если expectedVersion
+// задана, обновление выполняется только при совпадении с текущей версией строки
+func (repo *SubscriptionRepository) UpdateSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails, id int, expectedVersion *int) error {
 	query := `UPDATE subscriptions
 			SET service_name = $1,
 			    price = $2,
 			    user_id = $3,
 			    start_date = $4,
-			    end_date = $5
-			WHERE id = $6
-			`
-	res, err := exec.ExecContext(ctx, query,
+			    end_date = $5,
+			    currency = $6,
+			    billing_period = $7,
+			    billing_interval = $8,
+			    trial_end = $9,
+			    service_id = $10,
+			    category = $11,
+			    version = version + 1
+			WHERE id = $12 AND deleted_at IS NULL AND ($13::int IS NULL OR version = $13)
+			RETURNING ` + subscriptionColumns
+
+	// теги меняются до UPDATE, чтобы RETURNING вернул уже новые
+	if err := repo.replaceSubscriptionTags(ctx, exec, id, subscription.Tags); err != nil {
+		return err
+	}
+
+	err := sqlx.GetContext(ctx, exec, subscription, query,
 		subscription.ServiceName,
 		subscription.Price,
 		subscription.UserID,
 		subscription.StartDate.ToTime(),
 		subscription.EndDate.ToTime(),
+		subscription.Currency,
+		subscription.BillingPeriod,
+		subscription.BillingInterval,
+		dateArg(subscription.TrialEnd),
+		subscription.ServiceID,
+		subscription.Category,
 		id,
+		expectedVersion,
 	)
 	if err != nil {
-		return util.LogError("ошибка при обновлении подписки", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return repo.versionConflict(ctx, exec, id)
+		}
+		return databaseError("ошибка при обновлении подписки", err)
+	}
+
+	subscription.ID = id
+	return nil
+}
+
+// PatchSubscriptionByID обновляет только переданные в патче колонки и возвращает подписку
+// после изменения. Пустой патч ничего не меняет, но версия все равно проверяется
+func (repo *SubscriptionRepository) PatchSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, patch model.SubscriptionPatch, expectedVersion *int) (*model.SubscriptionDetails, error) {
+	if patch.IsEmpty() {
+		subscription, err := repo.GetSubscriptionByID(ctx, exec, id)
+		if err != nil {
+			return nil, err
+		}
+		if expectedVersion != nil && *expectedVersion != subscription.Version {
+			return nil, databaseError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+		}
+		return subscription, nil
 	}
 
-	rowsAffected, err := res.RowsAffected()
+	var args queryArgs
+	var assignments []string
+	if patch.ServiceID != nil {
+		assignments = append(assignments, "service_id = "+args.add(*patch.ServiceID))
+	}
+	if patch.ServiceName != nil {
+		assignments = append(assignments, "service_name = "+args.add(*patch.ServiceName))
+	}
+	if patch.Category != nil {
+		assignments = append(assignments, "category = "+args.add(*patch.Category))
+	}
+	if patch.ClearCategory {
+		assignments = append(assignments, "category = NULL")
+	}
+	if patch.Price != nil {
+		assignments = append(assignments, "price = "+args.add(*patch.Price))
+	}
+	if patch.Currency != nil {
+		assignments = append(assignments, "currency = "+args.add(*patch.Currency))
+	}
+	if patch.BillingPeriod != nil {
+		assignments = append(assignments, "billing_period = "+args.add(*patch.BillingPeriod))
+	}
+	if patch.BillingInterval != nil {
+		assignments = append(assignments, "billing_interval = "+args.add(*patch.BillingInterval))
+	}
+	if patch.UserID != nil {
+		assignments = append(assignments, "user_id = "+args.add(*patch.UserID))
+	}
+	if patch.StartDate != nil {
+		assignments = append(assignments, "start_date = "+args.add(patch.StartDate.ToTime()))
+	}
+	if patch.EndDate != nil {
+		assignments = append(assignments, "end_date = "+args.add(patch.EndDate.ToTime()))
+	}
+	if patch.ClearEndDate {
+		assignments = append(assignments, "end_date = NULL")
+	}
+	if patch.TrialEnd != nil {
+		assignments = append(assignments, "trial_end = "+args.add(patch.TrialEnd.ToTime()))
+	}
+	if patch.ClearTrialEnd {
+		assignments = append(assignments, "trial_end = NULL")
+	}
+	assignments = append(assignments, "version = version + 1")
+
+	versionArg := args.add(expectedVersion)
+	query := `UPDATE subscriptions
+			SET ` + strings.Join(assignments, ", ") + `
+			WHERE id = ` + args.add(id) + ` AND deleted_at IS NULL AND (` + versionArg + `::int IS NULL OR version = ` + versionArg + `)
+			RETURNING ` + subscriptionColumns
+
+	if patch.Tags != nil {
+		if err := repo.replaceSubscriptionTags(ctx, exec, id, *patch.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	var subscription model.SubscriptionDetails
+	err := sqlx.GetContext(ctx, exec, &subscription, query, args...)
 	if err != nil {
-		return util.LogError("не удалось получить количество затронутых строк", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repo.versionConflict(ctx, exec, id)
+		}
+		return nil, databaseError("ошибка при частичном обновлении подписки", err)
+	}
+
+	return &subscription, nil
+}
+
+// DeleteSubscriptionByID мягко удаляет подписку, проставляя deleted_at; при заданной
+// expectedVersion только если версия строки не изменилась. Удаленная подписка не видна
+// остальным запросам, пока ее не восстановят или не удалит окончательно очистка
+func (repo *SubscriptionRepository) DeleteSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, expectedVersion *int) error {
+	query := `UPDATE subscriptions
+			SET deleted_at = now(),
+			    version = version + 1
+			WHERE id = $1 AND deleted_at IS NULL AND ($2::int IS NULL OR version = $2)`
+
+	result, err := exec.ExecContext(ctx, query, id, expectedVersion)
+	if err != nil {
+		return databaseError("ошибка при удалении подписки по ID", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество удалённых строк", err)
 	}
 
 	if rowsAffected == 0 {
-		return util.LogError("подписка с таким ID не найдена", sql.ErrNoRows)
+		return repo.versionConflict(ctx, exec, id)
 	}
 
 	return nil
 }
 
-func (repo *SubscriptionRepository) DeleteSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) error {
-	query := `DELETE FROM subscriptions WHERE id = $1`
+// RestoreSubscriptionByID возвращает мягко удаленную подписку и увеличивает ее версию
+func (repo *SubscriptionRepository) RestoreSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error) {
+	query := `UPDATE subscriptions
+			SET deleted_at = NULL,
+			    version = version + 1
+			WHERE id = $1 AND deleted_at IS NOT NULL
+			RETURNING ` + subscriptionColumns
 
-	result, err := exec.ExecContext(ctx, query, id)
+	var subscription model.SubscriptionDetails
+	err := sqlx.GetContext(ctx, exec, &subscription, query, id)
 	if err != nil {
-		return util.LogError("ошибка при удалении подписки по ID", err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, repo.restoreConflict(ctx, exec, id)
+		}
+		return nil, databaseError("ошибка при восстановлении подписки", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return &subscription, nil
+}
+
+// PurgeDeletedSubscriptions окончательно удаляет подписки, мягко удаленные раньше deletedBefore
+func (repo *SubscriptionRepository) PurgeDeletedSubscriptions(ctx context.Context, exec sqlx.ExtContext, deletedBefore time.Time) (int64, error) {
+	query := `DELETE FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+
+	result, err := exec.ExecContext(ctx, query, deletedBefore)
 	if err != nil {
-		return util.LogError("не удалось получить количество удалённых строк", err)
+		return 0, databaseError("ошибка очистки удаленных подписок", err)
 	}
 
-	if rowsAffected == 0 {
-		return util.LogError("подписка с таким ID не найдена", sql.ErrNoRows)
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, databaseError("не удалось получить количество удалённых строк", err)
+	}
+	return purged, nil
+}
+
+// replaceSubscriptionTags заменяет теги подписки на tags, добавляя новые имена в справочник тегов
+func (repo *SubscriptionRepository) replaceSubscriptionTags(ctx context.Context, exec sqlx.ExtContext, id int, tags []string) error {
+	names := pq.StringArray(tags)
+
+	if _, err := exec.ExecContext(ctx, `INSERT INTO tags (name) SELECT unnest($1::text[]) ON CONFLICT (name) DO NOTHING`, names); err != nil {
+		return databaseError("ошибка при сохранении тегов", err)
+	}
+	if _, err := exec.ExecContext(ctx, `DELETE FROM subscription_tags WHERE subscription_id = $1`, id); err != nil {
+		return databaseError("ошибка при удалении тегов подписки", err)
 	}
 
+	query := `INSERT INTO subscription_tags (subscription_id, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2::text[])`
+	if _, err := exec.ExecContext(ctx, query, id, names); err != nil {
+		return databaseError("ошибка при сохранении тегов подписки", err)
+	}
 	return nil
 }
+
+// versionConflict объясняет, почему условное изменение не затронуло строк:
+// подписки нет совсем или ее версия уже другая
+func (repo *SubscriptionRepository) versionConflict(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	var exists bool
+	err := sqlx.GetContext(ctx, exec, &exists, `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = $1 AND deleted_at IS NULL)`, id)
+	if err != nil {
+		return databaseError("ошибка проверки существования подписки", err)
+	}
+
+	if !exists {
+		return databaseError("подписка с таким ID не найдена", sql.ErrNoRows)
+	}
+	return databaseError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+}
+
+// restoreConflict объясняет, почему не нашлось удаленной подписки: ее нет совсем
+// или она не удалена
+func (repo *SubscriptionRepository) restoreConflict(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	var active bool
+	err := sqlx.GetContext(ctx, exec, &active, `SELECT EXISTS(SELECT 1 FROM subscriptions WHERE id = $1)`, id)
+	if err != nil {
+		return databaseError("ошибка проверки существования подписки", err)
+	}
+
+	if active {
+		return databaseError("подписка не удалена", model.ErrConflict)
+	}
+	return databaseError("удаленная подписка с таким ID не найдена", sql.ErrNoRows)
+}
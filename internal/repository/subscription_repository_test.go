@@ -0,0 +1,503 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestGetTotalSubscriptionCost(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		start, end   time.Time
+		from, to     time.Time
+		total        int
+		billedMonths int
+	}{
+		{
+			name:  "бессрочная подписка внутри периода",
+			start: date(2025, time.January, 10), from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+			total: 300, billedMonths: 3,
+		},
+		{
+			name:  "первая оплата в день начала",
+			start: date(2025, time.January, 10), from: date(2025, time.January, 1), to: date(2025, time.January, 31),
+			total: 100, billedMonths: 1,
+		},
+		{
+			name:  "31 число в коротких месяцах",
+			start: date(2025, time.January, 31), from: date(2025, time.February, 1), to: date(2025, time.May, 31),
+			total: 400, billedMonths: 4,
+		},
+		{
+			name:  "оплаты до даты окончания включительно",
+			start: date(2024, time.December, 15), end: date(2025, time.March, 15),
+			from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+			total: 200, billedMonths: 2,
+		},
+		{
+			name:  "подписка закончилась до периода",
+			start: date(2024, time.June, 1), end: date(2025, time.January, 15),
+			from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+		},
+		{
+			name:  "подписка начинается после периода",
+			start: date(2025, time.May, 1), from: date(2025, time.February, 1), to: date(2025, time.April, 30),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userID := newTestUserID(t)
+			subscription := &model.SubscriptionDetails{
+				ServiceName:     "Тест Видео",
+				Price:           100,
+				Currency:        "RUB",
+				BillingPeriod:   model.BillingPeriodMonth,
+				BillingInterval: 1,
+				UserID:          userID,
+				StartDate:       model.DayMonthYear(test.start),
+				EndDate:         model.DayMonthYear(test.end),
+			}
+			subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+			if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+				t.Fatalf("не удалось сохранить подписку: %v", err)
+			}
+
+			filter := model.CostFilter{UserID: userID, Currency: "RUB", StartPeriod: test.from, EndPeriod: test.to}
+			cost, err := repo.GetTotalSubscriptionCost(ctx, database, filter)
+			if err != nil {
+				t.Fatalf("GetTotalSubscriptionCost: %v", err)
+			}
+			if cost.Total != test.total || cost.BilledMonths != test.billedMonths {
+				t.Errorf("стоимость %d за %d мес., ожидалось %d за %d мес.", cost.Total, cost.BilledMonths, test.total, test.billedMonths)
+			}
+		})
+	}
+}
+
+func TestGetSubscriptionCostBreakdown(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	userID := newTestUserID(t)
+	subscriptions := []model.SubscriptionDetails{
+		{ServiceName: "Видео", Price: 1000, StartDate: model.DayMonthYear(date(2024, time.December, 31))},
+		{ServiceName: "Музыка", Price: 300, StartDate: model.DayMonthYear(date(2025, time.January, 5)), EndDate: model.DayMonthYear(date(2025, time.January, 31))},
+		{ServiceName: "Книги", Price: 200, StartDate: model.DayMonthYear(date(2025, time.February, 10))},
+	}
+	for i := range subscriptions {
+		subscriptions[i].UserID = userID
+		subscriptions[i].Currency = "RUB"
+		subscriptions[i].BillingPeriod = model.BillingPeriodMonth
+		subscriptions[i].BillingInterval = 1
+		subscriptions[i].ServiceID = catalogServiceID(t, database, subscriptions[i].ServiceName)
+		if err := repo.SaveSubscription(ctx, database, &subscriptions[i]); err != nil {
+			t.Fatalf("не удалось сохранить подписку: %v", err)
+		}
+	}
+
+	january := model.MonthYear(date(2025, time.January, 1))
+	february := model.MonthYear(date(2025, time.February, 1))
+	books := "Книги"
+	tests := []struct {
+		name   string
+		filter model.CostFilter
+		want   []model.MonthlyCost
+	}{
+		{
+			name:   "все сервисы по месяцам",
+			filter: model.CostFilter{UserID: userID, Currency: "RUB", GroupBy: model.CostGroupByService, StartPeriod: date(2025, time.January, 1), EndPeriod: date(2025, time.February, 28)},
+			want: []model.MonthlyCost{
+				{Month: january, ServiceName: "Видео", Amount: 1000},
+				{Month: january, ServiceName: "Музыка", Amount: 300},
+				{Month: february, ServiceName: "Видео", Amount: 1000},
+				{Month: february, ServiceName: "Книги", Amount: 200},
+			},
+		},
+		{
+			name:   "один сервис",
+			filter: model.CostFilter{UserID: userID, Currency: "RUB", GroupBy: model.CostGroupByService, ServiceName: &books, StartPeriod: date(2025, time.January, 1), EndPeriod: date(2025, time.February, 28)},
+			want:   []model.MonthlyCost{{Month: february, ServiceName: "Книги", Amount: 200}},
+		},
+		{
+			name:   "период без оплат",
+			filter: model.CostFilter{UserID: userID, Currency: "RUB", GroupBy: model.CostGroupByService, StartPeriod: date(2024, time.January, 1), EndPeriod: date(2024, time.December, 30)},
+			want:   []model.MonthlyCost{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breakdown, err := repo.GetSubscriptionCostBreakdown(ctx, database, test.filter)
+			if err != nil {
+				t.Fatalf("GetSubscriptionCostBreakdown: %v", err)
+			}
+			if len(breakdown) != len(test.want) {
+				t.Fatalf("строк %d, ожидалось %d: %+v", len(breakdown), len(test.want), breakdown)
+			}
+			for i := range breakdown {
+				got, want := breakdown[i], test.want[i]
+				if !got.Month.ToTime().Equal(want.Month.ToTime()) || got.ServiceName != want.ServiceName || got.Amount != want.Amount {
+					t.Errorf("строка %d: %+v, ожидалась %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListSubscriptions(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	// цены повторяются, чтобы порядок внутри одной цены решал id
+	userID := newTestUserID(t)
+	prices := []int{300, 100, 300, 200, 100, 300, 400}
+	for _, price := range prices {
+		subscription := &model.SubscriptionDetails{
+			ServiceName: "Тест Видео", Price: price, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: userID, StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+		}
+		subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+		if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+			t.Fatalf("не удалось сохранить подписку: %v", err)
+		}
+	}
+
+	priceMin := 200
+	tests := []struct {
+		name   string
+		filter model.SubscriptionListFilter
+		want   int
+		less   func(a, b model.SubscriptionDetails) bool
+	}{
+		{
+			name:   "по id",
+			filter: model.SubscriptionListFilter{UserID: &userID, Sort: "id", Limit: 3},
+			want:   len(prices),
+			less:   func(a, b model.SubscriptionDetails) bool { return a.ID < b.ID },
+		},
+		{
+			name:   "по убыванию цены",
+			filter: model.SubscriptionListFilter{UserID: &userID, Sort: "price", Desc: true, Limit: 2},
+			want:   len(prices),
+			less: func(a, b model.SubscriptionDetails) bool {
+				return a.Price > b.Price || (a.Price == b.Price && a.ID > b.ID)
+			},
+		},
+		{
+			name:   "с фильтром по цене",
+			filter: model.SubscriptionListFilter{UserID: &userID, PriceMin: &priceMin, Sort: "price", Limit: 2},
+			want:   5,
+			less: func(a, b model.SubscriptionDetails) bool {
+				return a.Price < b.Price || (a.Price == b.Price && a.ID < b.ID)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var listed []model.SubscriptionDetails
+			filter := test.filter
+			for pages := 0; ; pages++ {
+				if pages > len(prices) {
+					t.Fatal("постраничный обход не заканчивается")
+				}
+				page, err := repo.ListSubscriptions(ctx, database, filter)
+				if err != nil {
+					t.Fatalf("ListSubscriptions: %v", err)
+				}
+				if len(page.Items) > filter.Limit {
+					t.Fatalf("на странице %d строк при limit=%d", len(page.Items), filter.Limit)
+				}
+				listed = append(listed, page.Items...)
+				if page.NextCursor == "" {
+					break
+				}
+				if filter.After, err = model.DecodeListCursor(page.NextCursor); err != nil {
+					t.Fatalf("неверный next_cursor: %v", err)
+				}
+			}
+
+			if len(listed) != test.want {
+				t.Fatalf("выдано %d подписок, ожидалось %d", len(listed), test.want)
+			}
+			for i := 1; i < len(listed); i++ {
+				if !test.less(listed[i-1], listed[i]) {
+					t.Errorf("нарушен порядок между id=%d и id=%d", listed[i-1].ID, listed[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestPatchSubscriptionByID(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	price := 500
+	serviceName := "Музыка"
+	tests := []struct {
+		name  string
+		patch model.SubscriptionPatch
+		check func(t *testing.T, before, after *model.SubscriptionDetails)
+	}{
+		{
+			name: "пустой патч возвращает подписку без изменений",
+			check: func(t *testing.T, before, after *model.SubscriptionDetails) {
+				if after.Price != before.Price || after.ServiceName != before.ServiceName {
+					t.Errorf("подписка изменилась: %+v", after)
+				}
+			},
+		},
+		{
+			name:  "меняются только переданные поля",
+			patch: model.SubscriptionPatch{Price: &price, ServiceName: &serviceName},
+			check: func(t *testing.T, before, after *model.SubscriptionDetails) {
+				if after.Price != price || after.ServiceName != serviceName || after.UserID != before.UserID ||
+					!after.StartDate.ToTime().Equal(before.StartDate.ToTime()) || !after.EndDate.ToTime().Equal(before.EndDate.ToTime()) {
+					t.Errorf("подписка после патча %+v", after)
+				}
+			},
+		},
+		{
+			name:  "null очищает дату окончания",
+			patch: model.SubscriptionPatch{ClearEndDate: true},
+			check: func(t *testing.T, before, after *model.SubscriptionDetails) {
+				if !after.EndDate.ToTime().IsZero() || after.Price != before.Price {
+					t.Errorf("подписка после патча %+v", after)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := &model.SubscriptionDetails{
+				ServiceName: "Видео", Price: 400, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: newTestUserID(t),
+				StartDate: model.DayMonthYear(date(2025, time.January, 1)), EndDate: model.DayMonthYear(date(2025, time.December, 31)),
+			}
+			before.ServiceID = catalogServiceID(t, database, before.ServiceName)
+			if err := repo.SaveSubscription(ctx, database, before); err != nil {
+				t.Fatalf("не удалось сохранить подписку: %v", err)
+			}
+
+			after, err := repo.PatchSubscriptionByID(ctx, database, before.ID, test.patch, nil)
+			if err != nil {
+				t.Fatalf("PatchSubscriptionByID: %v", err)
+			}
+			test.check(t, before, after)
+		})
+	}
+
+	if _, err := repo.PatchSubscriptionByID(ctx, database, -1, model.SubscriptionPatch{Price: &price}, nil); err == nil {
+		t.Error("патч несуществующей подписки должен вернуть ошибку")
+	}
+}
+
+func TestSubscriptionVersionCheck(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 400, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: newTestUserID(t),
+		StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+	stale := subscription.Version
+
+	price := 500
+	patched, err := repo.PatchSubscriptionByID(ctx, database, subscription.ID, model.SubscriptionPatch{Price: &price}, &stale)
+	if err != nil {
+		t.Fatalf("патч с актуальной версией: %v", err)
+	}
+	if patched.Version != stale+1 {
+		t.Errorf("версия после патча %d, ожидалась %d", patched.Version, stale+1)
+	}
+
+	if _, err := repo.PatchSubscriptionByID(ctx, database, subscription.ID, model.SubscriptionPatch{Price: &price}, &stale); !errors.Is(err, model.ErrVersionMismatch) {
+		t.Errorf("патч со старой версией: ожидалась ErrVersionMismatch, получено %v", err)
+	}
+	if err := repo.UpdateSubscriptionByID(ctx, database, subscription, subscription.ID, &stale); !errors.Is(err, model.ErrVersionMismatch) {
+		t.Errorf("обновление со старой версией: ожидалась ErrVersionMismatch, получено %v", err)
+	}
+	if err := repo.DeleteSubscriptionByID(ctx, database, subscription.ID, &stale); !errors.Is(err, model.ErrVersionMismatch) {
+		t.Errorf("удаление со старой версией: ожидалась ErrVersionMismatch, получено %v", err)
+	}
+	if err := repo.DeleteSubscriptionByID(ctx, database, -1, nil); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("удаление несуществующей подписки: ожидалась sql.ErrNoRows, получено %v", err)
+	}
+	if err := repo.DeleteSubscriptionByID(ctx, database, subscription.ID, &patched.Version); err != nil {
+		t.Errorf("удаление с актуальной версией: %v", err)
+	}
+}
+
+func TestSoftDeleteSubscription(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	userID := newTestUserID(t)
+	subscription := &model.SubscriptionDetails{
+		ServiceName: "Видео", Price: 400, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: userID,
+		StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+	}
+	subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+	if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+
+	if _, err := repo.RestoreSubscriptionByID(ctx, database, subscription.ID); !errors.Is(err, model.ErrConflict) {
+		t.Errorf("восстановление активной подписки: ожидалась ErrConflict, получено %v", err)
+	}
+	if err := repo.DeleteSubscriptionByID(ctx, database, subscription.ID, nil); err != nil {
+		t.Fatalf("DeleteSubscriptionByID: %v", err)
+	}
+	if _, err := repo.GetSubscriptionByID(ctx, database, subscription.ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("удаленная подписка видна: %v", err)
+	}
+	if err := repo.DeleteSubscriptionByID(ctx, database, subscription.ID, nil); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("повторное удаление: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	trash, err := repo.ListSubscriptions(ctx, database, model.SubscriptionListFilter{UserID: &userID, Deleted: true, Sort: "id", Limit: 10})
+	if err != nil {
+		t.Fatalf("ListSubscriptions: %v", err)
+	}
+	if len(trash.Items) != 1 || trash.Items[0].DeletedAt == nil {
+		t.Fatalf("корзина пользователя %+v", trash.Items)
+	}
+
+	restored, err := repo.RestoreSubscriptionByID(ctx, database, subscription.ID)
+	if err != nil {
+		t.Fatalf("RestoreSubscriptionByID: %v", err)
+	}
+	if restored.DeletedAt != nil || restored.Version != subscription.Version+2 {
+		t.Errorf("восстановленная подписка %+v", restored)
+	}
+	if _, err := repo.RestoreSubscriptionByID(ctx, database, -1); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("восстановление несуществующей подписки: ожидалась ErrNotFound, получено %v", err)
+	}
+}
+
+func TestPurgeDeletedSubscriptions(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	userID := newTestUserID(t)
+	var ids []int
+	for range 2 {
+		subscription := &model.SubscriptionDetails{
+			ServiceName: "Видео", Price: 400, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: userID,
+			StartDate: model.DayMonthYear(date(2025, time.January, 1)),
+		}
+		subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+		if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+			t.Fatalf("не удалось сохранить подписку: %v", err)
+		}
+		ids = append(ids, subscription.ID)
+	}
+	if err := repo.DeleteSubscriptionByID(ctx, database, ids[0], nil); err != nil {
+		t.Fatalf("DeleteSubscriptionByID: %v", err)
+	}
+
+	if _, err := repo.PurgeDeletedSubscriptions(ctx, database, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("PurgeDeletedSubscriptions: %v", err)
+	}
+	if _, err := repo.RestoreSubscriptionByID(ctx, database, ids[0]); err != nil {
+		t.Fatalf("подписка очищена раньше срока хранения: %v", err)
+	}
+	if err := repo.DeleteSubscriptionByID(ctx, database, ids[0], nil); err != nil {
+		t.Fatalf("DeleteSubscriptionByID: %v", err)
+	}
+
+	purged, err := repo.PurgeDeletedSubscriptions(ctx, database, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeletedSubscriptions: %v", err)
+	}
+	if purged < 1 {
+		t.Errorf("очищено %d подписок, ожидалась хотя бы одна", purged)
+	}
+	if _, err := repo.RestoreSubscriptionByID(ctx, database, ids[0]); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("очищенная подписка осталась в корзине: %v", err)
+	}
+	if _, err := repo.GetSubscriptionByID(ctx, database, ids[1]); err != nil {
+		t.Errorf("активная подписка затронута очисткой: %v", err)
+	}
+}
+
+func TestChargeDatesByBillingPeriod(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		period   string
+		interval int
+		start    time.Time
+		from, to time.Time
+		charges  int
+		months   int
+	}{
+		{name: "еженедельно", period: model.BillingPeriodWeek, interval: 1, start: date(2025, time.January, 1),
+			from: date(2025, time.January, 1), to: date(2025, time.January, 31), charges: 5, months: 1},
+		{name: "раз в две недели", period: model.BillingPeriodWeek, interval: 2, start: date(2025, time.January, 1),
+			from: date(2025, time.January, 1), to: date(2025, time.January, 31), charges: 3, months: 1},
+		{name: "раз в десять дней", period: model.BillingPeriodDay, interval: 10, start: date(2025, time.January, 1),
+			from: date(2025, time.January, 1), to: date(2025, time.January, 31), charges: 4, months: 1},
+		{name: "ежемесячно с 31-го", period: model.BillingPeriodMonth, interval: 1, start: date(2025, time.January, 31),
+			from: date(2025, time.January, 1), to: date(2025, time.April, 30), charges: 4, months: 4},
+		{name: "с 31-го, период в середине", period: model.BillingPeriodMonth, interval: 1, start: date(2025, time.January, 31),
+			from: date(2025, time.March, 1), to: date(2025, time.March, 31), charges: 1, months: 1},
+		{name: "с 31-го, период до оплаты", period: model.BillingPeriodMonth, interval: 1, start: date(2025, time.January, 31),
+			from: date(2025, time.March, 1), to: date(2025, time.March, 30)},
+		{name: "ежеквартально", period: model.BillingPeriodMonth, interval: 3, start: date(2025, time.January, 15),
+			from: date(2025, time.January, 1), to: date(2025, time.December, 31), charges: 4, months: 4},
+		{name: "ежегодно с 29 февраля", period: model.BillingPeriodYear, interval: 1, start: date(2024, time.February, 29),
+			from: date(2024, time.January, 1), to: date(2026, time.December, 31), charges: 3, months: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userID := newTestUserID(t)
+			subscription := &model.SubscriptionDetails{
+				ServiceName: "Видео", Price: 100, Currency: "RUB", UserID: userID,
+				BillingPeriod: test.period, BillingInterval: test.interval, StartDate: model.DayMonthYear(test.start),
+			}
+			subscription.ServiceID = catalogServiceID(t, database, subscription.ServiceName)
+			if err := repo.SaveSubscription(ctx, database, subscription); err != nil {
+				t.Fatalf("не удалось сохранить подписку: %v", err)
+			}
+
+			filter := model.CostFilter{UserID: userID, Currency: "RUB", StartPeriod: test.from, EndPeriod: test.to}
+			cost, err := repo.GetTotalSubscriptionCost(ctx, database, filter)
+			if err != nil {
+				t.Fatalf("GetTotalSubscriptionCost: %v", err)
+			}
+			if cost.Charges != test.charges || cost.Total != 100*test.charges || cost.BilledMonths != test.months {
+				t.Errorf("%d оплат на %d в %d мес., ожидалось %d оплат в %d мес.",
+					cost.Charges, cost.Total, cost.BilledMonths, test.charges, test.months)
+			}
+		})
+	}
+}
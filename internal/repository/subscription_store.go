@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+// SubscriptionStore
+// Хранилище подписок, их цен и пауз. Каждый метод получает исполнителя exec: вне
+// транзакции - Executor() хранилища, внутри - значение, переданное в fn методом
+// InTransaction, или обертку над ним. Реализации обязаны вести себя одинаково,
+// включая правила расчета стоимости; это проверяет набор storetest
+type SubscriptionStore interface {
+	// Executor возвращает исполнителя для запросов вне транзакции
+	Executor() sqlx.ExtContext
+	// InTransaction выполняет fn атомарно: изменения фиксируются, только если fn
+	// вернула nil. Подписки, прочитанные через Lock-методы, не меняются другими
+	// запросами до конца транзакции
+	InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error
+
+	SaveSubscription(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) error
+	GetSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error)
+	LockSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error)
+	LockDeletedSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error)
+	GetSubscriptionsByUserUUID(ctx context.Context, exec sqlx.ExtContext, uuid string) ([]model.SubscriptionDetails, error)
+	ListSubscriptions(ctx context.Context, exec sqlx.ExtContext, filter model.SubscriptionListFilter) (*model.SubscriptionPage, error)
+	UpdateSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails, id int, expectedVersion *int) error
+	PatchSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, patch model.SubscriptionPatch, expectedVersion *int) (*model.SubscriptionDetails, error)
+	DeleteSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int, expectedVersion *int) error
+	RestoreSubscriptionByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error)
+	PurgeDeletedSubscriptions(ctx context.Context, exec sqlx.ExtContext, deletedBefore time.Time) (int64, error)
+
+	GetTotalSubscriptionCost(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) (*model.SubscriptionCost, error)
+	GetSubscriptionCostGroups(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.CostGroup, error)
+	GetSubscriptionCostBreakdown(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter) ([]model.MonthlyCost, error)
+	GetMonthlySpend(ctx context.Context, exec sqlx.ExtContext, filter model.CostFilter, subscriptionID int) ([]model.MonthlySpend, error)
+	GetUpcomingRenewals(ctx context.Context, exec sqlx.ExtContext, uuid string, from, to time.Time) ([]model.UpcomingRenewal, error)
+
+	SaveSubscriptionPrice(ctx context.Context, exec sqlx.ExtContext, price *model.SubscriptionPrice) error
+	GetSubscriptionPrices(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPrice, error)
+
+	PauseSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error
+	ResumeSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error
+	CancelSubscription(ctx context.Context, exec sqlx.ExtContext, id int) error
+	IncrementSubscriptionVersion(ctx context.Context, exec sqlx.ExtContext, id int) (*model.SubscriptionDetails, error)
+	GetSubscriptionPauses(ctx context.Context, exec sqlx.ExtContext, subscriptionID int) ([]model.SubscriptionPause, error)
+}
+
+// ServiceCatalog
+// Часть каталога сервисов, через которую подписки связываются с сервисами. Выделена
+// из CatalogRepository, чтобы хранилища подписок без Postgres вели собственный каталог
+type ServiceCatalog interface {
+	SaveCatalogService(ctx context.Context, exec sqlx.ExtContext, service *model.CatalogService) error
+	GetCatalogServiceByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.CatalogService, error)
+	FindCatalogServiceByName(ctx context.Context, exec sqlx.ExtContext, name string) (*model.CatalogService, error)
+	LockCatalogServiceName(ctx context.Context, exec sqlx.ExtContext, name string) error
+}
+
+// ExchangeRateStore
+// Справочник валют и курсов, по которым хранилище подписок переводит стоимость.
+// Хранилище без Postgres ведет собственный справочник и реализует этот интерфейс само
+type ExchangeRateStore interface {
+	Executor() sqlx.ExtContext
+	InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error
+
+	SaveExchangeRates(ctx context.Context, exec sqlx.ExtContext, rates []model.ExchangeRate) error
+	GetExchangeRates(ctx context.Context, exec sqlx.ExtContext, filter model.ExchangeRateFilter) ([]model.ExchangeRate, error)
+	GetCurrencies(ctx context.Context, exec sqlx.ExtContext) ([]model.Currency, error)
+}
+
+// IdempotencyStore
+// Ключи идемпотентности создания подписок. Методы вызываются в транзакции хранилища
+// подписок, которое реализует этот интерфейс или делит с ним базу
+type IdempotencyStore interface {
+	// ReserveKey занимает ключ в области scope; false означает, что ключ уже использован
+	ReserveKey(ctx context.Context, exec sqlx.ExtContext, scope, key, requestHash string, expiresAt time.Time) (bool, error)
+	GetRecord(ctx context.Context, exec sqlx.ExtContext, scope, key string) (*model.IdempotencyRecord, error)
+	SaveResponse(ctx context.Context, exec sqlx.ExtContext, scope, key string, statusCode int, headers, body []byte) error
+	// DeleteExpiredKeys удаляет ключи, срок которых истек к expiredBefore, и возвращает их число
+	DeleteExpiredKeys(ctx context.Context, exec sqlx.ExtContext, expiredBefore time.Time) (int64, error)
+}
+
+// BudgetStore
+// Бюджеты, которые сервис подписок проверяет после записи подписки. Методы вызываются
+// в транзакции хранилища подписок
+type BudgetStore interface {
+	GetBudgetsForSubscription(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) ([]model.Budget, error)
+	// InSavepoint выполняет fn так, что ее ошибка откатывает только сделанное в fn,
+	// а транзакция exec продолжается
+	InSavepoint(ctx context.Context, exec sqlx.ExtContext, fn func() error) error
+}
+
+// OutboxStore
+// Запись событий в outbox. Событие записывается в транзакции изменения, которое его
+// породило, поэтому методы получают исполнителя этой транзакции
+type OutboxStore interface {
+	NextEventID(ctx context.Context, exec sqlx.ExtContext) (int64, error)
+	SaveOutboxEvent(ctx context.Context, exec sqlx.ExtContext, event *model.OutboxEvent) error
+}
+
+// OutboxRelayStore
+// Outbox со стороны релея. ClaimUnsentEvents забирает события в аренду, а публикация
+// идет вне транзакции, поэтому событие могут опубликовать два релея
+type OutboxRelayStore interface {
+	Executor() sqlx.ExtContext
+
+	ClaimUnsentEvents(ctx context.Context, exec sqlx.ExtContext, limit int, lease time.Duration) ([]model.OutboxEvent, error)
+	MarkEventSent(ctx context.Context, exec sqlx.ExtContext, id int64) error
+	MarkEventFailed(ctx context.Context, exec sqlx.ExtContext, id int64, reason, status string, nextAttemptAt time.Time) error
+	DeleteSentEvents(ctx context.Context, exec sqlx.ExtContext, before time.Time) (int64, error)
+}
+
+// ReminderStore
+// Очередь напоминаний о предстоящих оплатах. ClaimDueReminders забирает напоминания
+// в аренду, а доставка идет вне транзакции, поэтому одно напоминание могут забрать
+// два процесса; MarkReminderSent отмечает его только однажды
+type ReminderStore interface {
+	Executor() sqlx.ExtContext
+	InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error
+
+	EnqueueReminders(ctx context.Context, exec sqlx.ExtContext, from, to time.Time) (int64, error)
+	ClaimDueReminders(ctx context.Context, exec sqlx.ExtContext, limit int, lease time.Duration) ([]model.SubscriptionReminder, error)
+	MarkReminderSent(ctx context.Context, exec sqlx.ExtContext, id int64) (bool, error)
+	MarkReminderFailed(ctx context.Context, exec sqlx.ExtContext, id int64, reason string, nextAttemptAt time.Time) error
+}
+
+// CalendarTokenStore
+// Токены ссылок на календари оплат. Хранится только хеш токена; у пользователя не
+// больше одного токена, и SaveCalendarToken заменяет прежний
+type CalendarTokenStore interface {
+	Executor() sqlx.ExtContext
+
+	SaveCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string, tokenHash []byte) error
+	GetCalendarTokenHash(ctx context.Context, exec sqlx.ExtContext, userID string) ([]byte, error)
+	DeleteCalendarToken(ctx context.Context, exec sqlx.ExtContext, userID string) error
+}
+
+var (
+	_ SubscriptionStore  = (*SubscriptionRepository)(nil)
+	_ ServiceCatalog     = (*CatalogRepository)(nil)
+	_ ExchangeRateStore  = (*ExchangeRateRepository)(nil)
+	_ IdempotencyStore   = (*IdempotencyRepository)(nil)
+	_ BudgetStore        = (*BudgetRepository)(nil)
+	_ OutboxStore        = (*OutboxRepository)(nil)
+	_ OutboxRelayStore   = (*OutboxRepository)(nil)
+	_ ReminderStore      = (*ReminderRepository)(nil)
+	_ CalendarTokenStore = (*CalendarTokenRepository)(nil)
+)
+
+func (repo *SubscriptionRepository) Executor() sqlx.ExtContext {
+	return repo.Database
+}
+
+func (repo *SubscriptionRepository) InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error {
+	return inTransaction(ctx, repo.Database, fn)
+}
+
+func (repo *ExchangeRateRepository) Executor() sqlx.ExtContext {
+	return repo.Database
+}
+
+func (repo *ExchangeRateRepository) InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error {
+	return inTransaction(ctx, repo.Database, fn)
+}
+
+func (repo *CatalogRepository) InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error {
+	return inTransaction(ctx, repo.Database, fn)
+}
+
+func (repo *ReminderRepository) Executor() sqlx.ExtContext {
+	return repo.Database
+}
+
+func (repo *ReminderRepository) InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error {
+	return inTransaction(ctx, repo.Database, fn)
+}
+
+func (repo *OutboxRepository) Executor() sqlx.ExtContext {
+	return repo.Database
+}
+
+// inTransaction выполняет fn в транзакции Postgres: коммитит при успехе и откатывает при ошибке
+func inTransaction(ctx context.Context, database *config.Database, fn func(exec sqlx.ExtContext) error) error {
+	tx, err := database.BeginTxx(ctx, nil)
+	if err != nil {
+		return databaseError("не удалось начать транзакцию", err)
+	}
+
+	if err := fn(tx); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			log.Printf("ошибка отката транзакции: %v", rollbackErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return databaseError("не удалось зафиксировать транзакцию", err)
+	}
+	return nil
+}
+
+// inSavepoint выполняет fn под точкой сохранения транзакции exec. Ошибка запроса в
+// транзакции Postgres делает недействительными все последующие запросы, поэтому при
+// ошибке fn транзакция откатывается к точке сохранения и возвращается ошибка fn
+func inSavepoint(ctx context.Context, exec sqlx.ExtContext, name string, fn func() error) error {
+	if _, err := exec.ExecContext(ctx, `SAVEPOINT `+name); err != nil {
+		return databaseError("не удалось создать точку сохранения", err)
+	}
+
+	if err := fn(); err != nil {
+		if _, rollbackErr := exec.ExecContext(ctx, `ROLLBACK TO SAVEPOINT `+name); rollbackErr != nil {
+			return databaseError("не удалось откатиться к точке сохранения", rollbackErr)
+		}
+		return err
+	}
+
+	if _, err := exec.ExecContext(ctx, `RELEASE SAVEPOINT `+name); err != nil {
+		return databaseError("не удалось освободить точку сохранения", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCostGroupsByCategoryAndTag(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewSubscriptionRepository(database)
+	ctx := context.Background()
+
+	text := func(value string) *string { return &value }
+	userID := newTestUserID(t)
+	subscriptions := []model.SubscriptionDetails{
+		{ServiceName: "Видео", Price: 1000, Category: text("кино"), Tags: model.Tags{"семья", "работа"}},
+		{ServiceName: "Музыка", Price: 300, Category: text("музыка"), Tags: model.Tags{"семья"}},
+		{ServiceName: "Книги", Price: 200},
+	}
+	for i := range subscriptions {
+		subscriptions[i].UserID = userID
+		subscriptions[i].Currency = "RUB"
+		subscriptions[i].BillingPeriod = model.BillingPeriodMonth
+		subscriptions[i].BillingInterval = 1
+		subscriptions[i].StartDate = model.DayMonthYear(date(2025, time.January, 1))
+		subscriptions[i].EndDate = model.DayMonthYear(date(2025, time.January, 31))
+		subscriptions[i].ServiceID = catalogServiceID(t, database, subscriptions[i].ServiceName)
+		if err := repo.SaveSubscription(ctx, database, &subscriptions[i]); err != nil {
+			t.Fatalf("не удалось сохранить подписку: %v", err)
+		}
+	}
+
+	saved, err := repo.GetSubscriptionByID(ctx, database, subscriptions[0].ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionByID: %v", err)
+	}
+	if !reflect.DeepEqual(saved.Tags, model.Tags{"работа", "семья"}) || saved.Category == nil || *saved.Category != "кино" {
+		t.Errorf("сохраненные категория %v и теги %v", saved.Category, saved.Tags)
+	}
+
+	filter := model.CostFilter{UserID: userID, Currency: "RUB", StartPeriod: date(2025, time.January, 1), EndPeriod: date(2025, time.January, 31)}
+
+	// оплата подписки с двумя тегами попадает в обе группы, подписка без тегов - в группу nil
+	filter.GroupBy = model.CostGroupByTag
+	groups, err := repo.GetSubscriptionCostGroups(ctx, database, filter)
+	if err != nil {
+		t.Fatalf("GetSubscriptionCostGroups: %v", err)
+	}
+	want := []model.CostGroup{
+		{Tag: text("работа"), Total: 1000, Charges: 1},
+		{Tag: text("семья"), Total: 1300, Charges: 2},
+		{Total: 200, Charges: 1},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("группы по тегам %+v, ожидались %+v", groups, want)
+	}
+
+	filter.GroupBy = model.CostGroupByCategory
+	groups, err = repo.GetSubscriptionCostGroups(ctx, database, filter)
+	if err != nil {
+		t.Fatalf("GetSubscriptionCostGroups: %v", err)
+	}
+	want = []model.CostGroup{
+		{Category: text("кино"), Total: 1000, Charges: 1},
+		{Category: text("музыка"), Total: 300, Charges: 1},
+		{Total: 200, Charges: 1},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("группы по категориям %+v, ожидались %+v", groups, want)
+	}
+
+	filter.GroupBy = ""
+	filter.Tag = text("семья")
+	cost, err := repo.GetTotalSubscriptionCost(ctx, database, filter)
+	if err != nil {
+		t.Fatalf("GetTotalSubscriptionCost: %v", err)
+	}
+	if cost.Total != 1300 || cost.Charges != 2 {
+		t.Errorf("стоимость по тегу %+v, ожидалось 1300 за 2 оплаты", cost)
+	}
+}
@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"strings"
+	"time"
+)
+
+const webhookColumns = `id, url, secret, event_types, active, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_status_code, last_error, created_at, delivered_at`
+
+// webhookRow читает массив типов событий, который model.Webhook не сканирует сам
+type webhookRow struct {
+	model.Webhook
+	EventTypes pq.StringArray `db:"event_types"`
+}
+
+func (row webhookRow) toModel() model.Webhook {
+	webhook := row.Webhook
+	webhook.EventTypes = []string(row.EventTypes)
+	return webhook
+}
+
+type WebhookRepository struct {
+	*config.Database
+}
+
+func NewWebhookRepository(database *config.Database) *WebhookRepository {
+	return &WebhookRepository{database}
+}
+
+func (repo *WebhookRepository) SaveWebhook(ctx context.Context, exec sqlx.ExtContext, webhook *model.Webhook) error {
+	query := `INSERT INTO webhooks (url, secret, event_types, active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + webhookColumns
+
+	var row webhookRow
+	err := sqlx.GetContext(ctx, exec, &row, query,
+		webhook.URL,
+		webhook.Secret,
+		pq.StringArray(webhook.EventTypes),
+		webhook.Active,
+	)
+	if err != nil {
+		return databaseError("ошибка при сохранении вебхука", err)
+	}
+
+	*webhook = row.toModel()
+	return nil
+}
+
+func (repo *WebhookRepository) GetWebhookByID(ctx context.Context, exec sqlx.ExtContext, id int) (*model.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+	var row webhookRow
+	err := sqlx.GetContext(ctx, exec, &row, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("вебхук с таким ID не найден", err)
+		}
+		return nil, databaseError("ошибка получения вебхука", err)
+	}
+
+	webhook := row.toModel()
+	return &webhook, nil
+}
+
+func (repo *WebhookRepository) ListWebhooks(ctx context.Context, exec sqlx.ExtContext) ([]model.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY id`
+
+	var rows []webhookRow
+	err := sqlx.SelectContext(ctx, exec, &rows, query)
+	if err != nil {
+		return nil, databaseError("ошибка получения вебхуков", err)
+	}
+
+	webhooks := make([]model.Webhook, 0, len(rows))
+	for _, row := range rows {
+		webhooks = append(webhooks, row.toModel())
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhookByID перезаписывает вебхук. Пустой секрет оставляет прежний
+func (repo *WebhookRepository) UpdateWebhookByID(ctx context.Context, exec sqlx.ExtContext, webhook *model.Webhook, id int) error {
+	query := `UPDATE webhooks
+			SET url = $1,
+			    secret = COALESCE(NULLIF($2, ''), secret),
+			    event_types = $3,
+			    active = $4
+			WHERE id = $5
+			RETURNING ` + webhookColumns
+
+	var row webhookRow
+	err := sqlx.GetContext(ctx, exec, &row, query,
+		webhook.URL,
+		webhook.Secret,
+		pq.StringArray(webhook.EventTypes),
+		webhook.Active,
+		id,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return databaseError("вебхук с таким ID не найден", err)
+		}
+		return databaseError("ошибка при обновлении вебхука", err)
+	}
+
+	*webhook = row.toModel()
+	return nil
+}
+
+func (repo *WebhookRepository) DeleteWebhookByID(ctx context.Context, exec sqlx.ExtContext, id int) error {
+	result, err := exec.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return databaseError("ошибка при удалении вебхука", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return databaseError("не удалось получить количество удалённых строк", err)
+	}
+	if rowsAffected == 0 {
+		return databaseError("вебхук с таким ID не найден", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// EnqueueWebhookEvent создает доставку события каждому активному вебхуку, подписанному
+// на его тип. Вызывается в транзакции изменения, породившего событие
+func (repo *WebhookRepository) EnqueueWebhookEvent(ctx context.Context, exec sqlx.ExtContext, eventID int64, eventType string, payload []byte) error {
+	query := `INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3::jsonb FROM webhooks
+		WHERE active AND $2 = ANY (event_types)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`
+
+	if _, err := exec.ExecContext(ctx, query, eventID, eventType, string(payload)); err != nil {
+		return databaseError("ошибка при постановке события вебхуков в очередь", err)
+	}
+	return nil
+}
+
+// ClaimDueDeliveries забирает до limit доставок, время попытки которых наступило, и
+// откладывает их следующую попытку на lease. Если процесс упадет, не записав результат,
+// доставка повторится по истечении lease
+func (repo *WebhookRepository) ClaimDueDeliveries(ctx context.Context, exec sqlx.ExtContext, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+			d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at,
+			w.url, w.secret`
+
+	deliveries := []model.WebhookDelivery{}
+	err := sqlx.SelectContext(ctx, exec, &deliveries, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, databaseError("ошибка получения доставок вебхуков", err)
+	}
+	return deliveries, nil
+}
+
+// RecordDeliveryAttempt пишет попытку в журнал и переводит доставку в status. Для pending
+// следующая попытка назначается на nextAttemptAt
+func (repo *WebhookRepository) RecordDeliveryAttempt(
+	ctx context.Context,
+	exec sqlx.ExtContext,
+	attempt *model.WebhookDeliveryAttempt,
+	status string,
+	nextAttemptAt time.Time,
+) error {
+	query := `INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, attempted_at`
+
+	row := exec.QueryRowxContext(ctx, query, attempt.DeliveryID, attempt.StatusCode, attempt.Error, attempt.DurationMS)
+	if err := row.Scan(&attempt.ID, &attempt.AttemptedAt); err != nil {
+		return databaseError("ошибка записи попытки доставки вебхука", err)
+	}
+
+	query = `UPDATE webhook_deliveries
+			SET status = $2,
+			    attempts = attempts + 1,
+			    next_attempt_at = $3,
+			    last_status_code = $4,
+			    last_error = $5,
+			    delivered_at = CASE WHEN $2 = 'delivered' THEN now() END
+			WHERE id = $1`
+
+	_, err := exec.ExecContext(ctx, query, attempt.DeliveryID, status, nextAttemptAt, attempt.StatusCode, attempt.Error)
+	if err != nil {
+		return databaseError("ошибка обновления доставки вебхука", err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries возвращает журнал доставок вебхука, начиная с последних
+func (repo *WebhookRepository) GetWebhookDeliveries(ctx context.Context, exec sqlx.ExtContext, filter model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error) {
+	var args queryArgs
+	conditions := []string{"webhook_id = " + args.add(filter.WebhookID)}
+	if filter.Status != nil {
+		conditions = append(conditions, "status = "+args.add(*filter.Status))
+	}
+
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY id DESC
+		LIMIT ` + args.add(filter.Limit)
+
+	deliveries := []model.WebhookDelivery{}
+	err := sqlx.SelectContext(ctx, exec, &deliveries, query, args...)
+	if err != nil {
+		return nil, databaseError("ошибка получения журнала доставок вебхука", err)
+	}
+	return deliveries, nil
+}
+
+func (repo *WebhookRepository) GetWebhookDeliveryAttempts(ctx context.Context, exec sqlx.ExtContext, webhookID int, deliveryID int64) ([]model.WebhookDeliveryAttempt, error) {
+	query := `SELECT a.id, a.delivery_id, a.attempted_at, a.status_code, a.error, a.duration_ms
+		FROM webhook_delivery_attempts a
+		JOIN webhook_deliveries d ON d.id = a.delivery_id
+		WHERE d.webhook_id = $1 AND a.delivery_id = $2
+		ORDER BY a.id`
+
+	attempts := []model.WebhookDeliveryAttempt{}
+	err := sqlx.SelectContext(ctx, exec, &attempts, query, webhookID, deliveryID)
+	if err != nil {
+		return nil, databaseError("ошибка получения попыток доставки вебхука", err)
+	}
+	return attempts, nil
+}
+
+// RedeliverWebhookDelivery возвращает доставку в очередь с немедленной попыткой, в том
+// числе из состояния dead. Счетчик попыток сбрасывается
+func (repo *WebhookRepository) RedeliverWebhookDelivery(ctx context.Context, exec sqlx.ExtContext, webhookID int, deliveryID int64) (*model.WebhookDelivery, error) {
+	query := `UPDATE webhook_deliveries
+			SET status = 'pending', attempts = 0, next_attempt_at = now(), delivered_at = NULL
+			WHERE webhook_id = $1 AND id = $2
+			RETURNING ` + webhookDeliveryColumns
+
+	var delivery model.WebhookDelivery
+	err := sqlx.GetContext(ctx, exec, &delivery, query, webhookID, deliveryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, databaseError("доставка вебхука не найдена", err)
+		}
+		return nil, databaseError("ошибка при повторной постановке доставки вебхука", err)
+	}
+	return &delivery, nil
+}
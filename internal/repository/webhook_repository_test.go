@@ -0,0 +1,106 @@
+package repository_test
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// claimedDelivery ищет среди забранных доставок доставку вебхука webhookID
+func claimedDelivery(deliveries []model.WebhookDelivery, webhookID int) *model.WebhookDelivery {
+	for i := range deliveries {
+		if deliveries[i].WebhookID == webhookID {
+			return &deliveries[i]
+		}
+	}
+	return nil
+}
+
+func TestWebhookDeliveryQueue(t *testing.T) {
+	database := openTestDatabase(t)
+	repo := repository.NewWebhookRepository(database)
+	ctx := context.Background()
+
+	webhook := model.Webhook{
+		URL:        "https://example.com/hooks",
+		Secret:     "0123456789abcdef",
+		EventTypes: []string{model.EventSubscriptionCreated},
+		Active:     true,
+	}
+	if err := repo.SaveWebhook(ctx, database, &webhook); err != nil {
+		t.Fatalf("не удалось сохранить вебхук: %v", err)
+	}
+	t.Cleanup(func() { _ = repo.DeleteWebhookByID(ctx, database, webhook.ID) })
+
+	// событие другого типа и повтор события не создают доставок
+	payload := []byte(`{"id":1}`)
+	for _, eventType := range []string{model.EventSubscriptionCreated, model.EventSubscriptionCreated, model.EventSubscriptionDeleted} {
+		if err := repo.EnqueueWebhookEvent(ctx, database, 1, eventType, payload); err != nil {
+			t.Fatalf("EnqueueWebhookEvent: %v", err)
+		}
+	}
+	filter := model.WebhookDeliveryFilter{WebhookID: webhook.ID, Limit: 10}
+	deliveries, err := repo.GetWebhookDeliveries(ctx, database, filter)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].EventType != model.EventSubscriptionCreated {
+		t.Fatalf("доставки вебхука %+v, ожидалась одна", deliveries)
+	}
+
+	claimed, err := repo.ClaimDueDeliveries(ctx, database, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimDueDeliveries: %v", err)
+	}
+	delivery := claimedDelivery(claimed, webhook.ID)
+	if delivery == nil || delivery.URL != webhook.URL || delivery.Secret != webhook.Secret {
+		t.Fatalf("забранная доставка %+v", delivery)
+	}
+
+	// пока не истек lease, доставку не забирает другой процесс
+	claimed, err = repo.ClaimDueDeliveries(ctx, database, 1000, time.Hour)
+	if err != nil {
+		t.Fatalf("ClaimDueDeliveries: %v", err)
+	}
+	if claimedDelivery(claimed, webhook.ID) != nil {
+		t.Error("доставка забрана повторно до истечения lease")
+	}
+
+	status, reason := 500, "получатель ответил 500"
+	attempt := model.WebhookDeliveryAttempt{DeliveryID: delivery.ID, StatusCode: &status, Error: &reason, DurationMS: 12}
+	if err := repo.RecordDeliveryAttempt(ctx, database, &attempt, model.DeliveryStatusDead, time.Now()); err != nil {
+		t.Fatalf("RecordDeliveryAttempt: %v", err)
+	}
+	attempts, err := repo.GetWebhookDeliveryAttempts(ctx, database, webhook.ID, delivery.ID)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveryAttempts: %v", err)
+	}
+	if len(attempts) != 1 || attempts[0].StatusCode == nil || *attempts[0].StatusCode != status {
+		t.Errorf("журнал попыток %+v", attempts)
+	}
+
+	dead := model.DeliveryStatusDead
+	filter.Status = &dead
+	deliveries, err = repo.GetWebhookDeliveries(ctx, database, filter)
+	if err != nil {
+		t.Fatalf("GetWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].Attempts != 1 {
+		t.Fatalf("доставки в dead %+v", deliveries)
+	}
+
+	// повторная доставка возвращает dead в очередь со сброшенным счетчиком попыток
+	redelivered, err := repo.RedeliverWebhookDelivery(ctx, database, webhook.ID, delivery.ID)
+	if err != nil {
+		t.Fatalf("RedeliverWebhookDelivery: %v", err)
+	}
+	if redelivered.Status != model.DeliveryStatusPending || redelivered.Attempts != 0 {
+		t.Errorf("после повторной доставки %+v", redelivered)
+	}
+	if _, err := repo.RedeliverWebhookDelivery(ctx, database, webhook.ID, delivery.ID+1_000_000); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("чужая доставка: ожидалась ErrNotFound, получено %v", err)
+	}
+}
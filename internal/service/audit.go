@@ -0,0 +1,105 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// recordChange записывает изменение подписки в единице работы uow вместе с самим
+// изменением: запись журнала аудита, если он ведется, и событие подписки для outbox.
+// before и after - снимки до и после изменения, nil означает отсутствие снимка.
+// Инициатор и ID запроса берутся из контекста запроса
+func (s *SubscriptionService) recordChange(
+	ctx context.Context,
+	uow *unitOfWork,
+	action string,
+	subscriptionID int,
+	before any,
+	after any,
+) error {
+	var beforeJSON, afterJSON json.RawMessage
+	var err error
+	if before != nil {
+		if beforeJSON, err = json.Marshal(before); err != nil {
+			return util.LogError("не удалось сохранить снимок подписки до изменения", err)
+		}
+	}
+	if after != nil {
+		if afterJSON, err = json.Marshal(after); err != nil {
+			return util.LogError("не удалось сохранить снимок подписки после изменения", err)
+		}
+	}
+
+	occurredAt := time.Now().UTC()
+	if s.audit != nil {
+		record := model.SubscriptionAuditRecord{
+			SubscriptionID: subscriptionID,
+			Action:         action,
+			Before:         beforeJSON,
+			After:          afterJSON,
+			Actor:          util.ActorFromContext(ctx),
+		}
+		if requestID := util.RequestIDFromContext(ctx); requestID != "" {
+			record.RequestID = &requestID
+		}
+		if err := s.audit.SaveAuditRecord(ctx, uow, &record); err != nil {
+			return err
+		}
+		occurredAt = record.CreatedAt
+	}
+
+	addSubscriptionEvent(uow, action, occurredAt, beforeJSON, afterJSON)
+	return nil
+}
+
+// subscriptionEventTypes сопоставляет действия журнала аудита с типами событий подписок;
+// остальные действия публикуются как subscription.updated
+var subscriptionEventTypes = map[string]string{
+	model.AuditActionCreate: model.EventSubscriptionCreated,
+	model.AuditActionDelete: model.EventSubscriptionDeleted,
+}
+
+// addSubscriptionEvent добавляет в единицу работы событие подписки о действии action.
+// В событие попадает снимок после изменения, а при удалении - до него
+func addSubscriptionEvent(uow *unitOfWork, action string, occurredAt time.Time, before, after json.RawMessage) {
+	eventType, ok := subscriptionEventTypes[action]
+	if !ok {
+		eventType = model.EventSubscriptionUpdated
+	}
+
+	snapshot := after
+	if snapshot == nil {
+		snapshot = before
+	}
+
+	uow.addEvent(eventType, func(eventID int64) any {
+		return model.SubscriptionEvent{
+			ID:           eventID,
+			Type:         eventType,
+			Action:       action,
+			OccurredAt:   occurredAt,
+			Subscription: snapshot,
+		}
+	})
+}
+
+func (s *SubscriptionService) GetSubscriptionHistory(ctx context.Context, id int) ([]model.SubscriptionAuditRecord, error) {
+	if s.audit == nil {
+		return nil, util.LogError("журнал изменений подписок не ведется", model.ErrNotFound)
+	}
+
+	history, err := s.audit.GetSubscriptionHistory(ctx, s.audit.Database, id)
+	if err != nil {
+		return nil, util.LogError("не удалось получить историю изменений подписки", err)
+	}
+	if len(history) == 0 {
+		return nil, util.LogError("история изменений подписки пуста", model.ErrNotFound)
+	}
+
+	log.Printf("история изменений подписки с id=%d: %d записей", id, len(history))
+	return history, nil
+}
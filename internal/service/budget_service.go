@@ -0,0 +1,225 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+// budgetWarningHorizon - сколько будущих месяцев, начиная с текущего, проверяется
+// на превышение бюджета при создании и изменении подписки
+const budgetWarningHorizon = 12
+
+type BudgetService struct {
+	*repository.BudgetRepository
+	subscriptions repository.SubscriptionStore
+}
+
+func NewBudgetService(repo *repository.BudgetRepository, subscriptions repository.SubscriptionStore) *BudgetService {
+	return &BudgetService{
+		BudgetRepository: repo,
+		subscriptions:    subscriptions,
+	}
+}
+
+func (s *BudgetService) CreateBudget(ctx context.Context, budget *model.Budget) error {
+	normalizeBudget(budget)
+	if err := ValidateBudget(budget); err != nil {
+		return util.LogError("бюджет не прошел проверку", err)
+	}
+
+	if err := s.BudgetRepository.SaveBudget(ctx, s.Database, budget); err != nil {
+		return util.LogError("не удалось создать бюджет", err)
+	}
+
+	log.Printf("бюджет сохранен: %v", budget)
+	return nil
+}
+
+func (s *BudgetService) GetBudgetByID(ctx context.Context, id int) (*model.Budget, error) {
+	budget, err := s.BudgetRepository.GetBudgetByID(ctx, s.Database, id)
+	if err != nil {
+		return nil, util.LogError("не удалось найти бюджет", err)
+	}
+	return budget, nil
+}
+
+func (s *BudgetService) GetBudgetsByUserUUID(ctx context.Context, uuid string) ([]model.Budget, error) {
+	budgets, err := s.BudgetRepository.GetBudgetsByUserUUID(ctx, s.Database, uuid)
+	if err != nil {
+		return nil, util.LogError("не удалось получить бюджеты пользователя", err)
+	}
+
+	log.Printf("бюджеты пользователя с uuid=%s: %d", uuid, len(budgets))
+	return budgets, nil
+}
+
+func (s *BudgetService) UpdateBudgetByID(ctx context.Context, budget *model.Budget, id int) error {
+	normalizeBudget(budget)
+	if err := ValidateBudget(budget); err != nil {
+		return util.LogError("бюджет не прошел проверку", err)
+	}
+
+	if err := s.BudgetRepository.UpdateBudgetByID(ctx, s.Database, budget, id); err != nil {
+		return util.LogError("не удалось обновить бюджет", err)
+	}
+
+	log.Printf("бюджет с id=%d обновлен: %v", id, budget)
+	return nil
+}
+
+func (s *BudgetService) DeleteBudgetByID(ctx context.Context, id int) error {
+	if err := s.BudgetRepository.DeleteBudgetByID(ctx, s.Database, id); err != nil {
+		return util.LogError("не удалось удалить бюджет", err)
+	}
+
+	log.Printf("бюджет с id=%d удален", id)
+	return nil
+}
+
+// GetBudgetReports сравнивает траты пользователя с каждым его бюджетом по месяцам
+// с firstMonth по lastMonth включительно. Траты считаются так же, как общая стоимость
+// подписок, в валюте бюджета
+func (s *BudgetService) GetBudgetReports(ctx context.Context, uuid string, firstMonth, lastMonth time.Time) ([]model.BudgetReport, error) {
+	budgets, err := s.BudgetRepository.GetBudgetsByUserUUID(ctx, s.Database, uuid)
+	if err != nil {
+		return nil, util.LogError("не удалось получить бюджеты пользователя", err)
+	}
+
+	reports := make([]model.BudgetReport, 0, len(budgets))
+	for _, budget := range budgets {
+		spends, err := s.subscriptions.GetMonthlySpend(ctx, s.subscriptions.Executor(), budgetCostFilter(&budget, firstMonth, lastMonth), 0)
+		if err != nil {
+			return nil, util.LogError(fmt.Sprintf("не удалось посчитать траты по бюджету id=%d", budget.ID), err)
+		}
+
+		spentByMonth := make(map[string]int, len(spends))
+		for _, spend := range spends {
+			spentByMonth[spend.Month.ToTime().Format("2006-01")] = spend.Spent
+		}
+
+		report := model.BudgetReport{Budget: budget, Months: []model.BudgetMonth{}}
+		for month := firstMonth; !month.After(lastMonth); month = month.AddDate(0, 1, 0) {
+			spent := spentByMonth[month.Format("2006-01")]
+			report.Months = append(report.Months, model.BudgetMonth{
+				Month:     model.MonthYear(month),
+				Budget:    budget.Amount,
+				Spent:     spent,
+				Remaining: budget.Amount - spent,
+				Overspent: spent > budget.Amount,
+			})
+		}
+		reports = append(reports, report)
+	}
+
+	log.Printf("исполнение бюджетов пользователя с uuid=%s: %d бюджетов", uuid, len(reports))
+	return reports, nil
+}
+
+// checkSubscriptionBudgets ищет будущие месяцы, в которых траты с учетом подписки превышают
+// бюджеты пользователя, затрагивающие эту подписку. Проверяются месяцы от текущего или
+// месяца начала подписки на budgetWarningHorizon месяцев вперед, но не дальше ее окончания.
+// Вызывается в единице работы, записавшей подписку, поэтому траты считаются с ее изменением.
+// Предупреждения не должны мешать записи: бюджет, траты по которому посчитать не удалось
+// (например, нет курса к его валюте), пропускается, а его запросы откатываются к точке
+// сохранения, и транзакция подписки продолжается
+func (s *SubscriptionService) checkSubscriptionBudgets(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) []model.BudgetWarning {
+	if s.budgets == nil {
+		return nil
+	}
+
+	var budgets []model.Budget
+	err := s.budgets.InSavepoint(ctx, exec, func() (err error) {
+		budgets, err = s.budgets.GetBudgetsForSubscription(ctx, exec, subscription)
+		return err
+	})
+	if err != nil {
+		log.Printf("бюджеты подписки id=%d не проверены: %v", subscription.ID, err)
+		return nil
+	}
+	if len(budgets) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	firstMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	startDate := subscription.StartDate.ToTime()
+	if startDate.After(firstMonth) {
+		firstMonth = time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	lastMonth := firstMonth.AddDate(0, budgetWarningHorizon-1, 0)
+	if endDate := subscription.EndDate.ToTime(); !endDate.IsZero() && endDate.Before(lastMonth) {
+		lastMonth = time.Date(endDate.Year(), endDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	if lastMonth.Before(firstMonth) {
+		return nil
+	}
+
+	var warnings []model.BudgetWarning
+	for _, budget := range budgets {
+		var spends []model.MonthlySpend
+		err := s.budgets.InSavepoint(ctx, exec, func() (err error) {
+			spends, err = s.SubscriptionStore.GetMonthlySpend(ctx, exec, budgetCostFilter(&budget, firstMonth, lastMonth), subscription.ID)
+			return err
+		})
+		if err != nil {
+			log.Printf("бюджет id=%d пропущен при проверке подписки id=%d: %v", budget.ID, subscription.ID, err)
+			continue
+		}
+
+		for _, spend := range spends {
+			if spend.Spent <= budget.Amount || spend.SubscriptionSpent == 0 {
+				continue
+			}
+			warnings = append(warnings, model.BudgetWarning{
+				BudgetID: budget.ID,
+				Scope:    budget.Scope,
+				Month:    spend.Month,
+				Budget:   budget.Amount,
+				Spent:    spend.Spent,
+				Currency: budget.Currency,
+				Message: fmt.Sprintf("траты за %s превысят %s",
+					spend.Month.ToTime().Format("01-2006"), describeBudget(&budget)),
+			})
+		}
+	}
+
+	if len(warnings) > 0 {
+		log.Printf("подписка id=%d превышает бюджеты: %d предупреждений", subscription.ID, len(warnings))
+	}
+	return warnings
+}
+
+// budgetCostFilter строит фильтр расчета стоимости для области бюджета за месяцы
+// с firstMonth по lastMonth включительно
+func budgetCostFilter(budget *model.Budget, firstMonth, lastMonth time.Time) model.CostFilter {
+	filter := model.CostFilter{
+		UserID:      budget.UserID,
+		StartPeriod: firstMonth,
+		EndPeriod:   lastMonth.AddDate(0, 1, -1),
+		Currency:    budget.Currency,
+	}
+	switch budget.Scope {
+	case model.BudgetScopeCategory:
+		filter.Category = budget.Category
+	case model.BudgetScopeService:
+		filter.ServiceName = budget.ServiceName
+	}
+	return filter
+}
+
+func describeBudget(budget *model.Budget) string {
+	switch {
+	case budget.Scope == model.BudgetScopeCategory && budget.Category != nil:
+		return "бюджет категории " + *budget.Category
+	case budget.Scope == model.BudgetScopeService && budget.ServiceName != nil:
+		return "бюджет сервиса " + *budget.ServiceName
+	default:
+		return "общий бюджет"
+	}
+}
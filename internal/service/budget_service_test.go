@@ -0,0 +1,160 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository/memory"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"testing"
+	"time"
+)
+
+func TestBudgetCostFilter(t *testing.T) {
+	category, serviceName := "streaming", "Yandex Plus"
+	firstMonth := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	lastMonth := time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		budget model.Budget
+		check  func(t *testing.T, filter model.CostFilter)
+	}{
+		{
+			name:   "общий бюджет",
+			budget: model.Budget{Scope: model.BudgetScopeTotal},
+			check: func(t *testing.T, filter model.CostFilter) {
+				if filter.Category != nil || filter.ServiceName != nil {
+					t.Errorf("общий бюджет не должен сужать фильтр: %+v", filter)
+				}
+			},
+		},
+		{
+			name:   "бюджет категории",
+			budget: model.Budget{Scope: model.BudgetScopeCategory, Category: &category},
+			check: func(t *testing.T, filter model.CostFilter) {
+				if filter.Category == nil || *filter.Category != category || filter.ServiceName != nil {
+					t.Errorf("фильтр бюджета категории %+v", filter)
+				}
+			},
+		},
+		{
+			name:   "бюджет сервиса",
+			budget: model.Budget{Scope: model.BudgetScopeService, ServiceName: &serviceName},
+			check: func(t *testing.T, filter model.CostFilter) {
+				if filter.ServiceName == nil || *filter.ServiceName != serviceName || filter.Category != nil {
+					t.Errorf("фильтр бюджета сервиса %+v", filter)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.budget.UserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+			test.budget.Currency = "USD"
+			filter := budgetCostFilter(&test.budget, firstMonth, lastMonth)
+
+			if filter.UserID != test.budget.UserID || filter.Currency != "USD" {
+				t.Errorf("пользователь %q и валюта %q фильтра", filter.UserID, filter.Currency)
+			}
+			// период включает последний месяц целиком
+			if !filter.StartPeriod.Equal(firstMonth) || !filter.EndPeriod.Equal(time.Date(2026, time.March, 31, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("период фильтра %v - %v", filter.StartPeriod, filter.EndPeriod)
+			}
+			test.check(t, filter)
+		})
+	}
+}
+
+func TestDescribeBudget(t *testing.T) {
+	category, serviceName := "streaming", "Yandex Plus"
+	tests := []struct {
+		budget model.Budget
+		want   string
+	}{
+		{budget: model.Budget{Scope: model.BudgetScopeTotal}, want: "общий бюджет"},
+		{budget: model.Budget{Scope: model.BudgetScopeCategory, Category: &category}, want: "бюджет категории streaming"},
+		{budget: model.Budget{Scope: model.BudgetScopeService, ServiceName: &serviceName}, want: "бюджет сервиса Yandex Plus"},
+	}
+	for _, test := range tests {
+		if got := describeBudget(&test.budget); got != test.want {
+			t.Errorf("describeBudget(%s) = %q, ожидалось %q", test.budget.Scope, got, test.want)
+		}
+	}
+}
+
+// fakeBudgetStore отдает заданные бюджеты и считает точки сохранения, из которых
+// вернулась ошибка
+type fakeBudgetStore struct {
+	budgets    []model.Budget
+	err        error
+	rolledBack int
+}
+
+func (store *fakeBudgetStore) GetBudgetsForSubscription(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) ([]model.Budget, error) {
+	return store.budgets, store.err
+}
+
+func (store *fakeBudgetStore) InSavepoint(ctx context.Context, exec sqlx.ExtContext, fn func() error) error {
+	err := fn()
+	if err != nil {
+		store.rolledBack++
+	}
+	return err
+}
+
+func TestSubscriptionBudgetWarnings(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+
+	store := memory.NewStore()
+	video := &model.CatalogService{Name: "Тест Видео", Currency: "RUB"}
+	if err := store.SaveCatalogService(ctx, store.Executor(), video); err != nil {
+		t.Fatalf("не удалось добавить сервис в каталог: %v", err)
+	}
+	budgets := &fakeBudgetStore{budgets: []model.Budget{
+		{ID: 1, UserID: testUserA, Scope: model.BudgetScopeTotal, Amount: 150, Currency: "RUB"},
+		// курса RUB к USD нет: бюджет пропускается, а подписка все равно записывается
+		{ID: 2, UserID: testUserA, Scope: model.BudgetScopeTotal, Amount: 150, Currency: "USD"},
+	}}
+	s := NewSubscriptionService(store, store, budgets, nil, nil, nil, 0)
+	saveTestSubscription(t, s, video, testUserA, start, 100)
+
+	subscription := &model.SubscriptionDetails{
+		ServiceID: video.ID, Price: 100, UserID: testUserA,
+		StartDate: model.DayMonthYear(start), EndDate: model.DayMonthYear(start.AddDate(0, 2, -1)),
+	}
+	warnings, err := s.CreateSubscription(ctx, subscription)
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("предупреждения %+v, ожидалось по одному на два месяца подписки", warnings)
+	}
+	for i, warning := range warnings {
+		month := model.MonthYear(start.AddDate(0, i, 0))
+		if warning.BudgetID != 1 || warning.Month != month || warning.Spent != 200 || warning.Budget != 150 || warning.Message == "" {
+			t.Errorf("предупреждение %+v", warning)
+		}
+	}
+	if budgets.rolledBack != 1 {
+		t.Errorf("откатов к точке сохранения %d, ожидался один для бюджета в USD", budgets.rolledBack)
+	}
+
+	// в пределах бюджета предупреждений нет
+	patched, warnings, err := s.PatchSubscriptionByID(ctx, subscription.ID, model.SubscriptionPatch{Price: ptr(10)}, nil)
+	if err != nil || patched.Price != 10 || len(warnings) != 0 {
+		t.Fatalf("PatchSubscriptionByID: %+v, предупреждения %+v, ошибка %v", patched, warnings, err)
+	}
+
+	// без бюджетов подписка тоже записывается
+	budgets.err = errors.New("сбой")
+	warnings, err = s.CreateSubscription(ctx, &model.SubscriptionDetails{
+		ServiceID: video.ID, Price: 1000, UserID: testUserA, StartDate: model.DayMonthYear(start),
+	})
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("CreateSubscription без бюджетов: предупреждения %+v, ошибка %v", warnings, err)
+	}
+}
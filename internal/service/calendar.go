@@ -0,0 +1,320 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendarRefreshInterval - как часто приложения календаря перечитывают календарь оплат
+const calendarRefreshInterval = "PT6H"
+
+// icsFrequencies сопоставляют периоды оплаты с частотой RRULE
+var icsFrequencies = map[string]string{
+	model.BillingPeriodDay:   "DAILY",
+	model.BillingPeriodWeek:  "WEEKLY",
+	model.BillingPeriodMonth: "MONTHLY",
+	model.BillingPeriodYear:  "YEARLY",
+}
+
+// calendarTokenBytes - сколько случайных байт в токене календаря
+const calendarTokenBytes = 32
+
+// CalendarService
+// Календари оплат подписок в формате iCalendar (RFC 5545). Пользователь подписывается
+// на календарь из приложения календаря по ссылке с токеном, и приложение само
+// перечитывает его, поэтому календарь всегда собирается из текущего состояния подписок
+type CalendarService struct {
+	repository.SubscriptionStore
+	currencies repository.ExchangeRateStore
+	tokens     repository.CalendarTokenStore
+}
+
+func NewCalendarService(store repository.SubscriptionStore, currencies repository.ExchangeRateStore, tokens repository.CalendarTokenStore) *CalendarService {
+	return &CalendarService{
+		SubscriptionStore: store,
+		currencies:        currencies,
+		tokens:            tokens,
+	}
+}
+
+// IssueCalendarToken выпускает пользователю новый случайный токен календаря и отзывает
+// прежний. Хранится только SHA-256 токена, поэтому сам токен возвращается один раз
+func (s *CalendarService) IssueCalendarToken(ctx context.Context, uuid string) (string, error) {
+	userID, err := repository.CanonicalUUID(uuid)
+	if err != nil {
+		return "", util.LogError("не удалось выпустить токен календаря", err)
+	}
+
+	raw := make([]byte, calendarTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", util.LogError("не удалось сгенерировать токен календаря", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	if err := s.tokens.SaveCalendarToken(ctx, s.tokens.Executor(), userID, calendarTokenHash(token)); err != nil {
+		return "", util.LogError("не удалось выпустить токен календаря", err)
+	}
+
+	log.Printf("выпущен токен календаря пользователя с uuid=%s", userID)
+	return token, nil
+}
+
+// RevokeCalendarToken отзывает токен календаря пользователя: ссылка на календарь
+// перестает работать. Если токена нет, возвращает ErrNotFound
+func (s *CalendarService) RevokeCalendarToken(ctx context.Context, uuid string) error {
+	userID, err := repository.CanonicalUUID(uuid)
+	if err != nil {
+		return util.LogError("не удалось отозвать токен календаря", err)
+	}
+	if err := s.tokens.DeleteCalendarToken(ctx, s.tokens.Executor(), userID); err != nil {
+		return util.LogError("не удалось отозвать токен календаря", err)
+	}
+
+	log.Printf("отозван токен календаря пользователя с uuid=%s", userID)
+	return nil
+}
+
+// calendarTokenHash - SHA-256 токена, под которым он хранится. Токен случайный
+// и длинный, поэтому соль и медленный хеш не нужны
+func calendarTokenHash(token string) []byte {
+	hash := sha256.Sum256([]byte(token))
+	return hash[:]
+}
+
+// RenewalsCalendar возвращает календарь оплат пользователя: по повторяющемуся событию
+// на каждую неудаленную подписку. Неверный или отозванный токен возвращает ErrNotFound,
+// как и неизвестный календарь, чтобы по ответу нельзя было подобрать токен или UUID
+func (s *CalendarService) RenewalsCalendar(ctx context.Context, uuid, token string) ([]byte, error) {
+	notFound := fmt.Errorf("%w: календарь не найден", model.ErrNotFound)
+	userID, err := repository.CanonicalUUID(uuid)
+	if err != nil {
+		return nil, util.LogError("календарь оплат не выдан", notFound)
+	}
+	tokenHash, err := s.tokens.GetCalendarTokenHash(ctx, s.tokens.Executor(), userID)
+	if errors.Is(err, model.ErrNotFound) || (err == nil && subtle.ConstantTimeCompare(tokenHash, calendarTokenHash(token)) != 1) {
+		return nil, util.LogError("календарь оплат не выдан", notFound)
+	}
+	if err != nil {
+		return nil, util.LogError("не удалось проверить токен календаря", err)
+	}
+
+	currencies, err := s.currencies.GetCurrencies(ctx, s.currencies.Executor())
+	if err != nil {
+		return nil, util.LogError("не удалось получить валюты для календаря оплат", err)
+	}
+	minorUnits := make(map[string]int, len(currencies))
+	for _, currency := range currencies {
+		minorUnits[currency.Code] = currency.MinorUnits
+	}
+
+	calendar := &icsWriter{}
+	calendar.property("BEGIN", "VCALENDAR")
+	calendar.property("VERSION", "2.0")
+	calendar.property("PRODID", "-//Effective Mobile//Subscriptions//RU")
+	calendar.property("CALSCALE", "GREGORIAN")
+	calendar.property("METHOD", "PUBLISH")
+	calendar.text("X-WR-CALNAME", "Оплаты подписок")
+	calendar.property("REFRESH-INTERVAL;VALUE=DURATION", calendarRefreshInterval)
+	calendar.property("X-PUBLISHED-TTL", calendarRefreshInterval)
+
+	events := 0
+	err = s.InTransaction(ctx, func(exec sqlx.ExtContext) error {
+		subscriptions, err := s.GetSubscriptionsByUserUUID(ctx, exec, userID)
+		if err != nil {
+			return err
+		}
+		sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].ID < subscriptions[j].ID })
+
+		now := time.Now().UTC()
+		for i := range subscriptions {
+			subscription := &subscriptions[i]
+			prices, err := s.GetSubscriptionPrices(ctx, exec, subscription.ID)
+			if err != nil {
+				return err
+			}
+			pauses, err := s.GetSubscriptionPauses(ctx, exec, subscription.ID)
+			if err != nil {
+				return err
+			}
+
+			units, ok := minorUnits[subscription.Currency]
+			if !ok {
+				units = 2
+			}
+			written, err := writeRenewalEvent(calendar, subscription, billing.NewPlan(subscription, prices, pauses), units, now)
+			if err != nil {
+				return fmt.Errorf("подписка id=%d: %w", subscription.ID, err)
+			}
+			if written {
+				events++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, util.LogError(fmt.Sprintf("не удалось собрать календарь оплат пользователя с uuid=%s", userID), err)
+	}
+	calendar.property("END", "VCALENDAR")
+
+	log.Printf("календарь оплат пользователя с uuid=%s: %d событий", userID, events)
+	return calendar.Bytes(), nil
+}
+
+// writeRenewalEvent записывает событие с оплатами подписки. Правило повторения RRULE
+// дает те же даты, что и billing.ChargeDates, до end_date подписки или до начала
+// открытой паузы; оплаты в пробный период и внутри завершенных пауз исключаются
+// через EXDATE. Подписку, которая кончается или встает на паузу раньше start_date,
+// событие не описывает
+func writeRenewalEvent(calendar *icsWriter, subscription *model.SubscriptionDetails, plan billing.Plan, minorUnits int, now time.Time) (bool, error) {
+	frequency, ok := icsFrequencies[plan.Period]
+	if !ok {
+		return false, fmt.Errorf("неизвестный период оплаты %s", plan.Period)
+	}
+
+	start := billing.Day(plan.Start)
+	until := plan.End
+	horizon := plan.TrialEnd
+	for _, pause := range plan.Pauses {
+		if pause.ResumedOn == nil {
+			// после открытой паузы оплат нет, пока подписку не возобновят
+			stop := billing.Day(pause.PausedFrom.ToTime()).AddDate(0, 0, -1)
+			if until.IsZero() || stop.Before(until) {
+				until = stop
+			}
+			continue
+		}
+		if resumed := pause.ResumedOn.ToTime(); resumed.After(horizon) {
+			horizon = resumed
+		}
+	}
+	if !until.IsZero() && billing.Day(until).Before(start) {
+		return false, nil
+	}
+
+	var excluded []string
+	if !horizon.IsZero() {
+		dates, err := billing.ChargeDates(start, until, start, horizon, plan.Period, plan.Interval)
+		if err != nil {
+			return false, err
+		}
+		charges, err := plan.Charges(start, horizon)
+		if err != nil {
+			return false, err
+		}
+		charged := make(map[time.Time]bool, len(charges))
+		for _, charge := range charges {
+			charged[charge.Date] = true
+		}
+		for _, date := range dates {
+			if !charged[date] {
+				excluded = append(excluded, date.Format("20060102"))
+			}
+		}
+	}
+
+	rule := "FREQ=" + frequency + ";INTERVAL=" + strconv.Itoa(plan.Interval)
+	rule += lastDayRule(start, plan.Period)
+	if !until.IsZero() {
+		rule += ";UNTIL=" + billing.Day(until).Format("20060102")
+	}
+
+	calendar.property("BEGIN", "VEVENT")
+	calendar.property("UID", fmt.Sprintf("subscription-%d@effective-mobile-subscriptions", subscription.ID))
+	calendar.property("DTSTAMP", now.Format("20060102T150405Z"))
+	calendar.property("SEQUENCE", strconv.Itoa(subscription.Version))
+	calendar.property("DTSTART;VALUE=DATE", start.Format("20060102"))
+	calendar.property("RRULE", rule)
+	for len(excluded) > 0 {
+		// по 8 дат в строке, чтобы строки не приходилось переносить
+		n := min(len(excluded), 8)
+		calendar.property("EXDATE;VALUE=DATE", strings.Join(excluded[:n], ","))
+		excluded = excluded[n:]
+	}
+	calendar.text("SUMMARY", "Оплата "+subscription.ServiceName)
+	calendar.text("DESCRIPTION", renewalDescription(subscription, plan, minorUnits, now))
+	if subscription.Category != nil {
+		calendar.text("CATEGORIES", *subscription.Category)
+	}
+	calendar.property("TRANSP", "TRANSPARENT")
+	calendar.property("END", "VEVENT")
+	return true, nil
+}
+
+// lastDayRule уточняет RRULE для помесячных подписок, начатых 29-31 числа, и ежегодных,
+// начатых 29 февраля. billing.AddMonths переносит такую оплату на последний день
+// короткого месяца, а RRULE с одним днем месяца пропустил бы месяцы без него: из дней
+// с 28 по день начала берется последний, который есть в месяце
+func lastDayRule(start time.Time, period string) string {
+	day := start.Day()
+	if day <= 28 || (period == model.BillingPeriodYear && start.Month() != time.February) {
+		return ""
+	}
+	days := make([]string, 0, day-27)
+	for d := 28; d <= day; d++ {
+		days = append(days, strconv.Itoa(d))
+	}
+
+	switch period {
+	case model.BillingPeriodMonth:
+		return ";BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+	case model.BillingPeriodYear:
+		return ";BYMONTH=" + strconv.Itoa(int(start.Month())) + ";BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+	}
+	return ""
+}
+
+// renewalDescription описывает оплату: цену, действующую сейчас или на начало подписки,
+// и запланированные изменения цены
+func renewalDescription(subscription *model.SubscriptionDetails, plan billing.Plan, minorUnits int, now time.Time) string {
+	from := billing.Day(now)
+	if start := billing.Day(plan.Start); start.After(from) {
+		from = start
+	}
+
+	lines := []string{"Цена: " + formatMinorUnits(plan.PriceOn(from), minorUnits) + " " + subscription.Currency}
+	changes := append([]model.SubscriptionPrice(nil), plan.PriceChanges...)
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].EffectiveFrom.ToTime().Before(changes[j].EffectiveFrom.ToTime())
+	})
+	for i, change := range changes {
+		if i+1 < len(changes) && billing.Day(changes[i+1].EffectiveFrom.ToTime()).Equal(billing.Day(change.EffectiveFrom.ToTime())) {
+			// из изменений на одну дату действует последнее, как в billing.Plan.PriceOn
+			continue
+		}
+		if effective := billing.Day(change.EffectiveFrom.ToTime()); effective.After(from) {
+			lines = append(lines, fmt.Sprintf("С %s: %s %s",
+				effective.Format("02-01-2006"), formatMinorUnits(change.Price, minorUnits), subscription.Currency))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Подписка id=%d", subscription.ID))
+	return strings.Join(lines, "\n")
+}
+
+// formatMinorUnits записывает сумму в минимальных единицах валюты как десятичную дробь:
+// 49900 при двух знаках - 499.00
+func formatMinorUnits(amount, minorUnits int) string {
+	if minorUnits <= 0 {
+		return strconv.Itoa(amount)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	digits := fmt.Sprintf("%0*d", minorUnits+1, amount)
+	return sign + digits[:len(digits)-minorUnits] + "." + digits[len(digits)-minorUnits:]
+}
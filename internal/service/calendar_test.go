@@ -0,0 +1,244 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/billing"
+	"Effective_Mobile_Test_Project/internal/model"
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarTokens(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	calendars := NewCalendarService(store, store, store)
+
+	first, err := calendars.IssueCalendarToken(ctx, testUserA)
+	if err != nil {
+		t.Fatalf("не удалось выпустить токен: %v", err)
+	}
+	if stored, err := store.GetCalendarTokenHash(ctx, store.Executor(), testUserA); err != nil || bytes.Contains(stored, []byte(first)) {
+		t.Fatalf("хранилище должно держать только хеш токена: %x, ошибка %v", stored, err)
+	}
+	second, err := calendars.IssueCalendarToken(ctx, strings.ToUpper(testUserA))
+	if err != nil {
+		t.Fatalf("не удалось перевыпустить токен: %v", err)
+	}
+	if first == second {
+		t.Fatal("перевыпуск должен давать новый токен")
+	}
+	other, err := calendars.IssueCalendarToken(ctx, testUserB)
+	if err != nil {
+		t.Fatalf("не удалось выпустить токен второму пользователю: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		uuid  string
+		token string
+		err   error
+	}{
+		{name: "действующий токен", uuid: testUserA, token: second},
+		{name: "UUID в другом написании", uuid: strings.ToUpper(testUserA), token: second},
+		{name: "замененный токен", uuid: testUserA, token: first, err: model.ErrNotFound},
+		{name: "токен другого пользователя", uuid: testUserA, token: other, err: model.ErrNotFound},
+		{name: "пустой токен", uuid: testUserA, err: model.ErrNotFound},
+		{name: "пользователь без токена", uuid: "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c03", token: second, err: model.ErrNotFound},
+		{name: "неверный UUID", uuid: "not-a-uuid", token: second, err: model.ErrNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calendar, err := calendars.RenewalsCalendar(ctx, test.uuid, test.token)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("ошибка %v, ожидалась %v", err, test.err)
+				}
+				return
+			}
+			if err != nil || !bytes.HasPrefix(calendar, []byte("BEGIN:VCALENDAR\r\n")) {
+				t.Fatalf("календарь %q, ошибка %v", calendar, err)
+			}
+		})
+	}
+
+	if err := calendars.RevokeCalendarToken(ctx, testUserA); err != nil {
+		t.Fatalf("не удалось отозвать токен: %v", err)
+	}
+	if _, err := calendars.RenewalsCalendar(ctx, testUserA, second); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("отозванный токен не должен открывать календарь, ошибка %v", err)
+	}
+	if err := calendars.RevokeCalendarToken(ctx, testUserA); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("повторный отзыв должен вернуть ErrNotFound, получено %v", err)
+	}
+	if _, err := calendars.RenewalsCalendar(ctx, testUserB, other); err != nil {
+		t.Errorf("отзыв не должен затрагивать других пользователей: %v", err)
+	}
+	if _, err := calendars.IssueCalendarToken(ctx, "not-a-uuid"); !errors.Is(err, model.ErrValidation) {
+		t.Errorf("неверный UUID должен вернуть ErrValidation, получено %v", err)
+	}
+}
+
+func TestLastDayRule(t *testing.T) {
+	tests := []struct {
+		start  time.Time
+		period string
+		want   string
+	}{
+		{time.Date(2025, time.January, 28, 0, 0, 0, 0, time.UTC), model.BillingPeriodMonth, ""},
+		{time.Date(2025, time.January, 29, 0, 0, 0, 0, time.UTC), model.BillingPeriodMonth, ";BYMONTHDAY=28,29;BYSETPOS=-1"},
+		{time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), model.BillingPeriodMonth, ";BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+		{time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC), model.BillingPeriodYear, ";BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1"},
+		{time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), model.BillingPeriodYear, ""},
+		{time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), model.BillingPeriodWeek, ""},
+		{time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), model.BillingPeriodDay, ""},
+	}
+
+	for _, test := range tests {
+		if got := lastDayRule(test.start, test.period); got != test.want {
+			t.Errorf("lastDayRule(%s, %s) = %q, ожидалось %q", test.start.Format(time.DateOnly), test.period, got, test.want)
+		}
+	}
+}
+
+func TestWriteRenewalEvent(t *testing.T) {
+	day := func(year int, month time.Month, date int) model.DayMonthYear {
+		return model.DayMonthYear(time.Date(year, month, date, 0, 0, 0, 0, time.UTC))
+	}
+	now := time.Date(2025, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		start   model.DayMonthYear
+		end     model.DayMonthYear
+		period  string
+		trial   *model.DayMonthYear
+		pauses  []model.SubscriptionPause
+		rule    string
+		exdates []string
+		skipped bool
+	}{
+		{
+			name: "бессрочная помесячная", start: day(2025, time.January, 10), period: model.BillingPeriodMonth,
+			rule: "FREQ=MONTHLY;INTERVAL=1",
+		},
+		{
+			name: "31 число до даты окончания", start: day(2025, time.January, 31), end: day(2025, time.June, 30), period: model.BillingPeriodMonth,
+			rule: "FREQ=MONTHLY;INTERVAL=1;BYMONTHDAY=28,29,30,31;BYSETPOS=-1;UNTIL=20250630",
+		},
+		{
+			name: "год от 29 февраля", start: day(2024, time.February, 29), period: model.BillingPeriodYear,
+			rule: "FREQ=YEARLY;INTERVAL=1;BYMONTH=2;BYMONTHDAY=28,29;BYSETPOS=-1",
+		},
+		{
+			name: "пробный период", start: day(2025, time.January, 1), period: model.BillingPeriodMonth, trial: ptr(day(2025, time.February, 15)),
+			rule: "FREQ=MONTHLY;INTERVAL=1", exdates: []string{"20250101,20250201"},
+		},
+		{
+			name: "завершенная пауза", start: day(2025, time.January, 5), period: model.BillingPeriodMonth,
+			pauses: []model.SubscriptionPause{{PausedFrom: day(2025, time.March, 1), ResumedOn: ptr(day(2025, time.April, 15))}},
+			rule:   "FREQ=MONTHLY;INTERVAL=1", exdates: []string{"20250305,20250405"},
+		},
+		{
+			name: "открытая пауза заканчивает правило", start: day(2025, time.January, 5), period: model.BillingPeriodMonth,
+			pauses: []model.SubscriptionPause{{PausedFrom: day(2025, time.March, 10)}},
+			rule:   "FREQ=MONTHLY;INTERVAL=1;UNTIL=20250309",
+		},
+		{
+			name: "открытая пауза раньше даты окончания", start: day(2025, time.January, 5), end: day(2025, time.December, 31), period: model.BillingPeriodMonth,
+			pauses: []model.SubscriptionPause{{PausedFrom: day(2025, time.March, 10)}},
+			rule:   "FREQ=MONTHLY;INTERVAL=1;UNTIL=20250309",
+		},
+		{
+			name: "больше восьми исключенных дат", start: day(2025, time.January, 6), period: model.BillingPeriodWeek, trial: ptr(day(2025, time.March, 10)),
+			rule: "FREQ=WEEKLY;INTERVAL=1",
+			exdates: []string{
+				"20250106,20250113,20250120,20250127,20250203,20250210,20250217,20250224",
+				"20250303,20250310",
+			},
+		},
+		{
+			name: "пауза с первого дня подписки", start: day(2025, time.January, 5), period: model.BillingPeriodMonth,
+			pauses:  []model.SubscriptionPause{{PausedFrom: day(2025, time.January, 5)}},
+			skipped: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscription := &model.SubscriptionDetails{
+				ID: 7, ServiceName: "Видео", Price: 49900, Currency: "RUB",
+				BillingPeriod: test.period, BillingInterval: 1,
+				StartDate: test.start, EndDate: test.end, TrialEnd: test.trial, Version: 3,
+			}
+			var calendar icsWriter
+			written, err := writeRenewalEvent(&calendar, subscription, billing.NewPlan(subscription, nil, test.pauses), 2, now)
+			if err != nil {
+				t.Fatalf("writeRenewalEvent: %v", err)
+			}
+			if written == test.skipped {
+				t.Fatalf("событие записано: %v, ожидалось %v", written, !test.skipped)
+			}
+			if test.skipped {
+				if calendar.Bytes() != nil {
+					t.Errorf("пропущенная подписка записала %q", calendar.Bytes())
+				}
+				return
+			}
+
+			properties := map[string][]string{}
+			for _, line := range unfoldICS(string(calendar.Bytes())) {
+				name, value, _ := strings.Cut(line, ":")
+				properties[name] = append(properties[name], value)
+			}
+			want := map[string][]string{
+				"BEGIN":              {"VEVENT"},
+				"UID":                {"subscription-7@effective-mobile-subscriptions"},
+				"DTSTAMP":            {"20250101T090000Z"},
+				"SEQUENCE":           {"3"},
+				"DTSTART;VALUE=DATE": {test.start.ToTime().Format("20060102")},
+				"RRULE":              {test.rule},
+				"SUMMARY":            {"Оплата Видео"},
+				"DESCRIPTION":        {`Цена: 499.00 RUB\nПодписка id=7`},
+				"TRANSP":             {"TRANSPARENT"},
+				"END":                {"VEVENT"},
+			}
+			if test.exdates != nil {
+				want["EXDATE;VALUE=DATE"] = test.exdates
+			}
+			if !reflect.DeepEqual(properties, want) {
+				t.Errorf("событие %v, ожидалось %v", properties, want)
+			}
+		})
+	}
+
+	var calendar icsWriter
+	subscription := &model.SubscriptionDetails{ID: 7, BillingPeriod: "quarter", BillingInterval: 1, StartDate: day(2025, time.January, 1)}
+	if _, err := writeRenewalEvent(&calendar, subscription, billing.NewPlan(subscription, nil, nil), 2, now); err == nil {
+		t.Error("неизвестный период оплаты должен вернуть ошибку")
+	}
+}
+
+func TestFormatMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount     int
+		minorUnits int
+		want       string
+	}{
+		{49900, 2, "499.00"},
+		{5, 2, "0.05"},
+		{0, 2, "0.00"},
+		{-150, 2, "-1.50"},
+		{1234, 0, "1234"},
+		{1500, 3, "1.500"},
+	}
+
+	for _, test := range tests {
+		if got := formatMinorUnits(test.amount, test.minorUnits); got != test.want {
+			t.Errorf("formatMinorUnits(%d, %d) = %s, ожидалось %s", test.amount, test.minorUnits, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"strings"
+)
+
+// CatalogService
+// Каталог сервисов. Изменения, которые затрагивают подписки, выполняются в единице работы
+// subscriptions, чтобы попасть в журнал аудита и outbox
+type CatalogService struct {
+	*repository.CatalogRepository
+	subscriptions *SubscriptionService
+}
+
+func NewCatalogService(repo *repository.CatalogRepository, subscriptions *SubscriptionService) *CatalogService {
+	return &CatalogService{CatalogRepository: repo, subscriptions: subscriptions}
+}
+
+func (s *CatalogService) CreateCatalogService(ctx context.Context, service *model.CatalogService) error {
+	normalizeCatalogService(service)
+	if err := ValidateCatalogService(service); err != nil {
+		return util.LogError("сервис каталога не прошел проверку", err)
+	}
+
+	err := s.InTransaction(ctx, func(exec sqlx.ExtContext) error {
+		return s.CatalogRepository.SaveCatalogService(ctx, exec, service)
+	})
+	if err != nil {
+		return util.LogError("не удалось добавить сервис в каталог", err)
+	}
+
+	log.Printf("сервис добавлен в каталог: %v", service)
+	return nil
+}
+
+func (s *CatalogService) GetCatalogServiceByID(ctx context.Context, id int) (*model.CatalogService, error) {
+	service, err := s.CatalogRepository.GetCatalogServiceByID(ctx, s.Database, id)
+	if err != nil {
+		return nil, util.LogError("не удалось найти сервис в каталоге", err)
+	}
+	return service, nil
+}
+
+func (s *CatalogService) ListCatalogServices(ctx context.Context, filter model.CatalogServiceFilter) ([]model.CatalogService, error) {
+	services, err := s.CatalogRepository.ListCatalogServices(ctx, s.Database, filter)
+	if err != nil {
+		return nil, util.LogError("не удалось получить каталог сервисов", err)
+	}
+
+	log.Printf("каталог сервисов: %d строк", len(services))
+	return services, nil
+}
+
+// UpdateCatalogServiceByID перезаписывает сервис каталога целиком, включая написания.
+// Переименование сразу отражается в service_name подписок сервиса, включая удаленные:
+// каждая измененная подписка получает новую версию, запись журнала аудита и событие
+// subscription.updated в той же транзакции
+func (s *CatalogService) UpdateCatalogServiceByID(ctx context.Context, service *model.CatalogService, id int) error {
+	normalizeCatalogService(service)
+	if err := ValidateCatalogService(service); err != nil {
+		return util.LogError("сервис каталога не прошел проверку", err)
+	}
+
+	renamed := 0
+	err := s.subscriptions.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		if err := s.CatalogRepository.UpdateCatalogServiceByID(ctx, uow, service, id); err != nil {
+			return err
+		}
+
+		before, err := s.CatalogRepository.LockServiceSubscriptions(ctx, uow, id, service.Name)
+		if err != nil {
+			return err
+		}
+		if len(before) == 0 {
+			return nil
+		}
+		after, err := s.CatalogRepository.RenameServiceSubscriptions(ctx, uow, id, service.Name)
+		if err != nil {
+			return err
+		}
+
+		previous := make(map[int]*model.SubscriptionDetails, len(before))
+		for i := range before {
+			previous[before[i].ID] = &before[i]
+		}
+		for i := range after {
+			if err := s.subscriptions.recordChange(ctx, uow, model.AuditActionUpdate, after[i].ID, previous[after[i].ID], &after[i]); err != nil {
+				return err
+			}
+		}
+		renamed = len(after)
+		return nil
+	})
+	if err != nil {
+		return util.LogError("не удалось обновить сервис каталога", err)
+	}
+
+	log.Printf("сервис каталога с id=%d обновлен: %v, переименован в %d подписках", id, service, renamed)
+	return nil
+}
+
+func (s *CatalogService) DeleteCatalogServiceByID(ctx context.Context, id int) error {
+	if err := s.CatalogRepository.DeleteCatalogServiceByID(ctx, s.Database, id); err != nil {
+		return util.LogError("не удалось удалить сервис из каталога", err)
+	}
+
+	log.Printf("сервис с id=%d удален из каталога", id)
+	return nil
+}
+
+// resolveCatalogService связывает подписку с сервисом каталога и подставляет его
+// каноническое название. ServiceID, если задан, важнее ServiceName; название, которого
+// нет среди написаний каталога, добавляется в каталог как новый сервис
+func (s *SubscriptionService) resolveCatalogService(ctx context.Context, exec sqlx.ExtContext, subscription *model.SubscriptionDetails) error {
+	if subscription.ServiceID != 0 {
+		service, err := s.catalog.GetCatalogServiceByID(ctx, exec, subscription.ServiceID)
+		if errors.Is(err, model.ErrNotFound) {
+			var errs model.ValidationErrors
+			errs.Add("service_id", "unknown_service", "сервис не найден в каталоге")
+			return errs
+		}
+		if err != nil {
+			return err
+		}
+		subscription.ServiceName = service.Name
+		return nil
+	}
+
+	if err := s.catalog.LockCatalogServiceName(ctx, exec, subscription.ServiceName); err != nil {
+		return err
+	}
+	service, err := s.catalog.FindCatalogServiceByName(ctx, exec, subscription.ServiceName)
+	if errors.Is(err, model.ErrNotFound) {
+		service = &model.CatalogService{
+			Name:     strings.TrimSpace(subscription.ServiceName),
+			Currency: subscription.Currency,
+		}
+		if err := s.catalog.SaveCatalogService(ctx, exec, service); err != nil {
+			return err
+		}
+		log.Printf("сервис %q добавлен в каталог с id=%d", service.Name, service.ID)
+	} else if err != nil {
+		return err
+	}
+
+	subscription.ServiceID = service.ID
+	subscription.ServiceName = service.Name
+	return nil
+}
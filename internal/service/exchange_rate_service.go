@@ -0,0 +1,131 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exchangeRatesFileHeader - обязательная первая строка CSV-файла курсов
+var exchangeRatesFileHeader = []string{"rate_date", "base_currency", "quote_currency", "rate"}
+
+// ExchangeRateService
+// Справочник валют и курсов поверх репозитория Postgres или хранилища, которое
+// ведет справочник само
+type ExchangeRateService struct {
+	repository.ExchangeRateStore
+}
+
+func NewExchangeRateService(store repository.ExchangeRateStore) *ExchangeRateService {
+	return &ExchangeRateService{store}
+}
+
+// SaveExchangeRates проверяет все курсы и сохраняет их одной транзакцией:
+// либо записываются все, либо ни одного
+func (s *ExchangeRateService) SaveExchangeRates(ctx context.Context, rates []model.ExchangeRate) error {
+	for i := range rates {
+		rates[i].BaseCurrency = strings.ToUpper(strings.TrimSpace(rates[i].BaseCurrency))
+		rates[i].QuoteCurrency = strings.ToUpper(strings.TrimSpace(rates[i].QuoteCurrency))
+	}
+	if err := ValidateExchangeRates(rates); err != nil {
+		return util.LogError("курсы валют не прошли проверку", err)
+	}
+
+	err := s.InTransaction(ctx, func(exec sqlx.ExtContext) error {
+		return s.ExchangeRateStore.SaveExchangeRates(ctx, exec, rates)
+	})
+	if err != nil {
+		return util.LogError("не удалось сохранить курсы валют", err)
+	}
+
+	log.Printf("сохранено курсов валют: %d", len(rates))
+	return nil
+}
+
+// LoadExchangeRatesFile загружает курсы из CSV-файла с заголовком
+// rate_date,base_currency,quote_currency,rate; даты в формате DD-MM-YYYY
+func (s *ExchangeRateService) LoadExchangeRatesFile(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return util.LogError("не удалось открыть файл курсов валют", err)
+	}
+	defer file.Close()
+
+	rates, err := parseExchangeRatesCSV(file)
+	if err != nil {
+		return util.LogError("не удалось прочитать файл курсов валют "+path, err)
+	}
+
+	return s.SaveExchangeRates(ctx, rates)
+}
+
+func (s *ExchangeRateService) GetExchangeRates(ctx context.Context, filter model.ExchangeRateFilter) ([]model.ExchangeRate, error) {
+	rates, err := s.ExchangeRateStore.GetExchangeRates(ctx, s.Executor(), filter)
+	if err != nil {
+		return nil, util.LogError("не удалось получить курсы валют", err)
+	}
+	return rates, nil
+}
+
+func (s *ExchangeRateService) GetCurrencies(ctx context.Context) ([]model.Currency, error) {
+	currencies, err := s.ExchangeRateStore.GetCurrencies(ctx, s.Executor())
+	if err != nil {
+		return nil, util.LogError("не удалось получить справочник валют", err)
+	}
+	return currencies, nil
+}
+
+func parseExchangeRatesCSV(r io.Reader) ([]model.ExchangeRate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(exchangeRatesFileHeader)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать заголовок: %w", err)
+	}
+	for i, name := range exchangeRatesFileHeader {
+		if strings.TrimSpace(header[i]) != name {
+			return nil, fmt.Errorf("ожидался заголовок %s", strings.Join(exchangeRatesFileHeader, ","))
+		}
+	}
+
+	var rates []model.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		rateDate, err := time.Parse("02-01-2006", record[0])
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: неверная дата %q", line, record[0])
+		}
+		rate, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("строка %d: неверный курс %q", line, record[3])
+		}
+
+		rates = append(rates, model.ExchangeRate{
+			BaseCurrency:  record[1],
+			QuoteCurrency: record[2],
+			RateDate:      model.DayMonthYear(rateDate),
+			Rate:          rate,
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExchangeRatesCSV(t *testing.T) {
+	file := "rate_date,base_currency,quote_currency,rate\n" +
+		"01-01-2025,USD,RUB,92.5\n" +
+		"02-01-2025, EUR,RUB,100.25\n"
+
+	rates, err := parseExchangeRatesCSV(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("parseExchangeRatesCSV: %v", err)
+	}
+	want := []model.ExchangeRate{
+		{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)), Rate: 92.5},
+		{BaseCurrency: "EUR", QuoteCurrency: "RUB", RateDate: model.DayMonthYear(time.Date(2025, time.January, 2, 0, 0, 0, 0, time.UTC)), Rate: 100.25},
+	}
+	if !reflect.DeepEqual(rates, want) {
+		t.Errorf("курсы %+v, ожидались %+v", rates, want)
+	}
+}
+
+func TestParseExchangeRatesCSVErrors(t *testing.T) {
+	const header = "rate_date,base_currency,quote_currency,rate\n"
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{name: "пустой файл", file: "", want: "заголовок"},
+		{name: "чужой заголовок", file: "date,from,to,rate\n", want: "ожидался заголовок"},
+		{name: "неверная дата", file: header + "2025-01-01,USD,RUB,92.5\n", want: "строка 2: неверная дата"},
+		{name: "неверный курс", file: header + "01-01-2025,USD,RUB,много\n", want: "строка 2: неверный курс"},
+		{name: "лишнее поле", file: header + "01-01-2025,USD,RUB,92.5,1\n", want: "wrong number of fields"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := parseExchangeRatesCSV(strings.NewReader(test.file))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("ошибка %v, ожидалось упоминание %q", err, test.want)
+			}
+		})
+	}
+}
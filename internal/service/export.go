@@ -0,0 +1,215 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы выгрузки подписок
+const (
+	ExportFormatCSV   = "csv"
+	ExportFormatJSONL = "jsonl"
+	ExportFormatXLSX  = "xlsx"
+)
+
+// ExportContentTypes сопоставляет форматы выгрузки с Content-Type ответа
+var ExportContentTypes = map[string]string{
+	ExportFormatCSV:   "text/csv; charset=utf-8",
+	ExportFormatJSONL: "application/x-ndjson",
+	ExportFormatXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// SubscriptionWriter
+// Построчная запись выгрузки подписок. Close дописывает то, что формат требует в конце
+// файла; сам w не закрывает
+type SubscriptionWriter interface {
+	Write(subscription *model.SubscriptionDetails) error
+	Close() error
+}
+
+// NewSubscriptionWriter возвращает запись выгрузки в формате format поверх w
+func NewSubscriptionWriter(format string, w io.Writer) (SubscriptionWriter, error) {
+	switch format {
+	case ExportFormatCSV:
+		return newCSVSubscriptionWriter(w)
+	case ExportFormatJSONL:
+		return &jsonlSubscriptionWriter{encoder: json.NewEncoder(w)}, nil
+	case ExportFormatXLSX:
+		return newXLSXSubscriptionWriter(w)
+	}
+	return nil, fmt.Errorf("формат выгрузки %q не поддерживается", format)
+}
+
+// exportPageSize - сколько подписок выгрузки читается из хранилища за один запрос
+const exportPageSize = model.MaxListLimit
+
+// ExportSubscriptions передает fn по одной все подписки, подходящие под фильтр списка,
+// в порядке его сортировки. Подписки читаются страницами по курсору списка, каждая
+// отдельным коротким запросом вне транзакции, поэтому выгрузка любого размера занимает
+// постоянную память и не держит соединение, блокировку или снимок базы, пока клиент
+// читает ответ. Выгрузка не снимок: подписку, измененную во время выгрузки, fn получает
+// в состоянии на момент чтения ее страницы. Курсор базы на выделенном соединении дал бы
+// снимок, но держал бы соединение и снимок, пока клиент читает ответ, а у SQLite
+// соединение одно. Ошибка fn прерывает выгрузку
+func (s *SubscriptionService) ExportSubscriptions(
+	ctx context.Context,
+	filter model.SubscriptionListFilter,
+	fn func(subscription *model.SubscriptionDetails) error,
+) error {
+	filter.Limit = exportPageSize
+	filter.After = nil
+	exported := 0
+	for {
+		page, err := s.SubscriptionStore.ListSubscriptions(ctx, s.Executor(), filter)
+		if err != nil {
+			return util.LogError("не удалось выгрузить подписки", err)
+		}
+		for i := range page.Items {
+			if err := fn(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+		exported += len(page.Items)
+		if page.NextCursor == "" {
+			break
+		}
+		if filter.After, err = model.DecodeListCursor(page.NextCursor); err != nil {
+			return util.LogError("не удалось выгрузить подписки", err)
+		}
+	}
+
+	log.Printf("выгружено подписок: %d", exported)
+	return nil
+}
+
+// exportColumns - колонки табличных форматов выгрузки. Колонки, которые принимает импорт,
+// называются как в нем, поэтому выгрузку в CSV можно импортировать обратно
+var exportColumns = []struct {
+	name  string
+	value func(subscription *model.SubscriptionDetails) any
+}{
+	{"id", func(subscription *model.SubscriptionDetails) any { return subscription.ID }},
+	{"service_id", func(subscription *model.SubscriptionDetails) any { return subscription.ServiceID }},
+	{"service_name", func(subscription *model.SubscriptionDetails) any { return exportText(subscription.ServiceName) }},
+	{"category", func(subscription *model.SubscriptionDetails) any { return exportOptionalText(subscription.Category) }},
+	{"tags", func(subscription *model.SubscriptionDetails) any { return exportTags(subscription.Tags) }},
+	{"price", func(subscription *model.SubscriptionDetails) any { return subscription.Price }},
+	{"currency", func(subscription *model.SubscriptionDetails) any { return subscription.Currency }},
+	{"billing_period", func(subscription *model.SubscriptionDetails) any { return subscription.BillingPeriod }},
+	{"billing_interval", func(subscription *model.SubscriptionDetails) any { return subscription.BillingInterval }},
+	{"user_id", func(subscription *model.SubscriptionDetails) any { return subscription.UserID }},
+	{"start_date", func(subscription *model.SubscriptionDetails) any { return exportDate(&subscription.StartDate) }},
+	{"end_date", func(subscription *model.SubscriptionDetails) any { return exportDate(&subscription.EndDate) }},
+	{"trial_end", func(subscription *model.SubscriptionDetails) any { return exportDate(subscription.TrialEnd) }},
+	{"status", func(subscription *model.SubscriptionDetails) any { return subscription.Status }},
+	{"cancelled_at", func(subscription *model.SubscriptionDetails) any { return exportTime(subscription.CancelledAt) }},
+	{"version", func(subscription *model.SubscriptionDetails) any { return subscription.Version }},
+}
+
+// formulaPrefixes - символы, с которых табличные редакторы начинают формулу
+const formulaPrefixes = "=+-@\t\r"
+
+// exportText экранирует текст, который ввел пользователь: значение, которое табличный
+// редактор принял бы за формулу, выгружается с апострофом в начале и открывается как
+// текст. Импорт снимает этот апостроф, поэтому выгрузка импортируется обратно без изменений
+func exportText(value string) string {
+	if value != "" && strings.IndexByte(formulaPrefixes, value[0]) >= 0 {
+		return "'" + value
+	}
+	return value
+}
+
+// importText снимает апостроф, которым exportText экранировал формулу
+func importText(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.IndexByte(formulaPrefixes, value[1]) >= 0 {
+		return value[1:]
+	}
+	return value
+}
+
+// exportOptionalText экранирует текст как exportText; незаданный текст записывается
+// пустой строкой
+func exportOptionalText(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return exportText(*value)
+}
+
+// exportTags записывает теги через точку с запятой: запятая разделяет колонки CSV
+func exportTags(tags model.Tags) string {
+	return exportText(strings.Join(tags, ";"))
+}
+
+// exportDate записывает дату в формате API; незаданная дата записывается пустой строкой
+func exportDate(date *model.DayMonthYear) string {
+	if date == nil || date.ToTime().IsZero() {
+		return ""
+	}
+	return date.ToTime().Format("02-01-2006")
+}
+
+func exportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// csvSubscriptionWriter пишет подписки в CSV с заголовком из exportColumns
+type csvSubscriptionWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVSubscriptionWriter(w io.Writer) (*csvSubscriptionWriter, error) {
+	header := make([]string, 0, len(exportColumns))
+	for _, column := range exportColumns {
+		header = append(header, column.name)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvSubscriptionWriter{writer: writer, record: make([]string, len(exportColumns))}, nil
+}
+
+func (w *csvSubscriptionWriter) Write(subscription *model.SubscriptionDetails) error {
+	for i, column := range exportColumns {
+		switch value := column.value(subscription).(type) {
+		case int:
+			w.record[i] = strconv.Itoa(value)
+		case string:
+			w.record[i] = value
+		}
+	}
+	return w.writer.Write(w.record)
+}
+
+func (w *csvSubscriptionWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// jsonlSubscriptionWriter пишет подписки в JSON Lines в том же виде, что и API
+type jsonlSubscriptionWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlSubscriptionWriter) Write(subscription *model.SubscriptionDetails) error {
+	return w.encoder.Encode(subscription)
+}
+
+func (w *jsonlSubscriptionWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportSubscriptions(t *testing.T) {
+	s, video := newMemoryService(t)
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	// больше двух страниц выгрузки, с повторяющимися ценами, чтобы порядок решал id
+	const userSubscriptions = 2*exportPageSize + 7
+	for i := 0; i < userSubscriptions; i++ {
+		saveTestSubscription(t, s, video, testUserA, start.AddDate(0, 0, i%40), 100+i%13)
+	}
+	saveTestSubscription(t, s, video, testUserB, start, 500)
+
+	tests := []struct {
+		name   string
+		filter model.SubscriptionListFilter
+		want   int
+		less   func(a, b *model.SubscriptionDetails) bool
+	}{
+		{
+			name:   "все по id",
+			filter: model.SubscriptionListFilter{Sort: "id"},
+			want:   userSubscriptions + 1,
+			less:   func(a, b *model.SubscriptionDetails) bool { return a.ID < b.ID },
+		},
+		{
+			name:   "пользователь по убыванию цены",
+			filter: model.SubscriptionListFilter{UserID: ptr(testUserA), Sort: "price", Desc: true, Limit: 3},
+			want:   userSubscriptions,
+			less: func(a, b *model.SubscriptionDetails) bool {
+				return a.Price > b.Price || (a.Price == b.Price && a.ID > b.ID)
+			},
+		},
+		{
+			name:   "пустая выгрузка",
+			filter: model.SubscriptionListFilter{UserID: ptr(testUserB), Sort: "id", PriceMax: ptr(1)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var exported []model.SubscriptionDetails
+			err := s.ExportSubscriptions(context.Background(), test.filter, func(subscription *model.SubscriptionDetails) error {
+				exported = append(exported, *subscription)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("не удалось выгрузить подписки: %v", err)
+			}
+			if len(exported) != test.want {
+				t.Fatalf("выгружено %d подписок, ожидалось %d", len(exported), test.want)
+			}
+
+			seen := make(map[int]bool, len(exported))
+			for i := range exported {
+				if seen[exported[i].ID] {
+					t.Fatalf("подписка id=%d выгружена дважды", exported[i].ID)
+				}
+				seen[exported[i].ID] = true
+				if i > 0 && !test.less(&exported[i-1], &exported[i]) {
+					t.Fatalf("нарушен порядок выгрузки между id=%d и id=%d", exported[i-1].ID, exported[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestExportSubscriptionsStops(t *testing.T) {
+	s, video := newMemoryService(t)
+	for i := 0; i < exportPageSize+1; i++ {
+		saveTestSubscription(t, s, video, testUserA, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), 100)
+	}
+
+	stop := errors.New("остановка выгрузки")
+	calls := 0
+	err := s.ExportSubscriptions(context.Background(), model.SubscriptionListFilter{Sort: "id"}, func(*model.SubscriptionDetails) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ошибка fn должна прервать выгрузку: вызовов %d, ошибка %v", calls, err)
+	}
+}
+
+func TestExportSubscriptionsDoesNotBlockWrites(t *testing.T) {
+	s, video := newMemoryService(t)
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	saveTestSubscription(t, s, video, testUserA, start, 100)
+
+	// пока клиент читает выгрузку, хранилище должно принимать изменения
+	err := s.ExportSubscriptions(context.Background(), model.SubscriptionListFilter{Sort: "id"}, func(*model.SubscriptionDetails) error {
+		saved := make(chan error, 1)
+		go func() {
+			saved <- s.SubscriptionStore.SaveSubscription(context.Background(), s.Executor(), &model.SubscriptionDetails{
+				ServiceID: video.ID, Tags: model.Tags{}, Price: 200, Currency: "RUB",
+				BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1,
+				UserID: testUserB, StartDate: model.DayMonthYear(start),
+			})
+		}()
+		select {
+		case err := <-saved:
+			return err
+		case <-time.After(5 * time.Second):
+			return errors.New("запись в хранилище заблокирована выгрузкой")
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCSVExportImportRoundTrip(t *testing.T) {
+	category := "music"
+	trialEnd := model.DayMonthYear(time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC))
+	subscriptions := []model.SubscriptionDetails{
+		{
+			ID: 7, ServiceID: 3, ServiceName: "Музыка, семейная", Category: &category, Tags: model.Tags{"a", "b"},
+			Price: 299, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: testUserA,
+			StartDate: model.DayMonthYear(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)),
+			EndDate:   model.DayMonthYear(time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC)),
+			TrialEnd:  &trialEnd, Status: "active", Version: 2,
+		},
+		{
+			ID: 8, ServiceID: 4, ServiceName: `Видео "Плюс"`, Tags: model.Tags{"семья"}, Price: 5, Currency: "USD",
+			BillingPeriod: model.BillingPeriodWeek, BillingInterval: 2, UserID: testUserB,
+			StartDate: model.DayMonthYear(time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)),
+			Status:    "active", Version: 1,
+		},
+		{
+			ID: 9, ServiceID: 5, ServiceName: "=1+2", Category: ptr("-скидки"), Tags: model.Tags{"@дом", "+1"},
+			Price: 100, Currency: "RUB", BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: testUserA,
+			StartDate: model.DayMonthYear(time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)),
+			Status:    "active", Version: 1,
+		},
+	}
+
+	var file strings.Builder
+	writer, err := NewSubscriptionWriter(ExportFormatCSV, &file)
+	if err != nil {
+		t.Fatalf("NewSubscriptionWriter: %v", err)
+	}
+	for i := range subscriptions {
+		if err := writer.Write(&subscriptions[i]); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// табличный редактор открывает значения, похожие на формулы, как текст
+	if !strings.Contains(file.String(), `9,5,'=1+2,'-скидки,'@дом;+1,100,`) {
+		t.Errorf("формулы в выгрузке не экранированы:\n%s", file.String())
+	}
+
+	// импорт пропускает колонки, которые назначает хранилище, и снимает экранирование формул
+	rows, err := ParseSubscriptionsCSV(strings.NewReader(file.String()))
+	if err != nil {
+		t.Fatalf("выгрузка не импортируется: %v", err)
+	}
+	if len(rows) != len(subscriptions) {
+		t.Fatalf("импортировано строк %d, ожидалось %d", len(rows), len(subscriptions))
+	}
+	for i, row := range rows {
+		want := subscriptions[i]
+		want.ID, want.Status, want.Version = 0, "", 0
+		if len(row.Errors) > 0 || !reflect.DeepEqual(row.Subscription, want) {
+			t.Errorf("строка %d: %+v, ошибки %v, ожидалось %+v", row.Line, row.Subscription, row.Errors, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxICSLineOctets - сколько октетов RFC 5545 допускает в строке календаря без CRLF
+const maxICSLineOctets = 75
+
+// icsEscaper экранирует значения типа TEXT по RFC 5545
+var icsEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`;`, `\;`,
+	`,`, `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// icsWriter собирает календарь iCalendar: строки заканчиваются CRLF, длинные строки
+// переносятся с пробелом в начале продолжения, не разрывая символы UTF-8
+type icsWriter struct {
+	buffer bytes.Buffer
+}
+
+// property записывает свойство name со значением value как есть: даты, RRULE и
+// другие значения, которые не экранируются
+func (w *icsWriter) property(name, value string) {
+	line := name + ":" + value
+	for len(line) > maxICSLineOctets {
+		cut := maxICSLineOctets
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.buffer.WriteString(line[:cut])
+		w.buffer.WriteString("\r\n")
+		line = " " + line[cut:]
+	}
+	w.buffer.WriteString(line)
+	w.buffer.WriteString("\r\n")
+}
+
+// text записывает свойство с текстовым значением, экранируя его
+func (w *icsWriter) text(name, value string) {
+	w.property(name, icsEscaper.Replace(value))
+}
+
+func (w *icsWriter) Bytes() []byte {
+	return w.buffer.Bytes()
+}
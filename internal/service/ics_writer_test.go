@@ -0,0 +1,79 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// unfoldICS склеивает перенесенные строки календаря обратно, как это делает клиент
+func unfoldICS(calendar string) []string {
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(calendar, "\r\n ", ""), "\r\n"), "\r\n")
+}
+
+func TestICSWriterFolding(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		lines int
+	}{
+		{name: "короткая строка", value: "VCALENDAR", lines: 1},
+		{name: "ровно 75 октетов", value: strings.Repeat("a", maxICSLineOctets-len("X:")), lines: 1},
+		{name: "76 октетов", value: strings.Repeat("a", maxICSLineOctets-len("X:")+1), lines: 2},
+		{name: "длинная ASCII-строка", value: strings.Repeat("0123456789", 30), lines: 5},
+		{name: "кириллица", value: strings.Repeat("Подписка ", 20), lines: 5},
+		{name: "эмодзи на границе переноса", value: "a" + strings.Repeat("🎬", 40), lines: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calendar icsWriter
+			calendar.property("X", test.value)
+			written := string(calendar.Bytes())
+
+			if !strings.HasSuffix(written, "\r\n") {
+				t.Fatalf("строка %q не заканчивается CRLF", written)
+			}
+			lines := strings.Split(strings.TrimSuffix(written, "\r\n"), "\r\n")
+			if len(lines) != test.lines {
+				t.Errorf("строк %d, ожидалось %d: %q", len(lines), test.lines, written)
+			}
+			for i, line := range lines {
+				if len(line) > maxICSLineOctets {
+					t.Errorf("строка %d длиной %d октетов", i, len(line))
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("перенос разорвал символ UTF-8 в строке %d: %q", i, line)
+				}
+				if i > 0 && !strings.HasPrefix(line, " ") {
+					t.Errorf("продолжение %d не начинается с пробела: %q", i, line)
+				}
+			}
+			if unfolded := unfoldICS(written); len(unfolded) != 1 || unfolded[0] != "X:"+test.value {
+				t.Errorf("после склейки %q, ожидалось %q", unfolded, "X:"+test.value)
+			}
+		})
+	}
+}
+
+func TestICSWriterText(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"Оплата Видео", `Оплата Видео`},
+		{"кино; сериалы, музыка", `кино\; сериалы\, музыка`},
+		{`C:\путь`, `C:\\путь`},
+		{"Цена: 499\nПодписка id=7", `Цена: 499\nПодписка id=7`},
+		{"a\r\nb\rc", `a\nb\nc`},
+		{`\;`, `\\\;`},
+	}
+
+	for _, test := range tests {
+		var calendar icsWriter
+		calendar.text("SUMMARY", test.value)
+		if got := string(calendar.Bytes()); got != "SUMMARY:"+test.want+"\r\n" {
+			t.Errorf("text(%q) = %q, ожидалось %q", test.value, got, "SUMMARY:"+test.want+"\r\n")
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/util"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxImportRows - сколько подписок можно импортировать одним запросом
+const MaxImportRows = 10000
+
+// errImportRolledBack откатывает транзакцию импорта после пробного запуска или строки,
+// которую отклонило хранилище
+var errImportRolledBack = errors.New("импорт подписок откатывается")
+
+// importColumns разбирают значения колонок CSV-файла импорта. Колонки называются как поля
+// JSON подписки; пустое значение оставляет поле незаданным
+var importColumns = map[string]func(subscription *model.SubscriptionDetails, value string) error{
+	"service_id": func(subscription *model.SubscriptionDetails, value string) error {
+		return parseImportInt(&subscription.ServiceID, value)
+	},
+	"service_name": func(subscription *model.SubscriptionDetails, value string) error {
+		subscription.ServiceName = importText(value)
+		return nil
+	},
+	"category": func(subscription *model.SubscriptionDetails, value string) error {
+		category := importText(value)
+		subscription.Category = &category
+		return nil
+	},
+	"tags": func(subscription *model.SubscriptionDetails, value string) error {
+		// запятая разделяет колонки, поэтому теги перечисляются через точку с запятой
+		subscription.Tags = strings.Split(importText(value), ";")
+		return nil
+	},
+	"price": func(subscription *model.SubscriptionDetails, value string) error {
+		return parseImportInt(&subscription.Price, value)
+	},
+	"currency": func(subscription *model.SubscriptionDetails, value string) error {
+		subscription.Currency = value
+		return nil
+	},
+	"billing_period": func(subscription *model.SubscriptionDetails, value string) error {
+		subscription.BillingPeriod = value
+		return nil
+	},
+	"billing_interval": func(subscription *model.SubscriptionDetails, value string) error {
+		return parseImportInt(&subscription.BillingInterval, value)
+	},
+	"user_id": func(subscription *model.SubscriptionDetails, value string) error {
+		subscription.UserID = value
+		return nil
+	},
+	"start_date": func(subscription *model.SubscriptionDetails, value string) error {
+		return parseImportDate(&subscription.StartDate, value)
+	},
+	"end_date": func(subscription *model.SubscriptionDetails, value string) error {
+		return parseImportDate(&subscription.EndDate, value)
+	},
+	"trial_end": func(subscription *model.SubscriptionDetails, value string) error {
+		subscription.TrialEnd = new(model.DayMonthYear)
+		return parseImportDate(subscription.TrialEnd, value)
+	},
+}
+
+// importSkippedColumns - колонки выгрузки, которые импорт пропускает: их значения
+// назначает хранилище, поэтому выгрузку в CSV можно импортировать обратно
+var importSkippedColumns = map[string]bool{
+	"id":           true,
+	"status":       true,
+	"cancelled_at": true,
+	"version":      true,
+}
+
+func parseImportInt(target *int, value string) error {
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.New("ожидается целое число")
+	}
+	*target = number
+	return nil
+}
+
+func parseImportDate(target *model.DayMonthYear, value string) error {
+	date, err := time.Parse("02-01-2006", value)
+	if err != nil {
+		return errors.New("ожидается дата в формате DD-MM-YYYY")
+	}
+	*target = model.DayMonthYear(date)
+	return nil
+}
+
+// ParseSubscriptionsCSV читает подписки из CSV с заголовком. Ошибки формата значений
+// записываются в строки, а ошибка всего файла - неизвестная колонка, нарушенные кавычки,
+// больше MaxImportRows подписок - возвращается с текстом для клиента
+func ParseSubscriptionsCSV(r io.Reader) ([]model.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("файл пуст, ожидается заголовок с колонками подписки")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать заголовок: %w", err)
+	}
+	seen := map[string]bool{}
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if i == 0 {
+			// Excel сохраняет CSV в UTF-8 с BOM
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		if importColumns[column] == nil && !importSkippedColumns[column] {
+			return nil, fmt.Errorf("неизвестная колонка %q", column)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("колонка %q указана дважды", column)
+		}
+		seen[column] = true
+		header[i] = column
+	}
+
+	var rows []model.ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		// после ошибки разбора строки позиции ее полей недоступны
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			return nil, err
+		}
+		if len(rows) == MaxImportRows {
+			return nil, fmt.Errorf("в файле больше %d подписок", MaxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := model.ImportRow{Line: line}
+		if errors.Is(err, csv.ErrFieldCount) {
+			row.Errors.Add("columns", "field_count",
+				fmt.Sprintf("в строке %d значений вместо %d", len(record), len(header)))
+		} else {
+			for i, value := range record {
+				if value = strings.TrimSpace(value); value == "" || importSkippedColumns[header[i]] {
+					continue
+				}
+				if err := importColumns[header[i]](&row.Subscription, value); err != nil {
+					row.Errors.Add(header[i], "invalid_format", err.Error())
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// ParseSubscriptionsJSONL читает подписки из JSON Lines: по одному объекту подписки,
+// как в теле POST /subscriptions/create, на строку. Пустые строки пропускаются
+func ParseSubscriptionsJSONL(r io.Reader) ([]model.ImportRow, error) {
+	reader := bufio.NewReader(r)
+
+	var rows []model.ImportRow
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			if len(rows) == MaxImportRows {
+				return nil, fmt.Errorf("в файле больше %d подписок", MaxImportRows)
+			}
+
+			row := model.ImportRow{Line: line}
+			if unmarshalErr := json.Unmarshal(data, &row.Subscription); unmarshalErr != nil {
+				row.Subscription = model.SubscriptionDetails{}
+				row.Errors.Add("json", "invalid_json", "строка не разбирается как подписка: "+unmarshalErr.Error())
+			}
+			rows = append(rows, row)
+		}
+
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+	}
+}
+
+// ImportSubscriptions проверяет и создает подписки из файла импорта так же, как
+// CreateSubscription, но одной транзакцией: либо создаются все подписки, либо ни одной.
+// Сначала проверяются все строки и собираются все нарушения; если их нет, подписки
+// записываются по порядку, и первая строка, которую отклонило хранилище (например,
+// неизвестная валюта), откатывает импорт. С dryRun транзакция откатывается и после
+// успешной записи, поэтому пробный запуск находит те же ошибки, что и настоящий.
+// Нарушения возвращаются в отчете, а не ошибкой
+func (s *SubscriptionService) ImportSubscriptions(ctx context.Context, rows []model.ImportRow, dryRun bool) (*model.ImportReport, error) {
+	report := &model.ImportReport{DryRun: dryRun, Total: len(rows)}
+
+	for i := range rows {
+		row := &rows[i]
+		normalizeSubscription(&row.Subscription)
+		errs := append(model.ValidationErrors{}, row.Errors...)
+		if !unreadableRow(row) {
+			var validationErrors model.ValidationErrors
+			if errors.As(ValidateSubscription(&row.Subscription), &validationErrors) {
+				errs = appendNewFieldErrors(errs, validationErrors)
+			}
+		}
+		row.Subscription.Tags = NormalizeTags(row.Subscription.Tags)
+		if len(errs) > 0 {
+			report.Errors = append(report.Errors, model.ImportLineError{Line: row.Line, Errors: errs})
+		}
+	}
+	if len(report.Errors) > 0 {
+		log.Printf("импорт подписок отклонен: %d из %d строк не прошли проверку", len(report.Errors), len(rows))
+		return report, nil
+	}
+
+	ids := make([]int, 0, len(rows))
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		for i := range rows {
+			err := s.saveNewSubscription(ctx, uow, &rows[i].Subscription)
+			var validationErrors model.ValidationErrors
+			if errors.As(err, &validationErrors) {
+				report.Errors = append(report.Errors, model.ImportLineError{Line: rows[i].Line, Errors: validationErrors})
+				return errImportRolledBack
+			}
+			if err != nil {
+				return util.LogError(fmt.Sprintf("не удалось создать подписку из строки %d", rows[i].Line), err)
+			}
+			ids = append(ids, rows[i].Subscription.ID)
+		}
+		if dryRun {
+			return errImportRolledBack
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRolledBack) {
+		return nil, util.LogError("не удалось импортировать подписки", err)
+	}
+	if len(report.Errors) > 0 {
+		log.Printf("импорт подписок отклонен хранилищем в строке %d", report.Errors[0].Line)
+		return report, nil
+	}
+
+	report.Imported = len(ids)
+	if dryRun {
+		log.Printf("пробный импорт подписок: %d подписок прошли проверку", report.Imported)
+		return report, nil
+	}
+	report.IDs = ids
+	log.Printf("импортировано подписок: %d", report.Imported)
+	return report, nil
+}
+
+// unreadableRow проверяет, что строку не удалось прочитать целиком: ее подписка пуста,
+// и проверять ее поля бессмысленно
+func unreadableRow(row *model.ImportRow) bool {
+	for _, fieldError := range row.Errors {
+		if fieldError.Field == "json" || fieldError.Field == "columns" {
+			return true
+		}
+	}
+	return false
+}
+
+// appendNewFieldErrors добавляет к errs нарушения из more, кроме нарушений полей, уже
+// попавших в errs: значение, которое не удалось разобрать, не нужно еще раз называть пустым
+func appendNewFieldErrors(errs, more model.ValidationErrors) model.ValidationErrors {
+	reported := map[string]bool{}
+	for _, fieldError := range errs {
+		reported[fieldError.Field] = true
+	}
+	for _, fieldError := range more {
+		if !reported[fieldError.Field] {
+			errs = append(errs, fieldError)
+		}
+	}
+	return errs
+}
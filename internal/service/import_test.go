@@ -0,0 +1,273 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/config"
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository/sqlite"
+	"Effective_Mobile_Test_Project/migrations"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSQLiteStore возвращает хранилище SQLite во временном файле с примененными миграциями
+func newSQLiteStore(t *testing.T) *sqlite.Store {
+	t.Helper()
+
+	database, err := config.SetupDatabase(config.DatabaseConfig{
+		Driver: config.DriverSQLite,
+		DSN:    "file:" + filepath.Join(t.TempDir(), "subscriptions.db"),
+	})
+	if err != nil {
+		t.Fatalf("не удалось открыть базу SQLite: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	if err := migrations.RunSQLiteMigrations(database.DB.DB); err != nil {
+		t.Fatalf("ошибка миграции SQLite: %v", err)
+	}
+	return sqlite.NewStore(database)
+}
+
+// importErrorFields сводит нарушения строк импорта к полям по номерам строк
+func importErrorFields(line int, errs []model.FieldError, fields map[int][]string) {
+	for _, fieldError := range errs {
+		fields[line] = append(fields[line], fieldError.Field)
+	}
+}
+
+func TestParseSubscriptionsCSV(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		want   []model.SubscriptionDetails
+		errors map[int][]string
+	}{
+		{
+			name: "заголовок без строк",
+			file: "service_name,price,currency,billing_period,billing_interval,user_id,start_date\n",
+		},
+		{
+			name: "все колонки",
+			file: "service_name,category,tags,price,currency,billing_period,billing_interval,user_id,start_date,end_date,trial_end\n" +
+				"Музыка,music,семья;работа,299,rub,month,1," + testUserA + ",01-02-2025,31-12-2025,15-02-2025\n",
+			want: []model.SubscriptionDetails{{
+				ServiceName:     "Музыка",
+				Category:        ptr("music"),
+				Tags:            model.Tags{"семья", "работа"},
+				Price:           299,
+				Currency:        "rub",
+				BillingPeriod:   "month",
+				BillingInterval: 1,
+				UserID:          testUserA,
+				StartDate:       model.DayMonthYear(time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)),
+				EndDate:         model.DayMonthYear(time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC)),
+				TrialEnd:        ptr(model.DayMonthYear(time.Date(2025, time.February, 15, 0, 0, 0, 0, time.UTC))),
+			}},
+		},
+		{
+			name: "BOM, пробелы и колонки выгрузки",
+			file: "\ufeffid, service_name , price,status,version\n" +
+				"7, Видео ,100,active,3\n",
+			want: []model.SubscriptionDetails{{ServiceName: "Видео", Price: 100}},
+		},
+		{
+			name: "ошибки формата записываются в строки",
+			file: "service_name,price,start_date\n" +
+				"Видео,сто,01-02-2025\n" +
+				"Видео,100,2025-02-01\n" +
+				"Видео,100\n" +
+				"Видео,100,01-02-2025\n",
+			want: []model.SubscriptionDetails{
+				{ServiceName: "Видео", StartDate: model.DayMonthYear(time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC))},
+				{ServiceName: "Видео", Price: 100},
+				{},
+				{ServiceName: "Видео", Price: 100, StartDate: model.DayMonthYear(time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC))},
+			},
+			errors: map[int][]string{2: {"price"}, 3: {"start_date"}, 4: {"columns"}},
+		},
+		{
+			name: "строка в кавычках занимает несколько строк файла",
+			file: "service_name,price\n" +
+				"\"Видео\nсемейное\",100\n" +
+				"Музыка,сто\n",
+			want: []model.SubscriptionDetails{
+				{ServiceName: "Видео\nсемейное", Price: 100},
+				{ServiceName: "Музыка"},
+			},
+			errors: map[int][]string{4: {"price"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := ParseSubscriptionsCSV(strings.NewReader(test.file))
+			if err != nil {
+				t.Fatalf("ParseSubscriptionsCSV: %v", err)
+			}
+
+			var subscriptions []model.SubscriptionDetails
+			errors := map[int][]string{}
+			for _, row := range rows {
+				subscriptions = append(subscriptions, row.Subscription)
+				importErrorFields(row.Line, row.Errors, errors)
+			}
+			if !reflect.DeepEqual(subscriptions, test.want) {
+				t.Errorf("подписки %+v, ожидались %+v", subscriptions, test.want)
+			}
+			if test.errors == nil {
+				test.errors = map[int][]string{}
+			}
+			if !reflect.DeepEqual(errors, test.errors) {
+				t.Errorf("ошибки по строкам %v, ожидались %v", errors, test.errors)
+			}
+		})
+	}
+}
+
+func TestParseSubscriptionsCSVErrors(t *testing.T) {
+	tooMany := "price\n" + strings.Repeat("100\n", MaxImportRows+1)
+
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "пустой файл", file: ""},
+		{name: "неизвестная колонка", file: "service_name,amount\nВидео,100\n"},
+		{name: "колонка дважды", file: "price,price\n100,200\n"},
+		{name: "нарушенные кавычки", file: "service_name,price\n\"Видео,100\n"},
+		{name: "слишком много строк", file: tooMany},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if rows, err := ParseSubscriptionsCSV(strings.NewReader(test.file)); err == nil {
+				t.Errorf("ожидалась ошибка файла, прочитано строк: %d", len(rows))
+			}
+		})
+	}
+}
+
+func TestParseSubscriptionsJSONL(t *testing.T) {
+	file := `{"service_name":"Видео","price":100,"start_date":"01-02-2025"}` + "\n" +
+		"\n" +
+		`{"service_name":"Музыка","price":"сто"}` + "\r\n" +
+		`  {"service_name":"Книги","tags":["a"]}  ` + "\n" +
+		`{"service_name":` + "\n" +
+		`{"service_name":"Кино"}`
+
+	rows, err := ParseSubscriptionsJSONL(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("ParseSubscriptionsJSONL: %v", err)
+	}
+
+	want := []struct {
+		line        int
+		serviceName string
+		errors      []string
+	}{
+		{line: 1, serviceName: "Видео"},
+		{line: 3, errors: []string{"json"}},
+		{line: 4, serviceName: "Книги"},
+		{line: 5, errors: []string{"json"}},
+		{line: 6, serviceName: "Кино"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("прочитано строк %d, ожидалось %d", len(rows), len(want))
+	}
+	for i, row := range rows {
+		errors := map[int][]string{}
+		importErrorFields(row.Line, row.Errors, errors)
+		if row.Line != want[i].line || row.Subscription.ServiceName != want[i].serviceName || !reflect.DeepEqual(errors[row.Line], want[i].errors) {
+			t.Errorf("строка %d: %+v, ошибки %v, ожидались строка %d, сервис %q, ошибки %v",
+				i, row.Subscription, errors[row.Line], want[i].line, want[i].serviceName, want[i].errors)
+		}
+	}
+
+	if _, err := ParseSubscriptionsJSONL(strings.NewReader(strings.Repeat("{}\n", MaxImportRows+1))); err == nil {
+		t.Error("файл больше MaxImportRows подписок должен вернуть ошибку")
+	}
+}
+
+func TestImportSubscriptions(t *testing.T) {
+	const header = "service_name,price,currency,billing_period,billing_interval,user_id,start_date\n"
+	valid := "Видео,100,rub,month,1," + testUserA + ",01-02-2025\n"
+
+	tests := []struct {
+		name     string
+		file     string
+		dryRun   bool
+		imported int
+		stored   int
+		errors   map[int][]string
+	}{
+		{name: "импорт", file: header + valid + valid, imported: 2, stored: 2},
+		{name: "пробный запуск ничего не записывает", file: header + valid + valid, dryRun: true, imported: 2},
+		{
+			name:   "неверная строка отклоняет весь файл",
+			file:   header + valid + "Видео,сто,rub,month,1," + testUserA + ",01-02-2025\n" + ",100,rub,month,-1," + testUserA + ",01-02-2025\n",
+			errors: map[int][]string{3: {"price"}, 4: {"service_name", "billing_interval"}},
+		},
+		{
+			name:   "пробный запуск находит те же нарушения",
+			file:   header + valid + ",100,rub,month,1," + testUserA + ",01-02-2025\n",
+			dryRun: true,
+			errors: map[int][]string{3: {"service_name"}},
+		},
+		{
+			name:   "строка, которую отклонило хранилище",
+			file:   header + valid + "Видео,100,xyz,month,1," + testUserA + ",01-02-2025\n",
+			errors: map[int][]string{3: {"currency"}},
+		},
+		{
+			name:   "непрочитанная строка не проверяется по полям",
+			file:   header + valid + "Видео,100\n",
+			errors: map[int][]string{3: {"columns"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newSQLiteStore(t)
+			s := NewSubscriptionService(store, store, nil, nil, nil, nil, 0)
+
+			rows, err := ParseSubscriptionsCSV(strings.NewReader(test.file))
+			if err != nil {
+				t.Fatalf("ParseSubscriptionsCSV: %v", err)
+			}
+			report, err := s.ImportSubscriptions(ctx, rows, test.dryRun)
+			if err != nil {
+				t.Fatalf("ImportSubscriptions: %v", err)
+			}
+
+			errors := map[int][]string{}
+			for _, lineError := range report.Errors {
+				importErrorFields(lineError.Line, lineError.Errors, errors)
+			}
+			if test.errors == nil {
+				test.errors = map[int][]string{}
+			}
+			if !reflect.DeepEqual(errors, test.errors) {
+				t.Errorf("ошибки по строкам %v, ожидались %v", errors, test.errors)
+			}
+			if report.DryRun != test.dryRun || report.Total != len(rows) || report.Imported != test.imported {
+				t.Errorf("отчет %+v: ожидались dry_run=%v, total=%d, imported=%d", report, test.dryRun, len(rows), test.imported)
+			}
+			if len(report.IDs) != test.stored {
+				t.Errorf("в отчете %d id, ожидалось %d", len(report.IDs), test.stored)
+			}
+
+			subscriptions, err := s.SubscriptionStore.GetSubscriptionsByUserUUID(ctx, s.Executor(), testUserA)
+			if err != nil {
+				t.Fatalf("не удалось прочитать подписки: %v", err)
+			}
+			if len(subscriptions) != test.stored {
+				t.Errorf("в хранилище %d подписок, ожидалось %d", len(subscriptions), test.stored)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"slices"
+)
+
+// lifecycleTransitions перечисляет статусы, из которых разрешен каждый переход.
+// Отмененная и истекшая подписки в жизненном цикле больше не участвуют
+var lifecycleTransitions = map[string][]string{
+	model.AuditActionPause:  {model.StatusActive},
+	model.AuditActionResume: {model.StatusPaused},
+	model.AuditActionCancel: {model.StatusTrial, model.StatusActive, model.StatusPaused},
+}
+
+func (s *SubscriptionService) PauseSubscription(ctx context.Context, id int, expectedVersion *int) (*model.SubscriptionDetails, error) {
+	return s.transition(ctx, id, expectedVersion, model.AuditActionPause, s.SubscriptionStore.PauseSubscription)
+}
+
+func (s *SubscriptionService) ResumeSubscription(ctx context.Context, id int, expectedVersion *int) (*model.SubscriptionDetails, error) {
+	return s.transition(ctx, id, expectedVersion, model.AuditActionResume, s.SubscriptionStore.ResumeSubscription)
+}
+
+func (s *SubscriptionService) CancelSubscription(ctx context.Context, id int, expectedVersion *int) (*model.SubscriptionDetails, error) {
+	return s.transition(ctx, id, expectedVersion, model.AuditActionCancel, s.SubscriptionStore.CancelSubscription)
+}
+
+// transition переводит заблокированную подписку в новое состояние, если переход action
+// разрешен из ее текущего статуса, увеличивает версию и пишет переход в журнал аудита
+func (s *SubscriptionService) transition(
+	ctx context.Context,
+	id int,
+	expectedVersion *int,
+	action string,
+	apply func(ctx context.Context, exec sqlx.ExtContext, id int) error,
+) (*model.SubscriptionDetails, error) {
+	var subscription *model.SubscriptionDetails
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		current, err := s.SubscriptionStore.LockSubscriptionByID(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && *expectedVersion != current.Version {
+			return util.LogError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+		}
+		if !slices.Contains(lifecycleTransitions[action], current.Status) {
+			return util.LogError(fmt.Sprintf("переход %s недоступен из статуса %s", action, current.Status), model.ErrConflict)
+		}
+
+		if err := apply(ctx, uow, id); err != nil {
+			return err
+		}
+		subscription, err = s.SubscriptionStore.IncrementSubscriptionVersion(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+		return s.recordChange(ctx, uow, action, id, current, subscription)
+	})
+	if err != nil {
+		return nil, util.LogError("не удалось изменить статус подписки", err)
+	}
+
+	log.Printf("подписка с id=%d: переход %s, статус %s", id, action, subscription.Status)
+	return subscription, nil
+}
+
+func (s *SubscriptionService) GetSubscriptionPauses(ctx context.Context, id int) ([]model.SubscriptionPause, error) {
+	if _, err := s.SubscriptionStore.GetSubscriptionByID(ctx, s.Executor(), id); err != nil {
+		return nil, util.LogError("не удалось найти подписку", err)
+	}
+
+	pauses, err := s.SubscriptionStore.GetSubscriptionPauses(ctx, s.Executor(), id)
+	if err != nil {
+		return nil, util.LogError("не удалось получить паузы подписки", err)
+	}
+	return pauses, nil
+}
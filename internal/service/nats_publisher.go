@@ -0,0 +1,169 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSEventPublisher публикует события в NATS по текстовому протоколу клиента. Тема
+// события - SubjectPrefix и тип события, например events.subscription.created. После
+// каждой публикации сервер подтверждает ее ответом на PING, поэтому ошибка сервера
+// возвращается вызывающему, и событие публикуется повторно
+type NATSEventPublisher struct {
+	address       string
+	user          *url.Userinfo
+	subjectPrefix string
+	timeout       time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewNATSEventPublisher разбирает адрес вида nats://[user:password@]host:port. Соединение
+// устанавливается при первой публикации и восстанавливается после ошибки
+func NewNATSEventPublisher(serverURL, subjectPrefix string, timeout time.Duration) (*NATSEventPublisher, error) {
+	parsed, err := url.Parse(serverURL)
+	if err != nil || parsed.Scheme != "nats" || parsed.Host == "" {
+		return nil, fmt.Errorf("неверный адрес NATS %q, ожидается nats://host:port", serverURL)
+	}
+
+	address := parsed.Host
+	if parsed.Port() == "" {
+		address = net.JoinHostPort(parsed.Hostname(), "4222")
+	}
+
+	return &NATSEventPublisher{
+		address:       address,
+		user:          parsed.User,
+		subjectPrefix: strings.TrimSuffix(subjectPrefix, "."),
+		timeout:       timeout,
+	}, nil
+}
+
+func (publisher *NATSEventPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+
+	if err := publisher.publish(ctx, event); err != nil {
+		publisher.close()
+		return err
+	}
+	return nil
+}
+
+func (publisher *NATSEventPublisher) publish(ctx context.Context, event model.OutboxEvent) error {
+	if publisher.conn == nil {
+		if err := publisher.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	deadline := time.Now().Add(publisher.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := publisher.conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	subject := event.Type
+	if publisher.subjectPrefix != "" {
+		subject = publisher.subjectPrefix + "." + subject
+	}
+	message := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(event.Payload), event.Payload)
+	if _, err := publisher.conn.Write([]byte(message)); err != nil {
+		return fmt.Errorf("ошибка отправки в NATS: %w", err)
+	}
+	return publisher.awaitPong()
+}
+
+// connect открывает соединение, читает INFO сервера и представляется командой CONNECT
+func (publisher *NATSEventPublisher) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: publisher.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", publisher.address)
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к NATS %s: %w", publisher.address, err)
+	}
+	publisher.conn = conn
+	publisher.reader = bufio.NewReader(conn)
+
+	if err := conn.SetDeadline(time.Now().Add(publisher.timeout)); err != nil {
+		return err
+	}
+	line, err := publisher.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать INFO от NATS: %w", err)
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("неожиданное приветствие NATS: %q", strings.TrimSpace(line))
+	}
+
+	options := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "subscription-service",
+		"lang":     "go",
+		"protocol": 0,
+	}
+	if publisher.user != nil {
+		options["user"] = publisher.user.Username()
+		if password, ok := publisher.user.Password(); ok {
+			options["pass"] = password
+		}
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write([]byte("CONNECT " + string(connect) + "\r\nPING\r\n")); err != nil {
+		return fmt.Errorf("ошибка отправки CONNECT в NATS: %w", err)
+	}
+	return publisher.awaitPong()
+}
+
+// awaitPong читает ответы сервера до PONG; -ERR означает отказ сервера
+func (publisher *NATSEventPublisher) awaitPong() error {
+	for {
+		line, err := publisher.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("не удалось получить подтверждение NATS: %w", err)
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := publisher.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New("NATS отклонил публикацию: " + strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (publisher *NATSEventPublisher) close() {
+	if publisher.conn != nil {
+		_ = publisher.conn.Close()
+		publisher.conn = nil
+		publisher.reader = nil
+	}
+}
+
+// Close закрывает соединение с NATS
+func (publisher *NATSEventPublisher) Close() {
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	publisher.close()
+}
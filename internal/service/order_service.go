@@ -5,30 +5,193 @@ import (
 	"Effective_Mobile_Test_Project/internal/repository"
 	"Effective_Mobile_Test_Project/internal/util"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 )
 
+// SubscriptionService
+// Операции над подписками поверх хранилища SubscriptionStore и каталога сервисов.
+// Журнал аудита, outbox, бюджеты и ключи идемпотентности необязательны: без них
+// сервис работает с одним хранилищем, например repository/memory в тестах
 type SubscriptionService struct {
-	*repository.SubscriptionRepository
+	repository.SubscriptionStore
+	catalog        repository.ServiceCatalog
+	budgets        repository.BudgetStore
+	audit          *repository.AuditRepository
+	outbox         repository.OutboxStore
+	idempotency    repository.IdempotencyStore
+	idempotencyTTL time.Duration
 }
 
-func NewSubscriptionService(repo *repository.SubscriptionRepository) *SubscriptionService {
-	return &SubscriptionService{repo}
+func NewSubscriptionService(
+	store repository.SubscriptionStore,
+	catalog repository.ServiceCatalog,
+	budgets repository.BudgetStore,
+	audit *repository.AuditRepository,
+	outbox repository.OutboxStore,
+	idempotency repository.IdempotencyStore,
+	idempotencyTTL time.Duration,
+) *SubscriptionService {
+	return &SubscriptionService{
+		SubscriptionStore: store,
+		catalog:           catalog,
+		budgets:           budgets,
+		audit:             audit,
+		outbox:            outbox,
+		idempotency:       idempotency,
+		idempotencyTTL:    idempotencyTTL,
+	}
 }
 
-func (s *SubscriptionService) CreateSubscription(ctx context.Context, subscription *model.SubscriptionDetails) error {
-	err := s.SubscriptionRepository.SaveSubscription(ctx, s.Database, subscription)
+// CreateSubscription создает подписку и возвращает предупреждения о бюджетах, которые
+// она превысит
+func (s *SubscriptionService) CreateSubscription(ctx context.Context, subscription *model.SubscriptionDetails) ([]model.BudgetWarning, error) {
+	if err := prepareSubscription(subscription); err != nil {
+		return nil, util.LogError("подписка не прошла проверку", err)
+	}
+
+	var warnings []model.BudgetWarning
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		if err := s.saveNewSubscription(ctx, uow, subscription); err != nil {
+			return err
+		}
+		warnings = s.checkSubscriptionBudgets(ctx, uow, subscription)
+		return nil
+	})
 	if err != nil {
-		return util.LogError("не удалось создать подписку", err)
+		return nil, util.LogError("не удалось создать подписку", err)
 	}
 
 	log.Printf("подписка сохранена, детали подписки: %v", subscription)
-	return nil
+	return warnings, nil
+}
+
+// saveNewSubscription записывает проверенную подписку в единице работы: находит или
+// добавляет ее сервис в каталог, сохраняет подписку и запись журнала аудита
+func (s *SubscriptionService) saveNewSubscription(ctx context.Context, uow *unitOfWork, subscription *model.SubscriptionDetails) error {
+	if err := s.resolveCatalogService(ctx, uow, subscription); err != nil {
+		return err
+	}
+	if err := s.SubscriptionStore.SaveSubscription(ctx, uow, subscription); err != nil {
+		return err
+	}
+	return s.recordChange(ctx, uow, model.AuditActionCreate, subscription.ID, nil, subscription)
+}
+
+// CreateSubscriptionIdempotent создает подписку не более одного раза на ключ идемпотентности.
+// Ключ действует в пределах пользователя подписки, поэтому одинаковые ключи разных
+// пользователей не пересекаются. Ответ строит render после создания подписки, и он
+// сохраняется вместе с ключом: повтор с тем же ключом и тем же запросом возвращает его
+// байт в байт и replayed=true, повтор с другим запросом - model.ErrIdempotencyKeyReused.
+// Запросы сравниваются после разбора и нормализации, поэтому пробелы, порядок полей и
+// регистр валюты на сравнение не влияют
+func (s *SubscriptionService) CreateSubscriptionIdempotent(
+	ctx context.Context,
+	key string,
+	subscription *model.SubscriptionDetails,
+	render func(subscription *model.SubscriptionDetails, warnings []model.BudgetWarning) (*model.StoredResponse, error),
+) (response *model.StoredResponse, replayed bool, err error) {
+	if err := prepareSubscription(subscription); err != nil {
+		return nil, false, util.LogError("подписка не прошла проверку", err)
+	}
+	if s.idempotency == nil {
+		return nil, false, util.LogError("ключи идемпотентности не поддерживаются хранилищем", model.ErrUnavailable)
+	}
+
+	scope, err := repository.CanonicalUUID(subscription.UserID)
+	if err != nil {
+		return nil, false, util.LogError("подписка не прошла проверку", err)
+	}
+	subscription.UserID = scope
+	requestHash, err := subscriptionRequestHash(subscription)
+	if err != nil {
+		return nil, false, util.LogError("не удалось вычислить хеш запроса", err)
+	}
+
+	err = s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		reserved, err := s.idempotency.ReserveKey(ctx, uow, scope, key, requestHash, time.Now().Add(s.idempotencyTTL))
+		if err != nil {
+			return err
+		}
+
+		if !reserved {
+			record, err := s.idempotency.GetRecord(ctx, uow, scope, key)
+			if err != nil {
+				return err
+			}
+			if record.RequestHash != requestHash {
+				return util.LogError("ключ идемпотентности "+key+" получен с другим телом запроса", model.ErrIdempotencyKeyReused)
+			}
+			response, err = storedResponse(record)
+			if err != nil {
+				return util.LogError("не удалось прочитать сохраненный ответ", err)
+			}
+			replayed = true
+			return nil
+		}
+
+		if err := s.saveNewSubscription(ctx, uow, subscription); err != nil {
+			return err
+		}
+		warnings := s.checkSubscriptionBudgets(ctx, uow, subscription)
+
+		response, err = render(subscription, warnings)
+		if err != nil {
+			return util.LogError("не удалось сформировать ответ", err)
+		}
+		headers, err := json.Marshal(response.Header)
+		if err != nil {
+			return util.LogError("не удалось сохранить ответ", err)
+		}
+		return s.idempotency.SaveResponse(ctx, uow, scope, key, response.StatusCode, headers, response.Body)
+	})
+	if err != nil {
+		return nil, false, util.LogError("не удалось создать подписку", err)
+	}
+
+	if replayed {
+		log.Printf("повтор запроса с ключом идемпотентности %s пользователя с uuid=%s", key, scope)
+	} else {
+		log.Printf("подписка сохранена по ключу идемпотентности %s, детали подписки: %v", key, subscription)
+	}
+	return response, replayed, nil
+}
+
+// subscriptionRequestHash вычисляет хеш разобранного и нормализованного запроса на
+// создание подписки: кодирование структуры в JSON не зависит от того, как был записан
+// исходный JSON
+func subscriptionRequestHash(subscription *model.SubscriptionDetails) (string, error) {
+	canonical, err := json.Marshal(subscription)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(canonical)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// storedResponse восстанавливает сохраненный ответ. Ключ, запрос которого еще
+// выполняется, сюда не попадает: ReserveKey дожидается завершения его транзакции
+func storedResponse(record *model.IdempotencyRecord) (*model.StoredResponse, error) {
+	if record.StatusCode == nil {
+		return nil, fmt.Errorf("%w: запрос с ключом %s еще выполняется", model.ErrConflict, record.Key)
+	}
+
+	response := &model.StoredResponse{StatusCode: *record.StatusCode, Body: record.Response}
+	if len(record.ResponseHeaders) > 0 {
+		if err := json.Unmarshal(record.ResponseHeaders, &response.Header); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
 }
 
 func (s *SubscriptionService) GetSubscriptionsByUserUUID(ctx context.Context, uuid_id string) ([]model.SubscriptionDetails, error) {
-	subscriptions, err := s.SubscriptionRepository.GetSubscriptionsByUserUUID(ctx, s.Database, uuid_id)
+	subscriptions, err := s.SubscriptionStore.GetSubscriptionsByUserUUID(ctx, s.Executor(), uuid_id)
 	if err != nil {
 		return nil, util.LogError("не удалось найти подписку", err)
 	}
@@ -37,8 +200,18 @@ func (s *SubscriptionService) GetSubscriptionsByUserUUID(ctx context.Context, uu
 	return subscriptions, nil
 }
 
+func (s *SubscriptionService) ListSubscriptions(ctx context.Context, filter model.SubscriptionListFilter) (*model.SubscriptionPage, error) {
+	page, err := s.SubscriptionStore.ListSubscriptions(ctx, s.Executor(), filter)
+	if err != nil {
+		return nil, util.LogError("не удалось получить список подписок", err)
+	}
+
+	log.Printf("страница списка подписок: %d строк, есть следующая: %t", len(page.Items), page.NextCursor != "")
+	return page, nil
+}
+
 func (s *SubscriptionService) GetSubscriptionByID(ctx context.Context, id int) (*model.SubscriptionDetails, error) {
-	subscription, err := s.SubscriptionRepository.GetSubscriptionByID(ctx, s.Database, id)
+	subscription, err := s.SubscriptionStore.GetSubscriptionByID(ctx, s.Executor(), id)
 	if err != nil {
 		return nil, util.LogError("не удалось найти подписку", err)
 	}
@@ -47,38 +220,220 @@ func (s *SubscriptionService) GetSubscriptionByID(ctx context.Context, id int) (
 	return subscription, nil
 }
 
-func (s *SubscriptionService) GetSubscriptionsCostByUserDetails(
-	ctx context.Context,
-	userID string,
-	serviceName *string,
-	startPeriod time.Time,
-	endPeriod time.Time,
-) (int, error) {
-	totalCost, err := s.SubscriptionRepository.GetTotalSubscriptionCost(ctx, s.Database, userID, serviceName, startPeriod, endPeriod)
+func (s *SubscriptionService) GetSubscriptionsCostByUserDetails(ctx context.Context, filter model.CostFilter) (*model.SubscriptionCost, error) {
+	totalCost, err := s.SubscriptionStore.GetTotalSubscriptionCost(ctx, s.Executor(), filter)
 	if err != nil {
-		return 0, util.LogError("не удалось получить общую стоимость подписок", err)
+		return nil, util.LogError("не удалось получить общую стоимость подписок", err)
 	}
 
-	log.Printf("общая стоимость подписок пользователя с uuid=%s : %d", userID, totalCost)
+	log.Printf("общая стоимость подписок пользователя с uuid=%s : %d за %d мес.", filter.UserID, totalCost.Total, totalCost.BilledMonths)
 	return totalCost, nil
 }
 
-func (s *SubscriptionService) UpdateSubscriptionByID(ctx context.Context, subscription *model.SubscriptionDetails, id int) error {
-	err := s.SubscriptionRepository.UpdateSubscriptionByID(ctx, s.Database, subscription, id)
+// GetSubscriptionsCostGroups делит общую стоимость подписок на группы по разрезу filter.GroupBy
+func (s *SubscriptionService) GetSubscriptionsCostGroups(ctx context.Context, filter model.CostFilter) ([]model.CostGroup, error) {
+	groups, err := s.SubscriptionStore.GetSubscriptionCostGroups(ctx, s.Executor(), filter)
 	if err != nil {
-		return util.LogError("не удалось обновить подписку", err)
+		return nil, util.LogError("не удалось сгруппировать стоимость подписок", err)
 	}
 
-	log.Printf("подписка с id=%d успешно обновлена", subscription.ID)
-	return nil
+	log.Printf("стоимость подписок пользователя с uuid=%s по разрезу %s: %d групп", filter.UserID, filter.GroupBy, len(groups))
+	return groups, nil
+}
+
+func (s *SubscriptionService) GetSubscriptionsCostBreakdown(ctx context.Context, filter model.CostFilter) ([]model.MonthlyCost, error) {
+	breakdown, err := s.SubscriptionStore.GetSubscriptionCostBreakdown(ctx, s.Executor(), filter)
+	if err != nil {
+		return nil, util.LogError("не удалось получить помесячную стоимость подписок", err)
+	}
+
+	log.Printf("помесячная стоимость подписок пользователя с uuid=%s: %d строк", filter.UserID, len(breakdown))
+	return breakdown, nil
+}
+
+// UpdateSubscriptionByID перезаписывает подписку и возвращает предупреждения о бюджетах,
+// которые она превысит после изменения
+func (s *SubscriptionService) UpdateSubscriptionByID(ctx context.Context, subscription *model.SubscriptionDetails, id int, expectedVersion *int) ([]model.BudgetWarning, error) {
+	if err := prepareSubscription(subscription); err != nil {
+		return nil, util.LogError("подписка не прошла проверку", err)
+	}
+
+	var warnings []model.BudgetWarning
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		before, err := s.SubscriptionStore.LockSubscriptionByID(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && *expectedVersion != before.Version {
+			return util.LogError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+		}
+		if err := s.resolveCatalogService(ctx, uow, subscription); err != nil {
+			return err
+		}
+		if err := s.SubscriptionStore.UpdateSubscriptionByID(ctx, uow, subscription, id, expectedVersion); err != nil {
+			return err
+		}
+		if subscription.Price != before.Price {
+			if err := s.correctStartPrice(ctx, uow, subscription); err != nil {
+				return err
+			}
+		}
+		if err := s.recordChange(ctx, uow, model.AuditActionUpdate, id, before, subscription); err != nil {
+			return err
+		}
+		warnings = s.checkSubscriptionBudgets(ctx, uow, subscription)
+		return nil
+	})
+	if err != nil {
+		return nil, util.LogError("не удалось обновить подписку", err)
+	}
+
+	log.Printf("подписка с id=%d успешно обновлена, версия %d", subscription.ID, subscription.Version)
+	return warnings, nil
 }
 
-func (s *SubscriptionService) DeleteSubscriptionByID(ctx context.Context, id int) error {
-	err := s.SubscriptionRepository.DeleteSubscriptionByID(ctx, s.Database, id)
+// PatchSubscriptionByID применяет патч к заблокированной текущей версии подписки и
+// проверяет результат целиком, прежде чем записать изменения. Возвращает подписку после
+// изменения и предупреждения о бюджетах, которые она превысит
+func (s *SubscriptionService) PatchSubscriptionByID(ctx context.Context, id int, patch model.SubscriptionPatch, expectedVersion *int) (*model.SubscriptionDetails, []model.BudgetWarning, error) {
+	if patch.Currency != nil {
+		currency := NormalizeCurrency(*patch.Currency)
+		patch.Currency = &currency
+	}
+	if patch.BillingPeriod != nil {
+		billingPeriod := strings.ToLower(strings.TrimSpace(*patch.BillingPeriod))
+		patch.BillingPeriod = &billingPeriod
+	}
+	if patch.Category != nil {
+		patch.Category = NormalizeCategory(patch.Category)
+		patch.ClearCategory = patch.Category == nil
+	}
+	if patch.Tags != nil {
+		tags := normalizeTagSpelling(*patch.Tags)
+		patch.Tags = &tags
+	}
+
+	var subscription *model.SubscriptionDetails
+	var warnings []model.BudgetWarning
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		current, err := s.SubscriptionStore.LockSubscriptionByID(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+		if expectedVersion != nil && *expectedVersion != current.Version {
+			return util.LogError("подписка была изменена другим запросом", model.ErrVersionMismatch)
+		}
+
+		patched := *current
+		if patch.ServiceName != nil && patch.ServiceID == nil {
+			patched.ServiceID = 0
+		}
+		patch.Apply(&patched)
+		if err := ValidateSubscription(&patched); err != nil {
+			return util.LogError("подписка не прошла проверку", err)
+		}
+		if patch.Tags != nil {
+			tags := NormalizeTags(*patch.Tags)
+			patch.Tags = &tags
+		}
+		if patch.ServiceID != nil || patch.ServiceName != nil {
+			if err := s.resolveCatalogService(ctx, uow, &patched); err != nil {
+				return err
+			}
+			patch.ServiceID, patch.ServiceName = &patched.ServiceID, &patched.ServiceName
+		}
+
+		subscription, err = s.SubscriptionStore.PatchSubscriptionByID(ctx, uow, id, patch, expectedVersion)
+		if err != nil {
+			return err
+		}
+		if subscription.Price != current.Price {
+			if err := s.correctStartPrice(ctx, uow, subscription); err != nil {
+				return err
+			}
+		}
+		if err := s.recordChange(ctx, uow, model.AuditActionUpdate, id, current, subscription); err != nil {
+			return err
+		}
+		warnings = s.checkSubscriptionBudgets(ctx, uow, subscription)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, util.LogError("не удалось частично обновить подписку", err)
+	}
+
+	log.Printf("подписка с id=%d частично обновлена: %v", id, subscription)
+	return subscription, warnings, nil
+}
+
+func (s *SubscriptionService) DeleteSubscriptionByID(ctx context.Context, id int, expectedVersion *int) error {
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		before, err := s.SubscriptionStore.LockSubscriptionByID(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+		if err := s.SubscriptionStore.DeleteSubscriptionByID(ctx, uow, id, expectedVersion); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, uow, model.AuditActionDelete, id, before, nil)
+	})
 	if err != nil {
 		return util.LogError("не удалось удалить подписку", err)
 	}
 
-	log.Printf("подписка с id=%d успешно удалена", id)
+	log.Printf("подписка с id=%d перемещена в удаленные", id)
 	return nil
 }
+
+func (s *SubscriptionService) RestoreSubscriptionByID(ctx context.Context, id int) (*model.SubscriptionDetails, error) {
+	var subscription *model.SubscriptionDetails
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		before, err := s.SubscriptionStore.LockDeletedSubscriptionByID(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+		subscription, err = s.SubscriptionStore.RestoreSubscriptionByID(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+		return s.recordChange(ctx, uow, model.AuditActionRestore, id, before, subscription)
+	})
+	if err != nil {
+		return nil, util.LogError("не удалось восстановить подписку", err)
+	}
+
+	log.Printf("подписка с id=%d восстановлена", id)
+	return subscription, nil
+}
+
+// PurgeDeletedSubscriptions окончательно удаляет подписки, которые пролежали
+// удаленными дольше retention
+func (s *SubscriptionService) PurgeDeletedSubscriptions(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.SubscriptionStore.PurgeDeletedSubscriptions(ctx, s.Executor(), time.Now().Add(-retention))
+	if err != nil {
+		return 0, util.LogError("не удалось очистить удаленные подписки", err)
+	}
+
+	if purged > 0 {
+		log.Printf("окончательно удалено подписок: %d", purged)
+	}
+	return purged, nil
+}
+
+// PurgeExpiredIdempotencyKeys удаляет ключи идемпотентности с истекшим сроком. Без
+// хранилища ключей удалять нечего
+func (s *SubscriptionService) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	if s.idempotency == nil {
+		return 0, nil
+	}
+
+	deleted, err := s.idempotency.DeleteExpiredKeys(ctx, s.Executor(), time.Now())
+	if err != nil {
+		return 0, util.LogError("не удалось удалить просроченные ключи идемпотентности", err)
+	}
+
+	if deleted > 0 {
+		log.Printf("удалено просроченных ключей идемпотентности: %d", deleted)
+	}
+	return deleted, nil
+}
@@ -0,0 +1,367 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository/memory"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testUserA = "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c01"
+	testUserB = "7f3c2a10-5b4e-4d8a-9c61-0e2f4a6b8c02"
+)
+
+// newMemoryService возвращает сервис подписок поверх пустого хранилища в памяти
+// с видеосервисом в каталоге
+func newMemoryService(t *testing.T) (*SubscriptionService, *model.CatalogService) {
+	t.Helper()
+
+	store := memory.NewStore()
+	video := &model.CatalogService{Name: "Тест Видео", Currency: "RUB"}
+	if err := store.SaveCatalogService(context.Background(), store.Executor(), video); err != nil {
+		t.Fatalf("не удалось добавить сервис в каталог: %v", err)
+	}
+	return NewSubscriptionService(store, store, nil, nil, nil, nil, 0), video
+}
+
+// saveTestSubscription сохраняет месячную подписку пользователя в рублях напрямую в хранилище
+func saveTestSubscription(t *testing.T, s *SubscriptionService, service *model.CatalogService, userID string, start time.Time, price int) *model.SubscriptionDetails {
+	t.Helper()
+
+	subscription := &model.SubscriptionDetails{
+		ServiceID:       service.ID,
+		ServiceName:     service.Name,
+		Tags:            model.Tags{},
+		Price:           price,
+		Currency:        "RUB",
+		BillingPeriod:   model.BillingPeriodMonth,
+		BillingInterval: 1,
+		UserID:          userID,
+		StartDate:       model.DayMonthYear(start),
+	}
+	if err := s.SubscriptionStore.SaveSubscription(context.Background(), s.Executor(), subscription); err != nil {
+		t.Fatalf("не удалось сохранить подписку: %v", err)
+	}
+	return subscription
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
+func TestCreateSubscriptionResolvesCatalog(t *testing.T) {
+	ctx := context.Background()
+	s, video := newMemoryService(t)
+
+	subscription := &model.SubscriptionDetails{
+		ServiceName: " тест видео ",
+		Price:       100,
+		UserID:      testUserA,
+		StartDate:   model.DayMonthYear(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	if _, err := s.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	// название сервиса приводится к каталогу, незаданные поля получают значения по умолчанию
+	if subscription.ServiceID != video.ID || subscription.ServiceName != video.Name || subscription.Version != 1 {
+		t.Errorf("сохраненная подписка %+v", subscription)
+	}
+	if subscription.Currency != model.DefaultCurrency || subscription.BillingPeriod != model.BillingPeriodMonth || subscription.BillingInterval != 1 {
+		t.Errorf("значения по умолчанию: валюта %q, период %q, интервал %d", subscription.Currency, subscription.BillingPeriod, subscription.BillingInterval)
+	}
+
+	invalid := &model.SubscriptionDetails{ServiceName: "Тест Видео", Price: -1, UserID: testUserA}
+	var errs model.ValidationErrors
+	if _, err := s.CreateSubscription(ctx, invalid); !errors.As(err, &errs) {
+		t.Errorf("некорректная подписка: ожидались model.ValidationErrors, получено %v", err)
+	}
+
+	subscriptions, err := s.GetSubscriptionsByUserUUID(ctx, testUserA)
+	if err != nil || len(subscriptions) != 1 {
+		t.Errorf("подписки пользователя %+v, ошибка %v", subscriptions, err)
+	}
+}
+
+func TestSubscriptionVersions(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		expected *int
+		err      error
+	}{
+		{name: "текущая версия", expected: ptr(1)},
+		{name: "любая версия", expected: nil},
+		{name: "устаревшая версия", expected: ptr(0), err: model.ErrVersionMismatch},
+		{name: "будущая версия", expected: ptr(2), err: model.ErrVersionMismatch},
+	}
+
+	changes := []struct {
+		name   string
+		change func(s *SubscriptionService, subscription *model.SubscriptionDetails, expected *int) (int, error)
+	}{
+		{
+			name: "PUT",
+			change: func(s *SubscriptionService, subscription *model.SubscriptionDetails, expected *int) (int, error) {
+				updated := *subscription
+				updated.EndDate = model.DayMonthYear(start.AddDate(1, 0, 0))
+				_, err := s.UpdateSubscriptionByID(ctx, &updated, subscription.ID, expected)
+				return updated.Version, err
+			},
+		},
+		{
+			name: "PATCH",
+			change: func(s *SubscriptionService, subscription *model.SubscriptionDetails, expected *int) (int, error) {
+				patched, _, err := s.PatchSubscriptionByID(ctx, subscription.ID, model.SubscriptionPatch{Tags: &model.Tags{"семья"}}, expected)
+				if err != nil {
+					return 0, err
+				}
+				return patched.Version, nil
+			},
+		},
+		{
+			name: "DELETE",
+			change: func(s *SubscriptionService, subscription *model.SubscriptionDetails, expected *int) (int, error) {
+				return 0, s.DeleteSubscriptionByID(ctx, subscription.ID, expected)
+			},
+		},
+	}
+
+	for _, change := range changes {
+		for _, test := range tests {
+			t.Run(change.name+"/"+test.name, func(t *testing.T) {
+				s, video := newMemoryService(t)
+				subscription := saveTestSubscription(t, s, video, testUserA, start, 100)
+
+				version, err := change.change(s, subscription, test.expected)
+				if test.err != nil {
+					if !errors.Is(err, test.err) {
+						t.Fatalf("ошибка %v, ожидалась %v", err, test.err)
+					}
+					current, err := s.SubscriptionStore.GetSubscriptionByID(ctx, s.Executor(), subscription.ID)
+					if err != nil || current.Version != 1 {
+						t.Errorf("отклоненное изменение не должно менять подписку: %+v, ошибка %v", current, err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("изменение отклонено: %v", err)
+				}
+				if change.name != "DELETE" && version != 2 {
+					t.Errorf("версия после изменения %d, ожидалась 2", version)
+				}
+			})
+		}
+	}
+}
+
+func TestDeleteAndRestoreSubscription(t *testing.T) {
+	ctx := context.Background()
+	s, video := newMemoryService(t)
+	subscription := saveTestSubscription(t, s, video, testUserA, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), 100)
+
+	if err := s.DeleteSubscriptionByID(ctx, subscription.ID, nil); err != nil {
+		t.Fatalf("DeleteSubscriptionByID: %v", err)
+	}
+	if _, err := s.GetSubscriptionByID(ctx, subscription.ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("удаленная подписка: ожидалась ErrNotFound, получено %v", err)
+	}
+	if err := s.DeleteSubscriptionByID(ctx, subscription.ID, nil); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("повторное удаление: ожидалась ErrNotFound, получено %v", err)
+	}
+
+	restored, err := s.RestoreSubscriptionByID(ctx, subscription.ID)
+	if err != nil {
+		t.Fatalf("RestoreSubscriptionByID: %v", err)
+	}
+	if restored.ID != subscription.ID {
+		t.Errorf("восстановлена подписка id=%d, ожидалась id=%d", restored.ID, subscription.ID)
+	}
+	if _, err := s.RestoreSubscriptionByID(ctx, subscription.ID); !errors.Is(err, model.ErrConflict) {
+		t.Errorf("восстановление неудаленной подписки: ожидалась ErrConflict, получено %v", err)
+	}
+}
+
+func TestCreateSubscriptionIdempotent(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	s := NewSubscriptionService(store, store, nil, nil, nil, store, time.Hour)
+
+	request := func(userID string, price int, currency string) *model.SubscriptionDetails {
+		return &model.SubscriptionDetails{
+			ServiceName:     "Тест Видео",
+			Tags:            model.Tags{},
+			Price:           price,
+			Currency:        currency,
+			BillingPeriod:   model.BillingPeriodMonth,
+			BillingInterval: 1,
+			UserID:          userID,
+			StartDate:       model.DayMonthYear(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)),
+		}
+	}
+	render := func(subscription *model.SubscriptionDetails, _ []model.BudgetWarning) (*model.StoredResponse, error) {
+		body, err := json.Marshal(subscription)
+		if err != nil {
+			return nil, err
+		}
+		return &model.StoredResponse{
+			StatusCode: 201,
+			Header:     map[string]string{"Location": "/subscriptions/" + strconv.Itoa(subscription.ID)},
+			Body:       body,
+		}, nil
+	}
+
+	// запросы выполняются по порядку и видят результаты предыдущих
+	tests := []struct {
+		name     string
+		key      string
+		request  *model.SubscriptionDetails
+		replayed bool
+		same     string
+		err      error
+	}{
+		{name: "первый запрос", key: "key-1", request: request(testUserA, 100, "RUB")},
+		{name: "повтор", key: "key-1", request: request(testUserA, 100, "RUB"), replayed: true, same: "первый запрос"},
+		{name: "повтор с другим написанием", key: "key-1", request: request(strings.ToUpper(testUserA), 100, " rub "), replayed: true, same: "первый запрос"},
+		{name: "тот же ключ с другим телом", key: "key-1", request: request(testUserA, 200, "RUB"), err: model.ErrIdempotencyKeyReused},
+		{name: "тот же ключ у другого пользователя", key: "key-1", request: request(testUserB, 200, "RUB")},
+		{name: "другой ключ", key: "key-2", request: request(testUserA, 100, "RUB")},
+	}
+
+	responses := make(map[string]*model.StoredResponse, len(tests))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, replayed, err := s.CreateSubscriptionIdempotent(ctx, test.key, test.request, render)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("ошибка %v, ожидалась %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("запрос отклонен: %v", err)
+			}
+			if replayed != test.replayed {
+				t.Errorf("replayed = %v, ожидалось %v", replayed, test.replayed)
+			}
+			if test.same != "" && !reflect.DeepEqual(response, responses[test.same]) {
+				t.Errorf("повтор вернул %+v, ожидался ответ первого запроса %+v", response, responses[test.same])
+			}
+			responses[test.name] = response
+		})
+	}
+
+	subscriptions, err := s.SubscriptionStore.GetSubscriptionsByUserUUID(ctx, s.Executor(), testUserA)
+	if err != nil {
+		t.Fatalf("не удалось прочитать подписки: %v", err)
+	}
+	if len(subscriptions) != 2 {
+		t.Errorf("у пользователя %d подписок, ожидалось 2: повторы не должны создавать новые", len(subscriptions))
+	}
+
+	memoryService, _ := newMemoryService(t)
+	if _, _, err := memoryService.CreateSubscriptionIdempotent(ctx, "key-1", request(testUserA, 100, "RUB"), render); !errors.Is(err, model.ErrUnavailable) {
+		t.Errorf("хранилище без ключей идемпотентности должно вернуть ErrUnavailable, получено %v", err)
+	}
+}
+
+func TestPurgeExpiredIdempotencyKeys(t *testing.T) {
+	ctx := context.Background()
+	store := newSQLiteStore(t)
+	// отрицательный срок действия: ключ истекает сразу после создания подписки
+	s := NewSubscriptionService(store, store, nil, nil, nil, store, -time.Hour)
+
+	subscription := &model.SubscriptionDetails{
+		ServiceName:     "Тест Видео",
+		Price:           100,
+		Currency:        "RUB",
+		BillingPeriod:   model.BillingPeriodMonth,
+		BillingInterval: 1,
+		UserID:          testUserA,
+		StartDate:       model.DayMonthYear(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	render := func(*model.SubscriptionDetails, []model.BudgetWarning) (*model.StoredResponse, error) {
+		return &model.StoredResponse{StatusCode: 201, Header: map[string]string{}, Body: []byte("{}")}, nil
+	}
+	if _, _, err := s.CreateSubscriptionIdempotent(ctx, "key", subscription, render); err != nil {
+		t.Fatalf("CreateSubscriptionIdempotent: %v", err)
+	}
+
+	deleted, err := s.PurgeExpiredIdempotencyKeys(ctx)
+	if err != nil || deleted != 1 {
+		t.Fatalf("PurgeExpiredIdempotencyKeys: удалено %d, ошибка %v; ожидался один ключ", deleted, err)
+	}
+	if _, err := store.GetRecord(ctx, store.Executor(), testUserA, "key"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("просроченный ключ остался: %v", err)
+	}
+
+	withoutKeys, _ := newMemoryService(t)
+	if deleted, err := withoutKeys.PurgeExpiredIdempotencyKeys(ctx); err != nil || deleted != 0 {
+		t.Errorf("без хранилища ключей: удалено %d, ошибка %v", deleted, err)
+	}
+}
+
+func TestPatchSubscriptionTags(t *testing.T) {
+	ctx := context.Background()
+	s, video := newMemoryService(t)
+	subscription := saveTestSubscription(t, s, video, testUserA, time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), 100)
+
+	_, _, err := s.PatchSubscriptionByID(ctx, subscription.ID, model.SubscriptionPatch{Tags: &model.Tags{"Семья", "работа", ""}}, nil)
+	var errs model.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "tags[2]" {
+		t.Fatalf("пустой тег: %v, ожидалось нарушение tags[2]", err)
+	}
+
+	patched, _, err := s.PatchSubscriptionByID(ctx, subscription.ID, model.SubscriptionPatch{Tags: &model.Tags{"Семья", "работа", "семья"}}, nil)
+	if err != nil {
+		t.Fatalf("PatchSubscriptionByID: %v", err)
+	}
+	if want := (model.Tags{"работа", "семья"}); !reflect.DeepEqual(patched.Tags, want) {
+		t.Errorf("теги %q, ожидались %q", patched.Tags, want)
+	}
+}
+
+func TestCorrectSubscriptionPrice(t *testing.T) {
+	ctx := context.Background()
+	s, video := newMemoryService(t)
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -3, 0)
+	subscription := saveTestSubscription(t, s, video, testUserA, start, 100)
+
+	// ошибочная цена исправляется с начала подписки, прошлые оплаты пересчитываются
+	updated := *subscription
+	updated.Price = 1000
+	if _, err := s.UpdateSubscriptionByID(ctx, &updated, subscription.ID, ptr(subscription.Version)); err != nil {
+		t.Fatalf("UpdateSubscriptionByID: %v", err)
+	}
+	filter := model.CostFilter{UserID: testUserA, StartPeriod: start, EndPeriod: start.AddDate(0, 2, 0), Currency: "RUB"}
+	cost, err := s.GetSubscriptionsCostByUserDetails(ctx, filter)
+	if err != nil || cost.Total != 3000 {
+		t.Fatalf("стоимость после PUT %+v, ошибка %v; ожидалось 3000", cost, err)
+	}
+
+	patched, _, err := s.PatchSubscriptionByID(ctx, subscription.ID, model.SubscriptionPatch{Price: ptr(500)}, nil)
+	if err != nil || patched.Price != 500 {
+		t.Fatalf("PatchSubscriptionByID: %+v, %v", patched, err)
+	}
+	cost, err = s.GetSubscriptionsCostByUserDetails(ctx, filter)
+	if err != nil || cost.Total != 1500 {
+		t.Fatalf("стоимость после PATCH %+v, ошибка %v; ожидалось 1500", cost, err)
+	}
+
+	prices, err := s.GetSubscriptionPrices(ctx, subscription.ID)
+	if err != nil {
+		t.Fatalf("GetSubscriptionPrices: %v", err)
+	}
+	if len(prices) != 1 || prices[0].Price != 500 || !prices[0].EffectiveFrom.ToTime().Equal(start) {
+		t.Errorf("история цен %+v, ожидалась цена 500 с %s", prices, start.Format(time.DateOnly))
+	}
+}
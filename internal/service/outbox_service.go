@@ -0,0 +1,152 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// maxOutboxRetryDelay ограничивает экспоненциальную задержку между попытками
+// публикации события
+const maxOutboxRetryDelay = 10 * time.Minute
+
+// EventPublisher публикует событие из outbox во внешнюю систему. Публикация выполняется
+// не меньше одного раза: после ошибки или сбоя до отметки об отправке событие публикуется
+// снова, поэтому получатели отбрасывают повторы по EventID
+type EventPublisher interface {
+	Publish(ctx context.Context, event model.OutboxEvent) error
+}
+
+// LogEventPublisher пишет события в журнал сервера
+type LogEventPublisher struct{}
+
+func (LogEventPublisher) Publish(_ context.Context, event model.OutboxEvent) error {
+	log.Printf("событие %s id=%d: %s", event.Type, event.EventID, event.Payload)
+	return nil
+}
+
+// WebhookEventPublisher ставит событие в очередь доставки вебхукам, подписанным на его тип.
+// Повторная публикация того же события не создает повторных доставок
+type WebhookEventPublisher struct {
+	repo *repository.WebhookRepository
+}
+
+func NewWebhookEventPublisher(repo *repository.WebhookRepository) *WebhookEventPublisher {
+	return &WebhookEventPublisher{repo: repo}
+}
+
+func (publisher *WebhookEventPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	if !model.WebhookEventTypes[event.Type] {
+		return nil
+	}
+	return publisher.repo.EnqueueWebhookEvent(ctx, publisher.repo.Database, event.EventID, event.Type, event.Payload)
+}
+
+// OutboxRelayOptions
+// Публикация событий outbox: BatchSize событий за проход, каждое не дольше Timeout.
+// Неудачная публикация повторяется с экспоненциальной задержкой, после MaxAttempts
+// попыток событие переходит в dead. Отправленные события хранятся Retention
+type OutboxRelayOptions struct {
+	BatchSize   int
+	Timeout     time.Duration
+	MaxAttempts int
+	Retention   time.Duration
+}
+
+type OutboxService struct {
+	repository.OutboxRelayStore
+	publishers []EventPublisher
+	options    OutboxRelayOptions
+}
+
+func NewOutboxService(store repository.OutboxRelayStore, publishers []EventPublisher, options OutboxRelayOptions) *OutboxService {
+	return &OutboxService{
+		OutboxRelayStore: store,
+		publishers:       publishers,
+		options:          options,
+	}
+}
+
+// RelayEvents публикует очередную порцию неотправленных событий в порядке записи каждому
+// издателю и отмечает их отправленными. События забираются в аренду и публикуются вне
+// транзакции, каждое с таймаутом, поэтому медленный издатель не держит блокировки. Если
+// хотя бы один издатель не принял событие, оно публикуется всем повторно с экспоненциальной
+// задержкой, пока не исчерпает попытки
+func (s *OutboxService) RelayEvents(ctx context.Context) (int, error) {
+	// пока событие публикуется, его не заберет другой релей; запас покрывает таймаут
+	// публикации каждого события порции
+	lease := s.options.Timeout*time.Duration(s.options.BatchSize) + time.Minute
+	events, err := s.OutboxRelayStore.ClaimUnsentEvents(ctx, s.Executor(), s.options.BatchSize, lease)
+	if err != nil {
+		return 0, util.LogError("не удалось получить события outbox", err)
+	}
+
+	sent := 0
+	for _, event := range events {
+		if err := s.publish(ctx, event); err != nil {
+			status, nextAttemptAt := model.OutboxStatusPending, time.Now().Add(outboxRetryDelay(event.Attempts))
+			if event.Attempts+1 >= s.options.MaxAttempts {
+				status = model.OutboxStatusDead
+			}
+			log.Printf("не удалось опубликовать событие outbox id=%d (попытка %d, %s): %v", event.ID, event.Attempts+1, status, err)
+			if err := s.OutboxRelayStore.MarkEventFailed(ctx, s.Executor(), event.ID, err.Error(), status, nextAttemptAt); err != nil {
+				return sent, util.LogError("не удалось отложить событие outbox", err)
+			}
+			continue
+		}
+
+		if err := s.OutboxRelayStore.MarkEventSent(ctx, s.Executor(), event.ID); err != nil {
+			return sent, util.LogError("не удалось отметить отправку события outbox", err)
+		}
+		sent++
+	}
+
+	if sent > 0 {
+		log.Printf("опубликовано событий outbox: %d", sent)
+	}
+	return sent, nil
+}
+
+// publish публикует событие всем издателям, ограничивая публикацию таймаутом
+func (s *OutboxService) publish(ctx context.Context, event model.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, s.options.Timeout)
+	defer cancel()
+
+	for _, publisher := range s.publishers {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%T: %w", publisher, err)
+		}
+	}
+	return nil
+}
+
+// PurgeSentEvents удаляет события, отправленные дольше retention назад. Нулевой
+// retention оставляет отправленные события навсегда
+func (s *OutboxService) PurgeSentEvents(ctx context.Context) (int64, error) {
+	if s.options.Retention <= 0 {
+		return 0, nil
+	}
+
+	deleted, err := s.OutboxRelayStore.DeleteSentEvents(ctx, s.Executor(), time.Now().Add(-s.options.Retention))
+	if err != nil {
+		return 0, util.LogError("не удалось очистить outbox", err)
+	}
+
+	if deleted > 0 {
+		log.Printf("удалено отправленных событий outbox: %d", deleted)
+	}
+	return deleted, nil
+}
+
+// outboxRetryDelay - задержка перед следующей попыткой: 5 секунд, удваивающиеся
+// с каждой неудачей, но не больше maxOutboxRetryDelay
+func outboxRetryDelay(attempts int) time.Duration {
+	if attempts >= 7 {
+		return maxOutboxRetryDelay
+	}
+	return min(5*time.Second<<attempts, maxOutboxRetryDelay)
+}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingPublisher запоминает опубликованные события и возвращает err
+type recordingPublisher struct {
+	events []model.OutboxEvent
+	err    error
+}
+
+func (publisher *recordingPublisher) Publish(_ context.Context, event model.OutboxEvent) error {
+	publisher.events = append(publisher.events, event)
+	return publisher.err
+}
+
+func TestOutboxPublish(t *testing.T) {
+	event := model.OutboxEvent{ID: 1, EventID: 7, Type: model.EventSubscriptionCreated}
+	first := &recordingPublisher{}
+	failing := &recordingPublisher{err: errors.New("брокер недоступен")}
+	last := &recordingPublisher{}
+
+	s := NewOutboxService(nil, []EventPublisher{first, failing, last}, OutboxRelayOptions{BatchSize: 10, Timeout: time.Second})
+	err := s.publish(context.Background(), event)
+	if err == nil || !strings.Contains(err.Error(), "брокер недоступен") || !strings.Contains(err.Error(), "recordingPublisher") {
+		t.Fatalf("publish = %v, ожидалась ошибка издателя с его типом", err)
+	}
+	// после отказа издателя событие не передается следующим: оно будет опубликовано
+	// всем повторно
+	if len(first.events) != 1 || len(failing.events) != 1 || len(last.events) != 0 {
+		t.Errorf("получено событий: %d, %d, %d", len(first.events), len(failing.events), len(last.events))
+	}
+
+	failing.err = nil
+	if err := s.publish(context.Background(), event); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if len(last.events) != 1 || last.events[0].EventID != event.EventID {
+		t.Errorf("последний издатель получил %+v", last.events)
+	}
+}
+
+// relayedEvent - событие в fakeOutboxRelayStore
+type relayedEvent struct {
+	event         model.OutboxEvent
+	status        string
+	lastError     string
+	nextAttemptAt time.Time
+}
+
+// fakeOutboxRelayStore - outbox в памяти со стороны релея. Аренда в нем истекает сразу
+type fakeOutboxRelayStore struct {
+	events []*relayedEvent
+	leases []time.Duration
+}
+
+func newFakeOutboxRelayStore(events ...model.OutboxEvent) *fakeOutboxRelayStore {
+	store := &fakeOutboxRelayStore{}
+	for _, event := range events {
+		store.events = append(store.events, &relayedEvent{event: event, status: model.OutboxStatusPending})
+	}
+	return store
+}
+
+func (store *fakeOutboxRelayStore) Executor() sqlx.ExtContext {
+	return nil
+}
+
+func (store *fakeOutboxRelayStore) ClaimUnsentEvents(ctx context.Context, exec sqlx.ExtContext, limit int, lease time.Duration) ([]model.OutboxEvent, error) {
+	store.leases = append(store.leases, lease)
+	var due []model.OutboxEvent
+	for _, relayed := range store.events {
+		if relayed.status == model.OutboxStatusPending && !relayed.nextAttemptAt.After(time.Now()) && len(due) < limit {
+			due = append(due, relayed.event)
+		}
+	}
+	return due, nil
+}
+
+func (store *fakeOutboxRelayStore) MarkEventSent(ctx context.Context, exec sqlx.ExtContext, id int64) error {
+	if relayed := store.find(id); relayed.status == model.OutboxStatusPending {
+		relayed.status = model.OutboxStatusSent
+	}
+	return nil
+}
+
+func (store *fakeOutboxRelayStore) MarkEventFailed(ctx context.Context, exec sqlx.ExtContext, id int64, reason, status string, nextAttemptAt time.Time) error {
+	if relayed := store.find(id); relayed.status == model.OutboxStatusPending {
+		relayed.event.Attempts++
+		relayed.status, relayed.lastError, relayed.nextAttemptAt = status, reason, nextAttemptAt
+	}
+	return nil
+}
+
+func (store *fakeOutboxRelayStore) DeleteSentEvents(ctx context.Context, exec sqlx.ExtContext, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (store *fakeOutboxRelayStore) find(id int64) *relayedEvent {
+	for _, relayed := range store.events {
+		if relayed.event.ID == id {
+			return relayed
+		}
+	}
+	return nil
+}
+
+func TestRelayEvents(t *testing.T) {
+	store := newFakeOutboxRelayStore(
+		model.OutboxEvent{ID: 1, EventID: 11, Type: model.EventSubscriptionCreated},
+		model.OutboxEvent{ID: 2, EventID: 12, Type: model.EventSubscriptionUpdated},
+		// последняя попытка перед dead
+		model.OutboxEvent{ID: 3, EventID: 13, Type: model.EventSubscriptionDeleted, Attempts: 4},
+	)
+	publisher := &recordingPublisher{}
+	failing := &failingPublisher{failEventIDs: map[int64]bool{12: true, 13: true}}
+	s := NewOutboxService(store, []EventPublisher{failing, publisher}, OutboxRelayOptions{
+		BatchSize: 10, Timeout: time.Second, MaxAttempts: 5,
+	})
+
+	started := time.Now()
+	sent, err := s.RelayEvents(context.Background())
+	if err != nil || sent != 1 {
+		t.Fatalf("RelayEvents: отправлено %d, ошибка %v", sent, err)
+	}
+	if len(store.leases) != 1 || store.leases[0] != 10*time.Second+time.Minute {
+		t.Errorf("аренды %v: ожидалась одна, покрывающая таймауты порции", store.leases)
+	}
+	if len(publisher.events) != 1 || publisher.events[0].EventID != 11 {
+		t.Errorf("опубликованы события %+v", publisher.events)
+	}
+
+	if sent := store.find(1); sent.status != model.OutboxStatusSent {
+		t.Errorf("опубликованное событие %+v", sent)
+	}
+	retried := store.find(2)
+	if retried.status != model.OutboxStatusPending || retried.event.Attempts != 1 || !strings.Contains(retried.lastError, "брокер недоступен") {
+		t.Errorf("событие после первой неудачи %+v", retried)
+	}
+	if delay := retried.nextAttemptAt.Sub(started); delay < 5*time.Second || delay > 6*time.Second {
+		t.Errorf("следующая попытка через %s, ожидалось 5 секунд", delay)
+	}
+	if dead := store.find(3); dead.status != model.OutboxStatusDead || dead.event.Attempts != 5 {
+		t.Errorf("событие, исчерпавшее попытки, %+v", dead)
+	}
+
+	// до следующей попытки события не публикуются, dead не публикуется никогда
+	if sent, err := s.RelayEvents(context.Background()); err != nil || sent != 0 || len(failing.events) != 3 {
+		t.Fatalf("повторный проход: отправлено %d, попыток публикации %d, ошибка %v", sent, len(failing.events), err)
+	}
+
+	retried.nextAttemptAt = time.Time{}
+	failing.failEventIDs = nil
+	if sent, err := s.RelayEvents(context.Background()); err != nil || sent != 1 || retried.status != model.OutboxStatusSent {
+		t.Fatalf("публикация после задержки: отправлено %d, событие %+v, ошибка %v", sent, retried, err)
+	}
+}
+
+func TestRelayEventsTimeout(t *testing.T) {
+	store := newFakeOutboxRelayStore(model.OutboxEvent{ID: 1, EventID: 11, Type: model.EventSubscriptionCreated})
+	s := NewOutboxService(store, []EventPublisher{blockingPublisher{}}, OutboxRelayOptions{
+		BatchSize: 10, Timeout: 20 * time.Millisecond, MaxAttempts: 5,
+	})
+
+	started := time.Now()
+	sent, err := s.RelayEvents(context.Background())
+	if err != nil || sent != 0 {
+		t.Fatalf("RelayEvents с зависшим издателем: отправлено %d, ошибка %v", sent, err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("публикация длилась %s, таймаут не сработал", elapsed)
+	}
+	if relayed := store.find(1); relayed.status != model.OutboxStatusPending || !strings.Contains(relayed.lastError, context.DeadlineExceeded.Error()) {
+		t.Errorf("событие после таймаута %+v", relayed)
+	}
+}
+
+// failingPublisher отклоняет события с ID из failEventIDs
+type failingPublisher struct {
+	recordingPublisher
+	failEventIDs map[int64]bool
+}
+
+func (publisher *failingPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	publisher.events = append(publisher.events, event)
+	if publisher.failEventIDs[event.EventID] {
+		return errors.New("брокер недоступен")
+	}
+	return nil
+}
+
+// blockingPublisher не отвечает, пока публикацию не прервет таймаут
+type blockingPublisher struct{}
+
+func (blockingPublisher) Publish(ctx context.Context, _ model.OutboxEvent) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestWebhookEventPublisherSkipsOtherEvents(t *testing.T) {
+	// событие без подписчиков-вебхуков не доходит до репозитория
+	publisher := NewWebhookEventPublisher(nil)
+	if err := publisher.Publish(context.Background(), model.OutboxEvent{Type: "subscription.reminder"}); err != nil {
+		t.Errorf("Publish = %v, ожидался nil", err)
+	}
+}
+
+func TestOutboxRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: 5 * time.Second},
+		{attempts: 1, want: 10 * time.Second},
+		{attempts: 6, want: 320 * time.Second},
+		{attempts: 7, want: maxOutboxRetryDelay},
+		{attempts: 100, want: maxOutboxRetryDelay},
+	}
+	for _, test := range tests {
+		if got := outboxRetryDelay(test.attempts); got != test.want {
+			t.Errorf("outboxRetryDelay(%d) = %s, ожидалось %s", test.attempts, got, test.want)
+		}
+	}
+}
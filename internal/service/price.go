@@ -0,0 +1,70 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"log"
+	"time"
+)
+
+// ScheduleSubscriptionPrice планирует изменение цены подписки с будущей даты.
+// Прошлые оплаты продолжают считаться по ценам, действовавшим на их даты
+func (s *SubscriptionService) ScheduleSubscriptionPrice(ctx context.Context, id int, price *model.SubscriptionPrice) error {
+	price.SubscriptionID = id
+
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		subscription, err := s.SubscriptionStore.LockSubscriptionByID(ctx, uow, id)
+		if err != nil {
+			return err
+		}
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		if err := ValidateSubscriptionPrice(price, subscription, today); err != nil {
+			return util.LogError("изменение цены не прошло проверку", err)
+		}
+
+		if err := s.SubscriptionStore.SaveSubscriptionPrice(ctx, uow, price); err != nil {
+			return err
+		}
+		return s.recordChange(ctx, uow, model.AuditActionPriceChange, id, nil, price)
+	})
+	if err != nil {
+		return util.LogError("не удалось запланировать изменение цены подписки", err)
+	}
+
+	log.Printf("цена подписки с id=%d изменится на %d с %s", id, price.Price, price.EffectiveFrom.ToTime().Format("02-01-2006"))
+	return nil
+}
+
+// correctStartPrice записывает в историю цен исправленную цену подписки с даты ее начала.
+// PUT и PATCH меняют цену с start_date, по которой считаются оплаты до первого изменения
+// из истории цен: так исправляется ошибочно введенная цена, а запланированные изменения
+// продолжают действовать со своих дат
+func (s *SubscriptionService) correctStartPrice(ctx context.Context, uow *unitOfWork, subscription *model.SubscriptionDetails) error {
+	price := &model.SubscriptionPrice{
+		SubscriptionID: subscription.ID,
+		Price:          subscription.Price,
+		EffectiveFrom:  subscription.StartDate,
+	}
+	if err := s.SubscriptionStore.SaveSubscriptionPrice(ctx, uow, price); err != nil {
+		return err
+	}
+
+	log.Printf("цена подписки с id=%d исправлена на %d с %s", subscription.ID, price.Price, price.EffectiveFrom.ToTime().Format("02-01-2006"))
+	return nil
+}
+
+func (s *SubscriptionService) GetSubscriptionPrices(ctx context.Context, id int) ([]model.SubscriptionPrice, error) {
+	if _, err := s.SubscriptionStore.GetSubscriptionByID(ctx, s.Executor(), id); err != nil {
+		return nil, util.LogError("не удалось найти подписку", err)
+	}
+
+	prices, err := s.SubscriptionStore.GetSubscriptionPrices(ctx, s.Executor(), id)
+	if err != nil {
+		return nil, util.LogError("не удалось получить историю цен подписки", err)
+	}
+
+	log.Printf("история цен подписки с id=%d: %d изменений", id, len(prices))
+	return prices, nil
+}
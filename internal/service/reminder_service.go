@@ -0,0 +1,165 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"log"
+	"time"
+)
+
+// maxReminderRetryDelay ограничивает экспоненциальную задержку между попытками
+// доставки напоминания
+const maxReminderRetryDelay = time.Hour
+
+// ReminderNotifier доставляет напоминание о предстоящей оплате. Доставка выполняется
+// не меньше одного раза: после ошибки или сбоя до отметки об отправке напоминание
+// доставляется снова, поэтому получатель отбрасывает повторы по ID напоминания
+type ReminderNotifier interface {
+	NotifyRenewal(ctx context.Context, reminder model.SubscriptionReminder) error
+}
+
+// LogReminderNotifier пишет напоминания в журнал сервера
+type LogReminderNotifier struct{}
+
+func (LogReminderNotifier) NotifyRenewal(_ context.Context, reminder model.SubscriptionReminder) error {
+	log.Printf("напоминание id=%d: подписка id=%d (%s) пользователя %s будет оплачена %s, %d %s",
+		reminder.ID, reminder.SubscriptionID, reminder.ServiceName, reminder.UserID,
+		reminder.BillingDate.ToTime().Format("02-01-2006"), reminder.Price, reminder.Currency)
+	return nil
+}
+
+type ReminderService struct {
+	repository.ReminderStore
+	outbox     repository.OutboxStore
+	notifier   ReminderNotifier
+	daysBefore int
+	batchSize  int
+	timeout    time.Duration
+}
+
+func NewReminderService(
+	store repository.ReminderStore,
+	outbox repository.OutboxStore,
+	notifier ReminderNotifier,
+	daysBefore, batchSize int,
+	timeout time.Duration,
+) *ReminderService {
+	return &ReminderService{
+		ReminderStore: store,
+		outbox:        outbox,
+		notifier:      notifier,
+		daysBefore:    daysBefore,
+		batchSize:     batchSize,
+		timeout:       timeout,
+	}
+}
+
+// ProcessReminders создает напоминания об оплатах в ближайшие daysBefore дней и доставляет
+// накопившиеся. Напоминания забираются в аренду и доставляются вне транзакции, каждое
+// с таймаутом, поэтому медленный получатель не держит блокировки. Напоминание отмечается
+// отправленным только после успешной доставки, в одной короткой транзакции с событием
+// subscription.renewal_due в outbox; неудачная доставка повторяется с экспоненциальной
+// задержкой
+func (s *ReminderService) ProcessReminders(ctx context.Context) (int, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	created, err := s.ReminderStore.EnqueueReminders(ctx, s.Executor(), today, today.AddDate(0, 0, s.daysBefore))
+	if err != nil {
+		return 0, util.LogError("не удалось создать напоминания", err)
+	}
+	if created > 0 {
+		log.Printf("создано напоминаний о предстоящих оплатах: %d", created)
+	}
+
+	// пока напоминание доставляется, его не заберет другой процесс; запас покрывает
+	// таймаут каждой доставки порции
+	lease := s.timeout*time.Duration(s.batchSize) + time.Minute
+	reminders, err := s.ReminderStore.ClaimDueReminders(ctx, s.Executor(), s.batchSize, lease)
+	if err != nil {
+		return 0, util.LogError("не удалось получить напоминания к отправке", err)
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		if err := s.notify(ctx, reminder); err != nil {
+			log.Printf("не удалось доставить напоминание id=%d (попытка %d): %v", reminder.ID, reminder.Attempts+1, err)
+			nextAttemptAt := time.Now().Add(reminderRetryDelay(reminder.Attempts))
+			if err := s.ReminderStore.MarkReminderFailed(ctx, s.Executor(), reminder.ID, err.Error(), nextAttemptAt); err != nil {
+				return sent, util.LogError("не удалось отложить напоминание", err)
+			}
+			continue
+		}
+
+		marked, err := s.markSent(ctx, reminder)
+		if err != nil {
+			return sent, util.LogError("не удалось отметить отправку напоминания", err)
+		}
+		if marked {
+			sent++
+		}
+	}
+
+	if sent > 0 {
+		log.Printf("отправлено напоминаний о предстоящих оплатах: %d", sent)
+	}
+	return sent, nil
+}
+
+// notify доставляет напоминание, ограничивая доставку таймаутом
+func (s *ReminderService) notify(ctx context.Context, reminder model.SubscriptionReminder) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	return s.notifier.NotifyRenewal(ctx, reminder)
+}
+
+// markSent отмечает напоминание отправленным и записывает событие о нем в outbox одной
+// транзакцией. Напоминание, которое уже отметил другой процесс, событие не порождает
+func (s *ReminderService) markSent(ctx context.Context, reminder model.SubscriptionReminder) (bool, error) {
+	marked := false
+	err := s.InTransaction(ctx, func(exec sqlx.ExtContext) error {
+		var err error
+		if marked, err = s.ReminderStore.MarkReminderSent(ctx, exec, reminder.ID); err != nil || !marked {
+			return err
+		}
+
+		occurredAt := time.Now().UTC()
+		event := pendingEvent{
+			eventType: model.EventSubscriptionRenewalDue,
+			payload: func(eventID int64) any {
+				return model.ReminderEvent{
+					ID:         eventID,
+					Type:       model.EventSubscriptionRenewalDue,
+					OccurredAt: occurredAt,
+					Reminder:   reminder,
+				}
+			},
+		}
+		return saveOutboxEvents(ctx, s.outbox, exec, []pendingEvent{event})
+	})
+	return marked, err
+}
+
+// reminderRetryDelay - задержка перед следующей попыткой: минута, удваивающаяся
+// с каждой неудачей, но не больше maxReminderRetryDelay
+func reminderRetryDelay(attempts int) time.Duration {
+	if attempts >= 6 {
+		return maxReminderRetryDelay
+	}
+	return min(time.Minute<<attempts, maxReminderRetryDelay)
+}
+
+// GetUpcomingRenewals возвращает ближайшие оплаты подписок пользователя в следующие
+// within дней, включая сегодняшний
+func (s *SubscriptionService) GetUpcomingRenewals(ctx context.Context, uuid string, within int) ([]model.UpcomingRenewal, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	renewals, err := s.SubscriptionStore.GetUpcomingRenewals(ctx, s.Executor(), uuid, today, today.AddDate(0, 0, within))
+	if err != nil {
+		return nil, util.LogError(fmt.Sprintf("не удалось получить предстоящие оплаты пользователя с uuid=%s", uuid), err)
+	}
+
+	log.Printf("предстоящие оплаты пользователя с uuid=%s за %d дн.: %d", uuid, within, len(renewals))
+	return renewals, nil
+}
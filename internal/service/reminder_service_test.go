@@ -0,0 +1,248 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReminderRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: 2 * time.Minute},
+		{attempts: 5, want: 32 * time.Minute},
+		{attempts: 6, want: maxReminderRetryDelay},
+		{attempts: 100, want: maxReminderRetryDelay},
+	}
+	for _, test := range tests {
+		if got := reminderRetryDelay(test.attempts); got != test.want {
+			t.Errorf("reminderRetryDelay(%d) = %s, ожидалось %s", test.attempts, got, test.want)
+		}
+	}
+}
+
+// fakeReminder - напоминание в очереди fakeReminderStore
+type fakeReminder struct {
+	reminder      model.SubscriptionReminder
+	sent          bool
+	lastError     string
+	nextAttemptAt time.Time
+}
+
+// fakeReminderStore - очередь напоминаний в памяти. Аренда в ней истекает сразу:
+// ClaimDueReminders отдает напоминание, пока его не отметили отправленным или
+// не отложили, как после сбоя процесса, забравшего его раньше
+type fakeReminderStore struct {
+	mu        sync.Mutex
+	reminders []*fakeReminder
+}
+
+func (store *fakeReminderStore) Executor() sqlx.ExtContext {
+	return nil
+}
+
+func (store *fakeReminderStore) InTransaction(ctx context.Context, fn func(exec sqlx.ExtContext) error) error {
+	return fn(nil)
+}
+
+func (store *fakeReminderStore) EnqueueReminders(ctx context.Context, exec sqlx.ExtContext, from, to time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (store *fakeReminderStore) ClaimDueReminders(ctx context.Context, exec sqlx.ExtContext, limit int, lease time.Duration) ([]model.SubscriptionReminder, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var due []model.SubscriptionReminder
+	for _, queued := range store.reminders {
+		if !queued.sent && !queued.nextAttemptAt.After(time.Now()) && len(due) < limit {
+			due = append(due, queued.reminder)
+		}
+	}
+	return due, nil
+}
+
+func (store *fakeReminderStore) MarkReminderSent(ctx context.Context, exec sqlx.ExtContext, id int64) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	queued := store.find(id)
+	if queued.sent {
+		return false, nil
+	}
+	queued.sent = true
+	return true, nil
+}
+
+func (store *fakeReminderStore) MarkReminderFailed(ctx context.Context, exec sqlx.ExtContext, id int64, reason string, nextAttemptAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	queued := store.find(id)
+	queued.reminder.Attempts++
+	queued.lastError = reason
+	queued.nextAttemptAt = nextAttemptAt
+	return nil
+}
+
+func (store *fakeReminderStore) find(id int64) *fakeReminder {
+	for _, queued := range store.reminders {
+		if queued.reminder.ID == id {
+			return queued
+		}
+	}
+	return nil
+}
+
+// fakeOutboxStore копит записанные события
+type fakeOutboxStore struct {
+	mu     sync.Mutex
+	events []model.OutboxEvent
+}
+
+func (store *fakeOutboxStore) NextEventID(ctx context.Context, exec sqlx.ExtContext) (int64, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return int64(len(store.events) + 1), nil
+}
+
+func (store *fakeOutboxStore) SaveOutboxEvent(ctx context.Context, exec sqlx.ExtContext, event *model.OutboxEvent) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	event.ID = event.EventID
+	store.events = append(store.events, *event)
+	return nil
+}
+
+// notifierFunc позволяет задать доставку напоминаний функцией
+type notifierFunc func(ctx context.Context, reminder model.SubscriptionReminder) error
+
+func (fn notifierFunc) NotifyRenewal(ctx context.Context, reminder model.SubscriptionReminder) error {
+	return fn(ctx, reminder)
+}
+
+func newTestReminderStore() *fakeReminderStore {
+	return &fakeReminderStore{reminders: []*fakeReminder{{reminder: model.SubscriptionReminder{
+		ID: 7,
+		UpcomingRenewal: model.UpcomingRenewal{
+			SubscriptionID: 3, UserID: testUserA, ServiceName: "Тест Видео", Price: 100, Currency: "RUB",
+		},
+	}}}}
+}
+
+func TestProcessRemindersRetry(t *testing.T) {
+	store, outbox := newTestReminderStore(), &fakeOutboxStore{}
+	deliveries := 0
+	notifier := notifierFunc(func(ctx context.Context, reminder model.SubscriptionReminder) error {
+		deliveries++
+		if deliveries == 1 {
+			return errors.New("получатель недоступен")
+		}
+		return nil
+	})
+	s := NewReminderService(store, outbox, notifier, 3, 10, time.Second)
+
+	started := time.Now()
+	sent, err := s.ProcessReminders(context.Background())
+	if err != nil || sent != 0 {
+		t.Fatalf("ProcessReminders с ошибкой доставки: отправлено %d, ошибка %v", sent, err)
+	}
+	queued := store.reminders[0]
+	if queued.sent || queued.reminder.Attempts != 1 || queued.lastError != "получатель недоступен" {
+		t.Fatalf("после ошибки доставки напоминание %+v", queued)
+	}
+	if delay := queued.nextAttemptAt.Sub(started); delay < time.Minute || delay > time.Minute+time.Second {
+		t.Errorf("следующая попытка через %s, ожидалась минута", delay)
+	}
+	if len(outbox.events) != 0 {
+		t.Errorf("недоставленное напоминание записало события %+v", outbox.events)
+	}
+
+	// до следующей попытки напоминание не доставляется
+	if sent, err := s.ProcessReminders(context.Background()); err != nil || sent != 0 || deliveries != 1 {
+		t.Fatalf("ProcessReminders до следующей попытки: отправлено %d, доставок %d, ошибка %v", sent, deliveries, err)
+	}
+
+	queued.nextAttemptAt = time.Time{}
+	if sent, err := s.ProcessReminders(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("повторная доставка: отправлено %d, ошибка %v", sent, err)
+	}
+	if !queued.sent || len(outbox.events) != 1 {
+		t.Fatalf("после повторной доставки напоминание %+v, события %+v", queued, outbox.events)
+	}
+
+	event := outbox.events[0]
+	var payload model.ReminderEvent
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		t.Fatalf("тело события: %v", err)
+	}
+	if event.Type != model.EventSubscriptionRenewalDue || payload.ID != event.EventID || payload.Reminder.ID != 7 {
+		t.Errorf("событие %s, тело %+v", event.Type, payload)
+	}
+}
+
+func TestProcessRemindersTimeout(t *testing.T) {
+	store, outbox := newTestReminderStore(), &fakeOutboxStore{}
+	// получатель не отвечает, пока доставку не прервет таймаут
+	notifier := notifierFunc(func(ctx context.Context, reminder model.SubscriptionReminder) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s := NewReminderService(store, outbox, notifier, 3, 10, 20*time.Millisecond)
+
+	started := time.Now()
+	sent, err := s.ProcessReminders(context.Background())
+	if err != nil || sent != 0 {
+		t.Fatalf("ProcessReminders с зависшим получателем: отправлено %d, ошибка %v", sent, err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("доставка длилась %s, таймаут не сработал", elapsed)
+	}
+
+	queued := store.reminders[0]
+	if queued.sent || queued.reminder.Attempts != 1 || !strings.Contains(queued.lastError, context.DeadlineExceeded.Error()) {
+		t.Errorf("после таймаута напоминание %+v", queued)
+	}
+	if len(outbox.events) != 0 {
+		t.Errorf("события после таймаута %+v", outbox.events)
+	}
+}
+
+func TestProcessRemindersDuplicateClaim(t *testing.T) {
+	store, outbox := newTestReminderStore(), &fakeOutboxStore{}
+	other := NewReminderService(store, outbox, notifierFunc(func(ctx context.Context, reminder model.SubscriptionReminder) error {
+		return nil
+	}), 3, 10, time.Second)
+
+	// пока первый процесс доставляет напоминание, его аренда истекает, и второй
+	// процесс забирает, доставляет и отмечает то же напоминание
+	var otherSent int
+	deliveries := 0
+	notifier := notifierFunc(func(ctx context.Context, reminder model.SubscriptionReminder) error {
+		deliveries++
+		var err error
+		otherSent, err = other.ProcessReminders(ctx)
+		return err
+	})
+	s := NewReminderService(store, outbox, notifier, 3, 10, time.Second)
+
+	sent, err := s.ProcessReminders(context.Background())
+	if err != nil {
+		t.Fatalf("ProcessReminders: %v", err)
+	}
+	if deliveries != 1 || otherSent != 1 || sent != 0 {
+		t.Errorf("доставок первым процессом %d, отправлено вторым %d, первым %d", deliveries, otherSent, sent)
+	}
+	if !store.reminders[0].sent || len(outbox.events) != 1 {
+		t.Errorf("напоминание %+v, события %+v: ожидалось одно событие", store.reminders[0], outbox.events)
+	}
+}
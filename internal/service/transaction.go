@@ -0,0 +1,69 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"context"
+	"encoding/json"
+	"github.com/jmoiron/sqlx"
+)
+
+// pendingEvent - событие, которое единица работы запишет в outbox при фиксации. Тело
+// строится, когда outbox выдаст событию ID, потому что ID входит в тело
+type pendingEvent struct {
+	eventType string
+	payload   func(eventID int64) any
+}
+
+// unitOfWork - транзакция изменения подписки вместе с событиями, которые оно порождает.
+// События пишутся в outbox той же транзакцией перед фиксацией, поэтому публикуются
+// тогда и только тогда, когда зафиксировано само изменение. Передается в методы
+// репозиториев как sqlx.ExtContext
+type unitOfWork struct {
+	sqlx.ExtContext
+	events []pendingEvent
+}
+
+// addEvent откладывает событие до фиксации единицы работы
+func (uow *unitOfWork) addEvent(eventType string, payload func(eventID int64) any) {
+	uow.events = append(uow.events, pendingEvent{eventType: eventType, payload: payload})
+}
+
+// inUnitOfWork выполняет fn в единице работы поверх транзакции хранилища подписок:
+// при успехе записывает накопленные события в outbox и коммитит транзакцию, при ошибке
+// откатывает ее вместе с событиями
+func (s *SubscriptionService) inUnitOfWork(ctx context.Context, fn func(uow *unitOfWork) error) error {
+	return s.InTransaction(ctx, func(exec sqlx.ExtContext) error {
+		uow := &unitOfWork{ExtContext: exec}
+		if err := fn(uow); err != nil {
+			return err
+		}
+		return saveOutboxEvents(ctx, s.outbox, exec, uow.events)
+	})
+}
+
+// saveOutboxEvents записывает события в outbox в транзакции exec. Без outbox события
+// отбрасываются: хранилище без Postgres их не публикует
+func saveOutboxEvents(ctx context.Context, outbox repository.OutboxStore, exec sqlx.ExtContext, events []pendingEvent) error {
+	if outbox == nil {
+		return nil
+	}
+
+	for _, pending := range events {
+		id, err := outbox.NextEventID(ctx, exec)
+		if err != nil {
+			return err
+		}
+		payload, err := json.Marshal(pending.payload(id))
+		if err != nil {
+			return util.LogError("не удалось сформировать событие "+pending.eventType, err)
+		}
+
+		event := model.OutboxEvent{EventID: id, Type: pending.eventType, Payload: payload}
+		if err := outbox.SaveOutboxEvent(ctx, exec, &event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository/memory"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUnitOfWorkEvents(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	outbox := &fakeOutboxStore{}
+	s := NewSubscriptionService(store, store, nil, nil, outbox, nil, 0)
+
+	// события отмененной единицы работы не записываются
+	failed := errors.New("сбой изменения")
+	err := s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		uow.addEvent(model.EventSubscriptionCreated, func(eventID int64) any { return eventID })
+		return failed
+	})
+	if !errors.Is(err, failed) || len(outbox.events) != 0 {
+		t.Fatalf("inUnitOfWork с ошибкой: %v, события %+v", err, outbox.events)
+	}
+
+	// события записываются в порядке добавления, а тело строится с выданным ID
+	err = s.inUnitOfWork(ctx, func(uow *unitOfWork) error {
+		uow.addEvent(model.EventSubscriptionCreated, func(eventID int64) any { return map[string]int64{"id": eventID} })
+		uow.addEvent(model.EventSubscriptionDeleted, func(eventID int64) any { return map[string]int64{"id": eventID} })
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("inUnitOfWork: %v", err)
+	}
+	if len(outbox.events) != 2 {
+		t.Fatalf("записано событий %d, ожидалось 2", len(outbox.events))
+	}
+	for i, wantType := range []string{model.EventSubscriptionCreated, model.EventSubscriptionDeleted} {
+		event := outbox.events[i]
+		var payload map[string]int64
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			t.Fatalf("тело события: %v", err)
+		}
+		if event.Type != wantType || payload["id"] != event.EventID {
+			t.Errorf("событие %d: %s, тело %s", i, event.Type, event.Payload)
+		}
+	}
+}
+
+func TestSubscriptionChangeEvents(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	video := &model.CatalogService{Name: "Тест Видео", Currency: "RUB"}
+	if err := store.SaveCatalogService(ctx, store.Executor(), video); err != nil {
+		t.Fatalf("не удалось добавить сервис в каталог: %v", err)
+	}
+	outbox := &fakeOutboxStore{}
+	s := NewSubscriptionService(store, store, nil, nil, outbox, nil, 0)
+
+	subscription := &model.SubscriptionDetails{
+		ServiceID: video.ID, Price: 100, UserID: testUserA,
+		StartDate: model.DayMonthYear(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)),
+	}
+	if _, err := s.CreateSubscription(ctx, subscription); err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	// изменение, отклоненное хранилищем, события не порождает
+	if _, _, err := s.PatchSubscriptionByID(ctx, subscription.ID, model.SubscriptionPatch{Price: ptr(200)}, ptr(100)); !errors.Is(err, model.ErrVersionMismatch) {
+		t.Fatalf("PatchSubscriptionByID с устаревшей версией: %v", err)
+	}
+	if err := s.DeleteSubscriptionByID(ctx, subscription.ID, nil); err != nil {
+		t.Fatalf("DeleteSubscriptionByID: %v", err)
+	}
+
+	want := []struct{ eventType, action string }{
+		{model.EventSubscriptionCreated, model.AuditActionCreate},
+		{model.EventSubscriptionDeleted, model.AuditActionDelete},
+	}
+	if len(outbox.events) != len(want) {
+		t.Fatalf("события %+v, ожидались создание и удаление", outbox.events)
+	}
+	for i, event := range outbox.events {
+		var payload model.SubscriptionEvent
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			t.Fatalf("тело события: %v", err)
+		}
+		var snapshot model.SubscriptionDetails
+		if err := json.Unmarshal(payload.Subscription, &snapshot); err != nil {
+			t.Fatalf("снимок подписки в событии: %v", err)
+		}
+		if event.Type != want[i].eventType || payload.Type != event.Type || payload.Action != want[i].action ||
+			payload.ID != event.EventID || snapshot.ID != subscription.ID {
+			t.Errorf("событие %d: %s, тело %+v", i, event.Type, payload)
+		}
+	}
+}
@@ -0,0 +1,465 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxServiceNameLength = 255
+	maxBillingInterval   = 1000
+	maxServiceAliases    = 50
+	maxCategoryLength    = 64
+	maxLogoURLLength     = 2048
+	maxSubscriptionTags  = 20
+	maxTagLength         = 64
+	maxWebhookURLLength  = 2048
+	minWebhookSecret     = 16
+	maxWebhookSecret     = 256
+)
+
+var billingPeriods = map[string]bool{
+	model.BillingPeriodDay:   true,
+	model.BillingPeriodWeek:  true,
+	model.BillingPeriodMonth: true,
+	model.BillingPeriodYear:  true,
+}
+
+// ValidateSubscription проверяет подписку перед записью и возвращает сразу все нарушения
+// в виде model.ValidationErrors. Используется при создании, обновлении и частичном обновлении
+func ValidateSubscription(subscription *model.SubscriptionDetails) error {
+	var errs model.ValidationErrors
+
+	serviceName := strings.TrimSpace(subscription.ServiceName)
+	switch {
+	case subscription.ServiceID < 0:
+		errs.Add("service_id", "unknown_service", "сервис не найден в каталоге")
+	case subscription.ServiceID > 0:
+		// название подставит каталог
+	case serviceName == "":
+		errs.Add("service_name", "required", "название сервиса или service_id обязательны")
+	case utf8.RuneCountInString(serviceName) > maxServiceNameLength:
+		errs.Add("service_name", "too_long", "название сервиса длиннее 255 символов")
+	}
+
+	if subscription.Category != nil && utf8.RuneCountInString(*subscription.Category) > maxCategoryLength {
+		errs.Add("category", "too_long", "категория длиннее 64 символов")
+	}
+
+	// теги проверяются в порядке запроса, повторы считаются одним тегом
+	if len(NormalizeTags(subscription.Tags)) > maxSubscriptionTags {
+		errs.Add("tags", "too_many", "у подписки не может быть больше 20 тегов")
+	}
+	for i, tag := range subscription.Tags {
+		field := fmt.Sprintf("tags[%d]", i)
+		switch {
+		case tag == "":
+			errs.Add(field, "required", "тег не может быть пустым")
+		case utf8.RuneCountInString(tag) > maxTagLength:
+			errs.Add(field, "too_long", "тег длиннее 64 символов")
+		}
+	}
+
+	if subscription.Price < 0 {
+		errs.Add("price", "negative", "цена не может быть отрицательной")
+	}
+
+	if !IsCurrencyCode(subscription.Currency) {
+		errs.Add("currency", "invalid_currency", "валюта должна быть трехбуквенным кодом ISO 4217")
+	}
+
+	if !billingPeriods[subscription.BillingPeriod] {
+		errs.Add("billing_period", "invalid_billing_period", "период оплаты должен быть одним из: day, week, month, year")
+	}
+	switch {
+	case subscription.BillingInterval < 1:
+		errs.Add("billing_interval", "not_positive", "интервал оплаты должен быть положительным")
+	case subscription.BillingInterval > maxBillingInterval:
+		errs.Add("billing_interval", "too_large", "интервал оплаты не может превышать 1000 периодов")
+	}
+
+	switch {
+	case subscription.UserID == "":
+		errs.Add("user_id", "required", "UUID пользователя обязателен")
+	case !isUUID(subscription.UserID):
+		errs.Add("user_id", "invalid_uuid", "user_id должен быть UUID")
+	}
+
+	startDate := subscription.StartDate.ToTime()
+	endDate := subscription.EndDate.ToTime()
+	if startDate.IsZero() {
+		errs.Add("start_date", "required", "дата начала обязательна")
+	} else if !endDate.IsZero() && endDate.Before(startDate) {
+		errs.Add("end_date", "before_start_date", "дата окончания раньше даты начала")
+	}
+
+	if subscription.TrialEnd != nil {
+		trialEnd := subscription.TrialEnd.ToTime()
+		switch {
+		case !startDate.IsZero() && trialEnd.Before(startDate):
+			errs.Add("trial_end", "before_start_date", "пробный период заканчивается раньше начала подписки")
+		case !endDate.IsZero() && trialEnd.After(endDate):
+			errs.Add("trial_end", "after_end_date", "пробный период заканчивается позже окончания подписки")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// prepareSubscription нормализует и проверяет подписку перед записью. Теги сортируются
+// и освобождаются от повторов только после проверки, чтобы нарушения указывали на
+// индексы тегов в запросе клиента
+func prepareSubscription(subscription *model.SubscriptionDetails) error {
+	normalizeSubscription(subscription)
+	if err := ValidateSubscription(subscription); err != nil {
+		return err
+	}
+	subscription.Tags = NormalizeTags(subscription.Tags)
+	return nil
+}
+
+// normalizeSubscription подставляет значения по умолчанию для необязательных полей:
+// валюту RUB и ежемесячную оплату, и приводит категорию и теги к единой записи.
+// Порядок тегов не меняется
+func normalizeSubscription(subscription *model.SubscriptionDetails) {
+	subscription.Currency = NormalizeCurrency(subscription.Currency)
+	subscription.Category = NormalizeCategory(subscription.Category)
+	subscription.Tags = normalizeTagSpelling(subscription.Tags)
+
+	subscription.BillingPeriod = strings.ToLower(strings.TrimSpace(subscription.BillingPeriod))
+	if subscription.BillingPeriod == "" {
+		subscription.BillingPeriod = model.BillingPeriodMonth
+	}
+	if subscription.BillingInterval == 0 {
+		subscription.BillingInterval = 1
+	}
+}
+
+// NormalizeCurrency приводит код валюты к верхнему регистру и подставляет
+// model.DefaultCurrency вместо пустого
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return model.DefaultCurrency
+	}
+	return currency
+}
+
+// NormalizeCategory приводит категорию к нижнему регистру без крайних пробелов;
+// пустая категория превращается в nil
+func NormalizeCategory(category *string) *string {
+	category = trimOptional(category)
+	if category == nil {
+		return nil
+	}
+	lowered := strings.ToLower(*category)
+	return &lowered
+}
+
+// NormalizeTags приводит теги к нижнему регистру без крайних пробелов, убирает повторы
+// и сортирует. Пустые теги остаются, чтобы их отклонила проверка
+func NormalizeTags(tags []string) model.Tags {
+	normalized := normalizeTagSpelling(tags)
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// normalizeTagSpelling приводит теги к нижнему регистру без крайних пробелов,
+// сохраняя их порядок и повторы
+func normalizeTagSpelling(tags []string) model.Tags {
+	normalized := make(model.Tags, 0, len(tags))
+	for _, tag := range tags {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(tag)))
+	}
+	return normalized
+}
+
+// IsCurrencyCode проверяет запись кода валюты ISO 4217: три латинские заглавные буквы.
+// Есть ли валюта в справочнике, проверяет БД
+func IsCurrencyCode(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// isUUID проверяет каноническую запись UUID: 8-4-4-4-12 шестнадцатеричных цифр
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i, r := range value {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ValidateSubscriptionPrice проверяет запланированное изменение цены подписки:
+// оно вступает в силу не раньше завтрашнего дня и в пределах срока подписки
+func ValidateSubscriptionPrice(price *model.SubscriptionPrice, subscription *model.SubscriptionDetails, today time.Time) error {
+	var errs model.ValidationErrors
+
+	if price.Price < 0 {
+		errs.Add("price", "negative", "цена не может быть отрицательной")
+	}
+
+	effectiveFrom := price.EffectiveFrom.ToTime()
+	endDate := subscription.EndDate.ToTime()
+	switch {
+	case effectiveFrom.IsZero():
+		errs.Add("effective_from", "required", "дата вступления цены в силу обязательна")
+	case !effectiveFrom.After(today):
+		errs.Add("effective_from", "not_in_future", "изменение цены можно запланировать только на будущую дату")
+	case effectiveFrom.Before(subscription.StartDate.ToTime()):
+		errs.Add("effective_from", "before_start_date", "дата вступления цены в силу раньше начала подписки")
+	case !endDate.IsZero() && effectiveFrom.After(endDate):
+		errs.Add("effective_from", "after_end_date", "дата вступления цены в силу позже окончания подписки")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateCatalogService проверяет сервис каталога перед записью. Уникальность названия
+// и написаний проверяет БД
+func ValidateCatalogService(service *model.CatalogService) error {
+	var errs model.ValidationErrors
+
+	switch {
+	case service.Name == "":
+		errs.Add("name", "required", "название сервиса обязательно")
+	case utf8.RuneCountInString(service.Name) > maxServiceNameLength:
+		errs.Add("name", "too_long", "название сервиса длиннее 255 символов")
+	}
+
+	if len(service.Aliases) > maxServiceAliases {
+		errs.Add("aliases", "too_many", "у сервиса не может быть больше 50 написаний")
+	}
+	for i, alias := range service.Aliases {
+		field := fmt.Sprintf("aliases[%d]", i)
+		switch {
+		case alias == "":
+			errs.Add(field, "required", "написание сервиса не может быть пустым")
+		case utf8.RuneCountInString(alias) > maxServiceNameLength:
+			errs.Add(field, "too_long", "написание сервиса длиннее 255 символов")
+		}
+	}
+
+	if service.Category != nil && utf8.RuneCountInString(*service.Category) > maxCategoryLength {
+		errs.Add("category", "too_long", "категория длиннее 64 символов")
+	}
+
+	if service.DefaultPrice != nil && *service.DefaultPrice < 0 {
+		errs.Add("default_price", "negative", "цена не может быть отрицательной")
+	}
+
+	if !IsCurrencyCode(service.Currency) {
+		errs.Add("currency", "invalid_currency", "валюта должна быть трехбуквенным кодом ISO 4217")
+	}
+
+	if service.LogoURL != nil {
+		logoURL, err := url.Parse(*service.LogoURL)
+		switch {
+		case len(*service.LogoURL) > maxLogoURLLength:
+			errs.Add("logo_url", "too_long", "ссылка на логотип длиннее 2048 символов")
+		case err != nil || (logoURL.Scheme != "http" && logoURL.Scheme != "https") || logoURL.Host == "":
+			errs.Add("logo_url", "invalid_url", "ссылка на логотип должна быть абсолютным http(s) URL")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// normalizeCatalogService обрезает пробелы в текстовых полях сервиса каталога, подставляет
+// валюту по умолчанию и превращает пустые необязательные поля в nil
+func normalizeCatalogService(service *model.CatalogService) {
+	service.Name = strings.TrimSpace(service.Name)
+	for i := range service.Aliases {
+		service.Aliases[i] = strings.TrimSpace(service.Aliases[i])
+	}
+	service.Currency = NormalizeCurrency(service.Currency)
+	service.Category = NormalizeCategory(service.Category)
+	service.LogoURL = trimOptional(service.LogoURL)
+}
+
+func trimOptional(value *string) *string {
+	if value == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*value)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+var budgetScopes = map[string]bool{
+	model.BudgetScopeTotal:    true,
+	model.BudgetScopeCategory: true,
+	model.BudgetScopeService:  true,
+}
+
+// ValidateBudget проверяет бюджет перед записью: категория задается только для области
+// category, service_id - только для области service. Существование сервиса и уникальность
+// бюджета на область проверяет БД
+func ValidateBudget(budget *model.Budget) error {
+	var errs model.ValidationErrors
+
+	switch {
+	case budget.UserID == "":
+		errs.Add("user_id", "required", "UUID пользователя обязателен")
+	case !isUUID(budget.UserID):
+		errs.Add("user_id", "invalid_uuid", "user_id должен быть UUID")
+	}
+
+	if !budgetScopes[budget.Scope] {
+		errs.Add("scope", "invalid_scope", "область бюджета должна быть одной из: total, category, service")
+	}
+
+	switch {
+	case budget.Scope == model.BudgetScopeCategory && budget.Category == nil:
+		errs.Add("category", "required", "для бюджета категории нужна категория")
+	case budget.Scope != model.BudgetScopeCategory && budget.Category != nil:
+		errs.Add("category", "unexpected", "категория задается только для бюджета категории")
+	case budget.Category != nil && utf8.RuneCountInString(*budget.Category) > maxCategoryLength:
+		errs.Add("category", "too_long", "категория длиннее 64 символов")
+	}
+
+	switch {
+	case budget.Scope == model.BudgetScopeService && budget.ServiceID == nil:
+		errs.Add("service_id", "required", "для бюджета сервиса нужен service_id")
+	case budget.Scope != model.BudgetScopeService && budget.ServiceID != nil:
+		errs.Add("service_id", "unexpected", "service_id задается только для бюджета сервиса")
+	case budget.ServiceID != nil && *budget.ServiceID <= 0:
+		errs.Add("service_id", "unknown_service", "сервис не найден в каталоге")
+	}
+
+	if budget.Amount < 0 {
+		errs.Add("amount", "negative", "бюджет не может быть отрицательным")
+	}
+
+	if !IsCurrencyCode(budget.Currency) {
+		errs.Add("currency", "invalid_currency", "валюта должна быть трехбуквенным кодом ISO 4217")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// normalizeBudget приводит область и категорию бюджета к нижнему регистру, подставляет
+// валюту по умолчанию и сбрасывает название сервиса, которое только читается
+func normalizeBudget(budget *model.Budget) {
+	budget.Scope = strings.ToLower(strings.TrimSpace(budget.Scope))
+	budget.Category = NormalizeCategory(budget.Category)
+	budget.Currency = NormalizeCurrency(budget.Currency)
+	budget.ServiceName = nil
+}
+
+// ValidateWebhook проверяет вебхук перед записью: абсолютный http(s) URL, хотя бы
+// один известный тип события и секрет достаточной длины. Без allowPrivateNetworks URL
+// не может указывать на localhost и внутренние адреса; имена, которые разрешаются во
+// внутреннюю сеть, отсекаются при соединении. Пустой секрет допустим только при
+// изменении, он оставляет прежний
+func ValidateWebhook(webhook *model.Webhook, allowPrivateNetworks bool) error {
+	var errs model.ValidationErrors
+
+	webhookURL, err := url.Parse(webhook.URL)
+	switch {
+	case webhook.URL == "":
+		errs.Add("url", "required", "URL вебхука обязателен")
+	case len(webhook.URL) > maxWebhookURLLength:
+		errs.Add("url", "too_long", "URL вебхука длиннее 2048 символов")
+	case err != nil || (webhookURL.Scheme != "http" && webhookURL.Scheme != "https") || webhookURL.Host == "":
+		errs.Add("url", "invalid_url", "URL вебхука должен быть абсолютным http(s) URL")
+	case !allowPrivateNetworks && isPrivateWebhookHost(webhookURL.Hostname()):
+		errs.Add("url", "private_address", "URL вебхука не может указывать на localhost или адрес во внутренней сети")
+	}
+
+	if len(webhook.EventTypes) == 0 {
+		errs.Add("event_types", "required", "нужен хотя бы один тип события")
+	}
+	for i, eventType := range webhook.EventTypes {
+		if !model.WebhookEventTypes[eventType] {
+			errs.Add(fmt.Sprintf("event_types[%d]", i), "unknown_event_type",
+				"тип события должен быть одним из: subscription.created, subscription.updated, subscription.deleted, subscription.renewal_due")
+		}
+	}
+
+	if webhook.Secret != "" && (len(webhook.Secret) < minWebhookSecret || len(webhook.Secret) > maxWebhookSecret) {
+		errs.Add("secret", "invalid_length", "секрет вебхука должен быть длиной от 16 до 256 символов")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// normalizeWebhook обрезает пробелы в URL и типах событий и убирает повторы типов
+func normalizeWebhook(webhook *model.Webhook) {
+	webhook.URL = strings.TrimSpace(webhook.URL)
+	eventTypes := make([]string, 0, len(webhook.EventTypes))
+	for _, eventType := range webhook.EventTypes {
+		eventTypes = append(eventTypes, strings.ToLower(strings.TrimSpace(eventType)))
+	}
+	slices.Sort(eventTypes)
+	webhook.EventTypes = slices.Compact(eventTypes)
+}
+
+// ValidateExchangeRates проверяет пакет курсов; поле нарушения содержит индекс курса
+// в пакете, например rates[2].rate
+func ValidateExchangeRates(rates []model.ExchangeRate) error {
+	var errs model.ValidationErrors
+
+	for i, rate := range rates {
+		prefix := fmt.Sprintf("rates[%d].", i)
+		if !IsCurrencyCode(rate.BaseCurrency) {
+			errs.Add(prefix+"base_currency", "invalid_currency", "валюта должна быть трехбуквенным кодом ISO 4217")
+		}
+		if !IsCurrencyCode(rate.QuoteCurrency) {
+			errs.Add(prefix+"quote_currency", "invalid_currency", "валюта должна быть трехбуквенным кодом ISO 4217")
+		} else if rate.QuoteCurrency == rate.BaseCurrency {
+			errs.Add(prefix+"quote_currency", "same_currency", "валюты курса совпадают")
+		}
+		if rate.RateDate.ToTime().IsZero() {
+			errs.Add(prefix+"rate_date", "required", "дата курса обязательна")
+		}
+		if rate.Rate <= 0 {
+			errs.Add(prefix+"rate", "not_positive", "курс должен быть положительным")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
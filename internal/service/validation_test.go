@@ -0,0 +1,529 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validSubscription() *model.SubscriptionDetails {
+	return &model.SubscriptionDetails{
+		ServiceName:     "Yandex Plus",
+		Price:           40000,
+		Currency:        "RUB",
+		BillingPeriod:   model.BillingPeriodMonth,
+		BillingInterval: 1,
+		UserID:          "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		StartDate:       model.DayMonthYear(time.Date(2025, time.July, 1, 0, 0, 0, 0, time.UTC)),
+	}
+}
+
+func TestValidateSubscription(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(subscription *model.SubscriptionDetails)
+		want   []string
+	}{
+		{name: "корректная подписка", modify: func(*model.SubscriptionDetails) {}},
+		{
+			name:   "пустое название",
+			modify: func(s *model.SubscriptionDetails) { s.ServiceName = "   " },
+			want:   []string{"service_name/required"},
+		},
+		{
+			name: "сервис из каталога без названия",
+			modify: func(s *model.SubscriptionDetails) {
+				s.ServiceID = 3
+				s.ServiceName = ""
+			},
+		},
+		{
+			name:   "отрицательный service_id",
+			modify: func(s *model.SubscriptionDetails) { s.ServiceID = -1 },
+			want:   []string{"service_id/unknown_service"},
+		},
+		{
+			name:   "слишком длинное название",
+			modify: func(s *model.SubscriptionDetails) { s.ServiceName = strings.Repeat("я", maxServiceNameLength+1) },
+			want:   []string{"service_name/too_long"},
+		},
+		{
+			name:   "название предельной длины",
+			modify: func(s *model.SubscriptionDetails) { s.ServiceName = strings.Repeat("я", maxServiceNameLength) },
+		},
+		{
+			name:   "отрицательная цена",
+			modify: func(s *model.SubscriptionDetails) { s.Price = -1 },
+			want:   []string{"price/negative"},
+		},
+		{
+			name:   "бесплатная подписка",
+			modify: func(s *model.SubscriptionDetails) { s.Price = 0 },
+		},
+		{
+			name:   "валюта строчными буквами",
+			modify: func(s *model.SubscriptionDetails) { s.Currency = "rub" },
+			want:   []string{"currency/invalid_currency"},
+		},
+		{
+			name:   "валюта не из трех букв",
+			modify: func(s *model.SubscriptionDetails) { s.Currency = "RUBL" },
+			want:   []string{"currency/invalid_currency"},
+		},
+		{
+			name:   "неизвестный период оплаты",
+			modify: func(s *model.SubscriptionDetails) { s.BillingPeriod = "quarter" },
+			want:   []string{"billing_period/invalid_billing_period"},
+		},
+		{
+			name:   "нулевой интервал оплаты",
+			modify: func(s *model.SubscriptionDetails) { s.BillingInterval = 0 },
+			want:   []string{"billing_interval/not_positive"},
+		},
+		{
+			name:   "слишком большой интервал оплаты",
+			modify: func(s *model.SubscriptionDetails) { s.BillingInterval = maxBillingInterval + 1 },
+			want:   []string{"billing_interval/too_large"},
+		},
+		{
+			name: "раз в две недели",
+			modify: func(s *model.SubscriptionDetails) {
+				s.BillingPeriod = model.BillingPeriodWeek
+				s.BillingInterval = 2
+			},
+		},
+		{
+			name: "пробный период внутри срока",
+			modify: func(s *model.SubscriptionDetails) {
+				trialEnd := model.DayMonthYear(time.Date(2025, time.July, 14, 0, 0, 0, 0, time.UTC))
+				s.TrialEnd = &trialEnd
+			},
+		},
+		{
+			name: "пробный период до начала",
+			modify: func(s *model.SubscriptionDetails) {
+				trialEnd := model.DayMonthYear(time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC))
+				s.TrialEnd = &trialEnd
+			},
+			want: []string{"trial_end/before_start_date"},
+		},
+		{
+			name: "пробный период после окончания",
+			modify: func(s *model.SubscriptionDetails) {
+				trialEnd := model.DayMonthYear(time.Date(2025, time.September, 1, 0, 0, 0, 0, time.UTC))
+				s.TrialEnd = &trialEnd
+				s.EndDate = model.DayMonthYear(time.Date(2025, time.August, 31, 0, 0, 0, 0, time.UTC))
+			},
+			want: []string{"trial_end/after_end_date"},
+		},
+		{
+			name: "категория и теги",
+			modify: func(s *model.SubscriptionDetails) {
+				category := "streaming"
+				s.Category = &category
+				s.Tags = model.Tags{"работа", "семья"}
+			},
+		},
+		{
+			name: "длинная категория",
+			modify: func(s *model.SubscriptionDetails) {
+				category := strings.Repeat("к", maxCategoryLength+1)
+				s.Category = &category
+			},
+			want: []string{"category/too_long"},
+		},
+		{
+			name: "пустой и длинный теги",
+			modify: func(s *model.SubscriptionDetails) {
+				s.Tags = model.Tags{"", "семья", strings.Repeat("т", maxTagLength+1)}
+			},
+			want: []string{"tags[0]/required", "tags[2]/too_long"},
+		},
+		{
+			name: "повторы тегов считаются одним",
+			modify: func(s *model.SubscriptionDetails) {
+				s.Tags = nil
+				for i := 0; i <= maxSubscriptionTags; i++ {
+					s.Tags = append(s.Tags, "семья")
+				}
+			},
+		},
+		{
+			name: "слишком много тегов",
+			modify: func(s *model.SubscriptionDetails) {
+				s.Tags = nil
+				for i := 0; i <= maxSubscriptionTags; i++ {
+					s.Tags = append(s.Tags, fmt.Sprintf("тег %d", i))
+				}
+			},
+			want: []string{"tags/too_many"},
+		},
+		{
+			name:   "нет пользователя",
+			modify: func(s *model.SubscriptionDetails) { s.UserID = "" },
+			want:   []string{"user_id/required"},
+		},
+		{
+			name:   "пользователь не UUID",
+			modify: func(s *model.SubscriptionDetails) { s.UserID = "60601fee-2bf1-4721-ae6f-7636e79a0cbz" },
+			want:   []string{"user_id/invalid_uuid"},
+		},
+		{
+			name:   "нет даты начала",
+			modify: func(s *model.SubscriptionDetails) { s.StartDate = model.DayMonthYear{} },
+			want:   []string{"start_date/required"},
+		},
+		{
+			name: "окончание раньше начала",
+			modify: func(s *model.SubscriptionDetails) {
+				s.EndDate = model.DayMonthYear(time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC))
+			},
+			want: []string{"end_date/before_start_date"},
+		},
+		{
+			name: "окончание в день начала",
+			modify: func(s *model.SubscriptionDetails) {
+				s.EndDate = s.StartDate
+			},
+		},
+		{
+			name: "все нарушения сразу",
+			modify: func(s *model.SubscriptionDetails) {
+				*s = model.SubscriptionDetails{Price: -100, UserID: "user"}
+			},
+			want: []string{
+				"service_name/required", "price/negative", "currency/invalid_currency",
+				"billing_period/invalid_billing_period", "billing_interval/not_positive", "user_id/invalid_uuid", "start_date/required",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscription := validSubscription()
+			test.modify(subscription)
+
+			err := ValidateSubscription(subscription)
+			if got := validationCodes(t, err); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ошибки %v, ожидались %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPrepareSubscriptionTagIndexes(t *testing.T) {
+	subscription := validSubscription()
+	subscription.Tags = model.Tags{"Работа", "семья", " ", "Авто", strings.Repeat("т", maxTagLength+1), "работа"}
+
+	// после сортировки пустой тег оказался бы первым, а длинный - последним
+	err := prepareSubscription(subscription)
+	want := []string{"tags[2]/required", "tags[4]/too_long"}
+	if got := validationCodes(t, err); !reflect.DeepEqual(got, want) {
+		t.Errorf("ошибки %v, ожидались %v", got, want)
+	}
+
+	subscription.Tags = model.Tags{"Работа", " семья", "авто", "работа"}
+	if err := prepareSubscription(subscription); err != nil {
+		t.Fatalf("prepareSubscription: %v", err)
+	}
+	if want := (model.Tags{"авто", "работа", "семья"}); !reflect.DeepEqual(subscription.Tags, want) {
+		t.Errorf("теги после проверки %q, ожидались %q", subscription.Tags, want)
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	tests := map[string]bool{
+		"60601fee-2bf1-4721-ae6f-7636e79a0cba": true,
+		"60601FEE-2BF1-4721-AE6F-7636E79A0CBA": true,
+		"60601fee2bf14721ae6f7636e79a0cba":     false,
+		"60601fee-2bf1-4721-ae6f-7636e79a0cb":  false,
+		"60601fee+2bf1-4721-ae6f-7636e79a0cba": false,
+		"":                                     false,
+	}
+	for value, want := range tests {
+		if got := isUUID(value); got != want {
+			t.Errorf("isUUID(%q) = %v, ожидалось %v", value, got, want)
+		}
+	}
+}
+
+func TestValidateSubscriptionPrice(t *testing.T) {
+	day := func(month time.Month, d int) model.DayMonthYear {
+		return model.DayMonthYear(time.Date(2025, month, d, 0, 0, 0, 0, time.UTC))
+	}
+	today := day(time.March, 10).ToTime()
+	subscription := &model.SubscriptionDetails{StartDate: day(time.January, 1), EndDate: day(time.December, 31)}
+
+	tests := []struct {
+		name  string
+		price model.SubscriptionPrice
+		want  []string
+	}{
+		{name: "завтра", price: model.SubscriptionPrice{Price: 500, EffectiveFrom: day(time.March, 11)}},
+		{name: "в день окончания", price: model.SubscriptionPrice{Price: 500, EffectiveFrom: day(time.December, 31)}},
+		{name: "без даты", price: model.SubscriptionPrice{Price: 500}, want: []string{"effective_from/required"}},
+		{name: "сегодня", price: model.SubscriptionPrice{Price: 500, EffectiveFrom: day(time.March, 10)}, want: []string{"effective_from/not_in_future"}},
+		{
+			name:  "после окончания",
+			price: model.SubscriptionPrice{Price: 500, EffectiveFrom: model.DayMonthYear(time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC))},
+			want:  []string{"effective_from/after_end_date"},
+		},
+		{
+			name:  "отрицательная цена в прошлом",
+			price: model.SubscriptionPrice{Price: -1, EffectiveFrom: day(time.February, 1)},
+			want:  []string{"price/negative", "effective_from/not_in_future"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateSubscriptionPrice(&test.price, subscription, today)
+			if got := validationCodes(t, err); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ошибки %v, ожидались %v", got, test.want)
+			}
+		})
+	}
+
+	future := &model.SubscriptionDetails{StartDate: day(time.June, 1)}
+	err := ValidateSubscriptionPrice(&model.SubscriptionPrice{Price: 500, EffectiveFrom: day(time.May, 1)}, future, today)
+	if got := validationCodes(t, err); !reflect.DeepEqual(got, []string{"effective_from/before_start_date"}) {
+		t.Errorf("цена до начала подписки: ошибки %v", got)
+	}
+}
+
+// validationCodes возвращает нарушения из model.ValidationErrors в виде field/code
+func validationCodes(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	var errs model.ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("ожидались model.ValidationErrors, получено %v", err)
+	}
+	codes := make([]string, 0, len(errs))
+	for _, fieldError := range errs {
+		if fieldError.Message == "" {
+			t.Errorf("у ошибки %s/%s нет сообщения", fieldError.Field, fieldError.Code)
+		}
+		codes = append(codes, fieldError.Field+"/"+fieldError.Code)
+	}
+	return codes
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	tests := map[string]string{
+		"":       model.DefaultCurrency,
+		"  ":     model.DefaultCurrency,
+		"usd":    "USD",
+		" eur ":  "EUR",
+		"RUB":    "RUB",
+		"rubles": "RUBLES",
+	}
+	for currency, want := range tests {
+		if got := NormalizeCurrency(currency); got != want {
+			t.Errorf("NormalizeCurrency(%q) = %q, ожидалось %q", currency, got, want)
+		}
+	}
+}
+
+func TestNormalizeSubscription(t *testing.T) {
+	subscription := &model.SubscriptionDetails{Currency: " usd ", BillingPeriod: " Week "}
+	normalizeSubscription(subscription)
+	if subscription.Currency != "USD" || subscription.BillingPeriod != model.BillingPeriodWeek || subscription.BillingInterval != 1 {
+		t.Errorf("после нормализации %+v", subscription)
+	}
+
+	subscription = &model.SubscriptionDetails{BillingInterval: 3}
+	normalizeSubscription(subscription)
+	if subscription.Currency != model.DefaultCurrency || subscription.BillingPeriod != model.BillingPeriodMonth || subscription.BillingInterval != 3 {
+		t.Errorf("значения по умолчанию %+v", subscription)
+	}
+}
+
+func TestValidateExchangeRates(t *testing.T) {
+	rateDate := model.DayMonthYear(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC))
+	rates := []model.ExchangeRate{
+		{BaseCurrency: "USD", QuoteCurrency: "RUB", RateDate: rateDate, Rate: 92.5},
+		{BaseCurrency: "usd", QuoteCurrency: "RUB", RateDate: rateDate, Rate: 0},
+		{BaseCurrency: "EUR", QuoteCurrency: "EUR", Rate: 1},
+	}
+
+	want := []string{
+		"rates[1].base_currency/invalid_currency", "rates[1].rate/not_positive",
+		"rates[2].quote_currency/same_currency", "rates[2].rate_date/required",
+	}
+	if got := validationCodes(t, ValidateExchangeRates(rates)); !reflect.DeepEqual(got, want) {
+		t.Errorf("ошибки %v, ожидались %v", got, want)
+	}
+	if err := ValidateExchangeRates(rates[:1]); err != nil {
+		t.Errorf("корректный курс не прошел проверку: %v", err)
+	}
+}
+
+func TestValidateCatalogService(t *testing.T) {
+	text := func(value string) *string { return &value }
+	valid := func() *model.CatalogService {
+		price := 39900
+		return &model.CatalogService{
+			Name:         "Yandex Plus",
+			Aliases:      []string{"Яндекс Плюс"},
+			Category:     text("streaming"),
+			DefaultPrice: &price,
+			Currency:     "RUB",
+			LogoURL:      text("https://example.com/logo.png"),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(service *model.CatalogService)
+		want   []string
+	}{
+		{name: "корректный сервис", modify: func(*model.CatalogService) {}},
+		{name: "нет названия", modify: func(s *model.CatalogService) { s.Name = "" }, want: []string{"name/required"}},
+		{
+			name: "пустое написание",
+			modify: func(s *model.CatalogService) {
+				s.Aliases = []string{"Яндекс Плюс", "", strings.Repeat("я", 256)}
+			},
+			want: []string{"aliases[1]/required", "aliases[2]/too_long"},
+		},
+		{
+			name: "слишком много написаний",
+			modify: func(s *model.CatalogService) {
+				s.Aliases = nil
+				for i := 0; i <= maxServiceAliases; i++ {
+					s.Aliases = append(s.Aliases, fmt.Sprintf("Yandex Plus %d", i))
+				}
+			},
+			want: []string{"aliases/too_many"},
+		},
+		{name: "длинная категория", modify: func(s *model.CatalogService) { s.Category = text(strings.Repeat("a", 65)) }, want: []string{"category/too_long"}},
+		{name: "отрицательная цена", modify: func(s *model.CatalogService) { *s.DefaultPrice = -1 }, want: []string{"default_price/negative"}},
+		{name: "неверная валюта", modify: func(s *model.CatalogService) { s.Currency = "руб" }, want: []string{"currency/invalid_currency"}},
+		{name: "логотип не по http", modify: func(s *model.CatalogService) { s.LogoURL = text("ftp://example.com/logo.png") }, want: []string{"logo_url/invalid_url"}},
+		{name: "относительный логотип", modify: func(s *model.CatalogService) { s.LogoURL = text("/logo.png") }, want: []string{"logo_url/invalid_url"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := valid()
+			test.modify(service)
+			if got := validationCodes(t, ValidateCatalogService(service)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ошибки %v, ожидались %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeCatalogService(t *testing.T) {
+	category, logoURL := "  ", " https://example.com/logo.png "
+	service := &model.CatalogService{Name: " Yandex Plus ", Aliases: []string{" Яндекс Плюс "}, Category: &category, LogoURL: &logoURL}
+	normalizeCatalogService(service)
+
+	if service.Name != "Yandex Plus" || service.Aliases[0] != "Яндекс Плюс" || service.Currency != model.DefaultCurrency {
+		t.Errorf("после нормализации %+v", service)
+	}
+	if service.Category != nil || service.LogoURL == nil || *service.LogoURL != "https://example.com/logo.png" {
+		t.Errorf("необязательные поля после нормализации: категория %v, логотип %v", service.Category, service.LogoURL)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags := NormalizeTags([]string{" Семья", "работа ", "семья", "", "РАБОТА"})
+	if want := (model.Tags{"", "работа", "семья"}); !reflect.DeepEqual(tags, want) {
+		t.Errorf("NormalizeTags = %q, ожидалось %q", tags, want)
+	}
+	if tags := NormalizeTags(nil); tags == nil || len(tags) != 0 {
+		t.Errorf("NormalizeTags(nil) = %#v, ожидался пустой список", tags)
+	}
+}
+
+func TestNormalizeCategory(t *testing.T) {
+	text := func(value string) *string { return &value }
+	tests := []struct {
+		category *string
+		want     *string
+	}{
+		{category: nil, want: nil},
+		{category: text("   "), want: nil},
+		{category: text(" Streaming "), want: text("streaming")},
+	}
+	for _, test := range tests {
+		got := NormalizeCategory(test.category)
+		if (got == nil) != (test.want == nil) || (got != nil && *got != *test.want) {
+			t.Errorf("NormalizeCategory(%v) = %v, ожидалось %v", test.category, got, test.want)
+		}
+	}
+}
+
+func TestValidateBudget(t *testing.T) {
+	text := func(value string) *string { return &value }
+	number := func(value int) *int { return &value }
+	valid := func() *model.Budget {
+		return &model.Budget{
+			UserID:   "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+			Scope:    model.BudgetScopeCategory,
+			Category: text("streaming"),
+			Amount:   150000,
+			Currency: "RUB",
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(budget *model.Budget)
+		want   []string
+	}{
+		{name: "корректный бюджет категории", modify: func(*model.Budget) {}},
+		{name: "общий бюджет", modify: func(b *model.Budget) { b.Scope, b.Category = model.BudgetScopeTotal, nil }},
+		{name: "бюджет сервиса", modify: func(b *model.Budget) { b.Scope, b.Category, b.ServiceID = model.BudgetScopeService, nil, number(1) }},
+		{name: "нет пользователя", modify: func(b *model.Budget) { b.UserID = "" }, want: []string{"user_id/required"}},
+		{name: "пользователь не UUID", modify: func(b *model.Budget) { b.UserID = "user" }, want: []string{"user_id/invalid_uuid"}},
+		{name: "неизвестная область", modify: func(b *model.Budget) { b.Scope, b.Category = "year", nil }, want: []string{"scope/invalid_scope"}},
+		{name: "категория без названия", modify: func(b *model.Budget) { b.Category = nil }, want: []string{"category/required"}},
+		{name: "категория у общего бюджета", modify: func(b *model.Budget) { b.Scope = model.BudgetScopeTotal }, want: []string{"category/unexpected"}},
+		{name: "длинная категория", modify: func(b *model.Budget) { b.Category = text(strings.Repeat("a", 65)) }, want: []string{"category/too_long"}},
+		{name: "сервис без service_id", modify: func(b *model.Budget) { b.Scope, b.Category = model.BudgetScopeService, nil }, want: []string{"service_id/required"}},
+		{name: "service_id у бюджета категории", modify: func(b *model.Budget) { b.ServiceID = number(1) }, want: []string{"service_id/unexpected"}},
+		{name: "несуществующий service_id", modify: func(b *model.Budget) { b.Scope, b.Category, b.ServiceID = model.BudgetScopeService, nil, number(0) }, want: []string{"service_id/unknown_service"}},
+		{name: "отрицательная сумма", modify: func(b *model.Budget) { b.Amount = -1 }, want: []string{"amount/negative"}},
+		{name: "неверная валюта", modify: func(b *model.Budget) { b.Currency = "рубль" }, want: []string{"currency/invalid_currency"}},
+		{
+			name: "все нарушения сразу",
+			modify: func(b *model.Budget) {
+				b.UserID, b.Scope, b.Amount, b.Currency = "", model.BudgetScopeService, -1, ""
+			},
+			want: []string{"user_id/required", "category/unexpected", "service_id/required", "amount/negative", "currency/invalid_currency"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			budget := valid()
+			test.modify(budget)
+			if got := validationCodes(t, ValidateBudget(budget)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ошибки %v, ожидались %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeBudget(t *testing.T) {
+	category, serviceName := " Streaming ", "Yandex Plus"
+	budget := &model.Budget{Scope: " Category ", Category: &category, Currency: " usd ", ServiceName: &serviceName}
+	normalizeBudget(budget)
+
+	if budget.Scope != model.BudgetScopeCategory || budget.Currency != "USD" {
+		t.Errorf("после нормализации область %q, валюта %q", budget.Scope, budget.Currency)
+	}
+	if budget.Category == nil || *budget.Category != "streaming" || budget.ServiceName != nil {
+		t.Errorf("после нормализации категория %v, сервис %v", budget.Category, budget.ServiceName)
+	}
+}
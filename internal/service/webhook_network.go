@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errPrivateWebhookAddress - адрес получателя вебхука во внутренней сети
+var errPrivateWebhookAddress = errors.New("адрес получателя во внутренней сети")
+
+// nonPublicPrefixes - диапазоны адресов, куда сервис не отправляет вебхуки, чтобы через
+// них нельзя было обратиться к внутренним сервисам и метаданным облака (169.254.169.254).
+// Кольцевые, частные, link-local и групповые адреса проверяются методами netip.Addr
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicAddress сообщает, можно ли отправлять вебхук на адрес ip
+func isPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsValid() || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// isPrivateWebhookHost сообщает, что хост URL вебхука заведомо во внутренней сети:
+// IP-адрес не из публичных диапазонов или localhost. Имена проверяются при соединении
+func isPrivateWebhookHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip, err := netip.ParseAddr(strings.Trim(host, "[]"))
+	return err == nil && !isPublicAddress(ip)
+}
+
+// denyPrivateAddresses - net.Dialer.Control, который запрещает соединения с внутренними
+// адресами. Проверяется адрес, к которому действительно идет соединение после разрешения
+// имени, поэтому имя, которое после проверки URL стало указывать во внутреннюю сеть
+// (DNS rebinding), и перенаправления туда не помогают
+func denyPrivateAddresses(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddress(ip) {
+		return fmt.Errorf("%w: %s", errPrivateWebhookAddress, ip)
+	}
+	return nil
+}
+
+// newWebhookClient возвращает HTTP-клиент доставки вебхуков. Без allowPrivateNetworks
+// клиент соединяется только с публичными адресами и не ходит через прокси из окружения:
+// иначе проверялся бы адрес прокси, а не получателя
+func newWebhookClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !allowPrivateNetworks {
+		dialer.Control = denyPrivateAddresses
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"Effective_Mobile_Test_Project/internal/repository"
+	"Effective_Mobile_Test_Project/internal/util"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Заголовки доставки вебхука. Подпись - HMAC-SHA256 секретом вебхука от строки
+// "<timestamp>.<тело>" в виде "sha256=<hex>"
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookEventIDHeader   = "X-Webhook-Event-ID"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const (
+	defaultWebhookDeliveriesLimit = 100
+	maxWebhookDeliveriesLimit     = 1000
+	maxWebhookErrorLength         = 1024
+)
+
+// WebhookDeliveryOptions
+// Параметры доставки: задержка перед повтором удваивается с каждой неудачной попыткой
+// от InitialBackoff до MaxBackoff, после MaxAttempts попыток доставка переходит в dead.
+// AllowPrivateNetworks разрешает получателей во внутренней сети, например в локальном
+// окружении; по умолчанию вебхуки отправляются только на публичные адреса
+type WebhookDeliveryOptions struct {
+	MaxAttempts          int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	Timeout              time.Duration
+	BatchSize            int
+	AllowPrivateNetworks bool
+}
+
+type WebhookService struct {
+	*repository.WebhookRepository
+	client  *http.Client
+	options WebhookDeliveryOptions
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, options WebhookDeliveryOptions) *WebhookService {
+	return &WebhookService{
+		WebhookRepository: repo,
+		client:            newWebhookClient(options.Timeout, options.AllowPrivateNetworks),
+		options:           options,
+	}
+}
+
+// CreateWebhook сохраняет вебхук. Если секрет не передан, он генерируется; секрет
+// возвращается в webhook только здесь
+func (s *WebhookService) CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	normalizeWebhook(webhook)
+	if webhook.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return util.LogError("не удалось сгенерировать секрет вебхука", err)
+		}
+		webhook.Secret = secret
+	}
+	if err := ValidateWebhook(webhook, s.options.AllowPrivateNetworks); err != nil {
+		return util.LogError("вебхук не прошел проверку", err)
+	}
+
+	if err := s.WebhookRepository.SaveWebhook(ctx, s.Database, webhook); err != nil {
+		return util.LogError("не удалось создать вебхук", err)
+	}
+
+	log.Printf("вебхук id=%d создан: %s, события %v", webhook.ID, webhook.URL, webhook.EventTypes)
+	return nil
+}
+
+func (s *WebhookService) GetWebhookByID(ctx context.Context, id int) (*model.Webhook, error) {
+	webhook, err := s.WebhookRepository.GetWebhookByID(ctx, s.Database, id)
+	if err != nil {
+		return nil, util.LogError("не удалось найти вебхук", err)
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	webhooks, err := s.WebhookRepository.ListWebhooks(ctx, s.Database)
+	if err != nil {
+		return nil, util.LogError("не удалось получить вебхуки", err)
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhookByID перезаписывает вебхук; без секрета в запросе остается прежний
+func (s *WebhookService) UpdateWebhookByID(ctx context.Context, webhook *model.Webhook, id int) error {
+	normalizeWebhook(webhook)
+	if err := ValidateWebhook(webhook, s.options.AllowPrivateNetworks); err != nil {
+		return util.LogError("вебхук не прошел проверку", err)
+	}
+
+	if err := s.WebhookRepository.UpdateWebhookByID(ctx, s.Database, webhook, id); err != nil {
+		return util.LogError("не удалось обновить вебхук", err)
+	}
+	webhook.Secret = ""
+
+	log.Printf("вебхук id=%d обновлен: %s, события %v, активен: %t", id, webhook.URL, webhook.EventTypes, webhook.Active)
+	return nil
+}
+
+func (s *WebhookService) DeleteWebhookByID(ctx context.Context, id int) error {
+	if err := s.WebhookRepository.DeleteWebhookByID(ctx, s.Database, id); err != nil {
+		return util.LogError("не удалось удалить вебхук", err)
+	}
+
+	log.Printf("вебхук id=%d удален", id)
+	return nil
+}
+
+// GetWebhookDeliveries возвращает журнал доставок вебхука; Limit ограничивается
+// maxWebhookDeliveriesLimit
+func (s *WebhookService) GetWebhookDeliveries(ctx context.Context, filter model.WebhookDeliveryFilter) ([]model.WebhookDelivery, error) {
+	if _, err := s.WebhookRepository.GetWebhookByID(ctx, s.Database, filter.WebhookID); err != nil {
+		return nil, util.LogError("не удалось найти вебхук", err)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultWebhookDeliveriesLimit
+	}
+	filter.Limit = min(filter.Limit, maxWebhookDeliveriesLimit)
+
+	deliveries, err := s.WebhookRepository.GetWebhookDeliveries(ctx, s.Database, filter)
+	if err != nil {
+		return nil, util.LogError("не удалось получить журнал доставок вебхука", err)
+	}
+	return deliveries, nil
+}
+
+func (s *WebhookService) GetWebhookDeliveryAttempts(ctx context.Context, webhookID int, deliveryID int64) ([]model.WebhookDeliveryAttempt, error) {
+	attempts, err := s.WebhookRepository.GetWebhookDeliveryAttempts(ctx, s.Database, webhookID, deliveryID)
+	if err != nil {
+		return nil, util.LogError("не удалось получить попытки доставки вебхука", err)
+	}
+	if len(attempts) == 0 {
+		return nil, util.LogError("попыток доставки не найдено", model.ErrNotFound)
+	}
+	return attempts, nil
+}
+
+func (s *WebhookService) RedeliverWebhookDelivery(ctx context.Context, webhookID int, deliveryID int64) (*model.WebhookDelivery, error) {
+	delivery, err := s.WebhookRepository.RedeliverWebhookDelivery(ctx, s.Database, webhookID, deliveryID)
+	if err != nil {
+		return nil, util.LogError("не удалось повторить доставку вебхука", err)
+	}
+
+	log.Printf("доставка id=%d вебхука id=%d поставлена в очередь повторно", deliveryID, webhookID)
+	return delivery, nil
+}
+
+// DeliverWebhooks отправляет очередную порцию доставок. Результат каждой попытки пишется
+// в журнал; неудачная доставка повторяется с экспоненциальной задержкой, пока не
+// исчерпает попытки
+func (s *WebhookService) DeliverWebhooks(ctx context.Context) (int, error) {
+	// пока доставка отправляется, ее не заберет другой процесс; запас покрывает таймаут
+	// каждого запроса порции
+	lease := s.options.Timeout*time.Duration(s.options.BatchSize) + time.Minute
+	deliveries, err := s.WebhookRepository.ClaimDueDeliveries(ctx, s.Database, s.options.BatchSize, lease)
+	if err != nil {
+		return 0, util.LogError("не удалось получить доставки вебхуков", err)
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		attempt := s.send(ctx, &delivery)
+
+		status, nextAttemptAt := model.DeliveryStatusDelivered, time.Now()
+		if attempt.Error != nil {
+			status, nextAttemptAt = model.DeliveryStatusPending, time.Now().Add(s.retryDelay(delivery.Attempts))
+			if delivery.Attempts+1 >= s.options.MaxAttempts {
+				status = model.DeliveryStatusDead
+			}
+			log.Printf("доставка id=%d вебхуку id=%d не удалась (попытка %d, %s): %s",
+				delivery.ID, delivery.WebhookID, delivery.Attempts+1, status, *attempt.Error)
+		} else {
+			delivered++
+		}
+
+		if err := s.WebhookRepository.RecordDeliveryAttempt(ctx, s.Database, &attempt, status, nextAttemptAt); err != nil {
+			return delivered, util.LogError("не удалось записать результат доставки вебхука", err)
+		}
+	}
+
+	if len(deliveries) > 0 {
+		log.Printf("доставлено событий вебхукам: %d из %d", delivered, len(deliveries))
+	}
+	return delivered, nil
+}
+
+// send отправляет доставку получателю; ответ 2xx считается успешным
+func (s *WebhookService) send(ctx context.Context, delivery *model.WebhookDelivery) model.WebhookDeliveryAttempt {
+	attempt := model.WebhookDeliveryAttempt{DeliveryID: delivery.ID}
+	fail := func(err error) model.WebhookDeliveryAttempt {
+		message := err.Error()
+		if len(message) > maxWebhookErrorLength {
+			message = message[:maxWebhookErrorLength]
+		}
+		attempt.Error = &message
+		return attempt
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return fail(err)
+	}
+	timestamp := time.Now().Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WebhookSignatureHeader, SignWebhookPayload(delivery.Secret, timestamp, delivery.Payload))
+	request.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(WebhookEventHeader, delivery.EventType)
+	request.Header.Set(WebhookEventIDHeader, strconv.FormatInt(delivery.EventID, 10))
+	request.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+
+	started := time.Now()
+	response, err := s.client.Do(request)
+	attempt.DurationMS = int(time.Since(started).Milliseconds())
+	if err != nil {
+		return fail(err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	attempt.StatusCode = &response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fail(fmt.Errorf("получатель ответил %s", response.Status))
+	}
+	return attempt
+}
+
+// retryDelay - задержка после attempts+1 неудачной попытки: InitialBackoff, удваивающийся
+// с каждой попыткой, но не больше MaxBackoff
+func (s *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := s.options.InitialBackoff
+	for i := 0; i < attempts && delay < s.options.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.options.MaxBackoff)
+}
+
+// SignWebhookPayload подписывает тело доставки секретом вебхука. Получатель проверяет
+// подпись, вычислив ее так же от заголовка X-Webhook-Timestamp и тела запроса
+func SignWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"127.10.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::1", false},
+		{"::", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			if got := isPublicAddress(netip.MustParseAddr(test.address)); got != test.public {
+				t.Errorf("isPublicAddress(%s) = %v, ожидалось %v", test.address, got, test.public)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		name                 string
+		url                  string
+		allowPrivateNetworks bool
+		code                 string
+	}{
+		{name: "публичное имя", url: "https://example.com/hooks"},
+		{name: "публичный IP", url: "http://93.184.216.34:8080/hooks"},
+		{name: "localhost", url: "http://localhost:8080/hooks", code: "private_address"},
+		{name: "localhost в верхнем регистре с точкой", url: "http://LOCALHOST./hooks", code: "private_address"},
+		{name: "поддомен localhost", url: "http://api.localhost/hooks", code: "private_address"},
+		{name: "кольцевой адрес", url: "http://127.0.0.1/hooks", code: "private_address"},
+		{name: "частная сеть", url: "http://10.0.0.5/hooks", code: "private_address"},
+		{name: "частная сеть 192.168", url: "https://192.168.0.10/hooks", code: "private_address"},
+		{name: "метаданные облака", url: "http://169.254.169.254/latest/meta-data", code: "private_address"},
+		{name: "кольцевой IPv6", url: "http://[::1]:8080/hooks", code: "private_address"},
+		{name: "IPv4 в IPv6", url: "http://[::ffff:127.0.0.1]/hooks", code: "private_address"},
+		{name: "внутренние адреса разрешены", url: "http://127.0.0.1:8080/hooks", allowPrivateNetworks: true},
+		{name: "localhost разрешен", url: "http://localhost/hooks", allowPrivateNetworks: true},
+		{name: "не http", url: "ftp://example.com/hooks", code: "invalid_url"},
+		{name: "без хоста", url: "https:///hooks", code: "invalid_url"},
+		{name: "пустой", url: "", code: "required"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			webhook := &model.Webhook{URL: test.url, EventTypes: []string{model.EventSubscriptionCreated}}
+			err := ValidateWebhook(webhook, test.allowPrivateNetworks)
+			if test.code == "" {
+				if err != nil {
+					t.Fatalf("ValidateWebhook(%q) = %v, ожидался nil", test.url, err)
+				}
+				return
+			}
+
+			var errs model.ValidationErrors
+			if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "url" || errs[0].Code != test.code {
+				t.Fatalf("ValidateWebhook(%q) = %v, ожидалась ошибка url/%s", test.url, err, test.code)
+			}
+		})
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(webhook *model.Webhook)
+		want   []string
+	}{
+		{name: "корректный вебхук", modify: func(*model.Webhook) {}},
+		{name: "нет URL", modify: func(w *model.Webhook) { w.URL = "" }, want: []string{"url/required"}},
+		{name: "длинный URL", modify: func(w *model.Webhook) { w.URL = "https://example.com/" + strings.Repeat("a", 2048) }, want: []string{"url/too_long"}},
+		{name: "не http", modify: func(w *model.Webhook) { w.URL = "ftp://example.com/hooks" }, want: []string{"url/invalid_url"}},
+		{name: "без хоста", modify: func(w *model.Webhook) { w.URL = "https:///hooks" }, want: []string{"url/invalid_url"}},
+		{name: "относительный URL", modify: func(w *model.Webhook) { w.URL = "/hooks" }, want: []string{"url/invalid_url"}},
+		{name: "нет типов событий", modify: func(w *model.Webhook) { w.EventTypes = nil }, want: []string{"event_types/required"}},
+		{
+			name:   "неизвестный тип события",
+			modify: func(w *model.Webhook) { w.EventTypes = []string{model.EventSubscriptionCreated, "subscription.paid"} },
+			want:   []string{"event_types[1]/unknown_event_type"},
+		},
+		{name: "короткий секрет", modify: func(w *model.Webhook) { w.Secret = "secret" }, want: []string{"secret/invalid_length"}},
+		{name: "длинный секрет", modify: func(w *model.Webhook) { w.Secret = strings.Repeat("s", 257) }, want: []string{"secret/invalid_length"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			webhook := &model.Webhook{
+				URL:        "https://example.com/hooks",
+				EventTypes: []string{model.EventSubscriptionCreated},
+				Secret:     "0123456789abcdef",
+			}
+			test.modify(webhook)
+			if got := validationCodes(t, ValidateWebhook(webhook, false)); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ошибки %v, ожидались %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeWebhook(t *testing.T) {
+	webhook := &model.Webhook{
+		URL:        " https://example.com/hooks ",
+		EventTypes: []string{" Subscription.Deleted", "subscription.created", "subscription.deleted "},
+	}
+	normalizeWebhook(webhook)
+
+	want := []string{model.EventSubscriptionCreated, model.EventSubscriptionDeleted}
+	if webhook.URL != "https://example.com/hooks" || !reflect.DeepEqual(webhook.EventTypes, want) {
+		t.Errorf("после нормализации URL %q, типы событий %v", webhook.URL, webhook.EventTypes)
+	}
+}
+
+func TestSignWebhookPayload(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	payload := []byte(`{"id":7}`)
+
+	// подпись сверена с HMAC-SHA256 от "1700000000.{\"id\":7}"
+	want := "sha256=d78f6aa62c72411d2ba308942b6932cf997cd512ce2ea62396bce3bc90a86949"
+	if got := SignWebhookPayload(secret, 1700000000, payload); got != want {
+		t.Errorf("SignWebhookPayload = %q, ожидалась %q", got, want)
+	}
+	if SignWebhookPayload(secret, 1700000001, payload) == want {
+		t.Error("подпись не зависит от времени отправки")
+	}
+	if SignWebhookPayload("другой секрет вебхука", 1700000000, payload) == want {
+		t.Error("подпись не зависит от секрета")
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	s := NewWebhookService(nil, WebhookDeliveryOptions{InitialBackoff: time.Second, MaxBackoff: time.Minute})
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Second},
+		{attempts: 1, want: 2 * time.Second},
+		{attempts: 5, want: 32 * time.Second},
+		{attempts: 6, want: time.Minute},
+		{attempts: 100, want: time.Minute},
+	}
+	for _, test := range tests {
+		if got := s.retryDelay(test.attempts); got != test.want {
+			t.Errorf("retryDelay(%d) = %s, ожидалось %s", test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestWebhookSend(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	payload := []byte(`{"id":7,"type":"subscription.created"}`)
+
+	tests := []struct {
+		name      string
+		status    int
+		succeeded bool
+	}{
+		{name: "2xx", status: http.StatusNoContent, succeeded: true},
+		{name: "ошибка получателя", status: http.StatusInternalServerError},
+		{name: "неверный запрос", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received *http.Request
+			var body []byte
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				received = r
+				body, _ = io.ReadAll(r.Body)
+				w.WriteHeader(test.status)
+			}))
+			defer receiver.Close()
+
+			s := NewWebhookService(nil, WebhookDeliveryOptions{Timeout: 5 * time.Second, AllowPrivateNetworks: true})
+			delivery := &model.WebhookDelivery{
+				ID:        42,
+				EventID:   7,
+				EventType: model.EventSubscriptionCreated,
+				Payload:   payload,
+				URL:       receiver.URL + "/hooks",
+				Secret:    secret,
+			}
+			attempt := s.send(context.Background(), delivery)
+
+			if attempt.DeliveryID != delivery.ID {
+				t.Errorf("DeliveryID = %d, ожидался %d", attempt.DeliveryID, delivery.ID)
+			}
+			if attempt.StatusCode == nil || *attempt.StatusCode != test.status {
+				t.Fatalf("StatusCode = %v, ожидался %d", attempt.StatusCode, test.status)
+			}
+			if succeeded := attempt.Error == nil; succeeded != test.succeeded {
+				t.Errorf("успех = %v, ожидался %v (ошибка %v)", succeeded, test.succeeded, attempt.Error)
+			}
+
+			if received == nil {
+				t.Fatal("получатель не получил запрос")
+			}
+			if received.Method != http.MethodPost || received.URL.Path != "/hooks" {
+				t.Errorf("запрос %s %s, ожидался POST /hooks", received.Method, received.URL.Path)
+			}
+			if string(body) != string(payload) {
+				t.Errorf("тело %s, ожидалось %s", body, payload)
+			}
+			headers := map[string]string{
+				"Content-Type":        "application/json",
+				WebhookEventHeader:    model.EventSubscriptionCreated,
+				WebhookEventIDHeader:  "7",
+				WebhookDeliveryHeader: "42",
+			}
+			for name, want := range headers {
+				if got := received.Header.Get(name); got != want {
+					t.Errorf("%s = %q, ожидался %q", name, got, want)
+				}
+			}
+
+			timestamp, err := strconv.ParseInt(received.Header.Get(WebhookTimestampHeader), 10, 64)
+			if err != nil {
+				t.Fatalf("неверный %s: %v", WebhookTimestampHeader, err)
+			}
+			if got, want := received.Header.Get(WebhookSignatureHeader), SignWebhookPayload(secret, timestamp, body); got != want {
+				t.Errorf("%s = %q, ожидалась %q", WebhookSignatureHeader, got, want)
+			}
+		})
+	}
+}
+
+func TestDenyPrivateAddresses(t *testing.T) {
+	tests := []struct {
+		address string
+		denied  bool
+	}{
+		{"93.184.216.34:443", false},
+		{"[2606:4700:4700::1111]:443", false},
+		{"127.0.0.1:8080", true},
+		{"10.0.0.1:80", true},
+		{"169.254.169.254:80", true},
+		{"[::1]:8080", true},
+		{"[fe80::1]:80", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			err := denyPrivateAddresses("tcp", test.address, nil)
+			if denied := errors.Is(err, errPrivateWebhookAddress); denied != test.denied {
+				t.Errorf("denyPrivateAddresses(%s) = %v, ожидался запрет %v", test.address, err, test.denied)
+			}
+		})
+	}
+}
+
+func TestWebhookSendBlocksPrivateAddresses(t *testing.T) {
+	requests := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer receiver.Close()
+
+	// localhost проверяется по адресу, в который разрешилось имя, как любое другое имя
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "кольцевой адрес", url: receiver.URL},
+		{name: "имя с кольцевым адресом", url: strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)},
+	}
+
+	s := NewWebhookService(nil, WebhookDeliveryOptions{Timeout: 5 * time.Second})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attempt := s.send(context.Background(), &model.WebhookDelivery{ID: 1, URL: test.url, Payload: []byte(`{}`)})
+			if attempt.Error == nil || !strings.Contains(*attempt.Error, errPrivateWebhookAddress.Error()) {
+				t.Fatalf("ошибка %v, ожидалась %q", attempt.Error, errPrivateWebhookAddress)
+			}
+			if attempt.StatusCode != nil {
+				t.Errorf("StatusCode = %d, ожидался nil", *attempt.StatusCode)
+			}
+		})
+	}
+	if requests != 0 {
+		t.Errorf("получатель во внутренней сети получил %d запросов", requests)
+	}
+}
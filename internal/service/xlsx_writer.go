@@ -0,0 +1,136 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// maxXLSXRows - сколько строк вмещает лист Excel, включая заголовок
+const maxXLSXRows = 1048576
+
+// xlsxStaticParts - части книги XLSX, которые не зависят от данных: книга из одного листа
+// с выгрузкой. Лист пишется последним, потоком, прямо в архив
+var xlsxStaticParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Подписки" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxSubscriptionWriter пишет подписки в книгу XLSX. Архив пишется в w по мере записи
+// строк, без временных файлов: строки листа хранят текст прямо в ячейках (inlineStr)
+// вместо общей таблицы строк, которую пришлось бы держать в памяти до конца выгрузки
+type xlsxSubscriptionWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	rows    int
+}
+
+func newXLSXSubscriptionWriter(w io.Writer) (*xlsxSubscriptionWriter, error) {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxStaticParts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	writer := &xlsxSubscriptionWriter{archive: archive, sheet: bufio.NewWriter(file)}
+	writer.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	header := make([]any, 0, len(exportColumns))
+	for _, column := range exportColumns {
+		header = append(header, column.name)
+	}
+	if err := writer.writeRow(header); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *xlsxSubscriptionWriter) Write(subscription *model.SubscriptionDetails) error {
+	if w.rows == maxXLSXRows {
+		return fmt.Errorf("лист XLSX вмещает не больше %d подписок", maxXLSXRows-1)
+	}
+
+	values := make([]any, 0, len(exportColumns))
+	for _, column := range exportColumns {
+		values = append(values, column.value(subscription))
+	}
+	return w.writeRow(values)
+}
+
+// writeRow дописывает строку листа: числа - числовыми ячейками, остальное - текстом.
+// Пустые значения пропускаются, как пустые ячейки
+func (w *xlsxSubscriptionWriter) writeRow(values []any) error {
+	w.rows++
+	w.sheet.WriteString(`<row r="` + strconv.Itoa(w.rows) + `">`)
+	for i, value := range values {
+		ref := xlsxColumnName(i) + strconv.Itoa(w.rows)
+		switch value := value.(type) {
+		case int:
+			w.sheet.WriteString(`<c r="` + ref + `"><v>` + strconv.Itoa(value) + `</v></c>`)
+		case string:
+			if value == "" {
+				continue
+			}
+			w.sheet.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+			if err := xml.EscapeText(w.sheet, []byte(value)); err != nil {
+				return err
+			}
+			w.sheet.WriteString(`</t></is></c>`)
+		}
+	}
+	_, err := w.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (w *xlsxSubscriptionWriter) Close() error {
+	if _, err := w.sheet.WriteString(`</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
+
+// xlsxColumnName возвращает буквенное имя колонки листа по номеру с нуля: A, B, ..., Z, AA
+func xlsxColumnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
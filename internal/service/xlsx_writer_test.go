@@ -0,0 +1,227 @@
+package service
+
+import (
+	"Effective_Mobile_Test_Project/internal/model"
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXLSXColumnName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, "A"},
+		{1, "B"},
+		{25, "Z"},
+		{26, "AA"},
+		{27, "AB"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+		{16383, "XFD"},
+	}
+
+	for _, test := range tests {
+		if got := xlsxColumnName(test.index); got != test.want {
+			t.Errorf("xlsxColumnName(%d) = %s, ожидалось %s", test.index, got, test.want)
+		}
+	}
+}
+
+// xlsxCell - ячейка листа: числовая хранит значение в V, текстовая (inlineStr) - в Text
+type xlsxCell struct {
+	Ref  string `xml:"r,attr"`
+	Type string `xml:"t,attr"`
+	V    string `xml:"v"`
+	Text string `xml:"is>t"`
+}
+
+// readXLSXSheet распаковывает книгу и возвращает ячейки строк листа по ссылкам, например
+// "C2"; числовые ячейки отмечаются префиксом "#"
+func readXLSXSheet(t *testing.T, book []byte) []map[string]string {
+	t.Helper()
+
+	archive, err := zip.NewReader(bytes.NewReader(book), int64(len(book)))
+	if err != nil {
+		t.Fatalf("книга не читается как zip: %v", err)
+	}
+	parts := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("не удалось открыть %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			t.Fatalf("не удалось прочитать %s: %v", file.Name, err)
+		}
+		parts[file.Name] = content
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Fatalf("в книге нет части %s", name)
+		}
+		if err := xml.Unmarshal(content, new(struct{})); err != nil {
+			t.Fatalf("часть %s не является корректным XML: %v", name, err)
+		}
+	}
+
+	var sheet struct {
+		Rows []struct {
+			Ref   string     `xml:"r,attr"`
+			Cells []xlsxCell `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatalf("лист не разбирается: %v", err)
+	}
+
+	rows := make([]map[string]string, 0, len(sheet.Rows))
+	for i, row := range sheet.Rows {
+		if row.Ref != strconv.Itoa(i+1) {
+			t.Errorf("строка %d имеет номер %s", i+1, row.Ref)
+		}
+		cells := map[string]string{}
+		for _, cell := range row.Cells {
+			if !strings.HasSuffix(cell.Ref, row.Ref) {
+				t.Errorf("ячейка %s лежит в строке %s", cell.Ref, row.Ref)
+			}
+			switch cell.Type {
+			case "inlineStr":
+				cells[cell.Ref] = cell.Text
+			case "":
+				cells[cell.Ref] = "#" + cell.V
+			default:
+				t.Errorf("ячейка %s неожиданного типа %s", cell.Ref, cell.Type)
+			}
+		}
+		rows = append(rows, cells)
+	}
+	return rows
+}
+
+func TestXLSXSubscriptionWriter(t *testing.T) {
+	category := "кино & сериалы"
+	trialEnd := model.DayMonthYear(time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC))
+	cancelledAt := time.Date(2025, time.March, 1, 12, 30, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	tests := []struct {
+		name         string
+		subscription model.SubscriptionDetails
+		want         map[string]string
+	}{
+		{
+			name: "все поля",
+			subscription: model.SubscriptionDetails{
+				ID: 7, ServiceID: 3, ServiceName: `<Видео> "Плюс"`, Category: &category,
+				Tags: model.Tags{"семья", "работа"}, Price: 499, Currency: "RUB",
+				BillingPeriod: model.BillingPeriodMonth, BillingInterval: 1, UserID: testUserA,
+				StartDate:   model.DayMonthYear(time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)),
+				EndDate:     model.DayMonthYear(time.Date(2025, time.December, 31, 0, 0, 0, 0, time.UTC)),
+				TrialEnd:    &trialEnd,
+				Status:      "cancelled",
+				CancelledAt: &cancelledAt,
+				Version:     4,
+			},
+			want: map[string]string{
+				"A2": "#7", "B2": "#3", "C2": `<Видео> "Плюс"`, "D2": "кино & сериалы", "E2": "семья;работа",
+				"F2": "#499", "G2": "RUB", "H2": "month", "I2": "#1", "J2": testUserA,
+				"K2": "01-01-2025", "L2": "31-12-2025", "M2": "15-01-2025", "N2": "cancelled",
+				"O2": "2025-03-01T09:30:00Z", "P2": "#4",
+			},
+		},
+		{
+			name: "пустые значения не пишутся",
+			subscription: model.SubscriptionDetails{
+				ID: 8, ServiceID: 3, ServiceName: "Музыка", Tags: model.Tags{}, Price: 0, Currency: "USD",
+				BillingPeriod: model.BillingPeriodYear, BillingInterval: 1, UserID: testUserB,
+				StartDate: model.DayMonthYear(time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC)),
+				Status:    "active", Version: 1,
+			},
+			want: map[string]string{
+				"A2": "#8", "B2": "#3", "C2": "Музыка", "F2": "#0", "G2": "USD", "H2": "year", "I2": "#1",
+				"J2": testUserB, "K2": "01-02-2025", "N2": "active", "P2": "#1",
+			},
+		},
+		{
+			name: "формулы выгружаются текстом",
+			subscription: model.SubscriptionDetails{
+				ID: 10, ServiceName: `=HYPERLINK("https://example.com","Видео")`, Category: ptr("+7 кино"),
+				Tags: model.Tags{"@семья", "работа"}, Currency: "RUB", UserID: testUserA,
+			},
+			want: map[string]string{
+				"A2": "#10", "B2": "#0", "C2": `'=HYPERLINK("https://example.com","Видео")`, "D2": "'+7 кино",
+				"E2": "'@семья;работа", "F2": "#0", "G2": "RUB", "I2": "#0", "J2": testUserA, "P2": "#0",
+			},
+		},
+		{
+			name: "пробелы по краям сохраняются",
+			subscription: model.SubscriptionDetails{
+				ID: 9, ServiceName: "  Книги ", Tags: model.Tags{}, Currency: "RUB", UserID: testUserA,
+			},
+			want: map[string]string{
+				"A2": "#9", "B2": "#0", "C2": "  Книги ", "F2": "#0", "G2": "RUB", "I2": "#0", "J2": testUserA, "P2": "#0",
+			},
+		},
+	}
+
+	header := map[string]string{}
+	for i, column := range exportColumns {
+		header[xlsxColumnName(i)+"1"] = column.name
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var book bytes.Buffer
+			writer, err := NewSubscriptionWriter(ExportFormatXLSX, &book)
+			if err != nil {
+				t.Fatalf("NewSubscriptionWriter: %v", err)
+			}
+			if err := writer.Write(&test.subscription); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			rows := readXLSXSheet(t, book.Bytes())
+			if len(rows) != 2 {
+				t.Fatalf("в листе %d строк, ожидалось 2", len(rows))
+			}
+			if !reflect.DeepEqual(rows[0], header) {
+				t.Errorf("заголовок %v, ожидался %v", rows[0], header)
+			}
+			if !reflect.DeepEqual(rows[1], test.want) {
+				t.Errorf("строка %v, ожидалась %v", rows[1], test.want)
+			}
+		})
+	}
+}
+
+func TestXLSXSubscriptionWriterRowLimit(t *testing.T) {
+	writer, err := newXLSXSubscriptionWriter(io.Discard)
+	if err != nil {
+		t.Fatalf("newXLSXSubscriptionWriter: %v", err)
+	}
+	writer.rows = maxXLSXRows - 1
+
+	subscription := &model.SubscriptionDetails{ID: 1}
+	if err := writer.Write(subscription); err != nil {
+		t.Fatalf("последняя строка листа должна записаться: %v", err)
+	}
+	if err := writer.Write(subscription); err == nil {
+		t.Error("строка сверх размера листа должна вернуть ошибку")
+	}
+}
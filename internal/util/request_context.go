@@ -0,0 +1,31 @@
+package util
+
+import "context"
+
+// AnonymousActor подставляется, когда запрос не сообщил, кто его выполняет
+const AnonymousActor = "anonymous"
+
+type requestMetaKey struct{}
+
+type requestMeta struct {
+	actor     string
+	requestID string
+}
+
+// WithRequestMeta сохраняет в контексте инициатора изменения и ID запроса для журнала аудита
+func WithRequestMeta(ctx context.Context, actor, requestID string) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, requestMeta{actor: actor, requestID: requestID})
+}
+
+func ActorFromContext(ctx context.Context) string {
+	meta, ok := ctx.Value(requestMetaKey{}).(requestMeta)
+	if !ok || meta.actor == "" {
+		return AnonymousActor
+	}
+	return meta.actor
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	meta, _ := ctx.Value(requestMetaKey{}).(requestMeta)
+	return meta.requestID
+}
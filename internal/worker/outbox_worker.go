@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"Effective_Mobile_Test_Project/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// OutboxWorker - релей outbox: периодически публикует неотправленные события
+// и удаляет давно отправленные
+type OutboxWorker struct {
+	service  *service.OutboxService
+	interval time.Duration
+}
+
+func NewOutboxWorker(s *service.OutboxService, interval time.Duration) *OutboxWorker {
+	return &OutboxWorker{
+		service:  s,
+		interval: interval,
+	}
+}
+
+// Run публикует события сразу и затем каждые interval, пока не отменен ctx. Пока
+// в outbox есть неотправленные события, порции публикуются без паузы
+func (worker *OutboxWorker) Run(ctx context.Context) {
+	log.Printf("релей outbox запущен: интервал %s", worker.interval)
+
+	ticker := time.NewTicker(worker.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			sent, err := worker.service.RelayEvents(ctx)
+			if err != nil {
+				log.Printf("ошибка публикации событий outbox: %v", err)
+			}
+			if err != nil || sent == 0 || ctx.Err() != nil {
+				break
+			}
+		}
+		if _, err := worker.service.PurgeSentEvents(ctx); err != nil {
+			log.Printf("ошибка очистки outbox: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("релей outbox остановлен")
+			return
+		case <-ticker.C:
+		}
+	}
+}
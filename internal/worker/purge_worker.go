@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"Effective_Mobile_Test_Project/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeWorker периодически окончательно удаляет подписки, мягко удаленные
+// дольше срока хранения, и ключи идемпотентности с истекшим сроком. Нулевой
+// срок хранения отключает только удаление подписок
+type PurgeWorker struct {
+	service   *service.SubscriptionService
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurgeWorker(s *service.SubscriptionService, retention, interval time.Duration) *PurgeWorker {
+	return &PurgeWorker{
+		service:   s,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run выполняет очистку сразу и затем каждые interval, пока не отменен ctx
+func (worker *PurgeWorker) Run(ctx context.Context) {
+	log.Printf("очистка удаленных подписок и ключей идемпотентности запущена: срок хранения %s, интервал %s", worker.retention, worker.interval)
+
+	ticker := time.NewTicker(worker.interval)
+	defer ticker.Stop()
+
+	for {
+		if worker.retention > 0 {
+			if _, err := worker.service.PurgeDeletedSubscriptions(ctx, worker.retention); err != nil {
+				log.Printf("ошибка очистки удаленных подписок: %v", err)
+			}
+		}
+		if _, err := worker.service.PurgeExpiredIdempotencyKeys(ctx); err != nil {
+			log.Printf("ошибка удаления просроченных ключей идемпотентности: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("очистка удаленных подписок и ключей идемпотентности остановлена")
+			return
+		case <-ticker.C:
+		}
+	}
+}
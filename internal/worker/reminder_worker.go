@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"Effective_Mobile_Test_Project/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// ReminderWorker периодически создает и доставляет напоминания о предстоящих оплатах
+type ReminderWorker struct {
+	service  *service.ReminderService
+	interval time.Duration
+}
+
+func NewReminderWorker(s *service.ReminderService, interval time.Duration) *ReminderWorker {
+	return &ReminderWorker{
+		service:  s,
+		interval: interval,
+	}
+}
+
+// Run обрабатывает напоминания сразу и затем каждые interval, пока не отменен ctx
+func (worker *ReminderWorker) Run(ctx context.Context) {
+	log.Printf("планировщик напоминаний запущен: интервал %s", worker.interval)
+
+	ticker := time.NewTicker(worker.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := worker.service.ProcessReminders(ctx); err != nil {
+			log.Printf("ошибка обработки напоминаний: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("планировщик напоминаний остановлен")
+			return
+		case <-ticker.C:
+		}
+	}
+}
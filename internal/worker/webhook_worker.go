@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"Effective_Mobile_Test_Project/internal/service"
+	"context"
+	"log"
+	"time"
+)
+
+// WebhookWorker периодически доставляет события подписок вебхукам
+type WebhookWorker struct {
+	service  *service.WebhookService
+	interval time.Duration
+}
+
+func NewWebhookWorker(s *service.WebhookService, interval time.Duration) *WebhookWorker {
+	return &WebhookWorker{
+		service:  s,
+		interval: interval,
+	}
+}
+
+// Run доставляет события сразу и затем каждые interval, пока не отменен ctx. Пока
+// очередь не пуста, порции отправляются без паузы
+func (worker *WebhookWorker) Run(ctx context.Context) {
+	log.Printf("доставка вебхуков запущена: интервал %s", worker.interval)
+
+	ticker := time.NewTicker(worker.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			delivered, err := worker.service.DeliverWebhooks(ctx)
+			if err != nil {
+				log.Printf("ошибка доставки вебхуков: %v", err)
+			}
+			if err != nil || delivered == 0 || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("доставка вебхуков остановлена")
+			return
+		case <-ticker.C:
+		}
+	}
+}
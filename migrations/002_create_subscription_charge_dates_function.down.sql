@@ -0,0 +1 @@
+DROP FUNCTION IF EXISTS subscription_charge_dates(DATE, DATE, DATE, DATE);
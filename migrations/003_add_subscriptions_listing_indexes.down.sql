@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_subscriptions_end_date_id;
+DROP INDEX IF EXISTS idx_subscriptions_start_date_id;
+DROP INDEX IF EXISTS idx_subscriptions_price_id;
+DROP INDEX IF EXISTS idx_subscriptions_service_name_id;
+DROP INDEX IF EXISTS idx_subscriptions_user_id_id;
@@ -0,0 +1,6 @@
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id_id ON subscriptions (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_name_id ON subscriptions (service_name, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_price_id ON subscriptions (price, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_date_id ON subscriptions (start_date, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date_id
+    ON subscriptions ((COALESCE(NULLIF(end_date, '0001-01-01'), '9999-12-31'::date)), id);
@@ -0,0 +1,5 @@
+ALTER TABLE subscription_audit DROP CONSTRAINT IF EXISTS subscription_audit_action_check;
+ALTER TABLE subscription_audit ADD CONSTRAINT subscription_audit_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore')) NOT VALID;
+
+DROP TABLE IF EXISTS subscription_prices;
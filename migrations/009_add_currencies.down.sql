@@ -0,0 +1,8 @@
+DROP FUNCTION IF EXISTS convert_amount(BIGINT, CHAR(3), CHAR(3), DATE);
+
+UPDATE subscription_prices SET price = price / 100;
+UPDATE subscriptions SET price = price / 100;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS currency;
+
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS currencies;
@@ -0,0 +1,72 @@
+CREATE TABLE IF NOT EXISTS currencies (
+    code CHAR(3) PRIMARY KEY,
+    minor_units SMALLINT NOT NULL CHECK (minor_units BETWEEN 0 AND 4),
+    name TEXT NOT NULL
+);
+
+INSERT INTO currencies (code, minor_units, name) VALUES
+    ('RUB', 2, 'Российский рубль'),
+    ('USD', 2, 'Доллар США'),
+    ('EUR', 2, 'Евро')
+ON CONFLICT (code) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    base_currency CHAR(3) NOT NULL REFERENCES currencies (code),
+    quote_currency CHAR(3) NOT NULL REFERENCES currencies (code),
+    rate_date DATE NOT NULL,
+    rate NUMERIC(20, 10) NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (base_currency, quote_currency, rate_date),
+    CHECK (base_currency <> quote_currency)
+);
+
+-- цены хранятся в минимальных единицах валюты (копейках, центах)
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'RUB'
+    CONSTRAINT subscriptions_currency_fkey REFERENCES currencies (code);
+UPDATE subscriptions SET price = price * 100;
+UPDATE subscription_prices SET price = price * 100;
+
+CREATE OR REPLACE FUNCTION convert_amount(
+    amount BIGINT,
+    from_currency CHAR(3),
+    to_currency CHAR(3),
+    on_date DATE
+) RETURNS BIGINT
+LANGUAGE plpgsql STABLE AS $$
+DECLARE
+    found_rate NUMERIC;
+    scale_diff INTEGER;
+BEGIN
+    IF from_currency = to_currency THEN
+        RETURN amount;
+    END IF;
+
+    SELECT r.rate INTO found_rate
+    FROM (
+        SELECT er.rate, er.rate_date
+        FROM exchange_rates er
+        WHERE er.base_currency = from_currency AND er.quote_currency = to_currency AND er.rate_date <= on_date
+        UNION ALL
+        SELECT 1 / er.rate, er.rate_date
+        FROM exchange_rates er
+        WHERE er.base_currency = to_currency AND er.quote_currency = from_currency AND er.rate_date <= on_date
+    ) AS r
+    ORDER BY r.rate_date DESC
+    LIMIT 1;
+
+    IF found_rate IS NULL THEN
+        RAISE EXCEPTION 'нет курса % к % на %', from_currency, to_currency, to_char(on_date, 'DD-MM-YYYY')
+            USING ERRCODE = '22023', CONSTRAINT = 'exchange_rate_required';
+    END IF;
+
+    SELECT t.minor_units - f.minor_units INTO scale_diff
+    FROM currencies f, currencies t
+    WHERE f.code = from_currency AND t.code = to_currency;
+
+    IF scale_diff IS NULL THEN
+        RAISE EXCEPTION 'неизвестная валюта %', to_currency
+            USING ERRCODE = '22023', CONSTRAINT = 'currency_required';
+    END IF;
+
+    RETURN ROUND(amount * found_rate * power(10::numeric, scale_diff));
+END
+$$;
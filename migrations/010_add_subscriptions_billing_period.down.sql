@@ -0,0 +1,25 @@
+DROP FUNCTION IF EXISTS subscription_charge_dates(DATE, DATE, DATE, DATE, TEXT, INTEGER);
+
+CREATE OR REPLACE FUNCTION subscription_charge_dates(
+    anchor_date DATE,
+    until_date DATE,
+    from_date DATE,
+    to_date DATE
+) RETURNS SETOF DATE
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT charged_on
+    FROM (
+        SELECT (anchor_date + k * INTERVAL '1 month')::date AS charged_on
+        FROM generate_series(
+            GREATEST(0, ((date_part('year', from_date) - date_part('year', anchor_date)) * 12
+                + date_part('month', from_date) - date_part('month', anchor_date))::int - 1),
+            ((date_part('year', LEAST(COALESCE(until_date, to_date), to_date)) - date_part('year', anchor_date)) * 12
+                + date_part('month', LEAST(COALESCE(until_date, to_date), to_date)) - date_part('month', anchor_date))::int
+        ) AS k
+    ) AS charges
+    WHERE charged_on >= GREATEST(anchor_date, from_date)
+      AND charged_on <= LEAST(COALESCE(until_date, to_date), to_date)
+$$;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS billing_interval;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS billing_period;
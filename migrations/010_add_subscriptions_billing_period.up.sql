@@ -0,0 +1,63 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_period TEXT NOT NULL DEFAULT 'month'
+    CHECK (billing_period IN ('day', 'week', 'month', 'year'));
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS billing_interval INTEGER NOT NULL DEFAULT 1
+    CHECK (billing_interval > 0);
+
+DROP FUNCTION IF EXISTS subscription_charge_dates(DATE, DATE, DATE, DATE);
+
+-- даты оплаты отсчитываются от anchor_date: anchor_date + k периодов. Для месяцев и лет
+-- каждая дата считается от якоря, а не от предыдущей оплаты, поэтому подписка от 31-го
+-- оплачивается 28/29 февраля, а в марте снова 31-го
+CREATE OR REPLACE FUNCTION subscription_charge_dates(
+    anchor_date DATE,
+    until_date DATE,
+    from_date DATE,
+    to_date DATE,
+    billing_period TEXT,
+    billing_interval INTEGER
+) RETURNS SETOF DATE
+LANGUAGE plpgsql IMMUTABLE AS $$
+DECLARE
+    first_date DATE := GREATEST(anchor_date, from_date);
+    last_date DATE := LEAST(COALESCE(until_date, to_date), to_date);
+    step_days INTEGER;
+    step_months INTEGER;
+    first_k INTEGER;
+    last_k INTEGER;
+BEGIN
+    IF first_date > last_date THEN
+        RETURN;
+    END IF;
+
+    CASE billing_period
+        WHEN 'day' THEN step_days := billing_interval;
+        WHEN 'week' THEN step_days := 7 * billing_interval;
+        WHEN 'month' THEN step_months := billing_interval;
+        WHEN 'year' THEN step_months := 12 * billing_interval;
+        ELSE RAISE EXCEPTION 'неизвестный период оплаты %', billing_period;
+    END CASE;
+
+    IF step_days IS NOT NULL THEN
+        first_k := ceil((first_date - anchor_date)::numeric / step_days);
+        last_k := floor((last_date - anchor_date)::numeric / step_days);
+        RETURN QUERY
+            SELECT anchor_date + k * step_days
+            FROM generate_series(first_k, last_k) AS k;
+        RETURN;
+    END IF;
+
+    -- шаг назад от first_k покрывает оплату в последние дни короткого месяца
+    first_k := GREATEST(0, ((date_part('year', first_date) - date_part('year', anchor_date)) * 12
+        + date_part('month', first_date) - date_part('month', anchor_date))::int / step_months - 1);
+    last_k := ((date_part('year', last_date) - date_part('year', anchor_date)) * 12
+        + date_part('month', last_date) - date_part('month', anchor_date))::int / step_months;
+
+    RETURN QUERY
+        SELECT charged_on
+        FROM (
+            SELECT (anchor_date + k * step_months * INTERVAL '1 month')::date AS charged_on
+            FROM generate_series(first_k, last_k) AS k
+        ) AS charges
+        WHERE charged_on BETWEEN first_date AND last_date;
+END
+$$;
@@ -0,0 +1,8 @@
+ALTER TABLE subscription_audit DROP CONSTRAINT IF EXISTS subscription_audit_action_check;
+ALTER TABLE subscription_audit ADD CONSTRAINT subscription_audit_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'price_change')) NOT VALID;
+
+DROP TABLE IF EXISTS subscription_pauses;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS trial_end;
@@ -0,0 +1,20 @@
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS trial_end DATE;
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    paused_from DATE NOT NULL,
+    resumed_on DATE,
+    CHECK (resumed_on IS NULL OR resumed_on >= paused_from)
+);
+
+-- у подписки не больше одной незавершенной паузы
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_pauses_open
+    ON subscription_pauses (subscription_id) WHERE resumed_on IS NULL;
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription_id
+    ON subscription_pauses (subscription_id, paused_from);
+
+ALTER TABLE subscription_audit DROP CONSTRAINT IF EXISTS subscription_audit_action_check;
+ALTER TABLE subscription_audit ADD CONSTRAINT subscription_audit_action_check
+    CHECK (action IN ('create', 'update', 'delete', 'restore', 'price_change', 'pause', 'resume', 'cancel'));
@@ -0,0 +1,8 @@
+-- исходные написания service_name не восстанавливаются: подписки сохраняют канонические названия
+DROP INDEX IF EXISTS idx_subscriptions_service_id_id;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS service_id;
+
+DROP TABLE IF EXISTS service_aliases;
+DROP TABLE IF EXISTS services;
+
+DROP FUNCTION IF EXISTS normalize_service_name(TEXT);
@@ -0,0 +1,98 @@
+-- ключ сопоставления названий сервиса: нижний регистр, ё как е, любые последовательности
+-- знаков кроме букв и цифр схлопываются в один пробел. Название только из знаков
+-- сравнивается целиком в нижнем регистре
+CREATE OR REPLACE FUNCTION normalize_service_name(name TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE STRICT AS $$
+    SELECT COALESCE(
+        NULLIF(btrim(regexp_replace(translate(lower(name), 'ё', 'е'), '[^[:alnum:]]+', ' ', 'g')), ''),
+        lower(btrim(name))
+    )
+$$;
+
+CREATE TABLE IF NOT EXISTS services (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    category TEXT,
+    default_price INTEGER CHECK (default_price >= 0),
+    currency CHAR(3) NOT NULL DEFAULT 'RUB' CONSTRAINT services_currency_fkey REFERENCES currencies (code),
+    logo_url TEXT
+);
+
+-- написания, по которым находится сервис; каноническое название тоже хранится здесь,
+-- поэтому два сервиса не могут называться одинаково
+CREATE TABLE IF NOT EXISTS service_aliases (
+    id SERIAL PRIMARY KEY,
+    service_id INTEGER NOT NULL REFERENCES services (id) ON DELETE CASCADE,
+    alias TEXT NOT NULL,
+    normalized_alias TEXT GENERATED ALWAYS AS (normalize_service_name(alias)) STORED
+        CONSTRAINT service_aliases_normalized_alias_key UNIQUE
+        CHECK (normalized_alias <> '')
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_aliases_service_id ON service_aliases (service_id);
+
+-- сервисы, которые пишут разными алфавитами и нормализация не сводит к одному ключу
+INSERT INTO services (name, category)
+SELECT seed.name, seed.category
+FROM (VALUES
+    ('Yandex Plus', 'streaming'),
+    ('Kinopoisk', 'streaming'),
+    ('VK Music', 'music')
+) AS seed (name, category)
+WHERE NOT EXISTS (
+    SELECT 1 FROM service_aliases a WHERE a.normalized_alias = normalize_service_name(seed.name)
+);
+
+INSERT INTO service_aliases (service_id, alias)
+SELECT s.id, seed.alias
+FROM (VALUES
+    ('Yandex Plus', 'Yandex Plus'),
+    ('Yandex Plus', 'Яндекс Плюс'),
+    ('Yandex Plus', 'Яндекс Plus'),
+    ('Kinopoisk', 'Kinopoisk'),
+    ('Kinopoisk', 'Кинопоиск'),
+    ('VK Music', 'VK Music'),
+    ('VK Music', 'VK Музыка')
+) AS seed (name, alias)
+JOIN services s ON s.name = seed.name
+ON CONFLICT (normalized_alias) DO NOTHING;
+
+-- подписки, созданные до проверки названия, могут быть без названия: пустое название
+-- не дает ключа сопоставления, поэтому такие подписки получают сервис unknown
+UPDATE subscriptions
+SET service_name = 'unknown', version = version + 1
+WHERE btrim(service_name) = '';
+
+-- остальные названия из подписок становятся сервисами каталога: из написаний с одним
+-- ключом каноническим выбирается самое частое
+INSERT INTO services (name)
+SELECT DISTINCT ON (normalize_service_name(names.service_name)) names.service_name
+FROM (
+    SELECT service_name, count(*) AS uses FROM subscriptions GROUP BY service_name
+) AS names
+WHERE NOT EXISTS (
+    SELECT 1 FROM service_aliases a WHERE a.normalized_alias = normalize_service_name(names.service_name)
+)
+ORDER BY normalize_service_name(names.service_name), names.uses DESC, names.service_name;
+
+INSERT INTO service_aliases (service_id, alias)
+SELECT s.id, s.name
+FROM services s
+WHERE NOT EXISTS (SELECT 1 FROM service_aliases a WHERE a.service_id = s.id);
+
+-- service_name остается в подписках как копия канонического названия, чтобы по нему
+-- сортировать и группировать без соединения с каталогом
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS service_id INTEGER
+    CONSTRAINT subscriptions_service_id_fkey REFERENCES services (id);
+
+UPDATE subscriptions sub
+SET service_id = a.service_id,
+    service_name = s.name,
+    version = sub.version + CASE WHEN sub.service_name <> s.name THEN 1 ELSE 0 END
+FROM service_aliases a
+JOIN services s ON s.id = a.service_id
+WHERE a.normalized_alias = normalize_service_name(sub.service_name);
+
+ALTER TABLE subscriptions ALTER COLUMN service_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id_id ON subscriptions (service_id, id);
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS subscription_tags;
+DROP TABLE IF EXISTS tags;
+
+DROP INDEX IF EXISTS idx_subscriptions_category;
+DROP INDEX IF EXISTS idx_services_category;
+
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS category;
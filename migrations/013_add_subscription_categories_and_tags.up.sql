@@ -0,0 +1,21 @@
+-- категории сравниваются в нижнем регистре, чтобы группировка не делила одну категорию на несколько
+UPDATE services SET category = NULLIF(lower(btrim(category)), '') WHERE category IS NOT NULL;
+
+-- категория подписки заменяет категорию сервиса из каталога; NULL - использовать категорию сервиса
+ALTER TABLE subscriptions ADD COLUMN IF NOT EXISTS category TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_services_category ON services (category) WHERE category IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_subscriptions_category ON subscriptions (category) WHERE category IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE CHECK (name <> '')
+);
+
+CREATE TABLE IF NOT EXISTS subscription_tags (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag_id ON subscription_tags (tag_id, subscription_id);
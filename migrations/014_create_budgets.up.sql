@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS budgets (
+    id SERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,
+    scope TEXT NOT NULL CHECK (scope IN ('total', 'category', 'service')),
+    category TEXT,
+    service_id INTEGER CONSTRAINT budgets_service_id_fkey REFERENCES services (id) ON DELETE CASCADE,
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    currency CHAR(3) NOT NULL DEFAULT 'RUB' CONSTRAINT budgets_currency_fkey REFERENCES currencies (code),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT budgets_scope_target_check CHECK (
+        (scope = 'total' AND category IS NULL AND service_id IS NULL) OR
+        (scope = 'category' AND category IS NOT NULL AND service_id IS NULL) OR
+        (scope = 'service' AND service_id IS NOT NULL AND category IS NULL)
+    )
+);
+
+-- у пользователя не больше одного бюджета на область: общий, категорию или сервис
+CREATE UNIQUE INDEX IF NOT EXISTS budgets_scope_key
+    ON budgets (user_id, scope, COALESCE(category, ''), COALESCE(service_id, 0));
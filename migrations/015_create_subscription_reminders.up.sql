@@ -0,0 +1,18 @@
+-- напоминания о предстоящих оплатах. На дату оплаты подписки создается не больше одного
+-- напоминания, поэтому повторный проход планировщика не дублирует их; pending-напоминание
+-- доставляется повторно, пока доставка не будет подтверждена
+CREATE TABLE IF NOT EXISTS subscription_reminders (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    billing_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ,
+    CONSTRAINT subscription_reminders_billing_date_key UNIQUE (subscription_id, billing_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_reminders_pending
+    ON subscription_reminders (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1 @@
+DROP SEQUENCE IF EXISTS outbox_event_id_seq;
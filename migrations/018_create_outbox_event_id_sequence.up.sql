@@ -0,0 +1,9 @@
+-- ID событий выдаются из собственной последовательности, а не из журнала аудита, чтобы
+-- события не зависели от него. Последовательность продолжает уже выданные ID событий
+CREATE SEQUENCE IF NOT EXISTS outbox_event_id_seq AS BIGINT;
+
+SELECT setval('outbox_event_id_seq', GREATEST(
+    (SELECT COALESCE(MAX(event_id), 0) FROM outbox),
+    (SELECT COALESCE(MAX(id), 0) FROM subscription_audit),
+    1
+));
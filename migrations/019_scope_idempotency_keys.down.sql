@@ -0,0 +1,8 @@
+DELETE FROM idempotency_keys;
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (key);
+
+ALTER TABLE idempotency_keys ALTER COLUMN response TYPE JSONB USING convert_from(response, 'UTF8')::jsonb;
+ALTER TABLE idempotency_keys DROP COLUMN response_headers;
+ALTER TABLE idempotency_keys DROP COLUMN scope;
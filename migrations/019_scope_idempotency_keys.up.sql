@@ -0,0 +1,12 @@
+-- Ключ идемпотентности действует в пределах пользователя подписки, а ответ хранится
+-- байт в байт вместе с заголовками, чтобы повтор возвращал ровно исходный ответ.
+-- Старые записи хешировали сырое тело запроса и не хранили заголовки, поэтому
+-- перенести их нельзя: ключи живут не дольше idempotencyConfig.ttl
+DELETE FROM idempotency_keys;
+
+ALTER TABLE idempotency_keys ADD COLUMN scope TEXT NOT NULL;
+ALTER TABLE idempotency_keys ADD COLUMN response_headers JSONB;
+ALTER TABLE idempotency_keys ALTER COLUMN response TYPE BYTEA USING convert_to(response::text, 'UTF8');
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (scope, key);
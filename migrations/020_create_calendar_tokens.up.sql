@@ -0,0 +1,8 @@
+-- токены ссылок на календари оплат. Хранится только SHA-256 токена: сам токен
+-- показывается пользователю один раз при выпуске. У пользователя не больше одного
+-- токена, выпуск нового заменяет прежний, удаление строки отзывает ссылку
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id UUID PRIMARY KEY,
+    token_hash BYTEA NOT NULL CHECK (octet_length(token_hash) = 32),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,9 @@
+-- состояние события outbox. Событие, которое не удалось опубликовать за
+-- outboxConfig.maxAttempts попыток, переходит в dead и больше не публикуется релеем
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'sent', 'dead'));
+
+UPDATE outbox SET status = 'sent' WHERE sent_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_outbox_unsent;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (next_attempt_at, id) WHERE status = 'pending';
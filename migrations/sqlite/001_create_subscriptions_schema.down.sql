@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS subscription_tags;
+DROP TABLE IF EXISTS tags;
+DROP TABLE IF EXISTS subscription_pauses;
+DROP TABLE IF EXISTS subscription_prices;
+DROP TABLE IF EXISTS subscriptions;
+DROP TABLE IF EXISTS service_aliases;
+DROP TABLE IF EXISTS services;
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS currencies;
@@ -0,0 +1,127 @@
+-- схема хранилища подписок для SQLite. Повторяет таблицы миграций Postgres 001-013, которые
+-- нужны подпискам; ограничения, на которые ссылается приложение, названы так же.
+-- Даты хранятся текстом YYYY-MM-DD, моменты времени - текстом YYYY-MM-DD HH:MM:SS.SSSSSS
+-- в UTC, поэтому их можно сравнивать как строки. UUID хранится в каноническом виде:
+-- нижний регистр, группы 8-4-4-4-12
+
+CREATE TABLE IF NOT EXISTS currencies (
+    code TEXT PRIMARY KEY CHECK (length(code) = 3),
+    minor_units INTEGER NOT NULL CHECK (minor_units BETWEEN 0 AND 4),
+    name TEXT NOT NULL
+);
+
+INSERT OR IGNORE INTO currencies (code, minor_units, name) VALUES
+    ('RUB', 2, 'Российский рубль'),
+    ('USD', 2, 'Доллар США'),
+    ('EUR', 2, 'Евро');
+
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    base_currency TEXT NOT NULL CONSTRAINT exchange_rates_base_currency_fkey REFERENCES currencies (code),
+    quote_currency TEXT NOT NULL CONSTRAINT exchange_rates_quote_currency_fkey REFERENCES currencies (code),
+    rate_date DATE NOT NULL CHECK (rate_date IS date(rate_date)),
+    rate REAL NOT NULL CHECK (rate > 0),
+    PRIMARY KEY (base_currency, quote_currency, rate_date),
+    CHECK (base_currency <> quote_currency)
+);
+
+CREATE TABLE IF NOT EXISTS services (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    category TEXT,
+    default_price INTEGER CHECK (default_price >= 0),
+    currency TEXT NOT NULL DEFAULT 'RUB' CONSTRAINT services_currency_fkey REFERENCES currencies (code),
+    logo_url TEXT
+);
+
+-- в SQLite нет регулярных выражений, поэтому ключ сопоставления normalized_alias считает
+-- приложение (repository.NormalizeServiceName), а не генерируемая колонка
+CREATE TABLE IF NOT EXISTS service_aliases (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL REFERENCES services (id) ON DELETE CASCADE,
+    alias TEXT NOT NULL,
+    normalized_alias TEXT NOT NULL
+        CONSTRAINT service_aliases_normalized_alias_key UNIQUE
+        CONSTRAINT service_aliases_normalized_alias_check CHECK (normalized_alias <> '')
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_aliases_service_id ON service_aliases (service_id);
+
+INSERT INTO services (name, category) VALUES
+    ('Yandex Plus', 'streaming'),
+    ('Kinopoisk', 'streaming'),
+    ('VK Music', 'music');
+
+INSERT INTO service_aliases (service_id, alias, normalized_alias)
+SELECT s.id, seed.alias, seed.normalized_alias
+FROM (
+    SELECT 'Yandex Plus' AS name, 'Yandex Plus' AS alias, 'yandex plus' AS normalized_alias
+    UNION ALL SELECT 'Yandex Plus', 'Яндекс Плюс', 'яндекс плюс'
+    UNION ALL SELECT 'Yandex Plus', 'Яндекс Plus', 'яндекс plus'
+    UNION ALL SELECT 'Kinopoisk', 'Kinopoisk', 'kinopoisk'
+    UNION ALL SELECT 'Kinopoisk', 'Кинопоиск', 'кинопоиск'
+    UNION ALL SELECT 'VK Music', 'VK Music', 'vk music'
+    UNION ALL SELECT 'VK Music', 'VK Музыка', 'vk музыка'
+) AS seed
+JOIN services s ON s.name = seed.name;
+
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service_id INTEGER NOT NULL CONSTRAINT subscriptions_service_id_fkey REFERENCES services (id),
+    service_name TEXT NOT NULL,
+    category TEXT,
+    price INTEGER NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'RUB' CONSTRAINT subscriptions_currency_fkey REFERENCES currencies (code),
+    billing_period TEXT NOT NULL DEFAULT 'month'
+        CHECK (billing_period IN ('day', 'week', 'month', 'year')),
+    billing_interval INTEGER NOT NULL DEFAULT 1 CHECK (billing_interval > 0),
+    user_id TEXT NOT NULL CONSTRAINT subscriptions_user_id_check CHECK (
+        length(user_id) = 36 AND user_id NOT GLOB '*[^0-9a-f-]*' AND length(replace(user_id, '-', '')) = 32
+        AND substr(user_id, 9, 1) || substr(user_id, 14, 1) || substr(user_id, 19, 1) || substr(user_id, 24, 1) = '----'
+    ),
+    start_date DATE NOT NULL CHECK (start_date IS date(start_date)),
+    end_date DATE CHECK (end_date IS date(end_date)),
+    trial_end DATE CHECK (trial_end IS date(trial_end)),
+    cancelled_at TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscriptions_user_id ON subscriptions (user_id, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_service_id_id ON subscriptions (service_id, id);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_deleted_at ON subscriptions (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS subscription_prices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    effective_from DATE NOT NULL CHECK (effective_from IS date(effective_from)),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+    UNIQUE (subscription_id, effective_from)
+);
+
+CREATE TABLE IF NOT EXISTS subscription_pauses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    paused_from DATE NOT NULL CHECK (paused_from IS date(paused_from)),
+    resumed_on DATE CHECK (resumed_on IS date(resumed_on)),
+    CHECK (resumed_on IS NULL OR resumed_on >= paused_from)
+);
+
+-- у подписки не больше одной незавершенной паузы
+CREATE UNIQUE INDEX IF NOT EXISTS idx_subscription_pauses_open
+    ON subscription_pauses (subscription_id) WHERE resumed_on IS NULL;
+CREATE INDEX IF NOT EXISTS idx_subscription_pauses_subscription_id
+    ON subscription_pauses (subscription_id, paused_from);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE CHECK (name <> '')
+);
+
+CREATE TABLE IF NOT EXISTS subscription_tags (
+    subscription_id INTEGER NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (subscription_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_subscription_tags_tag_id ON subscription_tags (tag_id, subscription_id);
@@ -0,0 +1,6 @@
+-- токены ссылок на календари оплат, как calendar_tokens в Postgres после миграции 020
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id TEXT PRIMARY KEY,
+    token_hash BLOB NOT NULL CHECK (length(token_hash) = 32),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);